@@ -1,18 +1,149 @@
 CREATE TABLE cargo (
     id_cargo INT PRIMARY KEY AUTO_INCREMENT,
-    descricao TEXT NOT NULL
+    descricao TEXT NOT NULL,
+    exige_2fa BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 CREATE TABLE user (
     id_user INT PRIMARY KEY AUTO_INCREMENT,
-    nome VARCHAR(255) NOT NULL,
+    -- Users log in by name, so it is unique like the email
+    nome VARCHAR(255) NOT NULL UNIQUE,
     email VARCHAR(255) NOT NULL UNIQUE,
     password VARCHAR(255) NOT NULL,
     cargo_id INT,
     failed_attempts INT DEFAULT 0,
+    bloqueado_ate DATETIME NULL,
+    sessao_versao INT NOT NULL DEFAULT 0,
+    totp_secret VARCHAR(64) NULL,
+    totp_ativo BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Time step of the last accepted authenticator code; codes of that step or earlier are refused
+    totp_ultimo_passo BIGINT NULL,
+    -- Self-registered accounts stay pendente_email until the email is verified and pendente_aprovacao until an admin approves them
+    estado VARCHAR(20) NOT NULL DEFAULT 'ativo',
+    email_verificado_em DATETIME NULL,
+    registado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- Accounts provisioned from the directory have origem 'ldap' and no local password
+    origem VARCHAR(20) NOT NULL DEFAULT 'local',
+    -- Users who sign in through single sign-on can turn off the login form password
+    login_local BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Notification settings chosen by the user on the profile page
+    notif_concursos BOOLEAN NOT NULL DEFAULT TRUE,
+    notif_mencoes BOOLEAN NOT NULL DEFAULT TRUE,
     FOREIGN KEY (cargo_id) REFERENCES cargo(id_cargo)
 );
 
+-- Single sign-on accounts linked to a user, by issuer and subject of the identity provider
+CREATE TABLE user_identidade (
+    id_identidade INT PRIMARY KEY AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    provedor VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NULL,
+    criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_identidade (provedor, subject),
+    FOREIGN KEY (user_id) REFERENCES user(id_user) ON DELETE CASCADE
+);
+
+CREATE TABLE email_verificacao (
+    id_verificacao INT PRIMARY KEY AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expira_em DATETIME NOT NULL,
+    usado_em DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id_user) ON DELETE CASCADE
+);
+
+-- Read-only API tokens of a user, sent as a Bearer token; only the hash is stored
+CREATE TABLE api_token (
+    id_token INT PRIMARY KEY AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    nome VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    prefixo CHAR(8) NOT NULL,
+    criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ultimo_uso DATETIME NULL,
+    expira_em DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id_user) ON DELETE CASCADE
+);
+
+-- Earlier password hashes of a user, so that recent passwords are not reused
+CREATE TABLE password_historico (
+    id_historico INT PRIMARY KEY AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_password_historico_user (user_id),
+    FOREIGN KEY (user_id) REFERENCES user(id_user) ON DELETE CASCADE
+);
+
+CREATE TABLE user_recovery_code (
+    id_code INT PRIMARY KEY AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    usado_em DATETIME NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id_user) ON DELETE CASCADE
+);
+
+CREATE TABLE sessao (
+    id_sessao CHAR(64) PRIMARY KEY,
+    user_id INT NULL,
+    dados BLOB NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    ultimo_acesso DATETIME NOT NULL,
+    expira_em DATETIME NOT NULL,
+    INDEX idx_sessao_user (user_id),
+    FOREIGN KEY (user_id) REFERENCES user(id_user) ON DELETE CASCADE
+);
+
+CREATE TABLE login_tentativa (
+    id_tentativa INT PRIMARY KEY AUTO_INCREMENT,
+    user_id INT NULL,
+    login VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    sucesso BOOLEAN NOT NULL,
+    motivo VARCHAR(50) NOT NULL,
+    criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_login_tentativa_ip (ip, criado_em),
+    INDEX idx_login_tentativa_user (user_id, criado_em),
+    FOREIGN KEY (user_id) REFERENCES user(id_user) ON DELETE CASCADE
+);
+
+CREATE TABLE password_reset (
+    id_reset INT PRIMARY KEY AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expira_em DATETIME NOT NULL,
+    usado_em DATETIME NULL,
+    criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES user(id_user) ON DELETE CASCADE
+);
+
+-- Every password reset request, known email or not, to throttle them per IP and per email
+CREATE TABLE password_reset_pedido (
+    id_pedido INT PRIMARY KEY AUTO_INCREMENT,
+    email VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_password_reset_pedido_ip (ip, criado_em),
+    INDEX idx_password_reset_pedido_email (email, criado_em)
+);
+
+CREATE TABLE permissao (
+    id_permissao INT PRIMARY KEY AUTO_INCREMENT,
+    codigo VARCHAR(100) NOT NULL UNIQUE,
+    descricao VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE cargo_permissao (
+    cargo_id INT NOT NULL,
+    permissao_id INT NOT NULL,
+    PRIMARY KEY (cargo_id, permissao_id),
+    FOREIGN KEY (cargo_id) REFERENCES cargo(id_cargo) ON DELETE CASCADE,
+    FOREIGN KEY (permissao_id) REFERENCES permissao(id_permissao) ON DELETE CASCADE
+);
+
 CREATE TABLE tipo (
     id_tipo INT PRIMARY KEY AUTO_INCREMENT,
     descricao VARCHAR(255) NOT NULL
@@ -44,6 +175,19 @@ CREATE TABLE logs (
     FOREIGN KEY (id_user) REFERENCES user(id_user)
 );
 
+CREATE TABLE equipa (
+    id_equipa INT PRIMARY KEY AUTO_INCREMENT,
+    nome VARCHAR(255) NOT NULL UNIQUE
+);
+
+CREATE TABLE equipa_membro (
+    equipa_id INT NOT NULL,
+    user_id INT NOT NULL,
+    PRIMARY KEY (equipa_id, user_id),
+    FOREIGN KEY (equipa_id) REFERENCES equipa(id_equipa) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES user(id_user) ON DELETE CASCADE
+);
+
 CREATE TABLE concurso (
     id_concurso INT PRIMARY KEY AUTO_INCREMENT,
     referencia VARCHAR(255),
@@ -66,12 +210,124 @@ CREATE TABLE concurso (
     link VARCHAR(255),
     adjudicatario VARCHAR(255),
     resultado_id INT,
+    equipa_id INT NULL,
     FOREIGN KEY (resultado_id) REFERENCES resultado(id_resultado),
+    FOREIGN KEY (equipa_id) REFERENCES equipa(id_equipa) ON DELETE SET NULL,
     FOREIGN KEY (tipo_id) REFERENCES tipo(id_tipo),
     FOREIGN KEY (plataforma_id) REFERENCES plataforma(id_platforma),
     FOREIGN KEY (estado_id) REFERENCES estado(id_estado)
 );
 
+CREATE TABLE concurso_partilha (
+    concurso_id INT NOT NULL,
+    user_id INT NOT NULL,
+    PRIMARY KEY (concurso_id, user_id),
+    FOREIGN KEY (concurso_id) REFERENCES concurso(id_concurso) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES user(id_user) ON DELETE CASCADE
+);
+
+CREATE TABLE estado_transicao (
+    id_transicao INT PRIMARY KEY AUTO_INCREMENT,
+    estado_origem_id INT NOT NULL,
+    estado_destino_id INT NOT NULL,
+    campos_obrigatorios VARCHAR(255) NOT NULL DEFAULT '',
+    notificar BOOLEAN DEFAULT FALSE,
+    acao VARCHAR(255) NOT NULL,
+    UNIQUE (estado_origem_id, estado_destino_id),
+    FOREIGN KEY (estado_origem_id) REFERENCES estado(id_estado),
+    FOREIGN KEY (estado_destino_id) REFERENCES estado(id_estado)
+);
+
+CREATE TABLE estado_transicao_cargo (
+    transicao_id INT NOT NULL,
+    cargo_id INT NOT NULL,
+    PRIMARY KEY (transicao_id, cargo_id),
+    FOREIGN KEY (transicao_id) REFERENCES estado_transicao(id_transicao) ON DELETE CASCADE,
+    FOREIGN KEY (cargo_id) REFERENCES cargo(id_cargo) ON DELETE CASCADE
+);
+
+CREATE TABLE checklist_modelo (
+    id_modelo INT PRIMARY KEY AUTO_INCREMENT,
+    tipo_id INT NOT NULL,
+    descricao VARCHAR(255) NOT NULL,
+    ordem INT NOT NULL DEFAULT 0,
+    FOREIGN KEY (tipo_id) REFERENCES tipo(id_tipo)
+);
+
+CREATE TABLE checklist_item (
+    id_item INT PRIMARY KEY AUTO_INCREMENT,
+    concurso_id INT NOT NULL,
+    descricao VARCHAR(255) NOT NULL,
+    ordem INT NOT NULL DEFAULT 0,
+    concluido BOOLEAN DEFAULT FALSE,
+    concluido_por INT,
+    concluido_em TIMESTAMP NULL,
+    FOREIGN KEY (concurso_id) REFERENCES concurso(id_concurso) ON DELETE CASCADE,
+    FOREIGN KEY (concluido_por) REFERENCES user(id_user) ON DELETE SET NULL
+);
+
+CREATE TABLE anexo (
+    id_anexo INT PRIMARY KEY AUTO_INCREMENT,
+    concurso_id INT NOT NULL,
+    categoria VARCHAR(255) NOT NULL,
+    nome_ficheiro VARCHAR(255) NOT NULL,
+    versao INT NOT NULL DEFAULT 1,
+    chave VARCHAR(255) NOT NULL,
+    tamanho BIGINT NOT NULL DEFAULT 0,
+    content_type VARCHAR(255) NOT NULL DEFAULT 'application/octet-stream',
+    id_user INT,
+    criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (concurso_id) REFERENCES concurso(id_concurso) ON DELETE CASCADE,
+    FOREIGN KEY (id_user) REFERENCES user(id_user) ON DELETE SET NULL
+);
+
+CREATE TABLE comentario (
+    id_comentario INT PRIMARY KEY AUTO_INCREMENT,
+    concurso_id INT NOT NULL,
+    id_user INT,
+    texto TEXT NOT NULL,
+    criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    editado_em TIMESTAMP NULL,
+    FOREIGN KEY (concurso_id) REFERENCES concurso(id_concurso) ON DELETE CASCADE,
+    FOREIGN KEY (id_user) REFERENCES user(id_user) ON DELETE SET NULL
+);
+
+CREATE TABLE feriado (
+    id_feriado INT PRIMARY KEY AUTO_INCREMENT,
+    data DATE NOT NULL UNIQUE,
+    descricao VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE agendamento (
+    id_agendamento INT PRIMARY KEY AUTO_INCREMENT,
+    nome VARCHAR(255) NOT NULL,
+    cron VARCHAR(100) NOT NULL,
+    tipo_relatorio VARCHAR(50) NOT NULL,
+    estado_id INT NULL,
+    tipo_id INT NULL,
+    entidade VARCHAR(255) NOT NULL DEFAULT '',
+    dias INT NOT NULL DEFAULT 7,
+    destinatarios TEXT NOT NULL,
+    ativo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Reports only cover the concursos the creator can see; schedules whose creator was deleted fail to run
+    criado_por INT NULL,
+    criado_em TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (estado_id) REFERENCES estado(id_estado),
+    FOREIGN KEY (tipo_id) REFERENCES tipo(id_tipo),
+    FOREIGN KEY (criado_por) REFERENCES user(id_user) ON DELETE SET NULL
+);
+
+CREATE TABLE agendamento_execucao (
+    id_execucao INT PRIMARY KEY AUTO_INCREMENT,
+    agendamento_id INT NOT NULL,
+    inicio TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    fim TIMESTAMP NULL,
+    manual BOOLEAN NOT NULL DEFAULT FALSE,
+    sucesso BOOLEAN NULL,
+    mensagem TEXT,
+    FOREIGN KEY (agendamento_id) REFERENCES agendamento(id_agendamento) ON DELETE CASCADE
+);
+
 INSERT INTO resultado (id_resultado, descricao) VALUES (1, '');
 INSERT INTO resultado (id_resultado, descricao) VALUES (2, 'Ganho');
 INSERT INTO resultado (id_resultado, descricao) VALUES (3, 'Concorrência');
@@ -81,6 +337,22 @@ INSERT INTO cargo (id_cargo, descricao) VALUES (2, 'SAV');
 INSERT INTO cargo (id_cargo, descricao) VALUES (3, 'DCP');
 INSERT INTO cargo (id_cargo, descricao) VALUES (4, 'GUEST');
 
+-- Permissões e os cargos que as têm
+INSERT INTO permissao (id_permissao, codigo, descricao) VALUES (1, 'concurso.view', 'Ver concursos, calendário e comentar');
+INSERT INTO permissao (id_permissao, codigo, descricao) VALUES (2, 'concurso.edit', 'Criar e editar concursos e anexos');
+INSERT INTO permissao (id_permissao, codigo, descricao) VALUES (3, 'concurso.delete', 'Excluir concursos');
+INSERT INTO permissao (id_permissao, codigo, descricao) VALUES (4, 'checklist.tick', 'Marcar itens da checklist de submissão');
+INSERT INTO permissao (id_permissao, codigo, descricao) VALUES (5, 'report.export', 'Exportar PDFs, relatórios e calendário');
+INSERT INTO permissao (id_permissao, codigo, descricao) VALUES (6, 'user.manage', 'Gerir users');
+INSERT INTO permissao (id_permissao, codigo, descricao) VALUES (7, 'config.manage', 'Gerir checklists, feriados e relatórios agendados');
+INSERT INTO permissao (id_permissao, codigo, descricao) VALUES (8, 'role.manage', 'Gerir cargos e permissões');
+INSERT INTO permissao (id_permissao, codigo, descricao) VALUES (9, 'concurso.view_all', 'Ver concursos de todas as equipas');
+
+INSERT INTO cargo_permissao (cargo_id, permissao_id) VALUES (1, 1), (1, 2), (1, 3), (1, 4), (1, 5), (1, 6), (1, 7), (1, 8), (1, 9);
+INSERT INTO cargo_permissao (cargo_id, permissao_id) VALUES (2, 1), (2, 2), (2, 3), (2, 4), (2, 5), (2, 9);
+INSERT INTO cargo_permissao (cargo_id, permissao_id) VALUES (3, 1), (3, 4), (3, 5);
+INSERT INTO cargo_permissao (cargo_id, permissao_id) VALUES (4, 1), (4, 5);
+
 INSERT INTO tipo (id_tipo, descricao) VALUES (1, '');
 INSERT INTO tipo (id_tipo, descricao) VALUES (2, 'CTE');
 INSERT INTO tipo (id_tipo, descricao) VALUES (3, 'CON');
@@ -88,6 +360,46 @@ INSERT INTO tipo (id_tipo, descricao) VALUES (4, 'INF');
 INSERT INTO tipo (id_tipo, descricao) VALUES (5, 'CI');
 INSERT INTO tipo (id_tipo, descricao) VALUES (6, 'ROB');
 
+-- Modelos de checklist de submissão por tipo
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (2, 'Declaração de aceitação do caderno de encargos (Anexo I)', 1);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (2, 'DEUCP', 2);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (2, 'Proposta de preço', 3);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (2, 'Memória descritiva e justificativa', 4);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (2, 'Certidão de não dívida às Finanças', 5);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (2, 'Certidão de não dívida à Segurança Social', 6);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (2, 'Assinatura digital dos documentos', 7);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (2, 'Submissão na plataforma', 8);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (3, 'Declaração de aceitação do caderno de encargos (Anexo I)', 1);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (3, 'DEUCP', 2);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (3, 'Proposta de preço', 3);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (3, 'Plano de manutenção', 4);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (3, 'Certidão de não dívida às Finanças', 5);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (3, 'Certidão de não dívida à Segurança Social', 6);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (3, 'Assinatura digital dos documentos', 7);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (3, 'Submissão na plataforma', 8);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (4, 'Declaração de aceitação do caderno de encargos (Anexo I)', 1);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (4, 'Proposta de preço', 2);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (4, 'Especificações técnicas dos equipamentos', 3);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (4, 'Certidão de não dívida às Finanças', 4);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (4, 'Certidão de não dívida à Segurança Social', 5);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (4, 'Assinatura digital dos documentos', 6);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (4, 'Submissão na plataforma', 7);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (5, 'Declaração de aceitação do caderno de encargos (Anexo I)', 1);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (5, 'Proposta de preço', 2);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (5, 'Projeto de instalação', 3);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (5, 'Alvará / certificado de habilitações', 4);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (5, 'Certidão de não dívida às Finanças', 5);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (5, 'Certidão de não dívida à Segurança Social', 6);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (5, 'Assinatura digital dos documentos', 7);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (5, 'Submissão na plataforma', 8);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (6, 'Declaração de aceitação do caderno de encargos (Anexo I)', 1);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (6, 'Proposta de preço', 2);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (6, 'Fichas técnicas dos equipamentos', 3);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (6, 'Certidão de não dívida às Finanças', 4);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (6, 'Certidão de não dívida à Segurança Social', 5);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (6, 'Assinatura digital dos documentos', 6);
+INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (6, 'Submissão na plataforma', 7);
+
 INSERT INTO plataforma (id_platforma, descricao) VALUES (1, '');
 INSERT INTO plataforma (id_platforma, descricao) VALUES (2, 'email');
 INSERT INTO plataforma (id_platforma, descricao) VALUES (3, 'vortal');
@@ -95,14 +407,38 @@ INSERT INTO plataforma (id_platforma, descricao) VALUES (4, 'acingov');
 INSERT INTO plataforma (id_platforma, descricao) VALUES (5, 'anogov');
 INSERT INTO plataforma (id_platforma, descricao) VALUES (6, 'saphety');
 
+-- Initial admin with the bcrypt hash of the password 'beso'; change it after the first login
 INSERT INTO user (nome, email, password, cargo_id) 
-VALUES ('beso', 'notbeso2000@gmail.com', 'beso', 1);
+VALUES ('beso', 'notbeso2000@gmail.com', '$2a$10$pNdJSN8WQnPlBzmFUMcjDO79gZqDyKHxmXoK0zZQ9Aeqe//DJoW2O', 1);
 
 INSERT INTO estado (id_estado, descricao) VALUES (1, '');
 INSERT INTO estado (id_estado, descricao) VALUES (2, 'Em Andamento');
 INSERT INTO estado (id_estado, descricao) VALUES (3, 'Enviado');
 INSERT INTO estado (id_estado, descricao) VALUES (4, 'Não Enviado');
 INSERT INTO estado (id_estado, descricao) VALUES (5, 'Declaração');
+INSERT INTO estado (id_estado, descricao) VALUES (6, 'Concluído');
+
+-- Transições de estado permitidas (campos_obrigatorios separados por vírgula)
+INSERT INTO estado_transicao (id_transicao, estado_origem_id, estado_destino_id, campos_obrigatorios, notificar, acao) VALUES (1, 1, 2, '', FALSE, 'iniciar');
+INSERT INTO estado_transicao (id_transicao, estado_origem_id, estado_destino_id, campos_obrigatorios, notificar, acao) VALUES (2, 2, 3, 'dia_proposta,hora_proposta', TRUE, 'enviar');
+INSERT INTO estado_transicao (id_transicao, estado_origem_id, estado_destino_id, campos_obrigatorios, notificar, acao) VALUES (3, 2, 4, '', TRUE, 'nao_enviar');
+INSERT INTO estado_transicao (id_transicao, estado_origem_id, estado_destino_id, campos_obrigatorios, notificar, acao) VALUES (4, 2, 5, '', TRUE, 'declaracao');
+INSERT INTO estado_transicao (id_transicao, estado_origem_id, estado_destino_id, campos_obrigatorios, notificar, acao) VALUES (5, 3, 6, 'resultado_id,adjudicatario', TRUE, 'concluir');
+INSERT INTO estado_transicao (id_transicao, estado_origem_id, estado_destino_id, campos_obrigatorios, notificar, acao) VALUES (6, 5, 6, 'resultado_id', TRUE, 'concluir');
+INSERT INTO estado_transicao (id_transicao, estado_origem_id, estado_destino_id, campos_obrigatorios, notificar, acao) VALUES (7, 3, 2, '', TRUE, 'reabrir');
+INSERT INTO estado_transicao (id_transicao, estado_origem_id, estado_destino_id, campos_obrigatorios, notificar, acao) VALUES (8, 4, 2, '', TRUE, 'reabrir');
+INSERT INTO estado_transicao (id_transicao, estado_origem_id, estado_destino_id, campos_obrigatorios, notificar, acao) VALUES (9, 6, 3, '', TRUE, 'reabrir');
+
+-- Cargos que podem executar cada transição (reabrir apenas admin)
+INSERT INTO estado_transicao_cargo (transicao_id, cargo_id) VALUES (1, 1), (1, 2);
+INSERT INTO estado_transicao_cargo (transicao_id, cargo_id) VALUES (2, 1), (2, 2);
+INSERT INTO estado_transicao_cargo (transicao_id, cargo_id) VALUES (3, 1), (3, 2);
+INSERT INTO estado_transicao_cargo (transicao_id, cargo_id) VALUES (4, 1), (4, 2);
+INSERT INTO estado_transicao_cargo (transicao_id, cargo_id) VALUES (5, 1), (5, 2);
+INSERT INTO estado_transicao_cargo (transicao_id, cargo_id) VALUES (6, 1), (6, 2);
+INSERT INTO estado_transicao_cargo (transicao_id, cargo_id) VALUES (7, 1);
+INSERT INTO estado_transicao_cargo (transicao_id, cargo_id) VALUES (8, 1);
+INSERT INTO estado_transicao_cargo (transicao_id, cargo_id) VALUES (9, 1);
 
 INSERT INTO concurso (
     referencia, entidade, dia_erro, hora_erro, dia_proposta, hora_proposta, preco, 
@@ -131,3 +467,10 @@ VALUES (
     'notbeso2000@gmail.com',            -- adjudicatario
     2                                   -- resultado_id (Ganho)
 );
+
+-- Checklist do concurso de exemplo
+INSERT INTO checklist_item (concurso_id, descricao, ordem)
+SELECT 1, descricao, ordem FROM checklist_modelo WHERE tipo_id = 2;
+
+-- Feriados municipais (os nacionais são calculados pela aplicação)
+INSERT INTO feriado (data, descricao) VALUES ('2025-06-13', 'Santo António (Lisboa)');
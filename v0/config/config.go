@@ -3,14 +3,53 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
+
+	// Embedded zoneinfo so the timezone loads in containers without tzdata
+	_ "time/tzdata"
+)
+
+// Environments of the application; production refuses to start with default or weak secrets
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
 )
 
+// minSessionSecret is the shortest session secret accepted in production
+const minSessionSecret = 32
+
+// weakSecrets are defaults and placeholders that are never accepted as secrets in production
+var weakSecrets = map[string]bool{
+	"beso":        true,
+	"secret":      true,
+	"secret-key":  true,
+	"password":    true,
+	"changeme":    true,
+	"change-me":   true,
+	"admin":       true,
+	"development": true,
+}
+
 // Config holds all configuration for the application
 type Config struct {
-	Database DatabaseConfig
-	Server   ServerConfig
-	Email    EmailConfig
-	Session  SessionConfig
+	// Env is EnvDevelopment or EnvProduction
+	Env       string
+	Database  DatabaseConfig
+	Server    ServerConfig
+	Email     EmailConfig
+	Session   SessionConfig
+	Checklist ChecklistConfig
+	Storage   StorageConfig
+	Report    ReportConfig
+	Auth      AuthConfig
+	Password  PasswordConfig
+	LDAP      LDAPConfig
+	OIDC      OIDCConfig
+	// Avisos are the configuration problems tolerated outside production
+	Avisos []string
+
+	settings []Setting
 }
 
 // DatabaseConfig holds database configuration
@@ -25,6 +64,11 @@ type DatabaseConfig struct {
 // ServerConfig holds server configuration
 type ServerConfig struct {
 	Port string
+	// BaseURL is the public address of the application, used in links sent by email
+	BaseURL string
+	// Timezone is the IANA name of the zone deadline dates and times are entered in
+	Timezone string
+	Location *time.Location
 }
 
 // EmailConfig holds email configuration
@@ -38,27 +82,272 @@ type EmailConfig struct {
 // SessionConfig holds session configuration
 type SessionConfig struct {
 	Secret string
+	// IdleMinutes is how long a session lasts without requests
+	IdleMinutes int
+}
+
+// ChecklistConfig holds submission checklist configuration
+type ChecklistConfig struct {
+	// AvisoDias is how many days before the proposta deadline an incomplete checklist is flagged
+	AvisoDias int
+}
+
+// StorageConfig holds attachment storage configuration
+type StorageConfig struct {
+	Path        string
+	MaxUploadMB int
+}
+
+// ReportConfig holds PDF report configuration
+type ReportConfig struct {
+	// FontPath and FontBoldPath are UTF-8 TrueType fonts; without them reports fall back to Arial in cp1252,
+	// so production refuses to start when they are missing
+	FontPath     string
+	FontBoldPath string
+	// LogoPath is a PNG or JPEG drawn in the header of every report page, when present
+	LogoPath string
+	Empresa  string
+}
+
+// AuthConfig holds login and account recovery configuration
+type AuthConfig struct {
+	// ResetTokenMinutes is how long a password reset link stays valid
+	ResetTokenMinutes int
+	// ResetIPLimit requests from one IP, or ResetEmailLimit for one email, within ResetWindowMinutes are not sent
+	ResetIPLimit       int
+	ResetEmailLimit    int
+	ResetWindowMinutes int
+	// LockoutThreshold failed attempts in a row lock an account for LockoutMinutes
+	LockoutThreshold int
+	LockoutMinutes   int
+	// LoginMaxDelaySeconds caps the delay added to each failed login, which doubles with every failure
+	LoginMaxDelaySeconds int
+	// LoginIPLimit failed logins from one IP within LoginIPWindowMinutes block further attempts from it
+	LoginIPLimit         int
+	LoginIPWindowMinutes int
+	// VerifyTokenHours is how long the email verification link of a registration stays valid
+	VerifyTokenHours int
+	// RegistoDominios are the email domains whose registrations are approved without an admin, with cargo
+	// RegistoCargoID, which pending registrations hold until they are approved
+	RegistoDominios []string
+	RegistoCargoID  int
+	// Providers are the sources the login form checks credentials against, in order: "db" and "ldap"
+	Providers []string
+}
+
+// PasswordConfig holds the password policy and how passwords are hashed
+type PasswordConfig struct {
+	MinLength int
+	// Historico is how many of the latest passwords of a user cannot be reused, 0 to allow any
+	Historico int
+	// ListaComprometidas is a file of breached or common passwords, one per line, refused as new passwords
+	ListaComprometidas string
+	// Hash is bcrypt or argon2id; the cost settings apply to new hashes, and older hashes are upgraded on login
+	Hash            string
+	BcryptCost      int
+	Argon2MemoriaKB int
+	Argon2Iteracoes int
+	Argon2Threads   int
+}
+
+// LDAPConfig holds LDAP / Active Directory login configuration
+type LDAPConfig struct {
+	// URL is the directory address, ldap://host:389 or ldaps://host:636
+	URL           string
+	StartTLS      bool
+	SkipTLSVerify bool
+	Timeout       time.Duration
+	// BindDN and BindPassword are the account users are searched with; empty searches anonymously
+	BindDN       string
+	BindPassword string
+	// Users are searched under BaseDN with UserFilter and LoginAttr equal to the login
+	BaseDN     string
+	UserFilter string
+	LoginAttr  string
+	EmailAttr  string
+	// Groups are read from GroupAttr of the user or, with GroupBaseDN, searched with GroupFilter where %s is the user DN
+	GroupAttr   string
+	GroupBaseDN string
+	GroupFilter string
+	// GroupCargos map group DNs to cargos, the first group of the user wins; users in none get DefaultCargo, or no access when 0
+	GroupCargos  []CargoMap
+	DefaultCargo int
+}
+
+// OIDCConfig holds OpenID Connect single sign-on configuration
+type OIDCConfig struct {
+	// IssuerURL is the identity provider; single sign-on is off without it
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL defaults to BaseURL + /login/oidc/callback
+	RedirectURL string
+	Scopes      []string
+	// Label is the text of the login button
+	Label string
+	// NomeClaim is the claim new accounts take their user name from
+	NomeClaim string
+	// RoleClaim is the claim, or dotted path to it, holding the user's roles; RoleCargos map them to cargos like LDAP
+	// groups, and new users with none get DefaultCargo, or no access when 0
+	RoleClaim    string
+	RoleCargos   []CargoMap
+	DefaultCargo int
+}
+
+// CargoMap maps a directory group or identity provider role to a cargo
+type CargoMap struct {
+	Valor   string
+	CargoID int
 }
 
-// Load loads configuration from environment variables or defaults
-func Load() (*Config, error) {
-	// Set defaults and override with environment variables if available
-	dbHost := getEnv("DB_HOST", "127.0.0.1")
-	dbPort := getEnv("DB_PORT", "3306")
-	dbUser := getEnv("DB_USER", "beso")
-	dbPassword := getEnv("DB_PASSWORD", "beso")
-	dbName := getEnv("DB_NAME", "concurso")
+// Load loads configuration from environment variables, the YAML or TOML configuration file at path when it is
+// not empty, or defaults, in that order
+func Load(path string) (*Config, error) {
+	src, err := newSource(path)
+	if err != nil {
+		return nil, err
+	}
+
+	appEnv := strings.ToLower(src.getEnv("APP_ENV", EnvDevelopment))
+	if appEnv != EnvDevelopment && appEnv != EnvProduction {
+		return nil, fmt.Errorf("invalid APP_ENV %q: must be %s or %s", appEnv, EnvDevelopment, EnvProduction)
+	}
+
+	// Set defaults and override with the configuration file and environment variables if available
+	dbHost := src.getEnv("DB_HOST", "127.0.0.1")
+	dbPort := src.getEnv("DB_PORT", "3306")
+	dbUser := src.getEnv("DB_USER", "beso")
+	dbPassword := src.getEnv("DB_PASSWORD", "beso")
+	dbName := src.getEnv("DB_NAME", "concurso")
+
+	serverPort := src.getEnv("SERVER_PORT", "8080")
+	serverBaseURL := strings.TrimRight(src.getEnv("BASE_URL", "http://localhost:"+serverPort), "/")
+	serverTimezone := src.getEnv("TIMEZONE", "Europe/Lisbon")
+
+	location, err := time.LoadLocation(serverTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TIMEZONE %q: %w", serverTimezone, err)
+	}
+
+	emailFrom := src.getEnv("EMAIL_FROM", "")
+	emailPassword := src.getEnv("EMAIL_PASSWORD", "")
+	emailSMTPHost := src.getEnv("EMAIL_SMTP_HOST", "smtp.gmail.com")
+	emailSMTPPort := src.getEnv("EMAIL_SMTP_PORT", "587")
+
+	sessionSecret := src.getEnv("SESSION_SECRET", "secret-key")
+	sessionIdleMinutes := src.getEnvInt("SESSION_IDLE_MINUTES", 120)
+
+	checklistAvisoDias := src.getEnvInt("CHECKLIST_AVISO_DIAS", 3)
+
+	storagePath := src.getEnv("STORAGE_PATH", "./uploads")
+	storageMaxUploadMB := src.getEnvInt("STORAGE_MAX_UPLOAD_MB", 25)
+
+	reportFontPath := src.getEnv("REPORT_FONT", "./static/fonts/DejaVuSansCondensed.ttf")
+	reportFontBoldPath := src.getEnv("REPORT_FONT_BOLD", "./static/fonts/DejaVuSansCondensed-Bold.ttf")
+	reportLogoPath := src.getEnv("REPORT_LOGO", "./static/logo.png")
+	reportEmpresa := src.getEnv("REPORT_EMPRESA", "")
 
-	serverPort := getEnv("SERVER_PORT", "8080")
+	authResetTokenMinutes := src.getEnvInt("RESET_TOKEN_MINUTES", 60)
+	authResetIPLimit := src.getEnvInt("RESET_IP_LIMIT", 10)
+	authResetEmailLimit := src.getEnvInt("RESET_EMAIL_LIMIT", 3)
+	authResetWindowMinutes := src.getEnvInt("RESET_WINDOW_MINUTES", 60)
+	authLockoutThreshold := src.getEnvInt("LOCKOUT_THRESHOLD", 5)
+	authLockoutMinutes := src.getEnvInt("LOCKOUT_MINUTES", 15)
+	authLoginMaxDelaySeconds := src.getEnvInt("LOGIN_MAX_DELAY_SECONDS", 8)
+	authLoginIPLimit := src.getEnvInt("LOGIN_IP_LIMIT", 20)
+	authLoginIPWindowMinutes := src.getEnvInt("LOGIN_IP_WINDOW_MINUTES", 15)
+	authVerifyTokenHours := src.getEnvInt("EMAIL_VERIFY_HOURS", 48)
+	authRegistoCargoID := src.getEnvInt("REGISTRATION_DEFAULT_CARGO", 4)
 
-	emailFrom := getEnv("EMAIL_FROM", "notbeso2000@gmail.com")
-	emailPassword := getEnv("EMAIL_PASSWORD", "wmfhdtxnsegwhnzj")
-	emailSMTPHost := getEnv("EMAIL_SMTP_HOST", "smtp.gmail.com")
-	emailSMTPPort := getEnv("EMAIL_SMTP_PORT", "587")
+	var authRegistoDominios []string
+	for _, dominio := range splitList(src.getEnv("REGISTRATION_DOMAINS", ""), ',') {
+		authRegistoDominios = append(authRegistoDominios, strings.TrimPrefix(strings.ToLower(dominio), "@"))
+	}
 
-	sessionSecret := getEnv("SESSION_SECRET", "secret-key")
+	passwordMinLength := src.getEnvInt("PASSWORD_MIN_LENGTH", 8)
+	passwordHistorico := src.getEnvInt("PASSWORD_HISTORY", 5)
+	passwordListaComprometidas := src.getEnv("PASSWORD_BREACHED_LIST", "./data/passwords-comuns.txt")
+	passwordHash := strings.ToLower(src.getEnv("PASSWORD_HASH", "bcrypt"))
+	passwordBcryptCost := src.getEnvInt("PASSWORD_BCRYPT_COST", 10)
+	passwordArgon2MemoriaKB := src.getEnvInt("PASSWORD_ARGON2_MEMORY_KB", 64*1024)
+	passwordArgon2Iteracoes := src.getEnvInt("PASSWORD_ARGON2_TIME", 3)
+	passwordArgon2Threads := src.getEnvInt("PASSWORD_ARGON2_THREADS", 2)
 
-	return &Config{
+	if passwordMinLength < 1 || passwordHistorico < 0 {
+		return nil, fmt.Errorf("PASSWORD_MIN_LENGTH must be positive and PASSWORD_HISTORY not negative")
+	}
+	switch passwordHash {
+	case "bcrypt":
+		// bcrypt accepts costs from 4 to 31
+		if passwordBcryptCost < 4 || passwordBcryptCost > 31 {
+			return nil, fmt.Errorf("invalid PASSWORD_BCRYPT_COST %d: must be between 4 and 31", passwordBcryptCost)
+		}
+	case "argon2id":
+		if passwordArgon2MemoriaKB < 8*passwordArgon2Threads || passwordArgon2Iteracoes < 1 ||
+			passwordArgon2Threads < 1 || passwordArgon2Threads > 255 {
+			return nil, fmt.Errorf("invalid PASSWORD_ARGON2 settings")
+		}
+	default:
+		return nil, fmt.Errorf("invalid PASSWORD_HASH %q: must be bcrypt or argon2id", passwordHash)
+	}
+
+	var authProviders []string
+	for _, provider := range splitList(src.getEnv("AUTH_PROVIDERS", "db"), ',') {
+		switch provider = strings.ToLower(provider); provider {
+		case "db", "ldap":
+			authProviders = append(authProviders, provider)
+		default:
+			return nil, fmt.Errorf("invalid AUTH_PROVIDERS entry %q", provider)
+		}
+	}
+
+	ldapURL := src.getEnv("LDAP_URL", "")
+	ldapStartTLS := src.getEnvBool("LDAP_START_TLS", false)
+	ldapSkipTLSVerify := src.getEnvBool("LDAP_TLS_SKIP_VERIFY", false)
+	ldapTimeout := time.Duration(src.getEnvInt("LDAP_TIMEOUT_SECONDS", 10)) * time.Second
+	ldapBindDN := src.getEnv("LDAP_BIND_DN", "")
+	ldapBindPassword := src.getEnv("LDAP_BIND_PASSWORD", "")
+	ldapBaseDN := src.getEnv("LDAP_BASE_DN", "")
+	ldapUserFilter := src.getEnv("LDAP_USER_FILTER", "(objectClass=person)")
+	ldapLoginAttr := src.getEnv("LDAP_LOGIN_ATTR", "uid")
+	ldapEmailAttr := src.getEnv("LDAP_EMAIL_ATTR", "mail")
+	ldapGroupAttr := src.getEnv("LDAP_GROUP_ATTR", "memberOf")
+	ldapGroupBaseDN := src.getEnv("LDAP_GROUP_BASE_DN", "")
+	ldapGroupFilter := src.getEnv("LDAP_GROUP_FILTER", "(member=%s)")
+	ldapDefaultCargo := src.getEnvInt("LDAP_DEFAULT_CARGO", 0)
+
+	ldapGroupCargos, err := src.getEnvCargoMap("LDAP_GROUP_CARGOS")
+	if err != nil {
+		return nil, err
+	}
+
+	oidcIssuerURL := strings.TrimRight(src.getEnv("OIDC_ISSUER_URL", ""), "/")
+	oidcClientID := src.getEnv("OIDC_CLIENT_ID", "")
+	oidcClientSecret := src.getEnv("OIDC_CLIENT_SECRET", "")
+	oidcRedirectURL := src.getEnv("OIDC_REDIRECT_URL", serverBaseURL+"/login/oidc/callback")
+	oidcScopes := strings.Fields(src.getEnv("OIDC_SCOPES", "openid profile email"))
+	oidcLabel := src.getEnv("OIDC_LABEL", "Entrar com a conta da empresa")
+	oidcNomeClaim := src.getEnv("OIDC_NOME_CLAIM", "preferred_username")
+	oidcRoleClaim := src.getEnv("OIDC_ROLE_CLAIM", "groups")
+	oidcDefaultCargo := src.getEnvInt("OIDC_DEFAULT_CARGO", 0)
+
+	oidcRoleCargos, err := src.getEnvCargoMap("OIDC_ROLE_CARGOS")
+	if err != nil {
+		return nil, err
+	}
+
+	if oidcIssuerURL != "" && oidcClientID == "" {
+		return nil, fmt.Errorf("OIDC_ISSUER_URL is set but OIDC_CLIENT_ID is not")
+	}
+
+	for _, provider := range authProviders {
+		if provider == "ldap" && (ldapURL == "" || ldapBaseDN == "") {
+			return nil, fmt.Errorf("AUTH_PROVIDERS includes ldap but LDAP_URL or LDAP_BASE_DN is not set")
+		}
+	}
+
+	cfg := &Config{
+		Env: appEnv,
 		Database: DatabaseConfig{
 			Host:     dbHost,
 			Port:     dbPort,
@@ -67,7 +356,10 @@ func Load() (*Config, error) {
 			DBName:   dbName,
 		},
 		Server: ServerConfig{
-			Port: serverPort,
+			Port:     serverPort,
+			BaseURL:  serverBaseURL,
+			Timezone: serverTimezone,
+			Location: location,
 		},
 		Email: EmailConfig{
 			From:     emailFrom,
@@ -76,18 +368,151 @@ func Load() (*Config, error) {
 			SMTPPort: emailSMTPPort,
 		},
 		Session: SessionConfig{
-			Secret: sessionSecret,
+			Secret:      sessionSecret,
+			IdleMinutes: sessionIdleMinutes,
+		},
+		Checklist: ChecklistConfig{
+			AvisoDias: checklistAvisoDias,
+		},
+		Storage: StorageConfig{
+			Path:        storagePath,
+			MaxUploadMB: storageMaxUploadMB,
+		},
+		Report: ReportConfig{
+			FontPath:     reportFontPath,
+			FontBoldPath: reportFontBoldPath,
+			LogoPath:     reportLogoPath,
+			Empresa:      reportEmpresa,
+		},
+		Auth: AuthConfig{
+			ResetTokenMinutes:    authResetTokenMinutes,
+			ResetIPLimit:         authResetIPLimit,
+			ResetEmailLimit:      authResetEmailLimit,
+			ResetWindowMinutes:   authResetWindowMinutes,
+			LockoutThreshold:     authLockoutThreshold,
+			LockoutMinutes:       authLockoutMinutes,
+			LoginMaxDelaySeconds: authLoginMaxDelaySeconds,
+			LoginIPLimit:         authLoginIPLimit,
+			LoginIPWindowMinutes: authLoginIPWindowMinutes,
+			VerifyTokenHours:     authVerifyTokenHours,
+			RegistoDominios:      authRegistoDominios,
+			RegistoCargoID:       authRegistoCargoID,
+			Providers:            authProviders,
 		},
-	}, nil
+		Password: PasswordConfig{
+			MinLength:          passwordMinLength,
+			Historico:          passwordHistorico,
+			ListaComprometidas: passwordListaComprometidas,
+			Hash:               passwordHash,
+			BcryptCost:         passwordBcryptCost,
+			Argon2MemoriaKB:    passwordArgon2MemoriaKB,
+			Argon2Iteracoes:    passwordArgon2Iteracoes,
+			Argon2Threads:      passwordArgon2Threads,
+		},
+		LDAP: LDAPConfig{
+			URL:           ldapURL,
+			StartTLS:      ldapStartTLS,
+			SkipTLSVerify: ldapSkipTLSVerify,
+			Timeout:       ldapTimeout,
+			BindDN:        ldapBindDN,
+			BindPassword:  ldapBindPassword,
+			BaseDN:        ldapBaseDN,
+			UserFilter:    ldapUserFilter,
+			LoginAttr:     ldapLoginAttr,
+			EmailAttr:     ldapEmailAttr,
+			GroupAttr:     ldapGroupAttr,
+			GroupBaseDN:   ldapGroupBaseDN,
+			GroupFilter:   ldapGroupFilter,
+			GroupCargos:   ldapGroupCargos,
+			DefaultCargo:  ldapDefaultCargo,
+		},
+		OIDC: OIDCConfig{
+			IssuerURL:    oidcIssuerURL,
+			ClientID:     oidcClientID,
+			ClientSecret: oidcClientSecret,
+			RedirectURL:  oidcRedirectURL,
+			Scopes:       oidcScopes,
+			Label:        oidcLabel,
+			NomeClaim:    oidcNomeClaim,
+			RoleClaim:    oidcRoleClaim,
+			RoleCargos:   oidcRoleCargos,
+			DefaultCargo: oidcDefaultCargo,
+		},
+	}
+
+	// Settings of the file that nothing reads are refused, so that a typo does not silently leave a default
+	for _, chave := range src.unused() {
+		src.erros = append(src.erros, fmt.Sprintf("unknown setting %s in the config file", chave))
+	}
+	if len(src.erros) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %s", strings.Join(src.erros, "; "))
+	}
+
+	cfg.settings = src.settings
+	cfg.Avisos = cfg.secretProblems()
+	if cfg.Production() && len(cfg.Avisos) > 0 {
+		return nil, fmt.Errorf("refusing to start in production: %s", strings.Join(cfg.Avisos, "; "))
+	}
+
+	return cfg, nil
+}
+
+// Production reports whether the application runs in production
+func (c *Config) Production() bool {
+	return c.Env == EnvProduction
+}
+
+// Settings returns the effective value and origin of every setting, with secrets redacted
+func (c *Config) Settings() []Setting {
+	settings := make([]Setting, len(c.settings))
+	for i, s := range c.settings {
+		if isSecret(s.Chave) && s.Valor != "" {
+			s.Valor = "********"
+		}
+		settings[i] = s
+	}
+	return settings
 }
 
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// secretProblems lists default or weak secrets and other settings unsafe for production
+func (c *Config) secretProblems() []string {
+	var problemas []string
+	if len(c.Session.Secret) < minSessionSecret || weakSecrets[strings.ToLower(c.Session.Secret)] {
+		problemas = append(problemas, fmt.Sprintf("SESSION_SECRET must be a random value of at least %d characters", minSessionSecret))
 	}
-	return value
+	if c.Database.Password == "" || weakSecrets[strings.ToLower(c.Database.Password)] {
+		problemas = append(problemas, "DB_PASSWORD is empty or a default value")
+	}
+	if c.Email.From == "" || c.Email.Password == "" {
+		problemas = append(problemas, "EMAIL_FROM and EMAIL_PASSWORD are not set, emails cannot be sent")
+	}
+	if c.LDAP.BindDN != "" && (c.LDAP.BindPassword == "" || weakSecrets[strings.ToLower(c.LDAP.BindPassword)]) {
+		problemas = append(problemas, "LDAP_BIND_PASSWORD is empty or a default value")
+	}
+	if c.OIDC.IssuerURL != "" && weakSecrets[strings.ToLower(c.OIDC.ClientSecret)] {
+		problemas = append(problemas, "OIDC_CLIENT_SECRET is a default value")
+	}
+	if !c.Server.HTTPS() {
+		problemas = append(problemas, "BASE_URL is not https, session cookies are sent in the clear")
+	}
+	fontes := []struct{ chave, path string }{
+		{"REPORT_FONT", c.Report.FontPath},
+		{"REPORT_FONT_BOLD", c.Report.FontBoldPath},
+	}
+	for _, fonte := range fontes {
+		if fonte.path == "" {
+			continue
+		}
+		if info, err := os.Stat(fonte.path); err != nil || info.IsDir() {
+			problemas = append(problemas, fmt.Sprintf("%s %s is not a font file, reports would print accented characters wrongly", fonte.chave, fonte.path))
+		}
+	}
+	return problemas
+}
+
+// HTTPS reports whether the application is served over TLS, according to its public address
+func (c *ServerConfig) HTTPS() bool {
+	return strings.HasPrefix(c.BaseURL, "https://")
 }
 
 // GetDSN returns the database connection string
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cleanEnv unsets every setting Load reads, so that the environment of the machine running the tests does not
+// leak into them; an empty variable counts as unset
+func cleanEnv(t *testing.T) {
+	t.Helper()
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load with the test environment: %v", err)
+	}
+	for _, s := range cfg.settings {
+		t.Setenv(s.Chave, "")
+		t.Setenv(s.Chave+"_FILE", "")
+	}
+}
+
+// setting returns a setting of a configuration by key
+func setting(t *testing.T, cfg *Config, chave string) Setting {
+	t.Helper()
+	for _, s := range cfg.Settings() {
+		if s.Chave == chave {
+			return s
+		}
+	}
+	t.Fatalf("no setting %s", chave)
+	return Setting{}
+}
+
+func TestLoadLayersEnvFileAndDefaults(t *testing.T) {
+	cleanEnv(t)
+
+	for _, nome := range []string{"config.yaml", "config.toml"} {
+		conteudo := "db:\n  host: db.ficheiro\n  port: 3307\nsession_idle_minutes: 45\nregistration_domains:\n  - Empresa.pt\n  - '@filiais.pt'\n"
+		if strings.HasSuffix(nome, ".toml") {
+			conteudo = "session_idle_minutes = 45\nregistration_domains = [\"Empresa.pt\", \"@filiais.pt\"]\n\n[db]\nhost = \"db.ficheiro\"\nport = 3307\n"
+		}
+		path := writeFile(t, nome, conteudo)
+
+		t.Setenv("DB_HOST", "db.env")
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("%s: Load: %v", nome, err)
+		}
+
+		// The environment wins over the file, which wins over the defaults
+		if cfg.Database.Host != "db.env" || cfg.Database.Port != "3307" || cfg.Database.DBName != "concurso" {
+			t.Errorf("%s: database = %+v, want the env host, the file port and the default name", nome, cfg.Database)
+		}
+		if cfg.Session.IdleMinutes != 45 {
+			t.Errorf("%s: SESSION_IDLE_MINUTES = %d, want the file value 45", nome, cfg.Session.IdleMinutes)
+		}
+		if want := []string{"empresa.pt", "filiais.pt"}; !reflect.DeepEqual(cfg.Auth.RegistoDominios, want) {
+			t.Errorf("%s: REGISTRATION_DOMAINS = %q, want %q", nome, cfg.Auth.RegistoDominios, want)
+		}
+
+		origens := map[string]string{"DB_HOST": OrigemEnv, "DB_PORT": OrigemFicheiro, "DB_NAME": OrigemDefault}
+		for chave, origem := range origens {
+			if s := setting(t, cfg, chave); s.Origem != origem {
+				t.Errorf("%s: %s origin = %q, want %q", nome, chave, s.Origem, origem)
+			}
+		}
+
+		// An empty variable leaves the file value
+		t.Setenv("DB_HOST", "")
+		cfg, err = Load(path)
+		if err != nil {
+			t.Fatalf("%s: Load: %v", nome, err)
+		}
+		if cfg.Database.Host != "db.ficheiro" {
+			t.Errorf("%s: empty DB_HOST = %q, want the file value", nome, cfg.Database.Host)
+		}
+	}
+}
+
+func TestLoadReadsSecretFiles(t *testing.T) {
+	cleanEnv(t)
+
+	t.Setenv("DB_PASSWORD_FILE", writeFile(t, "db_password", "segredo-da-bd\n"))
+	path := writeFile(t, "config.yaml", "session_secret_file: "+writeFile(t, "session_secret", "segredo-da-sessao\n")+"\n")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Database.Password != "segredo-da-bd" {
+		t.Errorf("DB_PASSWORD = %q, want the env secret file without its line break", cfg.Database.Password)
+	}
+	if cfg.Session.Secret != "segredo-da-sessao" {
+		t.Errorf("SESSION_SECRET = %q, want the file secret file without its line break", cfg.Session.Secret)
+	}
+	if s := setting(t, cfg, "DB_PASSWORD"); s.Origem != OrigemEnv+" (DB_PASSWORD_FILE)" {
+		t.Errorf("DB_PASSWORD origin = %q", s.Origem)
+	}
+
+	t.Setenv("DB_PASSWORD_FILE", writeFile(t, "x", "")+".nenhum")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "DB_PASSWORD_FILE") {
+		t.Errorf("missing secret file: err = %v, want a DB_PASSWORD_FILE error", err)
+	}
+}
+
+func TestLoadRejectsUnknownAndInvalidSettings(t *testing.T) {
+	cleanEnv(t)
+
+	tests := []struct {
+		nome     string
+		conteudo string
+		erro     string
+	}{
+		{"typo", "db:\n  hots: x\n", "unknown setting DB_HOTS"},
+		{"unknown section", "smtp:\n  host: x\n", "unknown setting SMTP_HOST"},
+		{"not a number", "session_idle_minutes: muitos\n", "invalid SESSION_IDLE_MINUTES"},
+		{"environment", "app_env: staging\n", "invalid APP_ENV"},
+		{"timezone", "timezone: Lua/Base\n", "invalid TIMEZONE"},
+	}
+
+	for _, tt := range tests {
+		_, err := Load(writeFile(t, "config.yaml", tt.conteudo))
+		if err == nil || !strings.Contains(err.Error(), tt.erro) {
+			t.Errorf("%s: err = %v, want %q", tt.nome, err, tt.erro)
+		}
+	}
+}
+
+// setProduction sets a configuration production accepts
+func setProduction(t *testing.T) {
+	t.Helper()
+	fonte := writeFile(t, "fonte.ttf", "ttf")
+	for chave, valor := range map[string]string{
+		"APP_ENV":          EnvProduction,
+		"SESSION_SECRET":   strings.Repeat("a1b2", 10),
+		"DB_PASSWORD":      "uma-password-forte",
+		"EMAIL_FROM":       "concursos@empresa.pt",
+		"EMAIL_PASSWORD":   "password-do-email",
+		"BASE_URL":         "https://concursos.empresa.pt",
+		"REPORT_FONT":      fonte,
+		"REPORT_FONT_BOLD": fonte,
+	} {
+		t.Setenv(chave, valor)
+	}
+}
+
+func TestLoadRefusesUnsafeProductionSettings(t *testing.T) {
+	cleanEnv(t)
+	setProduction(t)
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("safe production settings: Load: %v", err)
+	}
+	if !cfg.Production() || len(cfg.Avisos) != 0 {
+		t.Errorf("production = %v with warnings %v, want none", cfg.Production(), cfg.Avisos)
+	}
+
+	tests := []struct {
+		chave, valor, aviso string
+	}{
+		{"SESSION_SECRET", "secret-key", "SESSION_SECRET"},
+		{"SESSION_SECRET", "curto", "SESSION_SECRET"},
+		{"DB_PASSWORD", "changeme", "DB_PASSWORD"},
+		{"EMAIL_PASSWORD", "", "EMAIL_PASSWORD"},
+		{"BASE_URL", "http://concursos.empresa.pt", "BASE_URL"},
+		{"REPORT_FONT", "/nenhuma/fonte.ttf", "REPORT_FONT"},
+		{"LDAP_BIND_DN", "cn=app,dc=empresa,dc=pt", "LDAP_BIND_PASSWORD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.chave+"="+tt.valor, func(t *testing.T) {
+			t.Setenv(tt.chave, tt.valor)
+
+			_, err := Load("")
+			if err == nil || !strings.Contains(err.Error(), "refusing to start in production") || !strings.Contains(err.Error(), tt.aviso) {
+				t.Errorf("err = %v, want a refusal naming %s", err, tt.aviso)
+			}
+
+			// Outside production the same problems are only warnings
+			t.Setenv("APP_ENV", EnvDevelopment)
+			cfg, err := Load("")
+			if err != nil {
+				t.Fatalf("development: Load: %v", err)
+			}
+			if !strings.Contains(strings.Join(cfg.Avisos, "; "), tt.aviso) {
+				t.Errorf("development warnings = %v, want one naming %s", cfg.Avisos, tt.aviso)
+			}
+		})
+	}
+}
+
+func TestSettingsRedactsSecrets(t *testing.T) {
+	cleanEnv(t)
+	t.Setenv("DB_PASSWORD", "uma-password-forte")
+	t.Setenv("SESSION_SECRET", "segredo-da-sessao")
+	t.Setenv("OIDC_CLIENT_SECRET", "segredo-do-cliente")
+	t.Setenv("DB_USER", "concursos")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	for _, chave := range []string{"DB_PASSWORD", "SESSION_SECRET", "OIDC_CLIENT_SECRET"} {
+		if s := setting(t, cfg, chave); s.Valor != "********" {
+			t.Errorf("%s = %q, want it redacted", chave, s.Valor)
+		}
+	}
+	// Empty secrets are shown empty, so that a missing secret can be seen
+	if s := setting(t, cfg, "EMAIL_PASSWORD"); s.Valor != "" {
+		t.Errorf("empty EMAIL_PASSWORD = %q, want it empty", s.Valor)
+	}
+	if s := setting(t, cfg, "DB_USER"); s.Valor != "concursos" {
+		t.Errorf("DB_USER = %q, want it shown", s.Valor)
+	}
+
+	// Redacting leaves the configuration itself alone
+	if cfg.Database.Password != "uma-password-forte" {
+		t.Error("Settings changed the configuration")
+	}
+	for _, s := range cfg.settings {
+		if s.Valor == "********" {
+			t.Errorf("stored setting %s was redacted", s.Chave)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Origins of a setting
+const (
+	OrigemEnv      = "env"
+	OrigemFicheiro = "ficheiro"
+	OrigemDefault  = "default"
+)
+
+// Setting is the effective value of a setting and where it came from
+type Setting struct {
+	Chave  string
+	Valor  string
+	Origem string
+}
+
+// source reads settings by environment variable name from the environment and the configuration file, in that
+// order. A setting KEY can also be read from the file named by KEY_FILE, such as a Docker secret
+type source struct {
+	file     map[string]string
+	usadas   map[string]bool
+	settings []Setting
+	erros    []string
+}
+
+// newSource creates a source over the configuration file at path, or over the environment only without one
+func newSource(path string) (*source, error) {
+	s := &source{file: map[string]string{}, usadas: map[string]bool{}}
+	if path == "" {
+		return s, nil
+	}
+
+	dados, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+
+	var valores map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(dados, &valores)
+	case ".toml":
+		err = toml.Unmarshal(dados, &valores)
+	default:
+		return nil, fmt.Errorf("config file %s: unknown format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	flatten("", valores, s.file)
+	return s, nil
+}
+
+// flatten stores the values of a configuration file by environment variable name: nested keys are joined with
+// underscores in upper case, so that db: {host: x} sets DB_HOST. Lists are kept one item per line
+func flatten(prefixo string, valores map[string]interface{}, out map[string]string) {
+	for chave, valor := range valores {
+		chave = strings.ToUpper(strings.ReplaceAll(chave, "-", "_"))
+		if prefixo != "" {
+			chave = prefixo + "_" + chave
+		}
+
+		switch v := valor.(type) {
+		case map[string]interface{}:
+			flatten(chave, v, out)
+		case []interface{}:
+			itens := make([]string, 0, len(v))
+			for _, item := range v {
+				itens = append(itens, fmt.Sprint(item))
+			}
+			out[chave] = strings.Join(itens, "\n")
+		case nil:
+			out[chave] = ""
+		default:
+			out[chave] = fmt.Sprint(v)
+		}
+	}
+}
+
+// lookup returns the value of a setting and its origin, or false when it is not set anywhere. An empty value
+// counts as unset, in the environment as in the file, so that a variable passed through empty, as Docker Compose
+// does with ${VAR} when VAR is not set, keeps the file value or the default instead of clearing the setting
+func (s *source) lookup(key string) (string, string, bool) {
+	s.usadas[key] = true
+	s.usadas[key+"_FILE"] = true
+
+	if value := os.Getenv(key); value != "" {
+		return value, OrigemEnv, true
+	}
+	if path := os.Getenv(key + "_FILE"); path != "" {
+		return s.readSecret(key, path), OrigemEnv + " (" + key + "_FILE)", true
+	}
+	if value := s.file[key]; value != "" {
+		return value, OrigemFicheiro, true
+	}
+	if path := s.file[key+"_FILE"]; path != "" {
+		return s.readSecret(key, path), OrigemFicheiro + " (" + key + "_FILE)", true
+	}
+
+	return "", OrigemDefault, false
+}
+
+// readSecret reads a setting from a file, without the trailing line break secret files usually end with
+func (s *source) readSecret(key, path string) string {
+	dados, err := os.ReadFile(path)
+	if err != nil {
+		s.erros = append(s.erros, fmt.Sprintf("%s_FILE: %v", key, err))
+		return ""
+	}
+	return strings.TrimRight(string(dados), "\r\n")
+}
+
+// record keeps the effective value of a setting for the configuration check
+func (s *source) record(key, value, origem string) {
+	s.settings = append(s.settings, Setting{Chave: key, Valor: value, Origem: origem})
+}
+
+// getEnv gets a setting or returns a default value
+func (s *source) getEnv(key, defaultValue string) string {
+	value, origem, ok := s.lookup(key)
+	if !ok {
+		value = defaultValue
+	}
+	s.record(key, value, origem)
+	return value
+}
+
+// getEnvInt gets an integer setting or returns a default value; a value that is not a number is an error
+func (s *source) getEnvInt(key string, defaultValue int) int {
+	raw, origem, ok := s.lookup(key)
+	value := defaultValue
+	if ok {
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			s.erros = append(s.erros, fmt.Sprintf("invalid %s %q: not a number", key, raw))
+		} else {
+			value = n
+		}
+	}
+	s.record(key, strconv.Itoa(value), origem)
+	return value
+}
+
+// getEnvBool gets a boolean setting or returns a default value; a value that is not a boolean is an error
+func (s *source) getEnvBool(key string, defaultValue bool) bool {
+	raw, origem, ok := s.lookup(key)
+	value := defaultValue
+	if ok {
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			s.erros = append(s.erros, fmt.Sprintf("invalid %s %q: not a boolean", key, raw))
+		} else {
+			value = b
+		}
+	}
+	s.record(key, strconv.FormatBool(value), origem)
+	return value
+}
+
+// getEnvCargoMap gets a "valor:cargoID;valor:cargoID" setting; entries are separated by semicolons, or given as a
+// list in the configuration file, since group DNs contain commas
+func (s *source) getEnvCargoMap(key string) ([]CargoMap, error) {
+	var cargos []CargoMap
+	for _, entry := range splitList(s.getEnv(key, ""), ';') {
+		sep := strings.LastIndex(entry, ":")
+		if sep < 0 {
+			return nil, fmt.Errorf("invalid %s entry %q", key, entry)
+		}
+		cargoID, err := strconv.Atoi(strings.TrimSpace(entry[sep+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", key, entry, err)
+		}
+		cargos = append(cargos, CargoMap{Valor: strings.TrimSpace(entry[:sep]), CargoID: cargoID})
+	}
+
+	return cargos, nil
+}
+
+// unused returns the settings of the configuration file that no setting reads, which are most likely typos
+func (s *source) unused() []string {
+	var chaves []string
+	for chave := range s.file {
+		if !s.usadas[chave] {
+			chaves = append(chaves, chave)
+		}
+	}
+	sort.Strings(chaves)
+	return chaves
+}
+
+// splitList splits a list setting on sep or line breaks, dropping empty entries
+func splitList(value string, sep rune) []string {
+	var itens []string
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == sep || r == '\n' }) {
+		if item = strings.TrimSpace(item); item != "" {
+			itens = append(itens, item)
+		}
+	}
+	return itens
+}
+
+// isSecret reports whether a setting holds a secret that is never printed
+func isSecret(key string) bool {
+	return strings.HasSuffix(key, "PASSWORD") || strings.HasSuffix(key, "SECRET")
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile writes a file in the test's temporary directory, returning its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewSourceFlattensYAMLAndTOML(t *testing.T) {
+	want := map[string]string{
+		"DB_HOST":              "db.interno",
+		"DB_PORT":              "3307",
+		"REGISTRATION_DOMAINS": "empresa.pt\nfiliais.pt",
+		"SESSION_IDLE_MINUTES": "30",
+		"EMAIL_FROM":           "",
+	}
+
+	ficheiros := map[string]string{
+		"config.yaml": `
+db:
+  host: db.interno
+  port: 3307
+registration-domains:
+  - empresa.pt
+  - filiais.pt
+session_idle_minutes: 30
+email_from:
+`,
+		"config.toml": `
+registration-domains = ["empresa.pt", "filiais.pt"]
+session_idle_minutes = 30
+email_from = ""
+
+[db]
+host = "db.interno"
+port = 3307
+`,
+	}
+
+	for nome, conteudo := range ficheiros {
+		src, err := newSource(writeFile(t, nome, conteudo))
+		if err != nil {
+			t.Fatalf("%s: newSource: %v", nome, err)
+		}
+		if !reflect.DeepEqual(src.file, want) {
+			t.Errorf("%s: settings = %q, want %q", nome, src.file, want)
+		}
+	}
+
+	if _, err := newSource(writeFile(t, "config.json", "{}")); err == nil {
+		t.Error("newSource accepted a .json file")
+	}
+	if _, err := newSource(writeFile(t, "config.yaml", "db: [")); err == nil {
+		t.Error("newSource accepted an invalid YAML file")
+	}
+	if _, err := newSource(filepath.Join(t.TempDir(), "nenhum.yaml")); err == nil {
+		t.Error("newSource accepted a missing file")
+	}
+}
+
+func TestLookupOrder(t *testing.T) {
+	segredoEnv := writeFile(t, "segredo-env", "do-env\n")
+	segredoFicheiro := writeFile(t, "segredo-ficheiro", "do-ficheiro\r\n")
+
+	tests := []struct {
+		nome     string
+		env      map[string]string
+		file     map[string]string
+		valor    string
+		origem   string
+		definido bool
+	}{
+		{"default", nil, nil, "", OrigemDefault, false},
+		{"file", nil, map[string]string{"K": "f"}, "f", OrigemFicheiro, true},
+		{"env over file", map[string]string{"K": "e"}, map[string]string{"K": "f"}, "e", OrigemEnv, true},
+		{"env secret file over file", map[string]string{"K_FILE": segredoEnv}, map[string]string{"K": "f"}, "do-env", OrigemEnv + " (K_FILE)", true},
+		{"env over env secret file", map[string]string{"K": "e", "K_FILE": segredoEnv}, nil, "e", OrigemEnv, true},
+		{"file over file secret file", nil, map[string]string{"K": "f", "K_FILE": segredoFicheiro}, "f", OrigemFicheiro, true},
+		{"file secret file", nil, map[string]string{"K_FILE": segredoFicheiro}, "do-ficheiro", OrigemFicheiro + " (K_FILE)", true},
+		// An empty value counts as unset, in the environment and in the file
+		{"empty env", map[string]string{"K": ""}, map[string]string{"K": "f"}, "f", OrigemFicheiro, true},
+		{"empty env and file", map[string]string{"K": ""}, map[string]string{"K": ""}, "", OrigemDefault, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.nome, func(t *testing.T) {
+			t.Setenv("K", "")
+			t.Setenv("K_FILE", "")
+			for chave, valor := range tt.env {
+				t.Setenv(chave, valor)
+			}
+			src := &source{file: tt.file, usadas: map[string]bool{}}
+
+			valor, origem, definido := src.lookup("K")
+			if valor != tt.valor || origem != tt.origem || definido != tt.definido {
+				t.Errorf("lookup = %q, %q, %v; want %q, %q, %v", valor, origem, definido, tt.valor, tt.origem, tt.definido)
+			}
+			if len(src.erros) > 0 {
+				t.Errorf("errors: %v", src.erros)
+			}
+		})
+	}
+}
+
+func TestLookupMissingSecretFile(t *testing.T) {
+	t.Setenv("K", "")
+	t.Setenv("K_FILE", filepath.Join(t.TempDir(), "nenhum"))
+
+	src := &source{file: map[string]string{}, usadas: map[string]bool{}}
+	src.lookup("K")
+	if len(src.erros) != 1 || !strings.HasPrefix(src.erros[0], "K_FILE: ") {
+		t.Errorf("errors = %v, want the K_FILE error", src.erros)
+	}
+}
+
+func TestGetEnvIntAndBoolRejectInvalidValues(t *testing.T) {
+	src := &source{file: map[string]string{"N": " 42 ", "X": "muitos", "B": "true", "Y": "talvez"}, usadas: map[string]bool{}}
+	for _, chave := range []string{"N", "X", "B", "Y", "Z"} {
+		t.Setenv(chave, "")
+	}
+
+	if n := src.getEnvInt("N", 1); n != 42 {
+		t.Errorf("getEnvInt(N) = %d, want 42", n)
+	}
+	if n := src.getEnvInt("X", 1); n != 1 {
+		t.Errorf("getEnvInt(X) = %d, want the default", n)
+	}
+	if n := src.getEnvInt("Z", 7); n != 7 {
+		t.Errorf("getEnvInt(Z) = %d, want the default", n)
+	}
+	if b := src.getEnvBool("B", false); !b {
+		t.Error("getEnvBool(B) = false, want true")
+	}
+	if b := src.getEnvBool("Y", true); !b {
+		t.Error("getEnvBool(Y) = false, want the default")
+	}
+	if len(src.erros) != 2 {
+		t.Errorf("errors = %v, want the X and Y values refused", src.erros)
+	}
+}
+
+func TestGetEnvCargoMap(t *testing.T) {
+	t.Setenv("GRUPOS", "")
+	src := &source{file: map[string]string{
+		"GRUPOS": "cn=gestores,ou=grupos,dc=empresa,dc=pt:2\ncn=tecnicos,ou=grupos,dc=empresa,dc=pt : 3",
+	}, usadas: map[string]bool{}}
+
+	cargos, err := src.getEnvCargoMap("GRUPOS")
+	if err != nil {
+		t.Fatalf("getEnvCargoMap: %v", err)
+	}
+	want := []CargoMap{
+		{Valor: "cn=gestores,ou=grupos,dc=empresa,dc=pt", CargoID: 2},
+		{Valor: "cn=tecnicos,ou=grupos,dc=empresa,dc=pt", CargoID: 3},
+	}
+	if !reflect.DeepEqual(cargos, want) {
+		t.Errorf("getEnvCargoMap = %+v, want %+v", cargos, want)
+	}
+
+	for _, valor := range []string{"gestores", "gestores:dois"} {
+		t.Setenv("GRUPOS", valor)
+		if _, err := src.getEnvCargoMap("GRUPOS"); err == nil {
+			t.Errorf("getEnvCargoMap(%q) accepted an invalid entry", valor)
+		}
+	}
+}
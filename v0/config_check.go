@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"v0/config"
+)
+
+// checkConfig prints the effective configuration with secrets redacted, returning the exit status
+func checkConfig(path string) int {
+	cfg, err := config.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration error: %v\n", err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, s := range cfg.Settings() {
+		fmt.Fprintf(w, "%s\t%s\t[%s]\n", s.Chave, strings.ReplaceAll(s.Valor, "\n", ", "), s.Origem)
+	}
+	w.Flush()
+
+	fmt.Printf("\nEnvironment: %s\n", cfg.Env)
+	for _, aviso := range cfg.Avisos {
+		fmt.Printf("Warning: %s\n", aviso)
+	}
+	if len(cfg.Avisos) == 0 {
+		fmt.Println("Configuration OK")
+	}
+
+	return 0
+}
@@ -160,34 +160,6 @@ func GetResultados(db *sql.DB) ([]struct {
 	return resultados, nil
 }
 
-// GetEmailsFromDB retrieves emails from the database for notification
-func GetEmailsFromDB(db *sql.DB) ([]string, error) {
-	var emails []string
-
-	rows, err := db.Query(`
-        SELECT email FROM user 
-        WHERE cargo_id NOT IN (1, 4) OR cargo_id IS NULL
-    `)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var email string
-		if err := rows.Scan(&email); err != nil {
-			return nil, err
-		}
-		emails = append(emails, email)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return emails, nil
-}
-
 // GetAllEmails retrieves all emails from the user table
 func GetAllEmails(db *sql.DB) ([]string, error) {
 	rows, err := db.Query("SELECT email FROM user")
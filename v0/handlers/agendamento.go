@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"v0/database"
+	"v0/models"
+	"v0/services"
+	"v0/utils"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+// AgendamentoHandler handles the scheduled report emails admin requests
+type AgendamentoHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	logService *services.LogService
+	scheduler  *services.Scheduler
+}
+
+// NewAgendamentoHandler creates a new AgendamentoHandler
+func NewAgendamentoHandler(db *sql.DB, store sessions.Store, scheduler *services.Scheduler) *AgendamentoHandler {
+	return &AgendamentoHandler{
+		db:         db,
+		store:      store,
+		logService: services.NewLogService(db),
+		scheduler:  scheduler,
+	}
+}
+
+// agendamentoItem is a schedule with its next run for the list page
+type agendamentoItem struct {
+	models.Agendamento
+	TipoDesc string
+	Proxima  string
+}
+
+// List handles the report schedules admin page
+func (h *AgendamentoHandler) List(w http.ResponseWriter, r *http.Request) {
+	agendamentos, err := models.GetAgendamentos(h.db)
+	if err != nil {
+		log.Printf("Error fetching report schedules: %v", err)
+		http.Error(w, "Erro ao buscar agendamentos", http.StatusInternalServerError)
+		return
+	}
+
+	execucoes, err := models.GetAgendamentoExecucoes(h.db, 50)
+	if err != nil {
+		log.Printf("Error fetching schedule runs: %v", err)
+		http.Error(w, "Erro ao buscar histórico de execuções", http.StatusInternalServerError)
+		return
+	}
+
+	estados, err := database.GetEstados(h.db)
+	if err != nil {
+		log.Printf("Error fetching estados: %v", err)
+		http.Error(w, "Erro ao buscar estados", http.StatusInternalServerError)
+		return
+	}
+
+	tipos, err := database.GetTipos(h.db)
+	if err != nil {
+		log.Printf("Error fetching tipos: %v", err)
+		http.Error(w, "Erro ao buscar tipos", http.StatusInternalServerError)
+		return
+	}
+
+	now := utils.Now()
+	var items []agendamentoItem
+	for _, a := range agendamentos {
+		item := agendamentoItem{Agendamento: a, TipoDesc: models.RelatorioTipos[a.TipoRelatorio]}
+		if cron, err := utils.ParseCron(a.Cron); err == nil && a.Ativo {
+			if next := cron.Next(now); !next.IsZero() {
+				item.Proxima = next.Format("2006-01-02 15:04")
+			}
+		}
+		items = append(items, item)
+	}
+
+	// Render template
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/admin/agendamentos/list.html"))
+	data := struct {
+		Title        string
+		User         interface{}
+		Agendamentos []agendamentoItem
+		Execucoes    []models.AgendamentoExecucao
+		Tipos        []struct {
+			ID        int
+			Descricao string
+		}
+		Estados []struct {
+			ID        int
+			Descricao string
+		}
+		Relatorios map[string]string
+	}{
+		Title:        "Relatórios Agendados",
+		User:         getSessionUser(h.db, h.store, r),
+		Agendamentos: items,
+		Execucoes:    execucoes,
+		Tipos:        tipos,
+		Estados:      estados,
+		Relatorios:   models.RelatorioTipos,
+	}
+	tmpl.Execute(w, data)
+}
+
+// Save handles the create report schedule form submission
+func (h *AgendamentoHandler) Save(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if err := r.ParseForm(); err != nil {
+		log.Printf("Form parse error: %v", err)
+		http.Error(w, "Erro ao processar formulário", http.StatusBadRequest)
+		return
+	}
+
+	nome := strings.TrimSpace(r.FormValue("nome"))
+	if nome == "" {
+		http.Error(w, "Nome obrigatório", http.StatusBadRequest)
+		return
+	}
+
+	expr := strings.Join(strings.Fields(r.FormValue("cron")), " ")
+	if _, err := utils.ParseCron(expr); err != nil {
+		http.Error(w, "Expressão cron inválida: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tipoRelatorio := r.FormValue("tipo_relatorio")
+	if _, ok := models.RelatorioTipos[tipoRelatorio]; !ok {
+		http.Error(w, "Tipo de relatório inválido", http.StatusBadRequest)
+		return
+	}
+
+	dias, err := strconv.Atoi(r.FormValue("dias"))
+	if err != nil || dias < 1 || dias > 366 {
+		dias = 7
+	}
+
+	estadoID, _ := strconv.Atoi(r.FormValue("estado_id"))
+	tipoID, _ := strconv.Atoi(r.FormValue("tipo_id"))
+
+	agendamento := &models.Agendamento{
+		Nome:          nome,
+		Cron:          expr,
+		TipoRelatorio: tipoRelatorio,
+		EstadoID:      estadoID,
+		TipoID:        tipoID,
+		Entidade:      strings.TrimSpace(r.FormValue("entidade")),
+		Dias:          dias,
+		Destinatarios: r.FormValue("destinatarios"),
+		Ativo:         true,
+		CriadoPor:     adminID,
+	}
+
+	destinatarios := agendamento.ListaDestinatarios()
+	if len(destinatarios) == 0 {
+		http.Error(w, "Indique pelo menos um destinatário", http.StatusBadRequest)
+		return
+	}
+	for _, d := range destinatarios {
+		if _, err := mail.ParseAddress(d); err != nil {
+			http.Error(w, "Email inválido: "+d, http.StatusBadRequest)
+			return
+		}
+	}
+	agendamento.Destinatarios = strings.Join(destinatarios, ", ")
+
+	if err := models.CreateAgendamento(h.db, agendamento); err != nil {
+		log.Printf("Error creating report schedule: %v", err)
+		http.Error(w, "Erro ao criar agendamento", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the create action
+	h.logService.LogCreate(adminID, "agendamento", agendamento)
+
+	http.Redirect(w, r, "/admin/agendamentos", http.StatusSeeOther)
+}
+
+// Toggle handles pausing and resuming a report schedule
+func (h *AgendamentoHandler) Toggle(w http.ResponseWriter, r *http.Request) {
+	agendamento, ok := h.getAgendamento(w, r)
+	if !ok {
+		return
+	}
+
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if err := models.SetAgendamentoAtivo(h.db, agendamento.ID, !agendamento.Ativo); err != nil {
+		log.Printf("Error updating report schedule: %v", err)
+		http.Error(w, "Erro ao atualizar agendamento", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the update action
+	h.logService.LogUpdate(adminID, "agendamento",
+		map[string]interface{}{"id_agendamento": agendamento.ID, "ativo": agendamento.Ativo},
+		map[string]interface{}{"id_agendamento": agendamento.ID, "ativo": !agendamento.Ativo})
+
+	http.Redirect(w, r, "/admin/agendamentos", http.StatusSeeOther)
+}
+
+// Run handles the run now button; the report is sent in the background
+func (h *AgendamentoHandler) Run(w http.ResponseWriter, r *http.Request) {
+	agendamento, ok := h.getAgendamento(w, r)
+	if !ok {
+		return
+	}
+
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	h.logService.LogAction(adminID, "agendamento", "RUN", nil, map[string]interface{}{
+		"id_agendamento": agendamento.ID,
+	})
+
+	go h.scheduler.Run(agendamento, true)
+
+	http.Redirect(w, r, "/admin/agendamentos", http.StatusSeeOther)
+}
+
+// Delete handles the delete report schedule request
+func (h *AgendamentoHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	agendamento, ok := h.getAgendamento(w, r)
+	if !ok {
+		return
+	}
+
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if err := models.DeleteAgendamento(h.db, agendamento.ID); err != nil {
+		log.Printf("Error deleting report schedule: %v", err)
+		http.Error(w, "Erro ao excluir agendamento", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the delete action
+	h.logService.LogDelete(adminID, "agendamento", agendamento)
+
+	http.Redirect(w, r, "/admin/agendamentos", http.StatusSeeOther)
+}
+
+// getAgendamento loads the schedule of the request URL, writing the error response when it fails
+func (h *AgendamentoHandler) getAgendamento(w http.ResponseWriter, r *http.Request) (*models.Agendamento, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID do agendamento inválido", http.StatusBadRequest)
+		return nil, false
+	}
+
+	agendamento, err := models.GetAgendamentoByID(h.db, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Agendamento não encontrado", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error fetching report schedule: %v", err)
+		http.Error(w, "Erro ao buscar agendamento", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return agendamento, true
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"v0/config"
+	"v0/models"
+	"v0/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+// AnexoHandler handles concurso attachment requests
+type AnexoHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	cfg        *config.Config
+	storage    services.Storage
+	logService *services.LogService
+}
+
+// NewAnexoHandler creates a new AnexoHandler
+func NewAnexoHandler(db *sql.DB, store sessions.Store, cfg *config.Config, storage services.Storage) *AnexoHandler {
+	return &AnexoHandler{
+		db:         db,
+		store:      store,
+		cfg:        cfg,
+		storage:    storage,
+		logService: services.NewLogService(db),
+	}
+}
+
+// Upload handles the upload of a file to a concurso
+func (h *AnexoHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	// Get concurso ID from URL
+	vars := mux.Vars(r)
+	concursoID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "ID do concurso inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from session
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+
+	// Make sure the concurso exists and is visible to the user
+	if _, err := models.GetConcursoByID(h.db, vars["id"], getVisibilidade(h.db, h.store, r)); err != nil {
+		http.Error(w, "Concurso não encontrado", http.StatusNotFound)
+		return
+	}
+
+	// Limit the upload size
+	maxBytes := int64(h.cfg.Storage.MaxUploadMB) << 20
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	if err := r.ParseMultipartForm(maxBytes); err != nil {
+		log.Printf("Upload parse error: %v", err)
+		http.Error(w, fmt.Sprintf("Ficheiro inválido ou maior que %d MB", h.cfg.Storage.MaxUploadMB), http.StatusBadRequest)
+		return
+	}
+
+	categoria := r.FormValue("categoria")
+	if !models.IsAnexoCategoria(categoria) {
+		http.Error(w, "Categoria inválida", http.StatusBadRequest)
+		return
+	}
+
+	file, header, err := r.FormFile("ficheiro")
+	if err != nil {
+		http.Error(w, "Ficheiro em falta", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	nomeFicheiro := cleanFileName(header.Filename)
+
+	versao, err := models.NextAnexoVersao(h.db, concursoID, categoria, nomeFicheiro)
+	if err != nil {
+		log.Printf("Error fetching attachment version: %v", err)
+		http.Error(w, "Erro ao guardar anexo", http.StatusInternalServerError)
+		return
+	}
+
+	// Store the file under a random key so that names never collide
+	chave, err := newStorageKey(concursoID)
+	if err != nil {
+		log.Printf("Error generating storage key: %v", err)
+		http.Error(w, "Erro ao guardar anexo", http.StatusInternalServerError)
+		return
+	}
+
+	tamanho, err := h.storage.Save(chave, file)
+	if err != nil {
+		log.Printf("Error storing attachment: %v", err)
+		http.Error(w, "Erro ao guardar anexo", http.StatusInternalServerError)
+		return
+	}
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	anexo := &models.Anexo{
+		ConcursoID:   concursoID,
+		Categoria:    categoria,
+		NomeFicheiro: nomeFicheiro,
+		Versao:       versao,
+		Chave:        chave,
+		Tamanho:      tamanho,
+		ContentType:  contentType,
+		UserID:       sql.NullInt64{Int64: int64(userID), Valid: userID > 0},
+	}
+
+	if err := models.CreateAnexo(h.db, anexo); err != nil {
+		log.Printf("Error creating attachment: %v", err)
+		h.storage.Delete(chave)
+		http.Error(w, "Erro ao guardar anexo", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the upload action
+	h.logAnexo(userID, "upload", anexo)
+
+	http.Redirect(w, r, fmt.Sprintf("/concursos/%d#anexos", concursoID), http.StatusSeeOther)
+}
+
+// Download handles the download of an attachment
+func (h *AnexoHandler) Download(w http.ResponseWriter, r *http.Request) {
+	anexo, ok := h.getAnexo(w, r)
+	if !ok {
+		return
+	}
+
+	// Get user ID from session
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+
+	file, err := h.storage.Open(anexo.Chave)
+	if err != nil {
+		log.Printf("Error opening attachment: %v", err)
+		http.Error(w, "Ficheiro do anexo não encontrado", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	// Log the download action
+	h.logAnexo(userID, "download", anexo)
+
+	w.Header().Set("Content-Type", anexo.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": anexo.NomeFicheiro}))
+	w.Header().Set("Content-Length", strconv.FormatInt(anexo.Tamanho, 10))
+
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("Error sending attachment: %v", err)
+	}
+}
+
+// Delete handles the deletion of an attachment version
+func (h *AnexoHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	anexo, ok := h.getAnexo(w, r)
+	if !ok {
+		return
+	}
+
+	// Get user ID from session
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+
+	if err := models.DeleteAnexo(h.db, anexo.ID); err != nil {
+		log.Printf("Error deleting attachment: %v", err)
+		http.Error(w, "Erro ao excluir anexo", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.storage.Delete(anexo.Chave); err != nil {
+		log.Printf("Error deleting attachment file: %v", err)
+	}
+
+	// Log the delete action
+	h.logAnexo(userID, "delete", anexo)
+
+	http.Redirect(w, r, fmt.Sprintf("/concursos/%d#anexos", anexo.ConcursoID), http.StatusSeeOther)
+}
+
+// getAnexo loads the attachment named in the URL, writing an error response if it does not exist
+// or its concurso is not visible to the user
+func (h *AnexoHandler) getAnexo(w http.ResponseWriter, r *http.Request) (*models.Anexo, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "ID do anexo inválido", http.StatusBadRequest)
+		return nil, false
+	}
+
+	anexo, err := models.GetAnexoByID(h.db, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Anexo não encontrado", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error fetching attachment: %v", err)
+		http.Error(w, "Erro ao buscar anexo", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	_, err = models.GetConcursoByID(h.db, strconv.Itoa(anexo.ConcursoID), getVisibilidade(h.db, h.store, r))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Anexo não encontrado", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error fetching concurso: %v", err)
+		http.Error(w, "Erro ao buscar concurso", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return anexo, true
+}
+
+// logAnexo writes an attachment event to the audit log
+func (h *AnexoHandler) logAnexo(userID int, acao string, anexo *models.Anexo) {
+	data := map[string]interface{}{
+		"anexo_id":      anexo.ID,
+		"concurso_id":   anexo.ConcursoID,
+		"categoria":     anexo.Categoria,
+		"nome_ficheiro": anexo.NomeFicheiro,
+		"versao":        anexo.Versao,
+		"tamanho":       anexo.Tamanho,
+	}
+
+	var err error
+	if acao == "delete" {
+		err = h.logService.LogAction(userID, "anexo", acao, data, nil)
+	} else {
+		err = h.logService.LogAction(userID, "anexo", acao, nil, data)
+	}
+	if err != nil {
+		log.Printf("Error logging attachment %s: %v", acao, err)
+	}
+}
+
+// newStorageKey generates a random storage key for a file of a concurso
+func newStorageKey(concursoID int) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("concursos/%d/%s", concursoID, hex.EncodeToString(b)), nil
+}
+
+// anexoNomeMax is the length of the anexo.nome_ficheiro column
+const anexoNomeMax = 255
+
+// cleanFileName keeps the base name of an uploaded file without markup, quotes, control characters or
+// characters file systems refuse, since the name is shown on pages and sent back on download
+func cleanFileName(nome string) string {
+	nome = filepath.Base(strings.ReplaceAll(nome, "\\", "/"))
+	nome = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`<>:"'/\|?*&`+"`", r) {
+			return '_'
+		}
+		return r
+	}, nome)
+	nome = strings.Trim(nome, " .")
+
+	if runes := []rune(nome); len(runes) > anexoNomeMax {
+		ext := []rune(filepath.Ext(nome))
+		if len(ext) > 16 {
+			ext = nil
+		}
+		nome = string(runes[:anexoNomeMax-len(ext)]) + string(ext)
+	}
+	if nome == "" {
+		return "ficheiro"
+	}
+	return nome
+}
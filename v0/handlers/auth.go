@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
 	"log"
 	"net/http"
-	"text/template"
+	"time"
 
+	"v0/config"
+	"v0/middleware"
 	"v0/models"
 	"v0/services"
 
@@ -15,16 +20,25 @@ import (
 // AuthHandler handles authentication-related requests
 type AuthHandler struct {
 	db         *sql.DB
-	store      *sessions.CookieStore
+	store      sessions.Store
+	cfg        *config.Config
 	logService *services.LogService
+	providers  []services.AuthProvider
+	oidc       *services.OIDCProvider
+	// oidcAccounts keeps the accounts of single sign-on users
+	oidcAccounts services.OIDCAccounts
 }
 
 // NewAuthHandler creates a new AuthHandler
-func NewAuthHandler(db *sql.DB, store *sessions.CookieStore) *AuthHandler {
+func NewAuthHandler(db *sql.DB, store sessions.Store, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
-		db:         db,
-		store:      store,
-		logService: services.NewLogService(db),
+		db:           db,
+		store:        store,
+		cfg:          cfg,
+		logService:   services.NewLogService(db),
+		providers:    services.NewAuthProviders(db, cfg),
+		oidc:         services.NewOIDCProvider(cfg.OIDC),
+		oidcAccounts: services.NewOIDCAccounts(db),
 	}
 }
 
@@ -44,105 +58,253 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodPost {
 		email := r.FormValue("email")
 		password := r.FormValue("password")
+		ip := services.ClientIP(r)
 
-		// Authenticate user
-		userID, cargoID, err := models.Authenticate(h.db, email, password)
+		// Throttle IPs with too many recent failures before looking at the account
+		falhasIP, err := models.CountFalhasIP(h.db, ip, h.cfg.Auth.LoginIPWindowMinutes)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Email não encontrado", http.StatusUnauthorized)
-			} else {
-				log.Printf("Login error: %v", err)
-				http.Error(w, "Erro ao fazer login", http.StatusInternalServerError)
+			log.Printf("Login error: %v", err)
+			http.Error(w, "Erro ao fazer login", http.StatusInternalServerError)
+			return
+		}
+		if h.cfg.Auth.LoginIPLimit > 0 && falhasIP >= h.cfg.Auth.LoginIPLimit {
+			h.recordTentativa(models.LoginTentativa{Login: email, IP: ip, Motivo: models.MotivoIP})
+			w.WriteHeader(http.StatusTooManyRequests)
+			h.renderLogin(w, r, loginPage{Title: "Login", Erro: "Demasiadas tentativas falhadas. Tente novamente mais tarde."})
+			return
+		}
+
+		// Authenticate user against the configured providers
+		userID, cargoID, metodo, err := services.Authenticate(h.providers, email, password)
+		if errors.Is(err, services.ErrSemAcesso) {
+			log.Printf("Login refused for %q: %v", email, err)
+			h.recordTentativa(models.LoginTentativa{Login: email, IP: ip, Motivo: models.MotivoSemAcesso})
+			w.WriteHeader(http.StatusForbidden)
+			h.renderLogin(w, r, loginPage{Title: "Login", Erro: "A sua conta não tem acesso a esta aplicação. Contacte um administrador."})
+			return
+		}
+		var loginErr *models.LoginError
+		if errors.As(err, &loginErr) && loginErr.Pendente != "" {
+			h.recordTentativa(models.LoginTentativa{UserID: loginErr.UserID, Login: email, IP: ip, Motivo: models.MotivoPendente})
+			data := loginPage{Title: "Login", Erro: "A sua conta aguarda a confirmação do email enviado no registo."}
+			if loginErr.Pendente == models.EstadoPendenteAprovacao {
+				data.Erro = "A sua conta aguarda a aprovação de um administrador."
 			}
+			w.WriteHeader(http.StatusForbidden)
+			h.renderLogin(w, r, data)
+			return
+		}
+		if errors.As(err, &loginErr) {
+			h.loginFailed(w, r, email, ip, falhasIP, loginErr)
+			return
+		}
+		if err != nil {
+			log.Printf("Login error: %v", err)
+			http.Error(w, "Erro ao fazer login", http.StatusInternalServerError)
 			return
 		}
 
-		// Log the login action
-		h.logService.LogAction(userID, "user", "login", nil, map[string]interface{}{
-			"user_id": userID,
-			"email":   email,
-		})
+		h.completeLogin(w, r, userID, cargoID, metodo)
+	} else {
+		data := loginPage{Title: "Login"}
+		if r.URL.Query().Get("reset") == "true" {
+			data.Mensagem = "Password alterada. Já pode entrar com a nova password."
+		}
+		h.renderLogin(w, r, data)
+	}
+}
+
+// completeLogin continues a login whose first step succeeded. Users with 2FA, or whose cargo requires it, go
+// through a second step before the session is authenticated
+func (h *AuthHandler) completeLogin(w http.ResponseWriter, r *http.Request, userID, cargoID int, metodo string) {
+	_, totpAtivo, err := models.GetTOTP(h.db, userID)
+	if err != nil {
+		log.Printf("Login error: %v", err)
+		http.Error(w, "Erro ao fazer login", http.StatusInternalServerError)
+		return
+	}
 
-		// Create session
+	exige2FA, err := models.CargoExige2FA(h.db, cargoID)
+	if err != nil {
+		log.Printf("Login error: %v", err)
+		http.Error(w, "Erro ao fazer login", http.StatusInternalServerError)
+		return
+	}
+
+	if totpAtivo || exige2FA {
 		session, _ := h.store.Get(r, "session-name")
-		session.Values["authenticated"] = true
-		session.Values["user_id"] = userID
-		session.Values["cargo"] = cargoID
+		session.Values["authenticated"] = false
+		session.Values["2fa_user_id"] = userID
+		session.Values["2fa_cargo"] = cargoID
+		session.Values["2fa_inicio"] = time.Now().Unix()
+		session.Values["2fa_falhas"] = 0
 		if err := session.Save(r, w); err != nil {
 			log.Printf("Session save error: %v", err)
 			http.Error(w, "Erro ao salvar sessão", http.StatusInternalServerError)
 			return
 		}
 
-		http.Redirect(w, r, "/concursos", http.StatusSeeOther)
-	} else {
-		// Use a simple login page without the base template
-		tmpl := template.Must(template.ParseFiles("templates/auth/login.html"))
-		data := struct {
-			Title string
-		}{
-			Title: "Login",
+		if totpAtivo {
+			http.Redirect(w, r, "/login/2fa", http.StatusSeeOther)
+		} else {
+			http.Redirect(w, r, "/login/2fa/ativar", http.StatusSeeOther)
 		}
-		tmpl.Execute(w, data)
+		return
 	}
+
+	if !h.startSession(w, r, userID, cargoID, metodo) {
+		return
+	}
+
+	http.Redirect(w, r, "/concursos", http.StatusSeeOther)
 }
 
-// Register handles the registration form submission
-func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodPost {
-		email := r.FormValue("email")
-		password := r.FormValue("password")
-		confirmPassword := r.FormValue("confirm-password")
+// loginPage is the data of the login page
+type loginPage struct {
+	Title     string
+	Mensagem  string
+	Erro      string
+	CSRFToken string
+	// SSO is the label of the single sign-on button, empty when it is off
+	SSO string
+}
 
-		// Basic validations
-		if password != confirmPassword {
-			http.Error(w, "As senhas não coincidem", http.StatusBadRequest)
-			return
-		}
+// renderLogin renders the login page, a simple page without the base template
+func (h *AuthHandler) renderLogin(w http.ResponseWriter, r *http.Request, data loginPage) {
+	data.CSRFToken = csrfToken(h.store, r)
+	if h.oidc != nil {
+		data.SSO = h.oidc.Label()
+	}
+	tmpl := template.Must(template.ParseFiles("templates/auth/login.html"))
+	tmpl.Execute(w, data)
+}
 
-		// Check if email already exists
-		exists, err := models.EmailExists(h.db, email)
-		if err != nil {
-			log.Printf("Email check error: %v", err)
-			http.Error(w, "Erro ao verificar email", http.StatusInternalServerError)
-			return
+// lockoutPolicy returns the configured account lockout policy
+func lockoutPolicy(cfg *config.Config) models.LockoutPolicy {
+	return models.LockoutPolicy{
+		Limite:  cfg.Auth.LockoutThreshold,
+		Minutos: cfg.Auth.LockoutMinutes,
+	}
+}
+
+// recordTentativa records a login attempt for the login history
+func (h *AuthHandler) recordTentativa(t models.LoginTentativa) {
+	if err := models.CreateLoginTentativa(h.db, t); err != nil {
+		log.Printf("Error recording login attempt: %v", err)
+	}
+}
+
+// loginDelay is the delay before answering a failed login, doubling with every failure up to the configured maximum
+func (h *AuthHandler) loginDelay(falhas int) time.Duration {
+	maximo := time.Duration(h.cfg.Auth.LoginMaxDelaySeconds) * time.Second
+	if falhas <= 0 || maximo <= 0 {
+		return 0
+	}
+
+	delay := 250 * time.Millisecond
+	for i := 1; i < falhas && delay < maximo; i++ {
+		delay *= 2
+	}
+	return min(delay, maximo)
+}
+
+// loginFailed records and answers a failed login, notifying the user and the admins when it locked the account
+func (h *AuthHandler) loginFailed(w http.ResponseWriter, r *http.Request, login, ip string, falhasIP int, loginErr *models.LoginError) {
+	motivo := models.MotivoCredenciais
+	if loginErr.Bloqueado && !loginErr.NovoBloqueio {
+		motivo = models.MotivoBloqueado
+	}
+	h.recordTentativa(models.LoginTentativa{UserID: loginErr.UserID, Login: login, IP: ip, Motivo: motivo})
+
+	if loginErr.NovoBloqueio {
+		notifyLockout(h.db, h.cfg, h.logService, loginErr.UserID, ip)
+	}
+
+	// Unknown logins are delayed like known ones so that accounts cannot be told apart
+	time.Sleep(h.loginDelay(max(loginErr.Falhas, falhasIP+1)))
+
+	data := loginPage{Title: "Login", Erro: "User ou password inválidos"}
+	if loginErr.Bloqueado {
+		data.Erro = "Conta bloqueada temporariamente após várias tentativas falhadas. Tente novamente mais tarde ou recupere a password."
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+	h.renderLogin(w, r, data)
+}
+
+// notifyLockout logs the lock of an account and emails its user and the users who manage accounts
+func notifyLockout(db *sql.DB, cfg *config.Config, logService *services.LogService, userID int, ip string) {
+	logService.LogAction(userID, "user", "bloqueio", nil, map[string]interface{}{
+		"user_id": userID,
+		"ip":      ip,
+		"minutos": cfg.Auth.LockoutMinutes,
+	})
+
+	user, err := models.GetUserByID(db, userID)
+	if err != nil {
+		log.Printf("Error fetching locked user: %v", err)
+		return
+	}
+
+	emailService := services.NewEmailService(cfg.Email)
+	if err := emailService.SendAccountLockedEmail(user.Email, user.Nome, ip, cfg.Auth.LockoutMinutes); err != nil {
+		log.Printf("Error sending account locked email: %v", err)
+	}
+
+	admins, err := models.GetUsersWithPermissao(db, models.PermUserManage)
+	if err != nil {
+		log.Printf("Error fetching admins: %v", err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/admin/users/edit/%d", cfg.Server.BaseURL, user.ID)
+	for _, admin := range admins {
+		if admin.ID == user.ID {
+			continue
 		}
-		if exists {
-			http.Error(w, "Email já em uso", http.StatusBadRequest)
-			return
+		if err := emailService.SendAccountLockedAdminEmail(admin.Email, user.Nome, user.Email, ip, link, cfg.Auth.LockoutMinutes); err != nil {
+			log.Printf("Error sending account locked email: %v", err)
 		}
+	}
+}
 
-		// Create user (cargo_id 4 = Guest by default)
-		if err := models.CreateUser(h.db, "user", email, password, 4); err != nil {
-			log.Printf("User creation error: %v", err)
-			http.Error(w, "Erro ao criar usuário", http.StatusInternalServerError)
-			return
-		}
+// startSession marks the session authenticated once every login step succeeded, writing the error response when it fails
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, userID, cargoID int, metodo string) bool {
+	// Sessions saved before the last password reset are no longer valid
+	sessaoVersao, err := models.GetSessaoVersao(h.db, userID)
+	if err != nil {
+		log.Printf("Login error: %v", err)
+		http.Error(w, "Erro ao fazer login", http.StatusInternalServerError)
+		return false
+	}
 
-		// Get the newly created user's ID for logging
-		var userID int
-		err = h.db.QueryRow("SELECT id_user FROM user WHERE email = ?", email).Scan(&userID)
-		if err == nil {
-			// Log the registration action
-			h.logService.LogCreate(userID, "user", map[string]interface{}{
-				"user_id":  userID,
-				"email":    email,
-				"cargo_id": 4,
-			})
-		}
+	// Log the login action
+	h.logService.LogAction(userID, "user", "login", nil, map[string]interface{}{
+		"user_id": userID,
+		"metodo":  metodo,
+	})
+	h.recordTentativa(models.LoginTentativa{UserID: userID, IP: services.ClientIP(r), Sucesso: true, Motivo: metodo})
 
-		// Redirect to login after successful registration
-		http.Redirect(w, r, "/login?registered=true", http.StatusSeeOther)
-	} else {
-		// If not POST, show the template normally
-		tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/auth/login.html"))
-		data := struct {
-			Title string
-		}{
-			Title: "Registro",
+	// Create session under a new token, so that a token set before the login cannot be used after it
+	session, _ := h.store.Get(r, "session-name")
+	if session.ID != "" {
+		if err := models.DeleteSessao(h.db, session.ID); err != nil {
+			log.Printf("Error deleting session: %v", err)
 		}
-		tmpl.Execute(w, data)
+		session.ID = ""
 	}
+	clearPending2FA(session)
+	delete(session.Values, middleware.CSRFField)
+	session.Values["authenticated"] = true
+	session.Values["user_id"] = userID
+	session.Values["cargo"] = cargoID
+	session.Values["sessao_versao"] = sessaoVersao
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Session save error: %v", err)
+		http.Error(w, "Erro ao salvar sessão", http.StatusInternalServerError)
+		return false
+	}
+
+	return true
 }
 
 // Logout handles user logout
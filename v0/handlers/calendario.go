@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"v0/config"
+	"v0/database"
+	"v0/models"
+	"v0/services"
+	"v0/utils"
+
+	"github.com/gorilla/sessions"
+)
+
+// maxCalendarioDias limits the date range a single calendar query may cover
+const maxCalendarioDias = 366
+
+// CalendarioHandler handles the deadlines calendar requests
+type CalendarioHandler struct {
+	db    *sql.DB
+	store sessions.Store
+	cfg   *config.Config
+}
+
+// NewCalendarioHandler creates a new CalendarioHandler
+func NewCalendarioHandler(db *sql.DB, store sessions.Store, cfg *config.Config) *CalendarioHandler {
+	return &CalendarioHandler{
+		db:    db,
+		store: store,
+		cfg:   cfg,
+	}
+}
+
+// Page handles the calendar page
+func (h *CalendarioHandler) Page(w http.ResponseWriter, r *http.Request) {
+	tipos, err := database.GetTipos(h.db)
+	if err != nil {
+		log.Printf("Error fetching tipos: %v", err)
+		http.Error(w, "Erro ao buscar tipos", http.StatusInternalServerError)
+		return
+	}
+
+	// Render template
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/concursos/calendario.html"))
+	data := struct {
+		Title    string
+		User     interface{}
+		Entidade string
+		Vista    string
+		Data     string
+		Tipos    []struct {
+			ID        int
+			Descricao string
+		}
+	}{
+		Title:    "Calendário de Prazos",
+		User:     getSessionUser(h.db, h.store, r),
+		Entidade: r.URL.Query().Get("entidade"),
+		Vista:    r.URL.Query().Get("vista"),
+		Data:     r.URL.Query().Get("data"),
+		Tipos:    tipos,
+	}
+	tmpl.Execute(w, data)
+}
+
+// Eventos returns the deadlines between the inicio and fim query parameters as JSON
+func (h *CalendarioHandler) Eventos(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	inicio, err := time.Parse("2006-01-02", query.Get("inicio"))
+	if err != nil {
+		http.Error(w, "Data de início inválida", http.StatusBadRequest)
+		return
+	}
+
+	fim, err := time.Parse("2006-01-02", query.Get("fim"))
+	if err != nil {
+		http.Error(w, "Data de fim inválida", http.StatusBadRequest)
+		return
+	}
+
+	if fim.Before(inicio) || fim.Sub(inicio).Hours()/24 > maxCalendarioDias {
+		http.Error(w, "Intervalo de datas inválido", http.StatusBadRequest)
+		return
+	}
+
+	eventos, err := models.GetEventos(h.db, inicio.Format("2006-01-02"), fim.Format("2006-01-02"), query.Get("entidade"),
+		getVisibilidade(h.db, h.store, r))
+	if err != nil {
+		log.Printf("Error fetching calendar events: %v", err)
+		http.Error(w, "Erro ao buscar prazos", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(eventos); err != nil {
+		log.Printf("Error encoding calendar events: %v", err)
+	}
+}
+
+// ICS returns the deadlines from a month ago to a year ahead as an iCalendar feed
+func (h *CalendarioHandler) ICS(w http.ResponseWriter, r *http.Request) {
+	hoje := utils.Now()
+	inicio := hoje.AddDate(0, -1, 0).Format("2006-01-02")
+	fim := hoje.AddDate(1, 0, 0).Format("2006-01-02")
+
+	eventos, err := models.GetEventos(h.db, inicio, fim, r.URL.Query().Get("entidade"), getVisibilidade(h.db, h.store, r))
+	if err != nil {
+		log.Printf("Error fetching calendar events: %v", err)
+		http.Error(w, "Erro ao buscar prazos", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=prazos.ics")
+	w.Write([]byte(services.BuildICS(eventos, h.cfg.Server.BaseURL)))
+}
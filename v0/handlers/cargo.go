@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"v0/models"
+	"v0/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+// CargoHandler handles the roles and permissions admin requests
+type CargoHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	logService *services.LogService
+}
+
+// NewCargoHandler creates a new CargoHandler
+func NewCargoHandler(db *sql.DB, store sessions.Store) *CargoHandler {
+	return &CargoHandler{
+		db:         db,
+		store:      store,
+		logService: services.NewLogService(db),
+	}
+}
+
+// cargoItem is a cargo with its permissions for the admin page
+type cargoItem struct {
+	models.Cargo
+	Permissoes models.Permissoes
+	Users      int
+}
+
+// List handles the roles admin page
+func (h *CargoHandler) List(w http.ResponseWriter, r *http.Request) {
+	cargos, err := models.GetAllCargos(h.db)
+	if err != nil {
+		log.Printf("Error fetching cargos: %v", err)
+		http.Error(w, "Erro ao buscar cargos", http.StatusInternalServerError)
+		return
+	}
+
+	permissoes, err := models.GetPermissoes(h.db)
+	if err != nil {
+		log.Printf("Error fetching permissions: %v", err)
+		http.Error(w, "Erro ao buscar permissões", http.StatusInternalServerError)
+		return
+	}
+
+	var items []cargoItem
+	for _, c := range cargos {
+		item := cargoItem{Cargo: c}
+		if item.Permissoes, err = models.GetCargoPermissoes(h.db, c.ID); err != nil {
+			log.Printf("Error fetching cargo permissions: %v", err)
+			http.Error(w, "Erro ao buscar permissões", http.StatusInternalServerError)
+			return
+		}
+		if item.Users, err = models.CountCargoUsers(h.db, c.ID); err != nil {
+			log.Printf("Error counting cargo users: %v", err)
+			http.Error(w, "Erro ao buscar cargos", http.StatusInternalServerError)
+			return
+		}
+		items = append(items, item)
+	}
+
+	// Render template
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/admin/cargos/list.html"))
+	data := struct {
+		Title      string
+		User       interface{}
+		Cargos     []cargoItem
+		Permissoes []models.Permissao
+	}{
+		Title:      "Cargos e Permissões",
+		User:       getSessionUser(h.db, h.store, r),
+		Cargos:     items,
+		Permissoes: permissoes,
+	}
+	tmpl.Execute(w, data)
+}
+
+// Save handles the create cargo form submission
+func (h *CargoHandler) Save(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if err := r.ParseForm(); err != nil {
+		log.Printf("Form parse error: %v", err)
+		http.Error(w, "Erro ao processar formulário", http.StatusBadRequest)
+		return
+	}
+
+	descricao := strings.TrimSpace(r.FormValue("descricao"))
+	if descricao == "" {
+		http.Error(w, "Descrição obrigatória", http.StatusBadRequest)
+		return
+	}
+
+	id, err := models.CreateCargo(h.db, descricao)
+	if err != nil {
+		log.Printf("Error creating cargo: %v", err)
+		http.Error(w, "Erro ao criar cargo", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.SetCargoPermissoes(h.db, id, r.Form["permissoes"]); err != nil {
+		log.Printf("Error saving cargo permissions: %v", err)
+		http.Error(w, "Erro ao guardar permissões", http.StatusInternalServerError)
+		return
+	}
+
+	exige2FA := r.FormValue("exige_2fa") != ""
+	if err := models.SetCargoExige2FA(h.db, id, exige2FA); err != nil {
+		log.Printf("Error saving cargo 2FA: %v", err)
+		http.Error(w, "Erro ao guardar cargo", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the create action
+	h.logService.LogCreate(adminID, "cargo", map[string]interface{}{
+		"id_cargo":   id,
+		"descricao":  descricao,
+		"permissoes": r.Form["permissoes"],
+		"exige_2fa":  exige2FA,
+	})
+
+	http.Redirect(w, r, "/admin/cargos", http.StatusSeeOther)
+}
+
+// UpdatePermissoes handles saving the permissions of a cargo
+func (h *CargoHandler) UpdatePermissoes(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID do cargo inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user info from session
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+	cargoID, _ := session.Values["cargo"].(int)
+
+	if err := r.ParseForm(); err != nil {
+		log.Printf("Form parse error: %v", err)
+		http.Error(w, "Erro ao processar formulário", http.StatusBadRequest)
+		return
+	}
+	codigos := r.Form["permissoes"]
+
+	// Admins cannot lock themselves out of this page
+	if id == cargoID && !slices.Contains(codigos, models.PermRoleManage) {
+		http.Error(w, "Não pode retirar a gestão de cargos ao seu próprio cargo", http.StatusBadRequest)
+		return
+	}
+
+	old, err := models.GetCargoPermissoes(h.db, id)
+	if err != nil {
+		log.Printf("Error fetching cargo permissions: %v", err)
+		http.Error(w, "Erro ao buscar permissões", http.StatusInternalServerError)
+		return
+	}
+
+	oldExige2FA, err := models.CargoExige2FA(h.db, id)
+	if err != nil {
+		log.Printf("Error fetching cargo 2FA: %v", err)
+		http.Error(w, "Erro ao buscar cargo", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.SetCargoPermissoes(h.db, id, codigos); err != nil {
+		log.Printf("Error saving cargo permissions: %v", err)
+		http.Error(w, "Erro ao guardar permissões", http.StatusInternalServerError)
+		return
+	}
+
+	exige2FA := r.FormValue("exige_2fa") != ""
+	if err := models.SetCargoExige2FA(h.db, id, exige2FA); err != nil {
+		log.Printf("Error saving cargo 2FA: %v", err)
+		http.Error(w, "Erro ao guardar cargo", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the update action
+	h.logService.LogUpdate(adminID, "cargo_permissao",
+		map[string]interface{}{"id_cargo": id, "permissoes": old, "exige_2fa": oldExige2FA},
+		map[string]interface{}{"id_cargo": id, "permissoes": codigos, "exige_2fa": exige2FA})
+
+	http.Redirect(w, r, "/admin/cargos", http.StatusSeeOther)
+}
+
+// Delete handles the delete cargo request; only cargos without users can be deleted
+func (h *CargoHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID do cargo inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	users, err := models.CountCargoUsers(h.db, id)
+	if err != nil {
+		log.Printf("Error counting cargo users: %v", err)
+		http.Error(w, "Erro ao excluir cargo", http.StatusInternalServerError)
+		return
+	}
+	if users > 0 {
+		http.Error(w, "O cargo tem users associados", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteCargo(h.db, id); err != nil {
+		log.Printf("Error deleting cargo: %v", err)
+		http.Error(w, "Erro ao excluir cargo", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the delete action
+	h.logService.LogDelete(adminID, "cargo", map[string]interface{}{
+		"id_cargo": id,
+	})
+
+	http.Redirect(w, r, "/admin/cargos", http.StatusSeeOther)
+}
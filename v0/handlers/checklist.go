@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"v0/database"
+	"v0/models"
+	"v0/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+// ChecklistHandler handles submission checklist requests
+type ChecklistHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	logService *services.LogService
+}
+
+// NewChecklistHandler creates a new ChecklistHandler
+func NewChecklistHandler(db *sql.DB, store sessions.Store) *ChecklistHandler {
+	return &ChecklistHandler{
+		db:         db,
+		store:      store,
+		logService: services.NewLogService(db),
+	}
+}
+
+// Toggle handles ticking or unticking a checklist item of a concurso. The form sends the state the user asked
+// for, so a resubmitted or stale form cannot undo what someone else just ticked
+func (h *ChecklistHandler) Toggle(w http.ResponseWriter, r *http.Request) {
+	// Get IDs from URL
+	vars := mux.Vars(r)
+	concursoID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "ID do concurso inválido", http.StatusBadRequest)
+		return
+	}
+
+	itemID, err := strconv.Atoi(vars["item"])
+	if err != nil {
+		http.Error(w, "ID do item inválido", http.StatusBadRequest)
+		return
+	}
+
+	concluido, err := strconv.ParseBool(r.FormValue("concluido"))
+	if err != nil {
+		http.Error(w, "Estado do item inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from session
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+
+	// Make sure the concurso is visible to the user
+	if _, err := models.GetConcursoByID(h.db, vars["id"], getVisibilidade(h.db, h.store, r)); err != nil {
+		http.Error(w, "Concurso não encontrado", http.StatusNotFound)
+		return
+	}
+
+	// Get the item and make sure it belongs to the concurso
+	item, err := models.GetChecklistItem(h.db, itemID)
+	if err != nil || item.ConcursoID != concursoID {
+		http.Error(w, "Item da checklist não encontrado", http.StatusNotFound)
+		return
+	}
+
+	// Already in the state asked for, so there is nothing to change or log
+	if item.Concluido == concluido {
+		http.Redirect(w, r, "/concursos/"+vars["id"]+"#checklist", http.StatusSeeOther)
+		return
+	}
+
+	if err := models.SetChecklistItemConcluido(h.db, itemID, concluido, userID); err != nil {
+		log.Printf("Error updating checklist item: %v", err)
+		http.Error(w, "Erro ao atualizar checklist", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the checklist action
+	acao := "concluir"
+	if !concluido {
+		acao = "reabrir"
+	}
+	if err := h.logService.LogAction(userID, "checklist_item", acao, nil, map[string]interface{}{
+		"concurso_id": concursoID,
+		"item_id":     itemID,
+		"descricao":   item.Descricao,
+	}); err != nil {
+		log.Printf("Error logging checklist action: %v", err)
+	}
+
+	http.Redirect(w, r, "/concursos/"+vars["id"]+"#checklist", http.StatusSeeOther)
+}
+
+// Modelos handles the checklist templates admin page
+func (h *ChecklistHandler) Modelos(w http.ResponseWriter, r *http.Request) {
+	modelos, err := models.GetChecklistModelos(h.db)
+	if err != nil {
+		log.Printf("Error fetching checklist templates: %v", err)
+		http.Error(w, "Erro ao buscar modelos de checklist", http.StatusInternalServerError)
+		return
+	}
+
+	tipos, err := database.GetTipos(h.db)
+	if err != nil {
+		log.Printf("Error fetching tipos: %v", err)
+		http.Error(w, "Erro ao buscar tipos", http.StatusInternalServerError)
+		return
+	}
+
+	// Render template
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/admin/checklists/list.html"))
+	data := struct {
+		Title   string
+		User    interface{}
+		Modelos []models.ChecklistModelo
+		Tipos   []struct {
+			ID        int
+			Descricao string
+		}
+	}{
+		Title:   "Modelos de Checklist",
+		User:    getSessionUser(h.db, h.store, r),
+		Modelos: modelos,
+		Tipos:   tipos,
+	}
+	tmpl.Execute(w, data)
+}
+
+// SaveModelo handles the create checklist template form submission
+func (h *ChecklistHandler) SaveModelo(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if err := r.ParseForm(); err != nil {
+		log.Printf("Form parse error: %v", err)
+		http.Error(w, "Erro ao processar formulário", http.StatusBadRequest)
+		return
+	}
+
+	tipoID, err := strconv.Atoi(r.FormValue("tipo_id"))
+	if err != nil {
+		http.Error(w, "Tipo inválido", http.StatusBadRequest)
+		return
+	}
+
+	// The order is optional and comes first when left empty
+	ordem := 0
+	if valor := strings.TrimSpace(r.FormValue("ordem")); valor != "" {
+		ordem, err = strconv.Atoi(valor)
+		if err != nil || ordem < 0 {
+			http.Error(w, "Ordem inválida", http.StatusBadRequest)
+			return
+		}
+	}
+
+	descricao := strings.TrimSpace(r.FormValue("descricao"))
+	if descricao == "" {
+		http.Error(w, "Descrição obrigatória", http.StatusBadRequest)
+		return
+	}
+
+	modelo := &models.ChecklistModelo{
+		TipoID:    tipoID,
+		Descricao: descricao,
+		Ordem:     ordem,
+	}
+
+	if err := models.CreateChecklistModelo(h.db, modelo); err != nil {
+		log.Printf("Error creating checklist template: %v", err)
+		http.Error(w, "Erro ao criar modelo de checklist", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the create action
+	h.logService.LogCreate(adminID, "checklist_modelo", modelo)
+
+	http.Redirect(w, r, "/admin/checklists", http.StatusSeeOther)
+}
+
+// DeleteModelo handles the delete checklist template request
+func (h *ChecklistHandler) DeleteModelo(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "ID do modelo inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if err := models.DeleteChecklistModelo(h.db, id); err != nil {
+		log.Printf("Error deleting checklist template: %v", err)
+		http.Error(w, "Erro ao excluir modelo de checklist", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the delete action
+	h.logService.LogDelete(adminID, "checklist_modelo", map[string]interface{}{
+		"id_modelo": id,
+	})
+
+	http.Redirect(w, r, "/admin/checklists", http.StatusSeeOther)
+}
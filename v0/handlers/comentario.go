@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"v0/config"
+	"v0/models"
+	"v0/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+// ComentarioHandler handles concurso comment requests
+type ComentarioHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	cfg        *config.Config
+	logService *services.LogService
+	mentions   *services.MentionService
+}
+
+// NewComentarioHandler creates a new ComentarioHandler
+func NewComentarioHandler(db *sql.DB, store sessions.Store, cfg *config.Config) *ComentarioHandler {
+	return &ComentarioHandler{
+		db:         db,
+		store:      store,
+		cfg:        cfg,
+		logService: services.NewLogService(db),
+		mentions:   services.NewMentionService(db),
+	}
+}
+
+// Create handles the new comment form submission
+func (h *ComentarioHandler) Create(w http.ResponseWriter, r *http.Request) {
+	// Get concurso ID from URL
+	vars := mux.Vars(r)
+	concursoID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "ID do concurso inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from session
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+
+	concurso, err := models.GetConcursoByID(h.db, vars["id"], getVisibilidade(h.db, h.store, r))
+	if err != nil {
+		http.Error(w, "Concurso não encontrado", http.StatusNotFound)
+		return
+	}
+
+	texto := strings.TrimSpace(r.FormValue("texto"))
+	if texto == "" {
+		http.Error(w, "O comentário não pode estar vazio", http.StatusBadRequest)
+		return
+	}
+
+	comentario := &models.Comentario{
+		ConcursoID: concursoID,
+		UserID:     sql.NullInt64{Int64: int64(userID), Valid: userID > 0},
+		Texto:      texto,
+	}
+
+	if err := models.CreateComentario(h.db, comentario); err != nil {
+		log.Printf("Error creating comment: %v", err)
+		http.Error(w, "Erro ao criar comentário", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the create action
+	if err := h.logService.LogCreate(userID, "comentario", map[string]interface{}{
+		"comentario_id": comentario.ID,
+		"concurso_id":   concursoID,
+		"texto":         texto,
+	}); err != nil {
+		log.Printf("Error logging comment: %v", err)
+	}
+
+	h.notifyMentions(userID, concurso, texto, models.ParseMentions(texto))
+
+	http.Redirect(w, r, fmt.Sprintf("/concursos/%d#comentario-%d", concursoID, comentario.ID), http.StatusSeeOther)
+}
+
+// Update handles the edit comment form submission
+func (h *ComentarioHandler) Update(w http.ResponseWriter, r *http.Request) {
+	comentario, userID, ok := h.getOwnComentario(w, r)
+	if !ok {
+		return
+	}
+
+	texto := strings.TrimSpace(r.FormValue("texto"))
+	if texto == "" {
+		http.Error(w, "O comentário não pode estar vazio", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.UpdateComentario(h.db, comentario.ID, texto); err != nil {
+		log.Printf("Error updating comment: %v", err)
+		http.Error(w, "Erro ao atualizar comentário", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the update action
+	if err := h.logService.LogUpdate(userID, "comentario",
+		map[string]interface{}{
+			"comentario_id": comentario.ID,
+			"concurso_id":   comentario.ConcursoID,
+			"texto":         comentario.Texto,
+		},
+		map[string]interface{}{
+			"comentario_id": comentario.ID,
+			"concurso_id":   comentario.ConcursoID,
+			"texto":         texto,
+		},
+	); err != nil {
+		log.Printf("Error logging comment update: %v", err)
+	}
+
+	// Only notify users that were not already mentioned before the edit
+	previous := make(map[string]bool)
+	for _, nome := range models.ParseMentions(comentario.Texto) {
+		previous[nome] = true
+	}
+	var added []string
+	for _, nome := range models.ParseMentions(texto) {
+		if !previous[nome] {
+			added = append(added, nome)
+		}
+	}
+
+	if len(added) > 0 {
+		concurso, err := models.GetConcursoByID(h.db, strconv.Itoa(comentario.ConcursoID), models.VisibilidadeTotal)
+		if err == nil {
+			h.notifyMentions(userID, concurso, texto, added)
+		}
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/concursos/%d#comentario-%d", comentario.ConcursoID, comentario.ID), http.StatusSeeOther)
+}
+
+// Delete handles the delete comment request
+func (h *ComentarioHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	comentario, userID, ok := h.getOwnComentario(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteComentario(h.db, comentario.ID); err != nil {
+		log.Printf("Error deleting comment: %v", err)
+		http.Error(w, "Erro ao excluir comentário", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the delete action
+	if err := h.logService.LogDelete(userID, "comentario", map[string]interface{}{
+		"comentario_id": comentario.ID,
+		"concurso_id":   comentario.ConcursoID,
+		"texto":         comentario.Texto,
+	}); err != nil {
+		log.Printf("Error logging comment delete: %v", err)
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/concursos/%d#comentarios", comentario.ConcursoID), http.StatusSeeOther)
+}
+
+// getOwnComentario loads the comment named in the URL and checks it belongs to the logged in user
+// and its concurso is still visible to them
+func (h *ComentarioHandler) getOwnComentario(w http.ResponseWriter, r *http.Request) (*models.Comentario, int, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "ID do comentário inválido", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	// Get user ID from session
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+
+	comentario, err := models.GetComentarioByID(h.db, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Comentário não encontrado", http.StatusNotFound)
+		return nil, 0, false
+	}
+	if err != nil {
+		log.Printf("Error fetching comment: %v", err)
+		http.Error(w, "Erro ao buscar comentário", http.StatusInternalServerError)
+		return nil, 0, false
+	}
+
+	if !comentario.UserID.Valid || int(comentario.UserID.Int64) != userID {
+		http.Error(w, "Só pode alterar os seus próprios comentários", http.StatusForbidden)
+		return nil, 0, false
+	}
+
+	_, err = models.GetConcursoByID(h.db, strconv.Itoa(comentario.ConcursoID), getVisibilidade(h.db, h.store, r))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Comentário não encontrado", http.StatusNotFound)
+		return nil, 0, false
+	}
+	if err != nil {
+		log.Printf("Error fetching concurso: %v", err)
+		http.Error(w, "Erro ao buscar concurso", http.StatusInternalServerError)
+		return nil, 0, false
+	}
+
+	return comentario, userID, true
+}
+
+// notifyMentions emails the mentioned users who can see the concurso, except the author and the users who
+// turned it off
+func (h *ComentarioHandler) notifyMentions(autorID int, concurso *models.Concurso, texto string, nomes []string) {
+	users, err := h.mentions.Recipients(autorID, concurso.ID, nomes)
+	if err != nil {
+		log.Printf("Error fetching mentioned users: %v", err)
+		return
+	}
+	if len(users) == 0 {
+		return
+	}
+
+	autor := "Um utilizador"
+	if user, err := models.GetUserByID(h.db, autorID); err == nil {
+		autor = user.Nome
+	}
+
+	link := fmt.Sprintf("%s/concursos/%d#comentarios", h.cfg.Server.BaseURL, concurso.ID)
+	emailService := services.NewEmailService(h.cfg.Email)
+	for _, user := range users {
+		if err := emailService.SendMentionEmail(user.Email, autor, concurso.Referencia, concurso.Entidade, texto, link); err != nil {
+			log.Printf("Error sending mention email: %v", err)
+		}
+	}
+}
@@ -2,19 +2,20 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
-	"sort"
+	"slices"
 	"strconv"
-	"text/template"
-	"time"
+	"strings"
 
 	"v0/config"
 	"v0/database"
-	"v0/middleware"
 	"v0/models"
 	"v0/services"
+	"v0/utils"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
@@ -22,19 +23,28 @@ import (
 
 // ConcursoHandler handles concurso-related requests
 type ConcursoHandler struct {
-	db         *sql.DB
-	store      *sessions.CookieStore
-	cfg        *config.Config
-	logService *services.LogService
+	db              *sql.DB
+	store           sessions.Store
+	cfg             *config.Config
+	logService      *services.LogService
+	workflowService *services.WorkflowService
+	deadlineService *services.DeadlineService
+	notificacoes    *services.NotificacaoService
+	storage         services.Storage
 }
 
 // NewConcursoHandler creates a new ConcursoHandler
-func NewConcursoHandler(db *sql.DB, store *sessions.CookieStore, cfg *config.Config) *ConcursoHandler {
+func NewConcursoHandler(db *sql.DB, store sessions.Store, cfg *config.Config, storage services.Storage) *ConcursoHandler {
+	logService := services.NewLogService(db)
 	return &ConcursoHandler{
-		db:         db,
-		store:      store,
-		cfg:        cfg,
-		logService: services.NewLogService(db),
+		db:              db,
+		store:           store,
+		cfg:             cfg,
+		logService:      logService,
+		workflowService: services.NewWorkflowService(db, logService, services.NewEmailService(cfg.Email)),
+		deadlineService: services.NewDeadlineService(db),
+		notificacoes:    services.NewNotificacaoService(db),
+		storage:         storage,
 	}
 }
 
@@ -44,53 +54,46 @@ func (h *ConcursoHandler) List(w http.ResponseWriter, r *http.Request) {
 	entidade := r.URL.Query().Get("entidade")
 
 	// Get concursos from database
-	concursos, err := models.GetConcursos(h.db, entidade)
+	concursos, err := models.GetConcursos(h.db, entidade, getVisibilidade(h.db, h.store, r))
 	if err != nil {
 		log.Printf("Error fetching concursos: %v", err)
 		http.Error(w, "Erro ao buscar concursos", http.StatusInternalServerError)
 		return
 	}
 
-	// Format prices for display
+	// Get checklist progress of all concursos
+	progress, err := models.GetChecklistProgress(h.db)
+	if err != nil {
+		log.Printf("Error fetching checklist progress: %v", err)
+		http.Error(w, "Erro ao buscar checklists", http.StatusInternalServerError)
+		return
+	}
+
+	// Format prices and checklist progress for display
 	type ConcursoDisplay struct {
 		models.Concurso
 		PrecoFormatted string
+		Checklist      models.ChecklistProgress
+		ChecklistAviso bool
 	}
 
 	var concursosDisplay []ConcursoDisplay
 	for _, c := range concursos {
+		aviso := false
+		if c.DiaProposta.Valid {
+			dias := utils.CalculateDaysRemaining(c.DiaProposta.String)
+			aviso = progress[c.ID].NeedsWarning(dias, h.cfg.Checklist.AvisoDias)
+		}
+
 		concursosDisplay = append(concursosDisplay, ConcursoDisplay{
 			Concurso:       c,
 			PrecoFormatted: fmt.Sprintf("%.2f€", c.Preco),
+			Checklist:      progress[c.ID],
+			ChecklistAviso: aviso,
 		})
 	}
 
-	// Get user info from session
-	session, _ := h.store.Get(r, "session-name")
-	userID, _ := session.Values["user_id"].(int)
-	cargoID, _ := session.Values["cargo"].(int)
-
-	user := struct {
-		ID      int
-		Nome    string
-		CargoID int
-	}{
-		ID:      userID,
-		Nome:    "User", // Default name
-		CargoID: cargoID,
-	}
-
-	// Get actual user name if possible
-	if userID > 0 {
-		var nome string
-		err := h.db.QueryRow("SELECT nome FROM user WHERE id_user = ?", userID).Scan(&nome)
-		if err == nil {
-			user.Nome = nome
-		}
-	}
-
-	// Check if user can edit (Admin or SAV)
-	canEdit := cargoID == middleware.RoleAdmin || cargoID == middleware.RoleSAV
+	user := getSessionUser(h.db, h.store, r)
 
 	// Render template
 	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/concursos/list.html"))
@@ -98,12 +101,10 @@ func (h *ConcursoHandler) List(w http.ResponseWriter, r *http.Request) {
 		Title     string
 		User      interface{}
 		Concursos []ConcursoDisplay
-		CanEdit   bool
 	}{
 		Title:     "Lista de Concursos",
 		User:      user,
 		Concursos: concursosDisplay,
-		CanEdit:   canEdit,
 	}
 	tmpl.Execute(w, data)
 }
@@ -119,7 +120,12 @@ func (h *ConcursoHandler) Edit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get concurso from database
-	concurso, err := models.GetConcursoByID(h.db, id)
+	vis := getVisibilidade(h.db, h.store, r)
+	concurso, err := models.GetConcursoByID(h.db, id, vis)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Concurso não encontrado", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		log.Printf("Error fetching concurso: %v", err)
 		http.Error(w, "Erro ao buscar concurso", http.StatusInternalServerError)
@@ -165,28 +171,30 @@ func (h *ConcursoHandler) Edit(w http.ResponseWriter, r *http.Request) {
 
 	// Get user info from session
 	session, _ := h.store.Get(r, "session-name")
-	userID, _ := session.Values["user_id"].(int)
 	cargoID, _ := session.Values["cargo"].(int)
 
-	user := struct {
-		ID      int
-		Nome    string
-		CargoID int
-	}{
-		ID:      userID,
-		Nome:    "User", // Default name
-		CargoID: cargoID,
+	// Only offer the estados reachable from the current one
+	estados, err = h.filterEstados(estados, concurso.EstadoID, cargoID)
+	if err != nil {
+		log.Printf("Error fetching estado transitions: %v", err)
+		http.Error(w, "Erro ao buscar transições de estado", http.StatusInternalServerError)
+		return
 	}
 
-	// Get actual user name if possible
-	if userID > 0 {
-		var nome string
-		err := h.db.QueryRow("SELECT nome FROM user WHERE id_user = ?", userID).Scan(&nome)
-		if err == nil {
-			user.Nome = nome
-		}
+	equipas, err := models.GetEquipasDisponiveis(h.db, vis)
+	if err != nil {
+		log.Printf("Error fetching equipas: %v", err)
+		http.Error(w, "Erro ao buscar equipas", http.StatusInternalServerError)
+		return
 	}
 
+	// Keep the current team selectable even when the user is not one of its members
+	if concurso.EquipaID > 0 && !slices.ContainsFunc(equipas, func(e models.Equipa) bool { return e.ID == concurso.EquipaID }) {
+		equipas = append(equipas, models.Equipa{ID: concurso.EquipaID, Nome: concurso.EquipaDesc})
+	}
+
+	user := getSessionUser(h.db, h.store, r)
+
 	// Prepare data for template
 	data := struct {
 		Title    string
@@ -208,7 +216,9 @@ func (h *ConcursoHandler) Edit(w http.ResponseWriter, r *http.Request) {
 			ID        int
 			Descricao string
 		}
-		Emails []string
+		Emails    []string
+		Equipas   []models.Equipa
+		SemEquipa bool
 	}{
 		Title:       "Editar Concurso",
 		User:        user,
@@ -218,6 +228,8 @@ func (h *ConcursoHandler) Edit(w http.ResponseWriter, r *http.Request) {
 		Estados:     estados,
 		Resultados:  resultados,
 		Emails:      emails,
+		Equipas:     equipas,
+		SemEquipa:   vis.Todos || concurso.EquipaID == 0,
 	}
 
 	// Render template
@@ -238,9 +250,15 @@ func (h *ConcursoHandler) Update(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from session
 	session, _ := h.store.Get(r, "session-name")
 	userID, _ := session.Values["user_id"].(int)
+	cargoID, _ := session.Values["cargo"].(int)
 
 	// Get original concurso for logging
-	oldConcurso, err := models.GetConcursoByID(h.db, id)
+	vis := getVisibilidade(h.db, h.store, r)
+	oldConcurso, err := models.GetConcursoByID(h.db, id, vis)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Concurso não encontrado", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		log.Printf("Error fetching original concurso: %v", err)
 		http.Error(w, "Erro ao buscar concurso original", http.StatusInternalServerError)
@@ -285,6 +303,11 @@ func (h *ConcursoHandler) Update(w http.ResponseWriter, r *http.Request) {
 			resultadoID = 1 // Default to empty if invalid
 		}
 
+		equipaID, _ := strconv.Atoi(r.FormValue("equipa_id"))
+		if !h.checkEquipa(w, vis, equipaID, oldConcurso.EquipaID) {
+			return
+		}
+
 		// Create concurso object
 		concurso := &models.Concurso{
 			ID:            oldConcurso.ID,
@@ -308,102 +331,13 @@ func (h *ConcursoHandler) Update(w http.ResponseWriter, r *http.Request) {
 			Link:          r.FormValue("link"),
 			Adjudicatario: r.FormValue("adjudicatario"),
 			ResultadoID:   resultadoID,
+			EquipaID:      equipaID,
 		}
 
-		// Update concurso in database
-		if err := models.UpdateConcurso(h.db, id, concurso); err != nil {
-			log.Printf("Error updating concurso: %v", err)
-			http.Error(w, "Erro ao atualizar concurso", http.StatusInternalServerError)
+		if !h.applyUpdate(w, userID, cargoID, id, oldConcurso, concurso) {
 			return
 		}
 
-		// Log the update action
-		if err := h.logService.LogUpdate(userID, "concurso", oldConcurso, concurso); err != nil {
-			log.Printf("Error logging update: %v", err)
-		}
-
-		// Get tipo description for email
-		var tipoDesc string
-		rows, err := h.db.Query("SELECT descricao FROM tipo WHERE id_tipo = ?", tipoID)
-		if err == nil {
-			defer rows.Close()
-			if rows.Next() {
-				rows.Scan(&tipoDesc)
-			}
-		}
-
-		// Get estado description for email
-		var estadoDesc string
-		rows, err = h.db.Query("SELECT descricao FROM estado WHERE id_estado = ?", estadoID)
-		if err == nil {
-			defer rows.Close()
-			if rows.Next() {
-				rows.Scan(&estadoDesc)
-			}
-		}
-
-		// Get resultado description for email
-		var resultadoDesc string
-		rows, err = h.db.Query("SELECT descricao FROM resultado WHERE id_resultado = ?", resultadoID)
-		if err == nil {
-			defer rows.Close()
-			if rows.Next() {
-				rows.Scan(&resultadoDesc)
-			}
-		}
-
-		// Send update email to DCP and SAV
-		emailService := services.NewEmailService(h.cfg.Email)
-		err = emailService.SendUpdateEmail(
-			concurso.Referencia,
-			concurso.Entidade,
-			tipoDesc,
-			estadoDesc,
-			concurso.DiaErro.NullString.String,
-			concurso.HoraErro.NullString.String,
-			concurso.DiaProposta.NullString.String,
-			concurso.HoraProposta.NullString.String,
-			concurso.DiaAudiencia.NullString.String,
-			concurso.HoraAudiencia.NullString.String,
-			concurso.Preliminar,
-			concurso.Final,
-			concurso.Recurso,
-			concurso.Impugnacao,
-			resultadoDesc,
-			concurso.Link,
-			h.db,
-		)
-		if err != nil {
-			log.Printf("Error sending email to DCP and SAV: %v", err)
-		}
-
-		// Check if adjudicatario was added or changed
-		if concurso.Adjudicatario != "" && concurso.Adjudicatario != oldConcurso.Adjudicatario {
-			// Send email to adjudicatario
-			err = emailService.SendAdjudicatarioEmail(
-				concurso.Adjudicatario,
-				concurso.Referencia,
-				concurso.Entidade,
-				tipoDesc,
-				estadoDesc,
-				concurso.DiaErro.NullString.String,
-				concurso.HoraErro.NullString.String,
-				concurso.DiaProposta.NullString.String,
-				concurso.HoraProposta.NullString.String,
-				concurso.DiaAudiencia.NullString.String,
-				concurso.HoraAudiencia.NullString.String,
-				concurso.Preliminar,
-				concurso.Final,
-				concurso.Recurso,
-				concurso.Impugnacao,
-				resultadoDesc,
-				concurso.Link,
-			)
-			if err != nil {
-				log.Printf("Error sending email to adjudicatario: %v", err)
-			}
-		}
-
 		// Redirect to concursos list
 		http.Redirect(w, r, "/concursos", http.StatusSeeOther)
 	}
@@ -450,28 +384,26 @@ func (h *ConcursoHandler) Create(w http.ResponseWriter, r *http.Request) {
 
 	// Get user info from session
 	session, _ := h.store.Get(r, "session-name")
-	userID, _ := session.Values["user_id"].(int)
 	cargoID, _ := session.Values["cargo"].(int)
 
-	user := struct {
-		ID      int
-		Nome    string
-		CargoID int
-	}{
-		ID:      userID,
-		Nome:    "User", // Default name
-		CargoID: cargoID,
+	// Only offer the estados reachable from the current one
+	estados, err = h.filterEstados(estados, models.EstadoInicial, cargoID)
+	if err != nil {
+		log.Printf("Error fetching estado transitions: %v", err)
+		http.Error(w, "Erro ao buscar transições de estado", http.StatusInternalServerError)
+		return
 	}
 
-	// Get actual user name if possible
-	if userID > 0 {
-		var nome string
-		err := h.db.QueryRow("SELECT nome FROM user WHERE id_user = ?", userID).Scan(&nome)
-		if err == nil {
-			user.Nome = nome
-		}
+	vis := getVisibilidade(h.db, h.store, r)
+	equipas, err := models.GetEquipasDisponiveis(h.db, vis)
+	if err != nil {
+		log.Printf("Error fetching equipas: %v", err)
+		http.Error(w, "Erro ao buscar equipas", http.StatusInternalServerError)
+		return
 	}
 
+	user := getSessionUser(h.db, h.store, r)
+
 	// Prepare data for template
 	data := struct {
 		Title string
@@ -492,7 +424,9 @@ func (h *ConcursoHandler) Create(w http.ResponseWriter, r *http.Request) {
 			ID        int
 			Descricao string
 		}
-		Emails []string
+		Emails    []string
+		Equipas   []models.Equipa
+		SemEquipa bool
 	}{
 		Title:       "Criar Concurso",
 		User:        user,
@@ -501,6 +435,8 @@ func (h *ConcursoHandler) Create(w http.ResponseWriter, r *http.Request) {
 		Estados:     estados,
 		Resultados:  resultados,
 		Emails:      emails,
+		Equipas:     equipas,
+		SemEquipa:   vis.Todos,
 	}
 
 	// Render template
@@ -513,6 +449,7 @@ func (h *ConcursoHandler) Save(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from session
 	session, _ := h.store.Get(r, "session-name")
 	userID, _ := session.Values["user_id"].(int)
+	cargoID, _ := session.Values["cargo"].(int)
 
 	// Process form
 	if r.Method == http.MethodPost {
@@ -577,6 +514,11 @@ func (h *ConcursoHandler) Save(w http.ResponseWriter, r *http.Request) {
 			resultadoID = 1 // Default to empty if not provided
 		}
 
+		equipaID, _ := strconv.Atoi(r.FormValue("equipa_id"))
+		if !h.checkEquipa(w, getVisibilidade(h.db, h.store, r), equipaID, -1) {
+			return
+		}
+
 		// Create concurso object
 		concurso := &models.Concurso{
 			Preco:         preco,
@@ -599,6 +541,15 @@ func (h *ConcursoHandler) Save(w http.ResponseWriter, r *http.Request) {
 			Link:          r.FormValue("link"),
 			Adjudicatario: r.FormValue("adjudicatario"),
 			ResultadoID:   resultadoID,
+			EquipaID:      equipaID,
+		}
+
+		// Validate the initial estado as a transition from the empty estado
+		initial := &models.Concurso{EstadoID: models.EstadoInicial}
+		transicao, err := h.workflowService.Validate(initial, concurso, cargoID)
+		if err != nil {
+			h.transitionError(w, err)
+			return
 		}
 
 		// Create concurso in database
@@ -613,6 +564,16 @@ func (h *ConcursoHandler) Save(w http.ResponseWriter, r *http.Request) {
 			log.Printf("Error logging create: %v", err)
 		}
 
+		// Instantiate the submission checklist of the tipo
+		if err := models.CreateChecklistFromModelo(h.db, concurso.ID, concurso.TipoID); err != nil {
+			log.Printf("Error creating checklist: %v", err)
+		}
+
+		// Log and notify the initial estado transition
+		if transicao != nil {
+			h.workflowService.Record(userID, transicao, initial, concurso)
+		}
+
 		// Get tipo description for email
 		var tipoDesc string
 		rows, err := h.db.Query("SELECT descricao FROM tipo WHERE id_tipo = ?", tipoID)
@@ -643,8 +604,12 @@ func (h *ConcursoHandler) Save(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		// Send update email to DCP and SAV
+		// Send update email to the subscribed users who can see the concurso
 		emailService := services.NewEmailService(h.cfg.Email)
+		recipients, err := h.notificacoes.Recipients(concurso.ID)
+		if err != nil {
+			log.Printf("Error fetching update email recipients: %v", err)
+		}
 		err = emailService.SendUpdateEmail(
 			concurso.Referencia,
 			concurso.Entidade,
@@ -662,10 +627,10 @@ func (h *ConcursoHandler) Save(w http.ResponseWriter, r *http.Request) {
 			concurso.Impugnacao,
 			resultadoDesc,
 			concurso.Link,
-			h.db,
+			recipients,
 		)
 		if err != nil {
-			log.Printf("Error sending email to DCP and SAV: %v", err)
+			log.Printf("Error sending update email: %v", err)
 		}
 
 		// Check if adjudicatario was added
@@ -715,13 +680,25 @@ func (h *ConcursoHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	userID, _ := session.Values["user_id"].(int)
 
 	// Get original concurso for logging
-	oldConcurso, err := models.GetConcursoByID(h.db, id)
+	oldConcurso, err := models.GetConcursoByID(h.db, id, getVisibilidade(h.db, h.store, r))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Concurso não encontrado", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		log.Printf("Error fetching concurso for deletion: %v", err)
 		http.Error(w, "Erro ao buscar concurso", http.StatusInternalServerError)
 		return
 	}
 
+	// The attachment rows are deleted with the concurso, so their files are looked up first
+	anexos, err := models.GetAnexos(h.db, oldConcurso.ID)
+	if err != nil {
+		log.Printf("Error fetching attachments for deletion: %v", err)
+		http.Error(w, "Erro ao excluir concurso", http.StatusInternalServerError)
+		return
+	}
+
 	// Delete concurso
 	if err := models.DeleteConcurso(h.db, id); err != nil {
 		log.Printf("Error deleting concurso: %v", err)
@@ -729,6 +706,12 @@ func (h *ConcursoHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for _, anexo := range anexos {
+		if err := h.storage.Delete(anexo.Chave); err != nil {
+			log.Printf("Error deleting attachment file: %v", err)
+		}
+	}
+
 	// Log the delete action
 	if err := h.logService.LogDelete(userID, "concurso", oldConcurso); err != nil {
 		log.Printf("Error logging delete: %v", err)
@@ -740,61 +723,47 @@ func (h *ConcursoHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 // ListOrdered handles the ordered concursos list page
 func (h *ConcursoHandler) ListOrdered(w http.ResponseWriter, r *http.Request) {
-	// Get current date and time
-	now := time.Now()
+	// Get current date and time in the deadlines timezone
+	now := utils.Now()
 
-	// Get future concursos from database
-	items, err := models.GetFutureConcursos(h.db)
+	// Get future deadlines, earliest first
+	items, err := h.deadlineService.Upcoming(getVisibilidade(h.db, h.store, r))
 	if err != nil {
 		log.Printf("Error fetching future concursos: %v", err)
 		http.Error(w, "Erro ao buscar concursos futuros", http.StatusInternalServerError)
 		return
 	}
 
-	// Sort items by date and time
-	sort.Slice(items, func(i, j int) bool {
-		// Compare first by date
-		if items[i].Data != items[j].Data {
-			return items[i].Data < items[j].Data
-		}
-		// If date is equal, compare by time
-		return items[i].Hora < items[j].Hora
-	})
-
-	// Get user info from session
-	session, _ := h.store.Get(r, "session-name")
-	userID, _ := session.Values["user_id"].(int)
-	cargoID, _ := session.Values["cargo"].(int)
+	// Get checklist progress of all concursos
+	progress, err := models.GetChecklistProgress(h.db)
+	if err != nil {
+		log.Printf("Error fetching checklist progress: %v", err)
+		http.Error(w, "Erro ao buscar checklists", http.StatusInternalServerError)
+		return
+	}
 
-	user := struct {
-		ID      int
-		Nome    string
-		CargoID int
-	}{
-		ID:      userID,
-		Nome:    "User", // Default name
-		CargoID: cargoID,
+	// Attach checklist progress, warning on proposta deadlines with incomplete checklists
+	type OrderedItem struct {
+		models.ConcursoItem
+		Checklist      models.ChecklistProgress
+		ChecklistAviso bool
 	}
 
-	// Get actual user name if possible
-	if userID > 0 {
-		var nome string
-		err := h.db.QueryRow("SELECT nome FROM user WHERE id_user = ?", userID).Scan(&nome)
-		if err == nil {
-			user.Nome = nome
-		}
+	var orderedItems []OrderedItem
+	for _, item := range items {
+		p := progress[item.ID]
+		orderedItems = append(orderedItems, OrderedItem{
+			ConcursoItem:   item,
+			Checklist:      p,
+			ChecklistAviso: item.Tipo == "Proposta" && p.NeedsWarning(item.DiasRestantes, h.cfg.Checklist.AvisoDias),
+		})
 	}
 
+	user := getSessionUser(h.db, h.store, r)
+
 	// Register daysUntil function for use in template
 	funcMap := template.FuncMap{
-		"daysUntil": func(date string) int {
-			targetDate, err := time.Parse("2006-01-02", date)
-			if err != nil {
-				return -1
-			}
-			duration := targetDate.Sub(now)
-			return int(duration.Hours() / 24)
-		},
+		"daysUntil": utils.CalculateDaysRemaining,
 	}
 
 	// Load template with custom functions
@@ -809,12 +778,12 @@ func (h *ConcursoHandler) ListOrdered(w http.ResponseWriter, r *http.Request) {
 	data := struct {
 		Title       string
 		User        interface{}
-		Items       []models.ConcursoItem
+		Items       []OrderedItem
 		CurrentTime string
 	}{
 		Title:       "Concursos Futuros",
 		User:        user,
-		Items:       items,
+		Items:       orderedItems,
 		CurrentTime: now.Format("2006-01-02 15:04:05"),
 	}
 
@@ -825,3 +794,418 @@ func (h *ConcursoHandler) ListOrdered(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// filterEstados keeps only the estados the cargo can move a concurso in estadoID to
+func (h *ConcursoHandler) filterEstados(estados []struct {
+	ID        int
+	Descricao string
+}, estadoID int, cargoID int) ([]struct {
+	ID        int
+	Descricao string
+}, error) {
+	allowed, err := h.workflowService.AllowedEstados(estadoID, cargoID)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []struct {
+		ID        int
+		Descricao string
+	}
+	for _, e := range estados {
+		if allowed[e.ID] {
+			filtered = append(filtered, e)
+		}
+	}
+
+	return filtered, nil
+}
+
+// transitionError writes the HTTP error matching an estado transition validation error
+func (h *ConcursoHandler) transitionError(w http.ResponseWriter, err error) {
+	var missing *services.CamposEmFaltaError
+	switch {
+	case errors.Is(err, services.ErrTransicaoSemPermissao):
+		http.Error(w, "Sem permissão para esta transição de estado", http.StatusForbidden)
+	case errors.Is(err, services.ErrTransicaoNaoPermitida):
+		http.Error(w, "Transição de estado não permitida", http.StatusBadRequest)
+	case errors.As(err, &missing):
+		http.Error(w, "Campos obrigatórios em falta: "+strings.Join(missing.Labels(), ", "), http.StatusBadRequest)
+	default:
+		log.Printf("Error validating estado transition: %v", err)
+		http.Error(w, "Erro ao validar transição de estado", http.StatusInternalServerError)
+	}
+}
+
+// checkEquipa validates the team chosen for a concurso, writing an error response when it is not allowed.
+// Users who do not see every concurso must keep the current team or pick one of their own
+func (h *ConcursoHandler) checkEquipa(w http.ResponseWriter, vis models.Visibilidade, equipaID, atual int) bool {
+	if equipaID == atual {
+		return true
+	}
+
+	equipas, err := models.GetEquipasDisponiveis(h.db, vis)
+	if err != nil {
+		log.Printf("Error fetching equipas: %v", err)
+		http.Error(w, "Erro ao buscar equipas", http.StatusInternalServerError)
+		return false
+	}
+
+	if (equipaID == 0 && vis.Todos) || slices.ContainsFunc(equipas, func(e models.Equipa) bool { return e.ID == equipaID }) {
+		return true
+	}
+
+	http.Error(w, "Equipa inválida", http.StatusBadRequest)
+	return false
+}
+
+// View handles the concurso detail page
+func (h *ConcursoHandler) View(w http.ResponseWriter, r *http.Request) {
+	// Get concurso ID from URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+	if id == "" {
+		http.Error(w, "ID do concurso inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get concurso from database
+	concurso, err := models.GetConcursoByID(h.db, id, getVisibilidade(h.db, h.store, r))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Concurso não encontrado", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching concurso: %v", err)
+		http.Error(w, "Erro ao buscar concurso", http.StatusInternalServerError)
+		return
+	}
+
+	// Get checklist of the concurso
+	checklist, err := models.GetChecklistItems(h.db, concurso.ID)
+	if err != nil {
+		log.Printf("Error fetching checklist: %v", err)
+		http.Error(w, "Erro ao buscar checklist", http.StatusInternalServerError)
+		return
+	}
+
+	progress := models.ChecklistProgress{Total: len(checklist)}
+	for _, item := range checklist {
+		if item.Concluido {
+			progress.Concluidos++
+		}
+	}
+
+	aviso := false
+	if concurso.DiaProposta.Valid {
+		dias := utils.CalculateDaysRemaining(concurso.DiaProposta.String)
+		aviso = progress.NeedsWarning(dias, h.cfg.Checklist.AvisoDias)
+	}
+
+	// Get attachments of the concurso
+	anexos, err := models.GetAnexos(h.db, concurso.ID)
+	if err != nil {
+		log.Printf("Error fetching attachments: %v", err)
+		http.Error(w, "Erro ao buscar anexos", http.StatusInternalServerError)
+		return
+	}
+
+	// Get comments and history of the concurso
+	comentarios, err := models.GetComentarios(h.db, concurso.ID)
+	if err != nil {
+		log.Printf("Error fetching comments: %v", err)
+		http.Error(w, "Erro ao buscar comentários", http.StatusInternalServerError)
+		return
+	}
+
+	historico, err := h.logService.GetConcursoHistory(concurso.ID)
+	if err != nil {
+		log.Printf("Error fetching history: %v", err)
+		http.Error(w, "Erro ao buscar histórico", http.StatusInternalServerError)
+		return
+	}
+
+	user := getSessionUser(h.db, h.store, r)
+
+	// Explicit shares, managed by editors
+	var partilhas, users []models.User
+	if user.Pode(models.PermConcursoEdit) {
+		if partilhas, err = models.GetPartilhas(h.db, concurso.ID); err != nil {
+			log.Printf("Error fetching shares: %v", err)
+			http.Error(w, "Erro ao buscar partilhas", http.StatusInternalServerError)
+			return
+		}
+		if users, err = models.GetAllUsers(h.db); err != nil {
+			log.Printf("Error fetching users: %v", err)
+			http.Error(w, "Erro ao buscar utilizadores", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Comments are user input: escape them before highlighting mentions
+	funcMap := template.FuncMap{
+		"comentario": func(texto string) template.HTML {
+			return template.HTML(strings.ReplaceAll(models.HighlightMentions(template.HTMLEscapeString(texto)), "\n", "<br>"))
+		},
+		"isAutor": func(c models.Comentario) bool {
+			return c.UserID.Valid && int(c.UserID.Int64) == user.ID
+		},
+	}
+
+	// Render template
+	tmpl := template.Must(template.New("base.html").Funcs(funcMap).ParseFiles("templates/layout/base.html", "templates/concursos/view.html"))
+	data := struct {
+		Title          string
+		User           interface{}
+		Concurso       *models.Concurso
+		PrecoFormatted string
+		Checklist      []models.ChecklistItem
+		Progress       models.ChecklistProgress
+		ChecklistAviso bool
+		Anexos         []models.Anexo
+		Categorias     []string
+		Comentarios    []models.Comentario
+		Historico      []services.HistoricoEntry
+		Partilhas      []models.User
+		Users          []models.User
+	}{
+		Title:          "Concurso " + concurso.Referencia,
+		User:           user,
+		Concurso:       concurso,
+		PrecoFormatted: fmt.Sprintf("%.2f€", concurso.Preco),
+		Checklist:      checklist,
+		Progress:       progress,
+		ChecklistAviso: aviso,
+		Anexos:         anexos,
+		Categorias:     models.AnexoCategorias,
+		Comentarios:    comentarios,
+		Historico:      historico,
+		Partilhas:      partilhas,
+		Users:          users,
+	}
+	tmpl.Execute(w, data)
+}
+
+// applyUpdate validates the estado transition, saves the concurso, logs the changes and sends the notifications.
+// It writes the error response and returns false when the concurso was not updated.
+func (h *ConcursoHandler) applyUpdate(w http.ResponseWriter, userID, cargoID int, id string, oldConcurso, concurso *models.Concurso) bool {
+	// Validate the estado transition
+	transicao, err := h.workflowService.Validate(oldConcurso, concurso, cargoID)
+	if err != nil {
+		h.transitionError(w, err)
+		return false
+	}
+
+	// Update concurso in database
+	if err := models.UpdateConcurso(h.db, id, concurso); err != nil {
+		log.Printf("Error updating concurso: %v", err)
+		http.Error(w, "Erro ao atualizar concurso", http.StatusInternalServerError)
+		return false
+	}
+
+	// Log the update action
+	if err := h.logService.LogUpdate(userID, "concurso", oldConcurso, concurso); err != nil {
+		log.Printf("Error logging update: %v", err)
+	}
+
+	// Log and notify the estado transition
+	if transicao != nil {
+		h.workflowService.Record(userID, transicao, oldConcurso, concurso)
+	}
+
+	// Get tipo description for email
+	var tipoDesc string
+	rows, err := h.db.Query("SELECT descricao FROM tipo WHERE id_tipo = ?", concurso.TipoID)
+	if err == nil {
+		defer rows.Close()
+		if rows.Next() {
+			rows.Scan(&tipoDesc)
+		}
+	}
+
+	// Get estado description for email
+	var estadoDesc string
+	rows, err = h.db.Query("SELECT descricao FROM estado WHERE id_estado = ?", concurso.EstadoID)
+	if err == nil {
+		defer rows.Close()
+		if rows.Next() {
+			rows.Scan(&estadoDesc)
+		}
+	}
+
+	// Get resultado description for email
+	var resultadoDesc string
+	rows, err = h.db.Query("SELECT descricao FROM resultado WHERE id_resultado = ?", concurso.ResultadoID)
+	if err == nil {
+		defer rows.Close()
+		if rows.Next() {
+			rows.Scan(&resultadoDesc)
+		}
+	}
+
+	// Send update email to the subscribed users who can see the concurso
+	emailService := services.NewEmailService(h.cfg.Email)
+	recipients, err := h.notificacoes.Recipients(concurso.ID)
+	if err != nil {
+		log.Printf("Error fetching update email recipients: %v", err)
+	}
+	err = emailService.SendUpdateEmail(
+		concurso.Referencia,
+		concurso.Entidade,
+		tipoDesc,
+		estadoDesc,
+		concurso.DiaErro.NullString.String,
+		concurso.HoraErro.NullString.String,
+		concurso.DiaProposta.NullString.String,
+		concurso.HoraProposta.NullString.String,
+		concurso.DiaAudiencia.NullString.String,
+		concurso.HoraAudiencia.NullString.String,
+		concurso.Preliminar,
+		concurso.Final,
+		concurso.Recurso,
+		concurso.Impugnacao,
+		resultadoDesc,
+		concurso.Link,
+		recipients,
+	)
+	if err != nil {
+		log.Printf("Error sending update email: %v", err)
+	}
+
+	// Check if adjudicatario was added or changed
+	if concurso.Adjudicatario != "" && concurso.Adjudicatario != oldConcurso.Adjudicatario {
+		// Send email to adjudicatario
+		err = emailService.SendAdjudicatarioEmail(
+			concurso.Adjudicatario,
+			concurso.Referencia,
+			concurso.Entidade,
+			tipoDesc,
+			estadoDesc,
+			concurso.DiaErro.NullString.String,
+			concurso.HoraErro.NullString.String,
+			concurso.DiaProposta.NullString.String,
+			concurso.HoraProposta.NullString.String,
+			concurso.DiaAudiencia.NullString.String,
+			concurso.HoraAudiencia.NullString.String,
+			concurso.Preliminar,
+			concurso.Final,
+			concurso.Recurso,
+			concurso.Impugnacao,
+			resultadoDesc,
+			concurso.Link,
+		)
+		if err != nil {
+			log.Printf("Error sending email to adjudicatario: %v", err)
+		}
+	}
+
+	return true
+}
+
+// Board handles the concursos board page, with one column per estado
+func (h *ConcursoHandler) Board(w http.ResponseWriter, r *http.Request) {
+	estados, err := database.GetEstados(h.db)
+	if err != nil {
+		log.Printf("Error fetching estados: %v", err)
+		http.Error(w, "Erro ao buscar estados", http.StatusInternalServerError)
+		return
+	}
+
+	concursos, err := models.GetConcursos(h.db, "", getVisibilidade(h.db, h.store, r))
+	if err != nil {
+		log.Printf("Error fetching concursos: %v", err)
+		http.Error(w, "Erro ao buscar concursos", http.StatusInternalServerError)
+		return
+	}
+
+	// Group the concursos into a column per estado
+	type BoardCard struct {
+		ID            int
+		Referencia    string
+		Entidade      string
+		Adjudicatario string
+		Prazo         *models.Prazo
+	}
+
+	type BoardColumn struct {
+		ID        int
+		Descricao string
+		Cards     []BoardCard
+	}
+
+	columns := make([]BoardColumn, len(estados))
+	index := make(map[int]int)
+	for i, e := range estados {
+		columns[i] = BoardColumn{ID: e.ID, Descricao: e.Descricao}
+		index[e.ID] = i
+	}
+
+	for i := range concursos {
+		c := &concursos[i]
+		col, ok := index[c.EstadoID]
+		if !ok {
+			continue
+		}
+		columns[col].Cards = append(columns[col].Cards, BoardCard{
+			ID:            c.ID,
+			Referencia:    c.Referencia,
+			Entidade:      c.Entidade,
+			Adjudicatario: c.Adjudicatario,
+			Prazo:         c.ProximoPrazo(),
+		})
+	}
+
+	user := getSessionUser(h.db, h.store, r)
+
+	// Render template
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/concursos/board.html"))
+	data := struct {
+		Title   string
+		User    interface{}
+		Columns []BoardColumn
+	}{
+		Title:   "Quadro de Concursos",
+		User:    user,
+		Columns: columns,
+	}
+	tmpl.Execute(w, data)
+}
+
+// MoveEstado handles changing the estado of a concurso from the board
+func (h *ConcursoHandler) MoveEstado(w http.ResponseWriter, r *http.Request) {
+	// Get concurso ID from URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	estadoID, err := strconv.Atoi(r.FormValue("estado_id"))
+	if err != nil {
+		http.Error(w, "Estado inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user info from session
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+	cargoID, _ := session.Values["cargo"].(int)
+
+	oldConcurso, err := models.GetConcursoByID(h.db, id, getVisibilidade(h.db, h.store, r))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Concurso não encontrado", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching concurso: %v", err)
+		http.Error(w, "Erro ao buscar concurso", http.StatusInternalServerError)
+		return
+	}
+
+	// Same concurso with only the estado changed
+	concurso := *oldConcurso
+	concurso.EstadoID = estadoID
+
+	if !h.applyUpdate(w, userID, cargoID, id, oldConcurso, &concurso) {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"v0/models"
+	"v0/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+// EquipaHandler handles the teams admin requests and the sharing of concursos with users
+type EquipaHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	logService *services.LogService
+}
+
+// NewEquipaHandler creates a new EquipaHandler
+func NewEquipaHandler(db *sql.DB, store sessions.Store) *EquipaHandler {
+	return &EquipaHandler{
+		db:         db,
+		store:      store,
+		logService: services.NewLogService(db),
+	}
+}
+
+// List handles the teams admin page
+func (h *EquipaHandler) List(w http.ResponseWriter, r *http.Request) {
+	equipas, err := models.GetEquipas(h.db)
+	if err != nil {
+		log.Printf("Error fetching equipas: %v", err)
+		http.Error(w, "Erro ao buscar equipas", http.StatusInternalServerError)
+		return
+	}
+
+	users, err := models.GetAllUsers(h.db)
+	if err != nil {
+		log.Printf("Error fetching users: %v", err)
+		http.Error(w, "Erro ao buscar utilizadores", http.StatusInternalServerError)
+		return
+	}
+
+	// Render template
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/admin/equipas/list.html"))
+	data := struct {
+		Title   string
+		User    interface{}
+		Equipas []models.Equipa
+		Users   []models.User
+	}{
+		Title:   "Equipas",
+		User:    getSessionUser(h.db, h.store, r),
+		Equipas: equipas,
+		Users:   users,
+	}
+	tmpl.Execute(w, data)
+}
+
+// Save handles the create team form submission
+func (h *EquipaHandler) Save(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	nome := strings.TrimSpace(r.FormValue("nome"))
+	if nome == "" {
+		http.Error(w, "Nome obrigatório", http.StatusBadRequest)
+		return
+	}
+
+	id, err := models.CreateEquipa(h.db, nome)
+	if err != nil {
+		log.Printf("Error creating equipa: %v", err)
+		http.Error(w, "Erro ao criar equipa", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the create action
+	h.logService.LogCreate(adminID, "equipa", map[string]interface{}{"id_equipa": id, "nome": nome})
+
+	http.Redirect(w, r, "/admin/equipas", http.StatusSeeOther)
+}
+
+// Delete handles the delete team request
+func (h *EquipaHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID da equipa inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if err := models.DeleteEquipa(h.db, id); err != nil {
+		log.Printf("Error deleting equipa: %v", err)
+		http.Error(w, "Erro ao excluir equipa", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the delete action
+	h.logService.LogDelete(adminID, "equipa", map[string]interface{}{"id_equipa": id})
+
+	http.Redirect(w, r, "/admin/equipas", http.StatusSeeOther)
+}
+
+// AddMembro handles adding a user to a team
+func (h *EquipaHandler) AddMembro(w http.ResponseWriter, r *http.Request) {
+	equipaID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID da equipa inválido", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil {
+		http.Error(w, "Utilizador inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if err := models.AddEquipaMembro(h.db, equipaID, userID); err != nil {
+		log.Printf("Error adding equipa member: %v", err)
+		http.Error(w, "Erro ao adicionar membro", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the add action
+	h.logService.LogCreate(adminID, "equipa_membro", map[string]interface{}{
+		"equipa_id": equipaID,
+		"user_id":   userID,
+	})
+
+	http.Redirect(w, r, "/admin/equipas", http.StatusSeeOther)
+}
+
+// RemoveMembro handles removing a user from a team
+func (h *EquipaHandler) RemoveMembro(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	equipaID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "ID da equipa inválido", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(vars["user"])
+	if err != nil {
+		http.Error(w, "Utilizador inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if err := models.RemoveEquipaMembro(h.db, equipaID, userID); err != nil {
+		log.Printf("Error removing equipa member: %v", err)
+		http.Error(w, "Erro ao remover membro", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the remove action
+	h.logService.LogDelete(adminID, "equipa_membro", map[string]interface{}{
+		"equipa_id": equipaID,
+		"user_id":   userID,
+	})
+
+	http.Redirect(w, r, "/admin/equipas", http.StatusSeeOther)
+}
+
+// AddPartilha handles sharing a concurso with a user
+func (h *EquipaHandler) AddPartilha(w http.ResponseWriter, r *http.Request) {
+	concurso, ok := h.getConcurso(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil {
+		http.Error(w, "Utilizador inválido", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := models.GetUserByID(h.db, userID); err != nil {
+		http.Error(w, "Utilizador não encontrado", http.StatusNotFound)
+		return
+	}
+
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	autorID, _ := session.Values["user_id"].(int)
+
+	if err := models.AddPartilha(h.db, concurso.ID, userID); err != nil {
+		log.Printf("Error sharing concurso: %v", err)
+		http.Error(w, "Erro ao partilhar concurso", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the share action
+	h.logService.LogCreate(autorID, "concurso_partilha", map[string]interface{}{
+		"concurso_id": concurso.ID,
+		"user_id":     userID,
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/concursos/%d#partilhas", concurso.ID), http.StatusSeeOther)
+}
+
+// RemovePartilha handles stopping sharing a concurso with a user
+func (h *EquipaHandler) RemovePartilha(w http.ResponseWriter, r *http.Request) {
+	concurso, ok := h.getConcurso(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(mux.Vars(r)["user"])
+	if err != nil {
+		http.Error(w, "Utilizador inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	autorID, _ := session.Values["user_id"].(int)
+
+	if err := models.RemovePartilha(h.db, concurso.ID, userID); err != nil {
+		log.Printf("Error unsharing concurso: %v", err)
+		http.Error(w, "Erro ao remover partilha", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the unshare action
+	h.logService.LogDelete(autorID, "concurso_partilha", map[string]interface{}{
+		"concurso_id": concurso.ID,
+		"user_id":     userID,
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/concursos/%d#partilhas", concurso.ID), http.StatusSeeOther)
+}
+
+// getConcurso loads the concurso of the request URL if visible to the user, writing the error response when it fails
+func (h *EquipaHandler) getConcurso(w http.ResponseWriter, r *http.Request) (*models.Concurso, bool) {
+	concurso, err := models.GetConcursoByID(h.db, mux.Vars(r)["id"], getVisibilidade(h.db, h.store, r))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Concurso não encontrado", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error fetching concurso: %v", err)
+		http.Error(w, "Erro ao buscar concurso", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return concurso, true
+}
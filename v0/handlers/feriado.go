@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"v0/models"
+	"v0/services"
+	"v0/utils"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+// FeriadoHandler handles the holidays admin requests
+type FeriadoHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	logService *services.LogService
+}
+
+// NewFeriadoHandler creates a new FeriadoHandler
+func NewFeriadoHandler(db *sql.DB, store sessions.Store) *FeriadoHandler {
+	return &FeriadoHandler{
+		db:         db,
+		store:      store,
+		logService: services.NewLogService(db),
+	}
+}
+
+// List handles the holidays admin page
+func (h *FeriadoHandler) List(w http.ResponseWriter, r *http.Request) {
+	feriados, err := models.GetFeriados(h.db)
+	if err != nil {
+		log.Printf("Error fetching holidays: %v", err)
+		http.Error(w, "Erro ao buscar feriados", http.StatusInternalServerError)
+		return
+	}
+
+	// National holidays of the selected year, computed rather than stored
+	ano, err := strconv.Atoi(r.URL.Query().Get("ano"))
+	if err != nil {
+		ano = utils.Now().Year()
+	}
+
+	var nacionais []models.Feriado
+	for data, descricao := range utils.FeriadosNacionais(ano) {
+		nacionais = append(nacionais, models.Feriado{Data: data, Descricao: descricao})
+	}
+	sort.Slice(nacionais, func(i, j int) bool {
+		return nacionais[i].Data < nacionais[j].Data
+	})
+
+	// Render template
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/admin/feriados/list.html"))
+	data := struct {
+		Title     string
+		User      interface{}
+		Feriados  []models.Feriado
+		Nacionais []models.Feriado
+		Ano       int
+	}{
+		Title:     "Feriados",
+		User:      getSessionUser(h.db, h.store, r),
+		Feriados:  feriados,
+		Nacionais: nacionais,
+		Ano:       ano,
+	}
+	tmpl.Execute(w, data)
+}
+
+// Save handles the create municipal holiday form submission
+func (h *FeriadoHandler) Save(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if err := r.ParseForm(); err != nil {
+		log.Printf("Form parse error: %v", err)
+		http.Error(w, "Erro ao processar formulário", http.StatusBadRequest)
+		return
+	}
+
+	data := r.FormValue("data")
+	if _, err := time.Parse("2006-01-02", data); err != nil {
+		http.Error(w, "Data inválida", http.StatusBadRequest)
+		return
+	}
+
+	descricao := strings.TrimSpace(r.FormValue("descricao"))
+	if descricao == "" {
+		http.Error(w, "Descrição obrigatória", http.StatusBadRequest)
+		return
+	}
+
+	feriado := &models.Feriado{
+		Data:      data,
+		Descricao: descricao,
+	}
+
+	if err := models.CreateFeriado(h.db, feriado); err != nil {
+		log.Printf("Error creating holiday: %v", err)
+		http.Error(w, "Erro ao criar feriado", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the create action
+	h.logService.LogCreate(adminID, "feriado", feriado)
+
+	http.Redirect(w, r, "/admin/feriados", http.StatusSeeOther)
+}
+
+// Delete handles the delete municipal holiday request
+func (h *FeriadoHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "ID do feriado inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if err := models.DeleteFeriado(h.db, id); err != nil {
+		log.Printf("Error deleting holiday: %v", err)
+		http.Error(w, "Erro ao excluir feriado", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the delete action
+	h.logService.LogDelete(adminID, "feriado", map[string]interface{}{
+		"id_feriado": id,
+	})
+
+	http.Redirect(w, r, "/admin/feriados", http.StatusSeeOther)
+}
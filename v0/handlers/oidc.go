@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"v0/models"
+	"v0/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"golang.org/x/oauth2"
+)
+
+// pendingOIDCTTL is how long the identity provider login page may take before the callback is refused
+const pendingOIDCTTL = 10 * time.Minute
+
+// clearPendingOIDC removes the values of a pending single sign-on from the session
+func clearPendingOIDC(session *sessions.Session) {
+	for _, key := range []string{"oidc_state", "oidc_nonce", "oidc_verifier", "oidc_inicio", "oidc_ligar"} {
+		delete(session.Values, key)
+	}
+}
+
+// randomState returns a random value for the state and nonce of a single sign-on
+func randomState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// LoginOIDC sends the user to the identity provider login page
+func (h *AuthHandler) LoginOIDC(w http.ResponseWriter, r *http.Request) {
+	h.redirectOIDC(w, r, 0)
+}
+
+// LinkOIDC sends the logged in user to the identity provider to link the account there to their own
+func (h *AuthHandler) LinkOIDC(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+	h.redirectOIDC(w, r, userID)
+}
+
+// redirectOIDC keeps the state, nonce and PKCE verifier of a single sign-on in the session and redirects to the
+// identity provider; ligarID is the user to link the account to, 0 to log in
+func (h *AuthHandler) redirectOIDC(w http.ResponseWriter, r *http.Request, ligarID int) {
+	if h.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	state, err := randomState()
+	if err != nil {
+		log.Printf("Error generating OIDC state: %v", err)
+		http.Error(w, "Erro ao iniciar login", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := randomState()
+	if err != nil {
+		log.Printf("Error generating OIDC nonce: %v", err)
+		http.Error(w, "Erro ao iniciar login", http.StatusInternalServerError)
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	authURL, err := h.oidc.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		log.Printf("OIDC error: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		h.renderLogin(w, r, loginPage{Title: "Login", Erro: "O fornecedor de identidade não está disponível. Tente novamente mais tarde."})
+		return
+	}
+
+	session, _ := h.store.Get(r, "session-name")
+	session.Values["oidc_state"] = state
+	session.Values["oidc_nonce"] = nonce
+	session.Values["oidc_verifier"] = verifier
+	session.Values["oidc_inicio"] = time.Now().Unix()
+	session.Values["oidc_ligar"] = ligarID
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Session save error: %v", err)
+		http.Error(w, "Erro ao salvar sessão", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// CallbackOIDC handles the return from the identity provider, logging the user in or linking the account
+func (h *AuthHandler) CallbackOIDC(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The pending values are used up whatever the outcome, so that a callback cannot be replayed
+	session, _ := h.store.Get(r, "session-name")
+	state, _ := session.Values["oidc_state"].(string)
+	nonce, _ := session.Values["oidc_nonce"].(string)
+	verifier, _ := session.Values["oidc_verifier"].(string)
+	inicio, _ := session.Values["oidc_inicio"].(int64)
+	ligarID, _ := session.Values["oidc_ligar"].(int)
+	clearPendingOIDC(session)
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Session save error: %v", err)
+		http.Error(w, "Erro ao salvar sessão", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	if state == "" || time.Since(time.Unix(inicio, 0)) > pendingOIDCTTL ||
+		subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		h.renderLogin(w, r, loginPage{Title: "Login", Erro: "O pedido de login expirou. Tente novamente."})
+		return
+	}
+
+	if errCode := query.Get("error"); errCode != "" {
+		log.Printf("OIDC login refused by provider: %s %s", errCode, query.Get("error_description"))
+		w.WriteHeader(http.StatusUnauthorized)
+		h.renderLogin(w, r, loginPage{Title: "Login", Erro: "O login foi cancelado ou recusado pelo fornecedor de identidade."})
+		return
+	}
+
+	identity, err := h.oidc.Exchange(r.Context(), query.Get("code"), verifier, nonce)
+	if err != nil {
+		log.Printf("OIDC error: %v", err)
+		w.WriteHeader(http.StatusBadGateway)
+		h.renderLogin(w, r, loginPage{Title: "Login", Erro: "Não foi possível entrar com o fornecedor de identidade. Tente novamente."})
+		return
+	}
+
+	if ligarID != 0 {
+		h.linkIdentidade(w, r, session, ligarID, identity)
+		return
+	}
+
+	userID, cargoID, err := h.oidc.User(h.oidcAccounts, identity)
+	var loginErr *models.LoginError
+	if errors.As(err, &loginErr) && loginErr.Pendente != "" {
+		h.recordTentativa(models.LoginTentativa{UserID: loginErr.UserID, Login: identity.Email, IP: services.ClientIP(r), Motivo: models.MotivoPendente})
+		w.WriteHeader(http.StatusForbidden)
+		h.renderLogin(w, r, loginPage{Title: "Login", Erro: "A sua conta aguarda a verificação do email ou a aprovação de um administrador."})
+		return
+	}
+	if errors.Is(err, services.ErrSemAcesso) {
+		log.Printf("OIDC login refused for %q: %v", identity.Subject, err)
+		h.recordTentativa(models.LoginTentativa{Login: identity.Email, IP: services.ClientIP(r), Motivo: models.MotivoSemAcesso})
+		w.WriteHeader(http.StatusForbidden)
+		h.renderLogin(w, r, loginPage{Title: "Login", Erro: "A sua conta não tem acesso a esta aplicação. Contacte um administrador."})
+		return
+	}
+	if err != nil {
+		log.Printf("OIDC login error: %v", err)
+		http.Error(w, "Erro ao fazer login", http.StatusInternalServerError)
+		return
+	}
+
+	h.completeLogin(w, r, userID, cargoID, "oidc")
+}
+
+// linkIdentidade links an identity to the logged in user who started the link
+func (h *AuthHandler) linkIdentidade(w http.ResponseWriter, r *http.Request, session *sessions.Session, userID int, identity *services.OIDCIdentity) {
+	// The link only applies to the session that started it
+	auth, _ := session.Values["authenticated"].(bool)
+	sessionUserID, _ := session.Values["user_id"].(int)
+	if !auth || sessionUserID != userID {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	err := models.LinkIdentidade(h.db, userID, identity.Issuer, identity.Subject, identity.Email)
+	if err == models.ErrIdentidadeLigada {
+		http.Redirect(w, r, "/conta/sso?erro=ligada", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		log.Printf("Error linking identity: %v", err)
+		http.Error(w, "Erro ao ligar conta", http.StatusInternalServerError)
+		return
+	}
+
+	h.logService.LogAction(userID, "user", "oidc_ligado", nil, map[string]interface{}{
+		"user_id":  userID,
+		"provedor": identity.Issuer,
+	})
+
+	http.Redirect(w, r, "/conta/sso", http.StatusSeeOther)
+}
+
+// IdentidadeHandler handles the single sign-on accounts of the logged in user
+type IdentidadeHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	logService *services.LogService
+	sso        bool
+}
+
+// NewIdentidadeHandler creates a new IdentidadeHandler
+func NewIdentidadeHandler(db *sql.DB, store sessions.Store, sso bool) *IdentidadeHandler {
+	return &IdentidadeHandler{
+		db:         db,
+		store:      store,
+		logService: services.NewLogService(db),
+		sso:        sso,
+	}
+}
+
+// Page handles the single sign-on page of the account
+func (h *IdentidadeHandler) Page(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+
+	user, err := models.GetUserByID(h.db, userID)
+	if err != nil {
+		log.Printf("Error fetching user: %v", err)
+		http.Error(w, "Erro ao buscar user", http.StatusInternalServerError)
+		return
+	}
+
+	identidades, err := models.GetUserIdentidades(h.db, userID)
+	if err != nil {
+		log.Printf("Error fetching identities: %v", err)
+		http.Error(w, "Erro ao buscar contas ligadas", http.StatusInternalServerError)
+		return
+	}
+
+	var erro string
+	switch r.URL.Query().Get("erro") {
+	case "ligada":
+		erro = "Essa conta do fornecedor de identidade já está ligada a outro user."
+	case "ultima":
+		erro = models.ErrUltimaIdentidade.Error() + "."
+	case "sem_conta":
+		erro = "Ligue uma conta do fornecedor de identidade antes de desativar o login com password."
+	}
+
+	// Render template
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/conta/sso.html"))
+	data := struct {
+		Title       string
+		User        interface{}
+		SSO         bool
+		Local       bool
+		LoginLocal  bool
+		Identidades []models.Identidade
+		Erro        string
+	}{
+		Title:       "Single Sign-On",
+		User:        getSessionUser(h.db, h.store, r),
+		SSO:         h.sso,
+		Local:       user.Origem == models.OrigemLocal,
+		LoginLocal:  user.LoginLocal,
+		Identidades: identidades,
+		Erro:        erro,
+	}
+	tmpl.Execute(w, data)
+}
+
+// SetLoginLocal handles allowing or forbidding the login form password of the user, which can only be
+// forbidden with a linked account
+func (h *IdentidadeHandler) SetLoginLocal(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+	permitido := r.FormValue("login_local") != ""
+
+	if !permitido {
+		identidades, err := models.GetUserIdentidades(h.db, userID)
+		if err != nil {
+			log.Printf("Error fetching identities: %v", err)
+			http.Error(w, "Erro ao buscar contas ligadas", http.StatusInternalServerError)
+			return
+		}
+		if len(identidades) == 0 {
+			http.Redirect(w, r, "/conta/sso?erro=sem_conta", http.StatusSeeOther)
+			return
+		}
+	}
+
+	if err := models.SetLoginLocal(h.db, userID, permitido); err != nil {
+		log.Printf("Error updating local login: %v", err)
+		http.Error(w, "Erro ao atualizar login com password", http.StatusInternalServerError)
+		return
+	}
+
+	h.logService.LogAction(userID, "user", "login_local", nil, map[string]interface{}{
+		"user_id":     userID,
+		"login_local": permitido,
+	})
+
+	http.Redirect(w, r, "/conta/sso", http.StatusSeeOther)
+}
+
+// Unlink handles unlinking one of the user's single sign-on accounts
+func (h *IdentidadeHandler) Unlink(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	err = models.DeleteUserIdentidade(h.db, userID, id)
+	if err == models.ErrUltimaIdentidade {
+		http.Redirect(w, r, "/conta/sso?erro=ultima", http.StatusSeeOther)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "Conta ligada não encontrada", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error unlinking identity: %v", err)
+		http.Error(w, "Erro ao desligar conta", http.StatusInternalServerError)
+		return
+	}
+
+	h.logService.LogAction(userID, "user", "oidc_desligado", nil, map[string]interface{}{
+		"user_id":       userID,
+		"identidade_id": id,
+	})
+
+	http.Redirect(w, r, "/conta/sso", http.StatusSeeOther)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"v0/config"
+	"v0/services"
+
+	"github.com/gorilla/sessions"
+)
+
+// newTestOIDCHandler returns an AuthHandler whose identity provider only answers discovery, which is all the
+// callback needs before it checks the state
+func newTestOIDCHandler(t *testing.T) *AuthHandler {
+	t.Chdir("..")
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/jwks",
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return &AuthHandler{
+		store: sessions.NewCookieStore([]byte("chave-de-teste-com-32-bytes-0000")),
+		oidc: services.NewOIDCProvider(config.OIDCConfig{
+			IssuerURL:   server.URL,
+			ClientID:    "concursos",
+			RedirectURL: "http://localhost/login/oidc/callback",
+		}),
+	}
+}
+
+// startOIDC starts a single sign-on, returning the session cookie and the state sent to the provider
+func startOIDC(t *testing.T, h *AuthHandler) (*http.Cookie, string) {
+	rec := httptest.NewRecorder()
+	h.LoginOIDC(rec, httptest.NewRequest(http.MethodGet, "/login/oidc", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("LoginOIDC status = %d, want %d", rec.Code, http.StatusFound)
+	}
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("LoginOIDC location: %v", err)
+	}
+	query := location.Query()
+	if query.Get("state") == "" || query.Get("nonce") == "" || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("LoginOIDC redirect without state, nonce or PKCE: %s", location)
+	}
+
+	return rec.Result().Cookies()[0], query.Get("state")
+}
+
+// callbackOIDC returns to the callback with a state and the provider refusing the login, which is answered
+// with 401 only once the state is accepted
+func callbackOIDC(h *AuthHandler, cookie *http.Cookie, state string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/login/oidc/callback?error=access_denied&state="+url.QueryEscape(state), nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	h.CallbackOIDC(rec, req)
+	return rec
+}
+
+func TestCallbackOIDCChecksTheState(t *testing.T) {
+	h := newTestOIDCHandler(t)
+
+	cookie, state := startOIDC(t, h)
+	rec := callbackOIDC(h, cookie, "outro-"+state)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("wrong state: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	// A failed check uses up the pending login too
+	if rec := callbackOIDC(h, rec.Result().Cookies()[0], state); rec.Code != http.StatusBadRequest {
+		t.Errorf("state of a used up login: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	cookie, state = startOIDC(t, h)
+	rec = callbackOIDC(h, cookie, state)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("valid state: status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	// The callback cannot be replayed with the session it left behind
+	if rec := callbackOIDC(h, rec.Result().Cookies()[0], state); rec.Code != http.StatusBadRequest {
+		t.Errorf("replayed callback: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if rec := callbackOIDC(h, &http.Cookie{Name: "session-name", Value: "x"}, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("no pending login: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCallbackOIDCRefusesExpiredLogins(t *testing.T) {
+	h := newTestOIDCHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session, _ := h.store.Get(req, "session-name")
+	session.Values["oidc_state"] = "estado"
+	session.Values["oidc_inicio"] = time.Now().Add(-pendingOIDCTTL - time.Minute).Unix()
+	rec := httptest.NewRecorder()
+	if err := session.Save(req, rec); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if rec := callbackOIDC(h, rec.Result().Cookies()[0], "estado"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expired login: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"v0/config"
+	"v0/models"
+	"v0/services"
+
+	"github.com/gorilla/sessions"
+)
+
+// PasswordHandler handles the forgot password flow
+type PasswordHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	cfg        *config.Config
+	logService *services.LogService
+}
+
+// NewPasswordHandler creates a new PasswordHandler
+func NewPasswordHandler(db *sql.DB, store sessions.Store, cfg *config.Config) *PasswordHandler {
+	return &PasswordHandler{
+		db:         db,
+		store:      store,
+		cfg:        cfg,
+		logService: services.NewLogService(db),
+	}
+}
+
+// passwordPage is the data of the forgot and reset password page
+type passwordPage struct {
+	Title     string
+	Token     string
+	Enviado   bool
+	Erro      string
+	CSRFToken string
+	// MinPassword is the minimum length of a new password
+	MinPassword int
+}
+
+// renderPassword renders the forgot and reset password page
+func (h *PasswordHandler) renderPassword(w http.ResponseWriter, r *http.Request, data passwordPage) {
+	data.CSRFToken = csrfToken(h.store, r)
+	data.MinPassword = models.MinPasswordLength()
+	tmpl := template.Must(template.ParseFiles("templates/auth/password.html"))
+	tmpl.Execute(w, data)
+}
+
+// Forgot handles the forgot password page and sends the reset link by email
+func (h *PasswordHandler) Forgot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.renderPassword(w, r, passwordPage{Title: "Recuperar Password"})
+		return
+	}
+
+	email := strings.TrimSpace(r.FormValue("email"))
+	ip := services.ClientIP(r)
+
+	// Throttle per IP and per email, known or not, so that the form cannot flood a mailbox
+	porIP, porEmail, err := models.CountResetPedidos(h.db, email, ip, h.cfg.Auth.ResetWindowMinutes)
+	if err != nil {
+		log.Printf("Error counting password reset requests: %v", err)
+		http.Error(w, "Erro ao pedir recuperação", http.StatusInternalServerError)
+		return
+	}
+	if h.cfg.Auth.ResetIPLimit > 0 && porIP >= h.cfg.Auth.ResetIPLimit {
+		log.Printf("Password reset requests throttled for %s", ip)
+		w.WriteHeader(http.StatusTooManyRequests)
+		h.renderPassword(w, r, passwordPage{Title: "Recuperar Password", Erro: "Demasiados pedidos. Tente novamente mais tarde."})
+		return
+	}
+
+	if err := models.RecordResetPedido(h.db, email, ip); err != nil {
+		log.Printf("Error recording password reset request: %v", err)
+	}
+
+	// The answer is the same, and given after the same work, whether the email exists or not, so that accounts
+	// cannot be discovered; the account is looked up and emailed in the background
+	if h.cfg.Auth.ResetEmailLimit > 0 && porEmail >= h.cfg.Auth.ResetEmailLimit {
+		log.Printf("Password reset requests throttled for %q", email)
+	} else {
+		go h.sendReset(email, ip)
+	}
+
+	h.renderPassword(w, r, passwordPage{Title: "Recuperar Password", Enviado: true})
+}
+
+// sendReset creates a reset token for the user of an email and emails the link. Accounts of an external
+// provider, or without password login, have no local password to reset
+func (h *PasswordHandler) sendReset(email, ip string) {
+	user, err := models.GetUserByEmail(h.db, email)
+	if err == sql.ErrNoRows {
+		log.Printf("Password reset requested for unknown email %q from %s", email, ip)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching user for password reset: %v", err)
+		return
+	}
+	if user.Origem != models.OrigemLocal || !user.LoginLocal {
+		log.Printf("Password reset requested for %q, which cannot log in with a password", email)
+		return
+	}
+
+	token, err := models.CreatePasswordReset(h.db, user.ID, h.cfg.Auth.ResetTokenMinutes)
+	if err != nil {
+		log.Printf("Error creating password reset: %v", err)
+		return
+	}
+
+	// Log the request
+	h.logService.LogAction(user.ID, "user", "password_reset_request", nil, map[string]interface{}{
+		"user_id": user.ID,
+		"ip":      ip,
+	})
+
+	link := fmt.Sprintf("%s/redefinir-password?token=%s", h.cfg.Server.BaseURL, url.QueryEscape(token))
+	emailService := services.NewEmailService(h.cfg.Email)
+	if err := emailService.SendPasswordResetEmail(user.Email, user.Nome, link, h.cfg.Auth.ResetTokenMinutes); err != nil {
+		log.Printf("Error sending password reset email: %v", err)
+	}
+}
+
+// Reset handles the reset password form opened from the emailed link
+func (h *PasswordHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+	data := passwordPage{Title: "Nova Password", Token: token}
+
+	if r.Method != http.MethodPost {
+		if _, err := models.GetPasswordResetUser(h.db, token); err != nil {
+			if err != models.ErrResetInvalido {
+				log.Printf("Error fetching password reset: %v", err)
+			}
+			data.Token, data.Erro = "", "O link de recuperação é inválido ou expirou. Peça um novo link."
+		}
+		h.renderPassword(w, r, data)
+		return
+	}
+
+	password := r.FormValue("password")
+	if password != r.FormValue("confirm-password") {
+		data.Erro = "As passwords não coincidem"
+		h.renderPassword(w, r, data)
+		return
+	}
+
+	if err := models.ValidatePassword(password); err != nil {
+		data.Erro = "Password inválida: " + err.Error()
+		h.renderPassword(w, r, data)
+		return
+	}
+
+	userID, err := models.ResetPassword(h.db, token, password)
+	if err == models.ErrResetInvalido {
+		data.Token, data.Erro = "", "O link de recuperação é inválido ou expirou. Peça um novo link."
+		h.renderPassword(w, r, data)
+		return
+	}
+	if err == models.ErrPasswordReutilizada {
+		data.Erro = "Password inválida: " + err.Error()
+		h.renderPassword(w, r, data)
+		return
+	}
+	if err != nil {
+		log.Printf("Error resetting password: %v", err)
+		http.Error(w, "Erro ao alterar password", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the reset
+	h.logService.LogAction(userID, "user", "password_reset", nil, map[string]interface{}{
+		"user_id": userID,
+		"ip":      services.ClientIP(r),
+	})
+
+	http.Redirect(w, r, "/login?reset=true", http.StatusSeeOther)
+}
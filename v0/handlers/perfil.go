@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"v0/config"
+	"v0/models"
+	"v0/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+const (
+	// perfilAtividadeLimite is how many of the user's latest actions the profile page shows
+	perfilAtividadeLimite = 20
+	// apiTokenNomeMax is the length of the api_token.nome column
+	apiTokenNomeMax = 100
+	// perfilBloqueada is the error shown when the current password is checked on a locked account
+	perfilBloqueada = "Conta bloqueada temporariamente após várias tentativas falhadas. Tente mais tarde."
+)
+
+// apiTokenValidades are the days an API token can be valid for, 0 meaning no expiry
+var apiTokenValidades = []int{30, 90, 365, 0}
+
+// perfilMensagens are the results of a profile form shown on the profile page
+type perfilMensagens struct {
+	Erro    string
+	Sucesso string
+	// Token is a new API token, shown only once
+	Token string
+}
+
+// PerfilHandler handles the profile page where the logged in user manages their own account
+type PerfilHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	cfg        *config.Config
+	logService *services.LogService
+}
+
+// NewPerfilHandler creates a new PerfilHandler
+func NewPerfilHandler(db *sql.DB, store sessions.Store, cfg *config.Config) *PerfilHandler {
+	return &PerfilHandler{
+		db:         db,
+		store:      store,
+		cfg:        cfg,
+		logService: services.NewLogService(db),
+	}
+}
+
+// sessionUserID returns the logged in user and the token of the current session
+func (h *PerfilHandler) sessionUserID(r *http.Request) (int, string) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+	return userID, session.ID
+}
+
+// checkPasswordAtual checks the current password typed on a profile form, returning the error to show when the
+// password is wrong or the account is locked. Wrong passwords count toward the account lockout like failed logins,
+// so a stolen session cannot be used to guess the password
+func (h *PerfilHandler) checkPasswordAtual(r *http.Request, userID int, erro string) (string, error) {
+	bloqueado, err := models.IsBloqueado(h.db, userID)
+	if err != nil {
+		return "", err
+	}
+	if bloqueado {
+		return perfilBloqueada, nil
+	}
+
+	ok, err := models.CheckPassword(h.db, userID, r.FormValue("password_atual"))
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return "", models.ResetFailedAttempts(h.db, userID)
+	}
+
+	loginErr, err := models.RegisterLoginFailure(h.db, userID, lockoutPolicy(h.cfg))
+	if err != nil {
+		return "", err
+	}
+	if loginErr.NovoBloqueio {
+		notifyLockout(h.db, h.cfg, h.logService, userID, services.ClientIP(r))
+	}
+	if loginErr.Bloqueado {
+		return perfilBloqueada, nil
+	}
+	return erro, nil
+}
+
+// render renders the profile page with the result of a form
+func (h *PerfilHandler) render(w http.ResponseWriter, r *http.Request, userID int, mensagens perfilMensagens) {
+	user, err := models.GetUserByID(h.db, userID)
+	if err != nil {
+		log.Printf("Error fetching user: %v", err)
+		http.Error(w, "Erro ao buscar user", http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := models.GetUserAPITokens(h.db, userID)
+	if err != nil {
+		log.Printf("Error fetching API tokens: %v", err)
+		http.Error(w, "Erro ao buscar tokens de API", http.StatusInternalServerError)
+		return
+	}
+
+	atividade, err := h.logService.GetUserActivity(userID, perfilAtividadeLimite)
+	if err != nil {
+		log.Printf("Error fetching user activity: %v", err)
+		http.Error(w, "Erro ao buscar atividade", http.StatusInternalServerError)
+		return
+	}
+
+	// Render template
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/conta/perfil.html"))
+	data := struct {
+		Title     string
+		User      interface{}
+		Perfil    *models.User
+		Local     bool
+		Password  bool
+		Tokens    []models.APIToken
+		Validades []int
+		Atividade []services.HistoricoEntry
+		perfilMensagens
+	}{
+		Title:  "O Meu Perfil",
+		User:   getSessionUser(h.db, h.store, r),
+		Perfil: user,
+		// Accounts from a directory or identity provider keep the name and email given there
+		Local:           user.Origem == models.OrigemLocal,
+		Password:        user.Origem == models.OrigemLocal && user.LoginLocal,
+		Tokens:          tokens,
+		Validades:       apiTokenValidades,
+		Atividade:       atividade,
+		perfilMensagens: mensagens,
+	}
+	tmpl.Execute(w, data)
+}
+
+// Page handles the profile page
+func (h *PerfilHandler) Page(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.sessionUserID(r)
+	h.render(w, r, userID, perfilMensagens{})
+}
+
+// Update handles changing the name and email of the user; a new email needs the current password
+func (h *PerfilHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.sessionUserID(r)
+
+	user, err := models.GetUserByID(h.db, userID)
+	if err != nil {
+		log.Printf("Error fetching user: %v", err)
+		http.Error(w, "Erro ao buscar user", http.StatusInternalServerError)
+		return
+	}
+
+	if user.Origem != models.OrigemLocal {
+		h.render(w, r, userID, perfilMensagens{Erro: "O nome e o email da sua conta são geridos fora da aplicação."})
+		return
+	}
+
+	nome := strings.TrimSpace(r.FormValue("nome"))
+	email := strings.TrimSpace(r.FormValue("email"))
+	if nome == "" || email == "" {
+		h.render(w, r, userID, perfilMensagens{Erro: "Preencha o user e o email."})
+		return
+	}
+
+	// Users log in by name, so a new name must be unique; a change of case only is the same name
+	if !strings.EqualFold(nome, user.Nome) {
+		exists, err := models.NomeExists(h.db, nome)
+		if err != nil {
+			log.Printf("Name check error: %v", err)
+			http.Error(w, "Erro ao verificar user", http.StatusInternalServerError)
+			return
+		}
+		if exists {
+			h.render(w, r, userID, perfilMensagens{Erro: "User já em uso."})
+			return
+		}
+	}
+
+	// The email receives password reset links, so changing it needs the current password
+	if !strings.EqualFold(email, user.Email) {
+		erro, err := h.checkPasswordAtual(r, userID, "Indique a password atual para alterar o email.")
+		if err != nil {
+			log.Printf("Password check error: %v", err)
+			http.Error(w, "Erro ao verificar password", http.StatusInternalServerError)
+			return
+		}
+		if erro != "" {
+			h.render(w, r, userID, perfilMensagens{Erro: erro})
+			return
+		}
+
+		exists, err := models.EmailExists(h.db, email)
+		if err != nil {
+			log.Printf("Email check error: %v", err)
+			http.Error(w, "Erro ao verificar email", http.StatusInternalServerError)
+			return
+		}
+		if exists {
+			h.render(w, r, userID, perfilMensagens{Erro: "Email já em uso."})
+			return
+		}
+	}
+
+	err = models.UpdateProfile(h.db, userID, nome, email)
+	if err == models.ErrNomeEmUso {
+		h.render(w, r, userID, perfilMensagens{Erro: "User já em uso."})
+		return
+	}
+	if err == models.ErrEmailEmUso {
+		h.render(w, r, userID, perfilMensagens{Erro: "Email já em uso."})
+		return
+	}
+	if err != nil {
+		log.Printf("Profile update error: %v", err)
+		http.Error(w, "Erro ao atualizar perfil", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the update action
+	h.logService.LogUpdate(userID, "user",
+		map[string]interface{}{"user_id": userID, "nome": user.Nome, "email": user.Email},
+		map[string]interface{}{"user_id": userID, "nome": nome, "email": email},
+	)
+
+	h.render(w, r, userID, perfilMensagens{Sucesso: "Perfil atualizado."})
+}
+
+// ChangePassword handles changing the password of a local user, who stays logged in on this session only
+func (h *PerfilHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, sessionID := h.sessionUserID(r)
+
+	user, err := models.GetUserByID(h.db, userID)
+	if err != nil {
+		log.Printf("Error fetching user: %v", err)
+		http.Error(w, "Erro ao buscar user", http.StatusInternalServerError)
+		return
+	}
+
+	if user.Origem != models.OrigemLocal || !user.LoginLocal {
+		h.render(w, r, userID, perfilMensagens{Erro: "A sua conta não usa password da aplicação."})
+		return
+	}
+
+	erro, err := h.checkPasswordAtual(r, userID, "A password atual está errada.")
+	if err != nil {
+		log.Printf("Password check error: %v", err)
+		http.Error(w, "Erro ao verificar password", http.StatusInternalServerError)
+		return
+	}
+	if erro != "" {
+		h.render(w, r, userID, perfilMensagens{Erro: erro})
+		return
+	}
+
+	password := r.FormValue("password")
+	if password != r.FormValue("confirm-password") {
+		h.render(w, r, userID, perfilMensagens{Erro: "As passwords não coincidem."})
+		return
+	}
+
+	if err := models.ValidatePassword(password); err != nil {
+		h.render(w, r, userID, perfilMensagens{Erro: "Password inválida: " + err.Error() + "."})
+		return
+	}
+
+	err = models.ChangePassword(h.db, userID, password, sessionID)
+	if err == models.ErrPasswordReutilizada {
+		h.render(w, r, userID, perfilMensagens{Erro: "Password inválida: " + err.Error() + "."})
+		return
+	}
+	if err != nil {
+		log.Printf("Password change error: %v", err)
+		http.Error(w, "Erro ao alterar password", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the password change
+	h.logService.LogAction(userID, "user", "alterar_password", nil, map[string]interface{}{
+		"user_id": userID,
+		"ip":      services.ClientIP(r),
+	})
+
+	h.render(w, r, userID, perfilMensagens{Sucesso: "Password alterada. As outras sessões da sua conta foram terminadas."})
+}
+
+// UpdateNotificacoes handles the notification settings of the user
+func (h *PerfilHandler) UpdateNotificacoes(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.sessionUserID(r)
+	concursos := r.FormValue("notif_concursos") != ""
+	mencoes := r.FormValue("notif_mencoes") != ""
+
+	if err := models.UpdateNotificacoes(h.db, userID, concursos, mencoes); err != nil {
+		log.Printf("Notification settings error: %v", err)
+		http.Error(w, "Erro ao guardar notificações", http.StatusInternalServerError)
+		return
+	}
+
+	h.logService.LogAction(userID, "user", "notificacoes", nil, map[string]interface{}{
+		"user_id":         userID,
+		"notif_concursos": concursos,
+		"notif_mencoes":   mencoes,
+	})
+
+	h.render(w, r, userID, perfilMensagens{Sucesso: "Notificações guardadas."})
+}
+
+// CreateToken handles creating an API token, which is shown once
+func (h *PerfilHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.sessionUserID(r)
+
+	nome := strings.TrimSpace(r.FormValue("nome"))
+	if nome == "" || len([]rune(nome)) > apiTokenNomeMax {
+		h.render(w, r, userID, perfilMensagens{Erro: "Dê ao token um nome com até 100 caracteres."})
+		return
+	}
+
+	dias, err := strconv.Atoi(r.FormValue("validade"))
+	if err != nil || !validadeValida(dias) {
+		http.Error(w, "Validade inválida", http.StatusBadRequest)
+		return
+	}
+
+	token, err := models.CreateAPIToken(h.db, userID, nome, dias)
+	if err != nil {
+		log.Printf("API token creation error: %v", err)
+		http.Error(w, "Erro ao criar token de API", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the creation, without the token
+	h.logService.LogAction(userID, "api_token", "create", nil, map[string]interface{}{
+		"user_id":  userID,
+		"nome":     nome,
+		"validade": dias,
+	})
+
+	h.render(w, r, userID, perfilMensagens{Sucesso: "Token de API criado.", Token: token})
+}
+
+// RevokeToken handles revoking one of the user's API tokens
+func (h *PerfilHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	userID, _ := h.sessionUserID(r)
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	err = models.DeleteUserAPIToken(h.db, userID, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Token de API não encontrado", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("API token revocation error: %v", err)
+		http.Error(w, "Erro ao revogar token de API", http.StatusInternalServerError)
+		return
+	}
+
+	h.logService.LogAction(userID, "api_token", "revogar", nil, map[string]interface{}{
+		"user_id":  userID,
+		"token_id": id,
+	})
+
+	http.Redirect(w, r, "/perfil", http.StatusSeeOther)
+}
+
+// validadeValida checks that a number of days is one of the API token validities
+func validadeValida(dias int) bool {
+	for _, v := range apiTokenValidades {
+		if v == dias {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"v0/config"
+	"v0/models"
+	"v0/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+// registerPage is the data of the registration and email verification page
+type registerPage struct {
+	Title      string
+	Nome       string
+	Email      string
+	Erro       string
+	Enviado    bool
+	Verificado bool
+	Ativo      bool
+	Invalido   bool
+	CSRFToken  string
+	// MinPassword is the minimum length of a new password
+	MinPassword int
+}
+
+// renderRegister renders the registration and email verification page
+func (h *AuthHandler) renderRegister(w http.ResponseWriter, r *http.Request, data registerPage) {
+	data.CSRFToken = csrfToken(h.store, r)
+	data.MinPassword = models.MinPasswordLength()
+	tmpl := template.Must(template.ParseFiles("templates/auth/register.html"))
+	tmpl.Execute(w, data)
+}
+
+// Register handles the registration page. New accounts wait for email verification and then for approval
+func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
+	data := registerPage{Title: "Criar Conta"}
+	if r.Method != http.MethodPost {
+		h.renderRegister(w, r, data)
+		return
+	}
+
+	data.Nome = strings.TrimSpace(r.FormValue("nome"))
+	data.Email = strings.TrimSpace(r.FormValue("email"))
+	password := r.FormValue("password")
+
+	if data.Nome == "" || data.Email == "" {
+		data.Erro = "Preencha o user e o email"
+		h.renderRegister(w, r, data)
+		return
+	}
+
+	if password != r.FormValue("confirm-password") {
+		data.Erro = "As passwords não coincidem"
+		h.renderRegister(w, r, data)
+		return
+	}
+
+	if err := models.ValidatePassword(password); err != nil {
+		data.Erro = "Password inválida: " + err.Error()
+		h.renderRegister(w, r, data)
+		return
+	}
+
+	// Users log in by name, so it must be unique as well as the email
+	nomeExists, err := models.NomeExists(h.db, data.Nome)
+	if err != nil {
+		log.Printf("Name check error: %v", err)
+		http.Error(w, "Erro ao verificar user", http.StatusInternalServerError)
+		return
+	}
+	if nomeExists {
+		data.Erro = "User já em uso"
+		h.renderRegister(w, r, data)
+		return
+	}
+
+	emailExists, err := models.EmailExists(h.db, data.Email)
+	if err != nil {
+		log.Printf("Email check error: %v", err)
+		http.Error(w, "Erro ao verificar email", http.StatusInternalServerError)
+		return
+	}
+	if emailExists {
+		data.Erro = "Email já em uso"
+		h.renderRegister(w, r, data)
+		return
+	}
+
+	// Until the registration is approved the account only holds the default cargo, which is never used to log in
+	userID, err := models.CreatePendingUser(h.db, data.Nome, data.Email, password, h.cfg.Auth.RegistoCargoID)
+	// Another registration may take the name or email after the checks above
+	if err == models.ErrNomeEmUso || err == models.ErrEmailEmUso {
+		data.Erro = "User ou email já em uso"
+		h.renderRegister(w, r, data)
+		return
+	}
+	if err != nil {
+		log.Printf("User creation error: %v", err)
+		http.Error(w, "Erro ao criar usuário", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the registration action
+	h.logService.LogCreate(userID, "user", map[string]interface{}{
+		"user_id": userID,
+		"nome":    data.Nome,
+		"email":   data.Email,
+		"estado":  models.EstadoPendenteEmail,
+		"ip":      services.ClientIP(r),
+	})
+
+	token, err := models.CreateEmailVerificacao(h.db, userID, h.cfg.Auth.VerifyTokenHours)
+	if err != nil {
+		log.Printf("Error creating email verification: %v", err)
+		http.Error(w, "Erro ao criar usuário", http.StatusInternalServerError)
+		return
+	}
+
+	link := fmt.Sprintf("%s/verificar-email?token=%s", h.cfg.Server.BaseURL, url.QueryEscape(token))
+	emailService := services.NewEmailService(h.cfg.Email)
+	if err := emailService.SendVerificacaoEmail(data.Email, data.Nome, link, h.cfg.Auth.VerifyTokenHours); err != nil {
+		log.Printf("Error sending verification email: %v", err)
+	}
+
+	h.renderRegister(w, r, registerPage{Title: "Criar Conta", Email: data.Email, Enviado: true})
+}
+
+// VerifyEmail handles the email verification link. Emails of an allowed domain are approved right away,
+// the others wait for an admin
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	data := registerPage{Title: "Confirmar Email"}
+
+	userID, err := models.VerifyEmail(h.db, r.URL.Query().Get("token"))
+	if err == models.ErrVerificacaoInvalida {
+		data.Invalido = true
+		data.Erro = "O link de confirmação é inválido ou expirou."
+		h.renderRegister(w, r, data)
+		return
+	}
+	if err != nil {
+		log.Printf("Error verifying email: %v", err)
+		http.Error(w, "Erro ao confirmar email", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := models.GetUserByID(h.db, userID)
+	if err != nil {
+		log.Printf("Error fetching user: %v", err)
+		http.Error(w, "Erro ao confirmar email", http.StatusInternalServerError)
+		return
+	}
+
+	if h.dominioAutorizado(user.Email) {
+		if err := models.ApproveUser(h.db, userID, h.cfg.Auth.RegistoCargoID); err != nil && err != sql.ErrNoRows {
+			log.Printf("Error approving user: %v", err)
+			http.Error(w, "Erro ao confirmar email", http.StatusInternalServerError)
+			return
+		}
+
+		h.logService.LogAction(userID, "user", "registo_aprovado", nil, map[string]interface{}{
+			"user_id":  userID,
+			"cargo_id": h.cfg.Auth.RegistoCargoID,
+			"dominio":  true,
+		})
+
+		data.Ativo = true
+		h.renderRegister(w, r, data)
+		return
+	}
+
+	h.logService.LogAction(userID, "user", "email_verificado", nil, map[string]interface{}{
+		"user_id": userID,
+	})
+	h.notifyRegistoPendente(user)
+
+	data.Verificado = true
+	h.renderRegister(w, r, data)
+}
+
+// dominioAutorizado reports whether an email belongs to one of the domains approved without an admin
+func (h *AuthHandler) dominioAutorizado(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+
+	dominio := strings.ToLower(email[at+1:])
+	for _, autorizado := range h.cfg.Auth.RegistoDominios {
+		if dominio == autorizado {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notifyRegistoPendente emails the users who manage accounts about a registration waiting for approval
+func (h *AuthHandler) notifyRegistoPendente(user *models.User) {
+	admins, err := models.GetUsersWithPermissao(h.db, models.PermUserManage)
+	if err != nil {
+		log.Printf("Error fetching admins: %v", err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/admin/registos", h.cfg.Server.BaseURL)
+	emailService := services.NewEmailService(h.cfg.Email)
+	for _, admin := range admins {
+		if err := emailService.SendRegistoPendenteEmail(admin.Email, user.Nome, user.Email, link); err != nil {
+			log.Printf("Error sending pending registration email: %v", err)
+		}
+	}
+}
+
+// RegistoHandler handles the approval of registrations
+type RegistoHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	cfg        *config.Config
+	logService *services.LogService
+}
+
+// NewRegistoHandler creates a new RegistoHandler
+func NewRegistoHandler(db *sql.DB, store sessions.Store, cfg *config.Config) *RegistoHandler {
+	return &RegistoHandler{
+		db:         db,
+		store:      store,
+		cfg:        cfg,
+		logService: services.NewLogService(db),
+	}
+}
+
+// List handles the pending registrations page
+func (h *RegistoHandler) List(w http.ResponseWriter, r *http.Request) {
+	registos, err := models.GetPendingUsers(h.db)
+	if err != nil {
+		log.Printf("Error fetching registrations: %v", err)
+		http.Error(w, "Erro ao buscar registos", http.StatusInternalServerError)
+		return
+	}
+
+	cargos, err := models.GetAllCargos(h.db)
+	if err != nil {
+		log.Printf("Error fetching cargos: %v", err)
+		http.Error(w, "Erro ao buscar cargos", http.StatusInternalServerError)
+		return
+	}
+
+	// Render template
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/admin/registos/list.html"))
+	data := struct {
+		Title       string
+		User        interface{}
+		Registos    []models.RegistoPendente
+		Cargos      []models.Cargo
+		CargoPadrao int
+	}{
+		Title:       "Pedidos de Conta",
+		User:        getSessionUser(h.db, h.store, r),
+		Registos:    registos,
+		Cargos:      cargos,
+		CargoPadrao: h.cfg.Auth.RegistoCargoID,
+	}
+	tmpl.Execute(w, data)
+}
+
+// Approve handles approving a registration with the chosen cargo
+func (h *RegistoHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	cargoID, err := strconv.Atoi(r.FormValue("cargo_id"))
+	if err != nil {
+		http.Error(w, "Cargo inválido", http.StatusBadRequest)
+		return
+	}
+
+	cargos, err := models.GetAllCargos(h.db)
+	if err != nil {
+		log.Printf("Error fetching cargos: %v", err)
+		http.Error(w, "Erro ao buscar cargos", http.StatusInternalServerError)
+		return
+	}
+
+	var cargo *models.Cargo
+	for i := range cargos {
+		if cargos[i].ID == cargoID {
+			cargo = &cargos[i]
+			break
+		}
+	}
+	if cargo == nil {
+		http.Error(w, "Cargo inválido", http.StatusBadRequest)
+		return
+	}
+
+	err = models.ApproveUser(h.db, id, cargoID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Registo não encontrado ou email por confirmar", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error approving user: %v", err)
+		http.Error(w, "Erro ao aprovar registo", http.StatusInternalServerError)
+		return
+	}
+
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+	h.logService.LogAction(adminID, "user", "registo_aprovado", nil, map[string]interface{}{
+		"user_id":  id,
+		"cargo_id": cargoID,
+	})
+
+	user, err := models.GetUserByID(h.db, id)
+	if err != nil {
+		log.Printf("Error fetching user: %v", err)
+	} else {
+		emailService := services.NewEmailService(h.cfg.Email)
+		link := fmt.Sprintf("%s/login", h.cfg.Server.BaseURL)
+		if err := emailService.SendContaAprovadaEmail(user.Email, user.Nome, cargo.Descricao, link); err != nil {
+			log.Printf("Error sending approval email: %v", err)
+		}
+	}
+
+	http.Redirect(w, r, "/admin/registos", http.StatusSeeOther)
+}
+
+// Reject handles rejecting a registration, deleting the account
+func (h *RegistoHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUserByID(h.db, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Registo não encontrado", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching user: %v", err)
+		http.Error(w, "Erro ao rejeitar registo", http.StatusInternalServerError)
+		return
+	}
+
+	err = models.RejectUser(h.db, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Registo não encontrado", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error rejecting user: %v", err)
+		http.Error(w, "Erro ao rejeitar registo", http.StatusInternalServerError)
+		return
+	}
+
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+	h.logService.LogAction(adminID, "user", "registo_rejeitado", map[string]interface{}{
+		"user_id": id,
+		"nome":    user.Nome,
+		"email":   user.Email,
+	}, nil)
+
+	http.Redirect(w, r, "/admin/registos", http.StatusSeeOther)
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"v0/config"
+	"v0/database"
+	"v0/models"
+	"v0/services"
+	"v0/utils"
+
+	"github.com/gorilla/sessions"
+)
+
+// RelatorioHandler handles the configurable PDF reports
+type RelatorioHandler struct {
+	db              *sql.DB
+	store           sessions.Store
+	deadlineService *services.DeadlineService
+	pdfService      *services.PDFService
+}
+
+// NewRelatorioHandler creates a new RelatorioHandler
+func NewRelatorioHandler(db *sql.DB, store sessions.Store, cfg *config.Config) *RelatorioHandler {
+	return &RelatorioHandler{
+		db:              db,
+		store:           store,
+		deadlineService: services.NewDeadlineService(db),
+		pdfService:      services.NewPDFService(cfg.Report),
+	}
+}
+
+// Page handles the report builder page
+func (h *RelatorioHandler) Page(w http.ResponseWriter, r *http.Request) {
+	estados, err := database.GetEstados(h.db)
+	if err != nil {
+		log.Printf("Error fetching estados: %v", err)
+		http.Error(w, "Erro ao buscar estados", http.StatusInternalServerError)
+		return
+	}
+
+	tipos, err := database.GetTipos(h.db)
+	if err != nil {
+		log.Printf("Error fetching tipos: %v", err)
+		http.Error(w, "Erro ao buscar tipos", http.StatusInternalServerError)
+		return
+	}
+
+	defaults := make(map[string]bool)
+	for _, key := range services.DefaultReportColumns {
+		defaults[key] = true
+	}
+
+	hoje := utils.Now()
+
+	// Render template
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/relatorios/form.html"))
+	data := struct {
+		Title   string
+		User    interface{}
+		Estados []struct {
+			ID        int
+			Descricao string
+		}
+		Tipos []struct {
+			ID        int
+			Descricao string
+		}
+		Colunas  []services.ReportColumn
+		Defaults map[string]bool
+		Inicio   string
+		Fim      string
+	}{
+		Title:    "Relatórios",
+		User:     getSessionUser(h.db, h.store, r),
+		Estados:  estados,
+		Tipos:    tipos,
+		Colunas:  services.ReportColumns,
+		Defaults: defaults,
+		Inicio:   hoje.Format("2006-01-02"),
+		Fim:      hoje.AddDate(0, 1, 0).Format("2006-01-02"),
+	}
+	tmpl.Execute(w, data)
+}
+
+// PDF handles generating a report from the builder form
+func (h *RelatorioHandler) PDF(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	inicio, err := time.ParseInLocation("2006-01-02", query.Get("inicio"), utils.Location())
+	if err != nil {
+		http.Error(w, "Data de início inválida", http.StatusBadRequest)
+		return
+	}
+
+	fim, err := time.ParseInLocation("2006-01-02", query.Get("fim"), utils.Location())
+	if err != nil {
+		http.Error(w, "Data de fim inválida", http.StatusBadRequest)
+		return
+	}
+
+	if fim.Before(inicio) {
+		http.Error(w, "Intervalo de datas inválido", http.StatusBadRequest)
+		return
+	}
+
+	estadoID, _ := strconv.Atoi(query.Get("estado_id"))
+	tipoID, _ := strconv.Atoi(query.Get("tipo_id"))
+	filtro := models.ConcursoFiltro{
+		EstadoID:     estadoID,
+		TipoID:       tipoID,
+		Entidade:     strings.TrimSpace(query.Get("entidade")),
+		Visibilidade: getVisibilidade(h.db, h.store, r),
+	}
+
+	// The end date is inclusive
+	items, err := h.deadlineService.Prazos(filtro, inicio, fim.AddDate(0, 0, 1))
+	if err != nil {
+		log.Printf("Error fetching report deadlines: %v", err)
+		http.Error(w, "Erro ao buscar prazos", http.StatusInternalServerError)
+		return
+	}
+
+	// Describe the applied filters under the title
+	filtros := []string{"Período: " + inicio.Format("2006-01-02") + " a " + fim.Format("2006-01-02")}
+	if estadoID > 0 {
+		filtros = append(filtros, "Estado: "+utils.GetEstadoString(estadoID))
+	}
+	if tipoID > 0 {
+		filtros = append(filtros, "Objeto: "+utils.GetObjetoString(tipoID))
+	}
+	if filtro.Entidade != "" {
+		filtros = append(filtros, "Entidade: "+filtro.Entidade)
+	}
+
+	titulo := strings.TrimSpace(query.Get("titulo"))
+	if titulo == "" {
+		titulo = "Relatório de Prazos"
+	}
+
+	pdf, err := h.pdfService.GenerateReport(items, services.ReportOptions{
+		Titulo:      titulo,
+		Subtitulo:   strings.Join(filtros, " | "),
+		Colunas:     query["colunas"],
+		Paisagem:    query.Get("orientacao") == "paisagem",
+		Agrupamento: query.Get("agrupamento"),
+	})
+	if err != nil {
+		log.Printf("Error generating report: %v", err)
+		http.Error(w, "Erro ao gerar relatório", http.StatusInternalServerError)
+		return
+	}
+
+	// Send PDF
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", "attachment; filename=relatorio.pdf")
+
+	if err := pdf.Output(w); err != nil {
+		log.Printf("Error writing report: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+
+	"v0/models"
+	"v0/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+// SessaoHandler handles the active sessions page of the logged in user
+type SessaoHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	logService *services.LogService
+}
+
+// NewSessaoHandler creates a new SessaoHandler
+func NewSessaoHandler(db *sql.DB, store sessions.Store) *SessaoHandler {
+	return &SessaoHandler{
+		db:         db,
+		store:      store,
+		logService: services.NewLogService(db),
+	}
+}
+
+// List handles the active sessions page
+func (h *SessaoHandler) List(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+
+	sessoes, err := models.GetUserSessoes(h.db, userID, session.ID)
+	if err != nil {
+		log.Printf("Error fetching sessions: %v", err)
+		http.Error(w, "Erro ao buscar sessões", http.StatusInternalServerError)
+		return
+	}
+
+	// Render template
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/conta/sessoes.html"))
+	data := struct {
+		Title   string
+		User    interface{}
+		Sessoes []models.Sessao
+	}{
+		Title:   "Sessões Ativas",
+		User:    getSessionUser(h.db, h.store, r),
+		Sessoes: sessoes,
+	}
+	tmpl.Execute(w, data)
+}
+
+// Revoke handles ending one of the user's sessions
+func (h *SessaoHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+	id := mux.Vars(r)["id"]
+
+	if err := models.DeleteUserSessao(h.db, userID, id); err != nil {
+		log.Printf("Error revoking session: %v", err)
+		http.Error(w, "Erro ao terminar sessão", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the revocation
+	h.logService.LogAction(userID, "sessao", "revogar", nil, map[string]interface{}{
+		"user_id": userID,
+	})
+
+	http.Redirect(w, r, "/conta/sessoes", http.StatusSeeOther)
+}
+
+// RevokeOthers handles ending all of the user's sessions except the current one
+func (h *SessaoHandler) RevokeOthers(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+
+	if err := models.DeleteUserSessoes(h.db, userID, session.ID); err != nil {
+		log.Printf("Error revoking sessions: %v", err)
+		http.Error(w, "Erro ao terminar sessões", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the revocation
+	h.logService.LogAction(userID, "sessao", "revogar_outras", nil, map[string]interface{}{
+		"user_id": userID,
+	})
+
+	http.Redirect(w, r, "/conta/sessoes", http.StatusSeeOther)
+}
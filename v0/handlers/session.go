@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"v0/middleware"
+	"v0/models"
+
+	"github.com/gorilla/sessions"
+)
+
+// SessionUser holds the logged in user shown in the page layout
+type SessionUser struct {
+	ID        int
+	Nome      string
+	CargoID   int
+	CargoDesc string
+	// Permissoes decides which links and actions the templates show
+	Permissoes models.Permissoes
+	// CSRFToken goes in every form that changes data, as {{$.User.CSRFToken}}
+	CSRFToken string
+}
+
+// Pode checks if the user has a permission, e.g. {{if .User.Pode "concurso.edit"}} in templates
+func (u SessionUser) Pode(codigo string) bool {
+	return u.Permissoes.Has(codigo)
+}
+
+// getSessionUser builds the layout user from the session, loading the name, cargo and permissions from the database
+func getSessionUser(db *sql.DB, store sessions.Store, r *http.Request) SessionUser {
+	session, _ := store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+	cargoID, _ := session.Values["cargo"].(int)
+
+	user := SessionUser{
+		ID:         userID,
+		Nome:       "User", // Default name
+		CargoID:    cargoID,
+		Permissoes: models.Permissoes{},
+		CSRFToken:  csrfToken(store, r),
+	}
+
+	// Get actual user name if possible
+	if userID > 0 {
+		var nome string
+		err := db.QueryRow("SELECT nome FROM user WHERE id_user = ?", userID).Scan(&nome)
+		if err == nil {
+			user.Nome = nome
+		}
+	}
+
+	if cargoID > 0 {
+		var descricao string
+		if err := db.QueryRow("SELECT descricao FROM cargo WHERE id_cargo = ?", cargoID).Scan(&descricao); err == nil {
+			user.CargoDesc = descricao
+		}
+
+		permissoes, err := models.GetCargoPermissoes(db, cargoID)
+		if err != nil {
+			log.Printf("Error fetching permissions: %v", err)
+		} else {
+			user.Permissoes = permissoes
+		}
+	}
+
+	return user
+}
+
+// getVisibilidade returns which concursos the logged in user can see
+func getVisibilidade(db *sql.DB, store sessions.Store, r *http.Request) models.Visibilidade {
+	session, _ := store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+	cargoID, _ := session.Values["cargo"].(int)
+
+	permissoes, err := models.GetCargoPermissoes(db, cargoID)
+	if err != nil {
+		log.Printf("Error fetching permissions: %v", err)
+	}
+
+	return models.Visibilidade{
+		Todos:  permissoes.Has(models.PermConcursoViewAll),
+		UserID: userID,
+	}
+}
+
+// csrfToken returns the CSRF token of the session, for the forms of pages without the layout user
+func csrfToken(store sessions.Store, r *http.Request) string {
+	session, _ := store.Get(r, "session-name")
+	token, _ := session.Values[middleware.CSRFField].(string)
+	return token
+}
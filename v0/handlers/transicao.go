@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"v0/database"
+	"v0/models"
+	"v0/services"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+)
+
+// TransicaoHandler handles the estado workflow admin requests
+type TransicaoHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	logService *services.LogService
+}
+
+// NewTransicaoHandler creates a new TransicaoHandler
+func NewTransicaoHandler(db *sql.DB, store sessions.Store) *TransicaoHandler {
+	return &TransicaoHandler{
+		db:         db,
+		store:      store,
+		logService: services.NewLogService(db),
+	}
+}
+
+// campoItem is a field a transition can require, for the admin page
+type campoItem struct {
+	Campo string
+	Label string
+}
+
+// List handles the estado transitions admin page
+func (h *TransicaoHandler) List(w http.ResponseWriter, r *http.Request) {
+	transicoes, err := models.GetTransicoes(h.db)
+	if err != nil {
+		log.Printf("Error fetching transitions: %v", err)
+		http.Error(w, "Erro ao buscar transições", http.StatusInternalServerError)
+		return
+	}
+
+	estados, err := database.GetEstados(h.db)
+	if err != nil {
+		log.Printf("Error fetching estados: %v", err)
+		http.Error(w, "Erro ao buscar estados", http.StatusInternalServerError)
+		return
+	}
+
+	cargos, err := models.GetAllCargos(h.db)
+	if err != nil {
+		log.Printf("Error fetching cargos: %v", err)
+		http.Error(w, "Erro ao buscar cargos", http.StatusInternalServerError)
+		return
+	}
+
+	var campos []campoItem
+	for campo, label := range models.CampoLabels {
+		campos = append(campos, campoItem{Campo: campo, Label: label})
+	}
+	sort.Slice(campos, func(i, j int) bool {
+		return campos[i].Label < campos[j].Label
+	})
+
+	// Render template
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/admin/transicoes/list.html"))
+	data := struct {
+		Title      string
+		User       interface{}
+		Transicoes []models.Transicao
+		Estados    []struct {
+			ID        int
+			Descricao string
+		}
+		Cargos []models.Cargo
+		Campos []campoItem
+	}{
+		Title:      "Transições de Estado",
+		User:       getSessionUser(h.db, h.store, r),
+		Transicoes: transicoes,
+		Estados:    estados,
+		Cargos:     cargos,
+		Campos:     campos,
+	}
+	tmpl.Execute(w, data)
+}
+
+// Save handles the create transition form submission
+func (h *TransicaoHandler) Save(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if err := r.ParseForm(); err != nil {
+		log.Printf("Form parse error: %v", err)
+		http.Error(w, "Erro ao processar formulário", http.StatusBadRequest)
+		return
+	}
+
+	origemID, err := strconv.Atoi(r.FormValue("estado_origem_id"))
+	if err != nil {
+		http.Error(w, "Estado de origem inválido", http.StatusBadRequest)
+		return
+	}
+	destinoID, err := strconv.Atoi(r.FormValue("estado_destino_id"))
+	if err != nil {
+		http.Error(w, "Estado de destino inválido", http.StatusBadRequest)
+		return
+	}
+	if origemID == destinoID {
+		http.Error(w, "Os estados de origem e destino devem ser diferentes", http.StatusBadRequest)
+		return
+	}
+
+	transicao := &models.Transicao{
+		EstadoOrigemID:  origemID,
+		EstadoDestinoID: destinoID,
+	}
+	if msg := parseTransicaoForm(r, transicao); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	_, err = models.GetTransicao(h.db, origemID, destinoID)
+	if err == nil {
+		http.Error(w, "A transição entre estes estados já existe", http.StatusBadRequest)
+		return
+	}
+	if err != sql.ErrNoRows {
+		log.Printf("Error fetching transition: %v", err)
+		http.Error(w, "Erro ao criar transição", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.CreateTransicao(h.db, transicao); err != nil {
+		log.Printf("Error creating transition: %v", err)
+		http.Error(w, "Erro ao criar transição", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the create action
+	h.logService.LogCreate(adminID, "estado_transicao", transicao)
+
+	http.Redirect(w, r, "/admin/transicoes", http.StatusSeeOther)
+}
+
+// Update handles saving the required fields, notification, action and cargos of a transition
+func (h *TransicaoHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID da transição inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if err := r.ParseForm(); err != nil {
+		log.Printf("Form parse error: %v", err)
+		http.Error(w, "Erro ao processar formulário", http.StatusBadRequest)
+		return
+	}
+
+	old, err := models.GetTransicaoByID(h.db, id)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching transition: %v", err)
+		http.Error(w, "Erro ao buscar transição", http.StatusInternalServerError)
+		return
+	}
+
+	transicao := *old
+	if msg := parseTransicaoForm(r, &transicao); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := models.UpdateTransicao(h.db, &transicao); err != nil {
+		log.Printf("Error updating transition: %v", err)
+		http.Error(w, "Erro ao guardar transição", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the update action
+	h.logService.LogUpdate(adminID, "estado_transicao", old, transicao)
+
+	http.Redirect(w, r, "/admin/transicoes", http.StatusSeeOther)
+}
+
+// Delete handles the delete transition request
+func (h *TransicaoHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID da transição inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get user ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	old, err := models.GetTransicaoByID(h.db, id)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching transition: %v", err)
+		http.Error(w, "Erro ao excluir transição", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.DeleteTransicao(h.db, id); err != nil {
+		log.Printf("Error deleting transition: %v", err)
+		http.Error(w, "Erro ao excluir transição", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the delete action
+	h.logService.LogDelete(adminID, "estado_transicao", old)
+
+	http.Redirect(w, r, "/admin/transicoes", http.StatusSeeOther)
+}
+
+// parseTransicaoForm reads the action, required fields, notification and cargos of a transition form,
+// returning the error message of an invalid value
+func parseTransicaoForm(r *http.Request, t *models.Transicao) string {
+	// The action names the log entries of the transition, "transicao_<acao>"
+	acao := strings.TrimSpace(r.FormValue("acao"))
+	if acao == "" || strings.Trim(acao, "abcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+		return "Ação inválida: use apenas letras minúsculas, números e _"
+	}
+	t.Acao = acao
+
+	t.CamposObrigatorios = nil
+	for _, campo := range r.Form["campos"] {
+		if _, ok := models.CampoLabels[campo]; !ok {
+			return "Campo obrigatório inválido"
+		}
+		t.CamposObrigatorios = append(t.CamposObrigatorios, campo)
+	}
+
+	t.Cargos = nil
+	for _, value := range r.Form["cargos"] {
+		cargo, err := strconv.Atoi(value)
+		if err != nil {
+			return "Cargo inválido"
+		}
+		t.Cargos = append(t.Cargos, cargo)
+	}
+
+	t.Notificar = r.FormValue("notificar") != ""
+	return ""
+}
@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"v0/models"
+	"v0/services"
+
+	"github.com/gorilla/sessions"
+)
+
+const (
+	// max2FAFalhas is how many wrong codes end the pending login
+	max2FAFalhas = 5
+	// pending2FATTL is how long the second login step stays open after the password
+	pending2FATTL = 5 * time.Minute
+)
+
+// clearPending2FA removes the values of a pending second login step from the session
+func clearPending2FA(session *sessions.Session) {
+	for _, key := range []string{"2fa_user_id", "2fa_cargo", "2fa_inicio", "2fa_falhas", "2fa_secret"} {
+		delete(session.Values, key)
+	}
+}
+
+// pending2FA returns the user whose password was accepted and who still has to confirm the second factor
+func pending2FA(session *sessions.Session) (int, int, bool) {
+	userID, _ := session.Values["2fa_user_id"].(int)
+	cargoID, _ := session.Values["2fa_cargo"].(int)
+	inicio, _ := session.Values["2fa_inicio"].(int64)
+
+	if userID == 0 || time.Since(time.Unix(inicio, 0)) > pending2FATTL {
+		return 0, 0, false
+	}
+
+	return userID, cargoID, true
+}
+
+// enrolmentSecret returns the secret being enrolled, kept in the session until a code confirms it
+func enrolmentSecret(db *sql.DB, session *sessions.Session, userID int) (string, template.URL, error) {
+	user, err := models.GetUserByID(db, userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	secret, _ := session.Values["2fa_secret"].(string)
+	if secret == "" {
+		if secret, err = services.NewTOTPSecret(user.Email); err != nil {
+			return "", "", err
+		}
+		session.Values["2fa_secret"] = secret
+	}
+
+	qrCode, err := services.TOTPQRCode(user.Email, secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	// The data URI is made here, so it is trusted as an image source
+	return secret, template.URL(qrCode), nil
+}
+
+// checkTOTP checks a code from the authenticator app of a user, refusing a code that was already accepted
+func checkTOTP(db *sql.DB, userID int, code, secret string) (bool, error) {
+	passo, ok := services.ValidateTOTP(code, secret)
+	if !ok {
+		return false, nil
+	}
+	return models.UseTOTPStep(db, userID, passo)
+}
+
+// twoFactorLoginPage is the data of the second login step page
+type twoFactorLoginPage struct {
+	Title     string
+	Ativar    bool
+	QRCode    template.URL
+	Secret    string
+	Codigos   []string
+	Erro      string
+	CSRFToken string
+}
+
+// renderTwoFactorLogin renders the second login step page
+func (h *AuthHandler) renderTwoFactorLogin(w http.ResponseWriter, r *http.Request, data twoFactorLoginPage) {
+	data.CSRFToken = csrfToken(h.store, r)
+	tmpl := template.Must(template.ParseFiles("templates/auth/2fa.html"))
+	tmpl.Execute(w, data)
+}
+
+// Login2FA handles the second login step, accepting a code from the authenticator app or a recovery code
+func (h *AuthHandler) Login2FA(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, cargoID, ok := pending2FA(session)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	data := twoFactorLoginPage{Title: "Verificação em Dois Passos"}
+	if r.Method != http.MethodPost {
+		h.renderTwoFactorLogin(w, r, data)
+		return
+	}
+
+	secret, ativo, err := models.GetTOTP(h.db, userID)
+	if err != nil {
+		log.Printf("Error fetching 2FA: %v", err)
+		http.Error(w, "Erro ao fazer login", http.StatusInternalServerError)
+		return
+	}
+	if !ativo {
+		http.Redirect(w, r, "/login/2fa/ativar", http.StatusSeeOther)
+		return
+	}
+
+	code := strings.TrimSpace(r.FormValue("code"))
+	metodo := "totp"
+	valido, err := checkTOTP(h.db, userID, code, secret)
+	if err != nil {
+		log.Printf("Error checking 2FA code: %v", err)
+		http.Error(w, "Erro ao fazer login", http.StatusInternalServerError)
+		return
+	}
+	if !valido {
+		metodo = "recovery"
+		if valido, err = models.UseRecoveryCode(h.db, userID, code); err != nil {
+			log.Printf("Error using recovery code: %v", err)
+			http.Error(w, "Erro ao fazer login", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if valido {
+		if !h.startSession(w, r, userID, cargoID, metodo) {
+			return
+		}
+		http.Redirect(w, r, "/concursos", http.StatusSeeOther)
+		return
+	}
+
+	falhas, _ := session.Values["2fa_falhas"].(int)
+	falhas++
+	ip := services.ClientIP(r)
+
+	// Log the failed attempt
+	h.logService.LogAction(userID, "user", "login_2fa_falhado", nil, map[string]interface{}{
+		"user_id": userID,
+		"falhas":  falhas,
+		"ip":      ip,
+	})
+	h.recordTentativa(models.LoginTentativa{UserID: userID, IP: ip, Motivo: models.Motivo2FA})
+
+	// Wrong codes count towards the account lockout like wrong passwords
+	loginErr, err := models.RegisterLoginFailure(h.db, userID, lockoutPolicy(h.cfg))
+	if err != nil {
+		log.Printf("Error registering login failure: %v", err)
+		http.Error(w, "Erro ao fazer login", http.StatusInternalServerError)
+		return
+	}
+	if loginErr.NovoBloqueio {
+		notifyLockout(h.db, h.cfg, h.logService, userID, ip)
+	}
+	time.Sleep(h.loginDelay(loginErr.Falhas))
+
+	// Too many wrong codes start the login over
+	if falhas >= max2FAFalhas || loginErr.Bloqueado {
+		clearPending2FA(session)
+		if err := session.Save(r, w); err != nil {
+			log.Printf("Session save error: %v", err)
+		}
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	session.Values["2fa_falhas"] = falhas
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Session save error: %v", err)
+		http.Error(w, "Erro ao salvar sessão", http.StatusInternalServerError)
+		return
+	}
+
+	data.Erro = "Código inválido"
+	h.renderTwoFactorLogin(w, r, data)
+}
+
+// Enrol2FA handles the enrolment required at login for users whose cargo requires 2FA
+func (h *AuthHandler) Enrol2FA(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, cargoID, ok := pending2FA(session)
+	if !ok {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	_, ativo, err := models.GetTOTP(h.db, userID)
+	if err != nil {
+		log.Printf("Error fetching 2FA: %v", err)
+		http.Error(w, "Erro ao fazer login", http.StatusInternalServerError)
+		return
+	}
+	if ativo {
+		http.Redirect(w, r, "/login/2fa", http.StatusSeeOther)
+		return
+	}
+
+	secret, qrCode, err := enrolmentSecret(h.db, session, userID)
+	if err != nil {
+		log.Printf("Error creating 2FA secret: %v", err)
+		http.Error(w, "Erro ao ativar 2FA", http.StatusInternalServerError)
+		return
+	}
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Session save error: %v", err)
+		http.Error(w, "Erro ao salvar sessão", http.StatusInternalServerError)
+		return
+	}
+
+	data := twoFactorLoginPage{Title: "Ativar Verificação em Dois Passos", Ativar: true, QRCode: qrCode, Secret: secret}
+	if r.Method != http.MethodPost {
+		h.renderTwoFactorLogin(w, r, data)
+		return
+	}
+
+	valido, err := checkTOTP(h.db, userID, r.FormValue("code"), secret)
+	if err != nil {
+		log.Printf("Error checking 2FA code: %v", err)
+		http.Error(w, "Erro ao ativar 2FA", http.StatusInternalServerError)
+		return
+	}
+	if !valido {
+		data.Erro = "Código inválido"
+		h.renderTwoFactorLogin(w, r, data)
+		return
+	}
+
+	codigos, err := models.GenerateRecoveryCodes()
+	if err != nil {
+		log.Printf("Error generating recovery codes: %v", err)
+		http.Error(w, "Erro ao ativar 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.EnableTOTP(h.db, userID, secret, codigos); err != nil {
+		log.Printf("Error enabling 2FA: %v", err)
+		http.Error(w, "Erro ao ativar 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the enrolment
+	h.logService.LogAction(userID, "user", "2fa_ativar", nil, map[string]interface{}{
+		"user_id": userID,
+	})
+
+	if !h.startSession(w, r, userID, cargoID, "totp") {
+		return
+	}
+
+	// The recovery codes are only shown once
+	h.renderTwoFactorLogin(w, r, twoFactorLoginPage{Title: "Códigos de Recuperação", Codigos: codigos})
+}
+
+// TwoFactorHandler handles the 2FA settings of the logged in user
+type TwoFactorHandler struct {
+	db         *sql.DB
+	store      sessions.Store
+	logService *services.LogService
+}
+
+// NewTwoFactorHandler creates a new TwoFactorHandler
+func NewTwoFactorHandler(db *sql.DB, store sessions.Store) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		db:         db,
+		store:      store,
+		logService: services.NewLogService(db),
+	}
+}
+
+// render renders the 2FA settings page, with the new recovery codes when they were just generated
+func (h *TwoFactorHandler) render(w http.ResponseWriter, r *http.Request, erro string, codigos []string) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+	cargoID, _ := session.Values["cargo"].(int)
+
+	_, ativo, err := models.GetTOTP(h.db, userID)
+	if err != nil {
+		log.Printf("Error fetching 2FA: %v", err)
+		http.Error(w, "Erro ao buscar 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	exigido, err := models.CargoExige2FA(h.db, cargoID)
+	if err != nil {
+		log.Printf("Error fetching cargo 2FA: %v", err)
+		http.Error(w, "Erro ao buscar 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Title     string
+		User      interface{}
+		Ativo     bool
+		Exigido   bool
+		Restantes int
+		QRCode    template.URL
+		Secret    string
+		Codigos   []string
+		Erro      string
+	}{
+		Title:   "Verificação em Dois Passos",
+		User:    getSessionUser(h.db, h.store, r),
+		Ativo:   ativo,
+		Exigido: exigido,
+		Codigos: codigos,
+		Erro:    erro,
+	}
+
+	if ativo {
+		if data.Restantes, err = models.CountRecoveryCodes(h.db, userID); err != nil {
+			log.Printf("Error counting recovery codes: %v", err)
+			http.Error(w, "Erro ao buscar 2FA", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		if data.Secret, data.QRCode, err = enrolmentSecret(h.db, session, userID); err != nil {
+			log.Printf("Error creating 2FA secret: %v", err)
+			http.Error(w, "Erro ao ativar 2FA", http.StatusInternalServerError)
+			return
+		}
+		if err := session.Save(r, w); err != nil {
+			log.Printf("Session save error: %v", err)
+			http.Error(w, "Erro ao salvar sessão", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/conta/2fa.html"))
+	tmpl.Execute(w, data)
+}
+
+// Page handles the 2FA settings page
+func (h *TwoFactorHandler) Page(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, "", nil)
+}
+
+// Enable handles enabling 2FA with the code of the secret being enrolled
+func (h *TwoFactorHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+	secret, _ := session.Values["2fa_secret"].(string)
+
+	valido, err := checkTOTP(h.db, userID, r.FormValue("code"), secret)
+	if err != nil {
+		log.Printf("Error checking 2FA code: %v", err)
+		http.Error(w, "Erro ao ativar 2FA", http.StatusInternalServerError)
+		return
+	}
+	if !valido {
+		h.render(w, r, "Código inválido", nil)
+		return
+	}
+
+	codigos, err := models.GenerateRecoveryCodes()
+	if err != nil {
+		log.Printf("Error generating recovery codes: %v", err)
+		http.Error(w, "Erro ao ativar 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.EnableTOTP(h.db, userID, secret, codigos); err != nil {
+		log.Printf("Error enabling 2FA: %v", err)
+		http.Error(w, "Erro ao ativar 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	delete(session.Values, "2fa_secret")
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Session save error: %v", err)
+	}
+
+	// Log the enrolment
+	h.logService.LogAction(userID, "user", "2fa_ativar", nil, map[string]interface{}{
+		"user_id": userID,
+	})
+
+	h.render(w, r, "", codigos)
+}
+
+// checkCode checks a code from the authenticator app of the logged in user
+func (h *TwoFactorHandler) checkCode(r *http.Request, userID int) (bool, error) {
+	secret, ativo, err := models.GetTOTP(h.db, userID)
+	if err != nil {
+		return false, err
+	}
+	if !ativo {
+		return false, nil
+	}
+	return checkTOTP(h.db, userID, r.FormValue("code"), secret)
+}
+
+// Disable handles disabling 2FA, which needs a current code and is not allowed when the cargo requires 2FA
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+	cargoID, _ := session.Values["cargo"].(int)
+
+	exigido, err := models.CargoExige2FA(h.db, cargoID)
+	if err != nil {
+		log.Printf("Error fetching cargo 2FA: %v", err)
+		http.Error(w, "Erro ao desativar 2FA", http.StatusInternalServerError)
+		return
+	}
+	if exigido {
+		h.render(w, r, "O seu cargo exige a verificação em dois passos", nil)
+		return
+	}
+
+	valido, err := h.checkCode(r, userID)
+	if err != nil {
+		log.Printf("Error fetching 2FA: %v", err)
+		http.Error(w, "Erro ao desativar 2FA", http.StatusInternalServerError)
+		return
+	}
+	if !valido {
+		h.render(w, r, "Código inválido", nil)
+		return
+	}
+
+	if err := models.DisableTOTP(h.db, userID); err != nil {
+		log.Printf("Error disabling 2FA: %v", err)
+		http.Error(w, "Erro ao desativar 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the change
+	h.logService.LogAction(userID, "user", "2fa_desativar", nil, map[string]interface{}{
+		"user_id": userID,
+	})
+
+	http.Redirect(w, r, "/conta/2fa", http.StatusSeeOther)
+}
+
+// RecoveryCodes handles generating a new set of recovery codes, which needs a current code
+func (h *TwoFactorHandler) RecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	session, _ := h.store.Get(r, "session-name")
+	userID, _ := session.Values["user_id"].(int)
+
+	valido, err := h.checkCode(r, userID)
+	if err != nil {
+		log.Printf("Error fetching 2FA: %v", err)
+		http.Error(w, "Erro ao gerar códigos", http.StatusInternalServerError)
+		return
+	}
+	if !valido {
+		h.render(w, r, "Código inválido", nil)
+		return
+	}
+
+	codigos, err := models.GenerateRecoveryCodes()
+	if err != nil {
+		log.Printf("Error generating recovery codes: %v", err)
+		http.Error(w, "Erro ao gerar códigos", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.ReplaceRecoveryCodes(h.db, userID, codigos); err != nil {
+		log.Printf("Error saving recovery codes: %v", err)
+		http.Error(w, "Erro ao gerar códigos", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the change
+	h.logService.LogAction(userID, "user", "2fa_codigos", nil, map[string]interface{}{
+		"user_id": userID,
+	})
+
+	h.render(w, r, "", codigos)
+}
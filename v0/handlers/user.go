@@ -2,10 +2,10 @@ package handlers
 
 import (
 	"database/sql"
+	"html/template"
 	"log"
 	"net/http"
 	"strconv"
-	"text/template"
 
 	"v0/models"
 	"v0/services"
@@ -17,12 +17,12 @@ import (
 // UserHandler handles user-related requests
 type UserHandler struct {
 	db         *sql.DB
-	store      *sessions.CookieStore
+	store      sessions.Store
 	logService *services.LogService
 }
 
 // NewUserHandler creates a new UserHandler
-func NewUserHandler(db *sql.DB, store *sessions.CookieStore) *UserHandler {
+func NewUserHandler(db *sql.DB, store sessions.Store) *UserHandler {
 	return &UserHandler{
 		db:         db,
 		store:      store,
@@ -73,29 +73,7 @@ func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	// Get user info from session
-	session, _ := h.store.Get(r, "session-name")
-	userID, _ := session.Values["user_id"].(int)
-	cargoID, _ := session.Values["cargo"].(int)
-
-	user := struct {
-		ID      int
-		Nome    string
-		CargoID int
-	}{
-		ID:      userID,
-		Nome:    "User", // Default name
-		CargoID: cargoID,
-	}
-
-	// Get actual user name if possible
-	if userID > 0 {
-		var nome string
-		err := h.db.QueryRow("SELECT nome FROM user WHERE id_user = ?", userID).Scan(&nome)
-		if err == nil {
-			user.Nome = nome
-		}
-	}
+	user := getSessionUser(h.db, h.store, r)
 
 	// Render template
 	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/admin/users/list.html"))
@@ -121,29 +99,7 @@ func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user info from session
-	session, _ := h.store.Get(r, "session-name")
-	userID, _ := session.Values["user_id"].(int)
-	cargoID, _ := session.Values["cargo"].(int)
-
-	user := struct {
-		ID      int
-		Nome    string
-		CargoID int
-	}{
-		ID:      userID,
-		Nome:    "User", // Default name
-		CargoID: cargoID,
-	}
-
-	// Get actual user name if possible
-	if userID > 0 {
-		var nome string
-		err := h.db.QueryRow("SELECT nome FROM user WHERE id_user = ?", userID).Scan(&nome)
-		if err == nil {
-			user.Nome = nome
-		}
-	}
+	user := getSessionUser(h.db, h.store, r)
 
 	// Render template
 	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/admin/users/create.html"))
@@ -185,6 +141,11 @@ func (h *UserHandler) Save(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if err := models.ValidatePassword(password); err != nil {
+			http.Error(w, "Password inválida: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// Check if email already exists
 		exists, err := models.EmailExists(h.db, email)
 		if err != nil {
@@ -205,7 +166,12 @@ func (h *UserHandler) Save(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Create user
-		if err := models.CreateUser(h.db, nome, email, password, cargoID); err != nil {
+		err = models.CreateUser(h.db, nome, email, password, cargoID)
+		if err == models.ErrNomeEmUso || err == models.ErrEmailEmUso {
+			http.Error(w, "User ou email já em uso", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
 			log.Printf("User creation error: %v", err)
 			http.Error(w, "Erro ao criar user", http.StatusInternalServerError)
 			return
@@ -262,42 +228,30 @@ func (h *UserHandler) Edit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get admin info from session
-	session, _ := h.store.Get(r, "session-name")
-	adminID, _ := session.Values["user_id"].(int)
-	adminCargoID, _ := session.Values["cargo"].(int)
-
-	admin := struct {
-		ID      int
-		Nome    string
-		CargoID int
-	}{
-		ID:      adminID,
-		Nome:    "User", // Default name
-		CargoID: adminCargoID,
+	// Get the latest login attempts
+	tentativas, err := models.GetLoginTentativas(h.db, userID, 20)
+	if err != nil {
+		log.Printf("Error fetching login attempts: %v", err)
+		http.Error(w, "Erro ao buscar tentativas de login", http.StatusInternalServerError)
+		return
 	}
 
-	// Get actual admin name if possible
-	if adminID > 0 {
-		var nome string
-		err := h.db.QueryRow("SELECT nome FROM user WHERE id_user = ?", adminID).Scan(&nome)
-		if err == nil {
-			admin.Nome = nome
-		}
-	}
+	admin := getSessionUser(h.db, h.store, r)
 
 	// Render template
 	tmpl := template.Must(template.ParseFiles("templates/layout/base.html", "templates/admin/users/edit.html"))
 	data := struct {
-		Title  string
-		User   interface{}  // This is the admin
-		Target *models.User // This is the user being edited
-		Cargos []models.Cargo
+		Title      string
+		User       interface{}  // This is the admin
+		Target     *models.User // This is the user being edited
+		Cargos     []models.Cargo
+		Tentativas []models.LoginTentativa
 	}{
-		Title:  "Editar User",
-		User:   admin,
-		Target: user,
-		Cargos: cargos,
+		Title:      "Editar User",
+		User:       admin,
+		Target:     user,
+		Cargos:     cargos,
+		Tentativas: tentativas,
 	}
 	tmpl.Execute(w, data)
 }
@@ -375,7 +329,12 @@ func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Update user in database
-		if err := models.UpdateUser(h.db, updatedUser); err != nil {
+		err = models.UpdateUser(h.db, updatedUser)
+		if err == models.ErrNomeEmUso || err == models.ErrEmailEmUso {
+			http.Error(w, "User ou email já em uso", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
 			log.Printf("User update error: %v", err)
 			http.Error(w, "Erro ao atualizar user", http.StatusInternalServerError)
 			return
@@ -384,6 +343,21 @@ func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 		// Log the update action
 		h.logService.LogUpdate(adminID, "user", oldUser, updatedUser)
 
+		// Users who sign in through single sign-on can be kept from using a password
+		loginLocal := r.FormValue("login_local") != ""
+		if loginLocal != oldUser.LoginLocal {
+			if err := models.SetLoginLocal(h.db, userID, loginLocal); err != nil {
+				log.Printf("Local login update error: %v", err)
+				http.Error(w, "Erro ao atualizar login com password", http.StatusInternalServerError)
+				return
+			}
+
+			h.logService.LogAction(adminID, "user", "login_local", nil, map[string]interface{}{
+				"user_id":     userID,
+				"login_local": loginLocal,
+			})
+		}
+
 		// Update password if provided
 		if password != "" && confirmPassword != "" {
 			if password != confirmPassword {
@@ -391,7 +365,17 @@ func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			if err := models.UpdatePassword(h.db, userID, password); err != nil {
+			if err := models.ValidatePassword(password); err != nil {
+				http.Error(w, "Password inválida: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			err := models.UpdatePassword(h.db, userID, password)
+			if err == models.ErrPasswordReutilizada {
+				http.Error(w, "Password inválida: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
 				log.Printf("Password update error: %v", err)
 				http.Error(w, "Erro ao atualizar password", http.StatusInternalServerError)
 				return
@@ -491,3 +475,35 @@ func (h *UserHandler) Reset(w http.ResponseWriter, r *http.Request) {
 	// Redireciona de volta à lista de users ou retorna sucesso
 	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
 }
+
+// Reset2FA handles an admin disabling the 2FA of a user who lost their device and recovery codes
+func (h *UserHandler) Reset2FA(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID do user inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Get admin ID from session for logging
+	session, _ := h.store.Get(r, "session-name")
+	adminID, _ := session.Values["user_id"].(int)
+
+	if _, err := models.GetUserByID(h.db, userID); err != nil {
+		log.Printf("Error fetching user for 2FA reset: %v", err)
+		http.Error(w, "User não encontrado", http.StatusNotFound)
+		return
+	}
+
+	if err := models.ResetTOTP(h.db, userID); err != nil {
+		log.Printf("Error resetting 2FA: %v", err)
+		http.Error(w, "Erro ao repor 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	// Log the reset
+	h.logService.LogAction(adminID, "user", "2fa_reset", nil, map[string]interface{}{
+		"user_id": userID,
+	})
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
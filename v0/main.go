@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,19 +13,57 @@ import (
 
 	"v0/config"
 	"v0/database"
+	"v0/models"
 	"v0/routes"
+	"v0/services"
+	"v0/utils"
 )
 
 func main() {
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML configuration file, overridden by environment variables")
+	flag.Parse()
+
+	// "config check" prints the effective configuration and exits
+	if flag.Arg(0) == "config" && flag.Arg(1) == "check" {
+		os.Exit(checkConfig(*configFile))
+	}
+
 	// Initialize logger
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.Println("Starting application...")
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.Load(*configFile)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	for _, aviso := range cfg.Avisos {
+		log.Printf("Warning: %s", aviso)
+	}
+
+	// Interpret deadline dates and times in the configured timezone
+	utils.SetLocation(cfg.Server.Location)
+	log.Printf("Deadlines timezone: %s", cfg.Server.Timezone)
+
+	// Apply the password policy, refusing the passwords of the breached list when it is present
+	passwordPolicy := models.PasswordPolicy{
+		MinLength:       cfg.Password.MinLength,
+		Historico:       cfg.Password.Historico,
+		Hash:            cfg.Password.Hash,
+		BcryptCost:      cfg.Password.BcryptCost,
+		Argon2Memoria:   uint32(cfg.Password.Argon2MemoriaKB),
+		Argon2Iteracoes: uint32(cfg.Password.Argon2Iteracoes),
+		Argon2Threads:   uint8(cfg.Password.Argon2Threads),
+	}
+	if cfg.Password.ListaComprometidas != "" {
+		passwordPolicy.Comprometidas, err = models.LoadPasswordList(cfg.Password.ListaComprometidas)
+		if err != nil {
+			log.Printf("Warning: breached password list not loaded: %v", err)
+		} else {
+			log.Printf("Breached password list: %d passwords", len(passwordPolicy.Comprometidas))
+		}
+	}
+	models.SetPasswordPolicy(passwordPolicy)
 
 	// Initialize database
 	db, err := database.Connect(cfg.Database)
@@ -33,8 +72,23 @@ func main() {
 	}
 	defer db.Close()
 
+	// Passwords stored without a hash never match, so their users have to get a new one
+	semHash, err := models.GetUnhashedPasswordUsers(db)
+	if err != nil {
+		log.Printf("Error checking stored passwords: %v", err)
+	}
+	for _, user := range semHash {
+		log.Printf("Warning: user %s (id %d) has a password stored without a hash and cannot log in; reset it", user.Nome, user.ID)
+	}
+
+	// Start the scheduled report emails
+	scheduler := services.NewScheduler(db, cfg)
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	go scheduler.Start(schedulerCtx)
+
 	// Initialize router
-	router := routes.SetupRoutes(db, cfg)
+	router := routes.SetupRoutes(db, cfg, scheduler)
 
 	// Create static directory if it doesn't exist
 	if _, err := os.Stat("./static"); os.IsNotExist(err) {
@@ -66,6 +120,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopScheduler()
 
 	// Create a deadline for server shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
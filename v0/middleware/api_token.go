@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+
+	"v0/models"
+
+	"github.com/gorilla/sessions"
+)
+
+// APIToken creates a middleware that logs in the user of an API token sent as a Bearer token. The token's
+// user is set on a session that lasts only for the request, and tokens only allow read requests. It must run
+// before CSRF, so that the request never loads or creates a browser session
+func APIToken(db *sql.DB, store sessions.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			if !strings.HasPrefix(auth, "Bearer ") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			switch r.Method {
+			case http.MethodGet, http.MethodHead:
+			default:
+				http.Error(w, "Os tokens de API só permitem pedidos de leitura", http.StatusForbidden)
+				return
+			}
+
+			user, err := models.GetAPITokenUser(db, strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")))
+			if err == sql.ErrNoRows {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, "Token de API inválido ou expirado", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				log.Printf("Error checking API token: %v", err)
+				http.Error(w, "Erro ao verificar token de API", http.StatusInternalServerError)
+				return
+			}
+
+			// Cookies are ignored, so that the session is a new one holding only the token's user
+			r.Header.Del("Cookie")
+			session, err := store.Get(r, "session-name")
+			if err != nil {
+				http.Error(w, "Session error", http.StatusInternalServerError)
+				log.Printf("Session error: %v", err)
+				return
+			}
+
+			session.Values["api_token"] = true
+			session.Values["authenticated"] = true
+			session.Values["user_id"] = user.UserID
+			session.Values["cargo"] = user.CargoID
+			session.Values["sessao_versao"] = user.SessaoVersao
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
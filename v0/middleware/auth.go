@@ -1,22 +1,17 @@
 package middleware
 
 import (
+	"database/sql"
 	"log"
 	"net/http"
 
-	"github.com/gorilla/sessions"
-)
+	"v0/models"
 
-// Role constants
-const (
-	RoleAdmin = 1
-	RoleSAV   = 2
-	RoleDCP   = 3
-	RoleGuest = 4
+	"github.com/gorilla/sessions"
 )
 
 // AuthMiddleware creates a middleware that checks if the user is authenticated
-func AuthMiddleware(store *sessions.CookieStore) func(http.Handler) http.Handler {
+func AuthMiddleware(db *sql.DB, store sessions.Store) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Check authentication
@@ -27,7 +22,7 @@ func AuthMiddleware(store *sessions.CookieStore) func(http.Handler) http.Handler
 				return
 			}
 
-			if auth, ok := session.Values["authenticated"].(bool); !ok || !auth {
+			if auth, ok := session.Values["authenticated"].(bool); !ok || !auth || !sessionCurrent(db, session) {
 				http.Redirect(w, r, "/login", http.StatusSeeOther)
 				return
 			}
@@ -38,8 +33,8 @@ func AuthMiddleware(store *sessions.CookieStore) func(http.Handler) http.Handler
 	}
 }
 
-// RoleMiddleware creates a middleware that checks if the user has the required role
-func RoleMiddleware(store *sessions.CookieStore, allowedRoles []int) func(http.Handler) http.Handler {
+// RequirePermission creates a middleware that checks if the user's cargo has a permission
+func RequirePermission(db *sql.DB, store sessions.Store, permissao string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Check authentication first
@@ -50,7 +45,7 @@ func RoleMiddleware(store *sessions.CookieStore, allowedRoles []int) func(http.H
 				return
 			}
 
-			if auth, ok := session.Values["authenticated"].(bool); !ok || !auth {
+			if auth, ok := session.Values["authenticated"].(bool); !ok || !auth || !sessionCurrent(db, session) {
 				http.Redirect(w, r, "/login", http.StatusSeeOther)
 				return
 			}
@@ -62,16 +57,15 @@ func RoleMiddleware(store *sessions.CookieStore, allowedRoles []int) func(http.H
 				return
 			}
 
-			// Check if the user's role is in the allowed roles
-			allowed := false
-			for _, role := range allowedRoles {
-				if cargo == role {
-					allowed = true
-					break
-				}
+			// Permissions are read on every request so role changes apply immediately
+			permissoes, err := models.GetCargoPermissoes(db, cargo)
+			if err != nil {
+				log.Printf("Error fetching permissions: %v", err)
+				http.Error(w, "Erro ao verificar permissões", http.StatusInternalServerError)
+				return
 			}
 
-			if !allowed {
+			if !permissoes.Has(permissao) {
 				http.Error(w, "Não autorizado para esta operação", http.StatusForbidden)
 				return
 			}
@@ -82,29 +76,14 @@ func RoleMiddleware(store *sessions.CookieStore, allowedRoles []int) func(http.H
 	}
 }
 
-// AdminSAVMiddleware creates a middleware that only allows Admin and SAV roles
-func AdminSAVMiddleware(store *sessions.CookieStore) func(http.Handler) http.Handler {
-	return RoleMiddleware(store, []int{RoleAdmin, RoleSAV})
-}
-
-// ViewOnlyMiddleware creates a middleware that allows all roles (everyone can view)
-func ViewOnlyMiddleware(store *sessions.CookieStore) func(http.Handler) http.Handler {
-	return RoleMiddleware(store, []int{RoleAdmin, RoleSAV, RoleDCP, RoleGuest})
-}
-
-// AdminOnlyMiddleware creates a middleware that only allows Admin role
-func AdminOnlyMiddleware(store *sessions.CookieStore) func(http.Handler) http.Handler {
-	return RoleMiddleware(store, []int{RoleAdmin})
-}
-
-// HasRole checks if the user has one of the specified roles
-func HasRole(r *http.Request, store *sessions.CookieStore, roles []int) bool {
+// HasPermission checks if the logged in user's cargo has a permission
+func HasPermission(r *http.Request, db *sql.DB, store sessions.Store, permissao string) bool {
 	session, err := store.Get(r, "session-name")
 	if err != nil {
 		return false
 	}
 
-	if auth, ok := session.Values["authenticated"].(bool); !ok || !auth {
+	if auth, ok := session.Values["authenticated"].(bool); !ok || !auth || !sessionCurrent(db, session) {
 		return false
 	}
 
@@ -113,21 +92,27 @@ func HasRole(r *http.Request, store *sessions.CookieStore, roles []int) bool {
 		return false
 	}
 
-	for _, role := range roles {
-		if cargo == role {
-			return true
-		}
+	permissoes, err := models.GetCargoPermissoes(db, cargo)
+	if err != nil {
+		log.Printf("Error fetching permissions: %v", err)
+		return false
 	}
 
-	return false
+	return permissoes.Has(permissao)
 }
 
-// IsAdminOrSAV checks if the user is an Admin or SAV
-func IsAdminOrSAV(r *http.Request, store *sessions.CookieStore) bool {
-	return HasRole(r, store, []int{RoleAdmin, RoleSAV})
-}
+// sessionCurrent checks that the session was saved after the user's last password reset
+func sessionCurrent(db *sql.DB, session *sessions.Session) bool {
+	userID, _ := session.Values["user_id"].(int)
+	versao, _ := session.Values["sessao_versao"].(int)
+
+	atual, err := models.GetSessaoVersao(db, userID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Error fetching session version: %v", err)
+		}
+		return false
+	}
 
-// IsAdmin checks if the user is an Admin
-func IsAdmin(r *http.Request, store *sessions.CookieStore) bool {
-	return HasRole(r, store, []int{RoleAdmin})
+	return versao == atual
 }
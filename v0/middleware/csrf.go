@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// CSRFField is the form field, and for multipart forms the query parameter, carrying the CSRF token
+const CSRFField = "csrf_token"
+
+// csrfCookie is the signed cookie holding the CSRF token of visitors without a stored session
+const csrfCookie = "csrf"
+
+// CSRF creates a middleware that requires the session's CSRF token on state-changing requests,
+// creating the token for sessions that have none. Visitors without a stored session keep their token in a
+// signed cookie, so that anonymous requests never create a session; logging in then stores it in the session
+func CSRF(store sessions.Store, secure bool, keyPairs ...[]byte) func(http.Handler) http.Handler {
+	codecs := securecookie.CodecsFromPairs(keyPairs...)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session, err := store.Get(r, "session-name")
+			if err != nil {
+				http.Error(w, "Session error", http.StatusInternalServerError)
+				log.Printf("Session error: %v", err)
+				return
+			}
+
+			// API token requests carry no cookie to forge and only read
+			if apiToken, _ := session.Values["api_token"].(bool); apiToken {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token, _ := session.Values[CSRFField].(string)
+			if token == "" && session.IsNew {
+				if cookie, err := r.Cookie(csrfCookie); err == nil {
+					securecookie.DecodeMulti(csrfCookie, cookie.Value, &token, codecs...)
+				}
+			}
+			if token == "" {
+				if token, err = newCSRFToken(); err != nil {
+					http.Error(w, "Session error", http.StatusInternalServerError)
+					log.Printf("CSRF token error: %v", err)
+					return
+				}
+
+				if session.IsNew {
+					encoded, err := securecookie.EncodeMulti(csrfCookie, token, codecs...)
+					if err != nil {
+						http.Error(w, "Session error", http.StatusInternalServerError)
+						log.Printf("CSRF cookie error: %v", err)
+						return
+					}
+					http.SetCookie(w, &http.Cookie{
+						Name:     csrfCookie,
+						Value:    encoded,
+						Path:     "/",
+						HttpOnly: true,
+						Secure:   secure,
+						SameSite: http.SameSiteLaxMode,
+					})
+				}
+			}
+
+			// Kept in the cached session of the request for the forms, and stored only when a handler saves it
+			if session.Values[CSRFField] != token {
+				session.Values[CSRFField] = token
+				if !session.IsNew {
+					if err := session.Save(r, w); err != nil {
+						http.Error(w, "Session error", http.StatusInternalServerError)
+						log.Printf("Session save error: %v", err)
+						return
+					}
+				}
+			}
+
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			enviado := r.Header.Get("X-CSRF-Token")
+			if enviado == "" {
+				// Multipart forms send the token in the URL, so that uploads are only read by their handler
+				// with its size limit
+				if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+					enviado = r.URL.Query().Get(CSRFField)
+				} else {
+					enviado = r.PostFormValue(CSRFField)
+				}
+			}
+
+			if subtle.ConstantTimeCompare([]byte(enviado), []byte(token)) != 1 {
+				log.Printf("CSRF token mismatch on %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+				http.Error(w, "Pedido inválido ou expirado. Recarregue a página e tente novamente.", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// newCSRFToken generates a random CSRF token
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,258 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+var testCSRFKey = []byte("chave-de-teste-com-32-bytes-0000")
+
+// csrfClient browses a test server behind the CSRF middleware, keeping its cookies
+type csrfClient struct {
+	t      *testing.T
+	server *httptest.Server
+	client *http.Client
+}
+
+// newCSRFClient starts a server behind the CSRF middleware whose /token page shows the token of the request,
+// /login logs in like the login handler and every other page accepts any method
+func newCSRFClient(t *testing.T) *csrfClient {
+	store := sessions.NewCookieStore(testCSRFKey)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		session, _ := store.Get(r, "session-name")
+		token, _ := session.Values[CSRFField].(string)
+		io.WriteString(w, token)
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		session, _ := store.Get(r, "session-name")
+		delete(session.Values, CSRFField)
+		session.Values["user_id"] = 1
+		if err := session.Save(r, w); err != nil {
+			t.Errorf("login: %v", err)
+		}
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+
+	server := httptest.NewServer(CSRF(store, false, testCSRFKey)(mux))
+	t.Cleanup(server.Close)
+
+	jar, _ := cookiejar.New(nil)
+	return &csrfClient{t: t, server: server, client: &http.Client{Jar: jar}}
+}
+
+// do sends a request, returning the status and body of the response
+func (c *csrfClient) do(req *http.Request) (int, string) {
+	c.t.Helper()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+// token returns the CSRF token the forms of a page would carry
+func (c *csrfClient) token() string {
+	c.t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, c.server.URL+"/token", nil)
+	status, token := c.do(req)
+	if status != http.StatusOK || token == "" {
+		c.t.Fatalf("GET /token = %d %q, want a token", status, token)
+	}
+	return token
+}
+
+// postForm posts a form with a csrf_token field, left out when empty
+func (c *csrfClient) postForm(path, token string) int {
+	c.t.Helper()
+	form := url.Values{"nome": {"x"}}
+	if token != "" {
+		form.Set(CSRFField, token)
+	}
+	req, _ := http.NewRequest(http.MethodPost, c.server.URL+path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	status, _ := c.do(req)
+	return status
+}
+
+// cookie returns the value of a cookie the client holds for the server
+func (c *csrfClient) cookie(name string) *http.Cookie {
+	u, _ := url.Parse(c.server.URL)
+	for _, cookie := range c.client.Jar.Cookies(u) {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestCSRFRefusesMissingAndWrongTokens(t *testing.T) {
+	c := newCSRFClient(t)
+	token := c.token()
+
+	// Reading needs no token
+	req, _ := http.NewRequest(http.MethodGet, c.server.URL+"/concursos", nil)
+	if status, _ := c.do(req); status != http.StatusOK {
+		t.Errorf("GET without token: status = %d, want %d", status, http.StatusOK)
+	}
+
+	tests := []struct {
+		nome   string
+		token  string
+		status int
+	}{
+		{"right token", token, http.StatusOK},
+		{"missing token", "", http.StatusForbidden},
+		{"wrong token", strings.Repeat("0", len(token)), http.StatusForbidden},
+		// The compare needs the whole token, not a part or more of it
+		{"prefix of the token", token[:len(token)-1], http.StatusForbidden},
+		{"token with more after it", token + "0", http.StatusForbidden},
+		{"token in upper case", strings.ToUpper(token), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		if status := c.postForm("/concursos/save", tt.token); status != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.nome, status, tt.status)
+		}
+	}
+
+	// Every state-changing method is checked
+	for _, method := range []string{http.MethodPut, http.MethodDelete, http.MethodPatch} {
+		req, _ := http.NewRequest(method, c.server.URL+"/concursos/1", nil)
+		if status, _ := c.do(req); status != http.StatusForbidden {
+			t.Errorf("%s without token: status = %d, want %d", method, status, http.StatusForbidden)
+		}
+	}
+}
+
+func TestCSRFTokenLookup(t *testing.T) {
+	c := newCSRFClient(t)
+	token := c.token()
+
+	// The header, as sent by scripts
+	req, _ := http.NewRequest(http.MethodPost, c.server.URL+"/checklist/toggle", nil)
+	req.Header.Set("X-CSRF-Token", token)
+	if status, _ := c.do(req); status != http.StatusOK {
+		t.Errorf("header token: status = %d, want %d", status, http.StatusOK)
+	}
+
+	// The header wins over the form, so a wrong header is refused whatever the form carries
+	form := url.Values{CSRFField: {token}}
+	req, _ = http.NewRequest(http.MethodPost, c.server.URL+"/concursos/save", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-CSRF-Token", "errado")
+	if status, _ := c.do(req); status != http.StatusForbidden {
+		t.Errorf("wrong header with right form token: status = %d, want %d", status, http.StatusForbidden)
+	}
+
+	// Only multipart forms are read from the URL
+	req, _ = http.NewRequest(http.MethodPost, c.server.URL+"/concursos/save?"+CSRFField+"="+token, strings.NewReader("nome=x"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if status, _ := c.do(req); status != http.StatusForbidden {
+		t.Errorf("URL token on a plain form: status = %d, want %d", status, http.StatusForbidden)
+	}
+
+	multipartPost := func(query string, campo bool) int {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if campo {
+			mw.WriteField(CSRFField, token)
+		}
+		fw, _ := mw.CreateFormFile("ficheiro", "anexo.pdf")
+		fw.Write([]byte("%PDF-1.4"))
+		mw.Close()
+
+		req, _ := http.NewRequest(http.MethodPost, c.server.URL+"/anexos/upload"+query, &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		status, _ := c.do(req)
+		return status
+	}
+
+	if status := multipartPost("?"+CSRFField+"="+token, false); status != http.StatusOK {
+		t.Errorf("multipart with URL token: status = %d, want %d", status, http.StatusOK)
+	}
+	// The body of an upload is left for its handler, so a token in it does not count
+	if status := multipartPost("", true); status != http.StatusForbidden {
+		t.Errorf("multipart with body token: status = %d, want %d", status, http.StatusForbidden)
+	}
+}
+
+func TestCSRFAnonymousTokenIsASignedCookie(t *testing.T) {
+	c := newCSRFClient(t)
+	token := c.token()
+
+	// Anonymous visitors get no session, only the signed token cookie
+	if c.cookie("session-name") != nil {
+		t.Error("an anonymous request created a session")
+	}
+	signed := c.cookie(csrfCookie)
+	if signed == nil {
+		t.Fatal("no CSRF cookie for an anonymous visitor")
+	}
+	if strings.Contains(signed.Value, token) {
+		t.Error("the CSRF cookie holds the token in the clear")
+	}
+
+	// The token stays the same between requests and is accepted
+	if again := c.token(); again != token {
+		t.Errorf("second request token = %q, want %q", again, token)
+	}
+	if status := c.postForm("/login", token); status != http.StatusOK {
+		t.Fatalf("anonymous post with token: status = %d, want %d", status, http.StatusOK)
+	}
+
+	// A cookie that is not signed with the key is ignored, so its token is refused
+	outro := newCSRFClient(t)
+	u, _ := url.Parse(outro.server.URL)
+	outro.client.Jar.SetCookies(u, []*http.Cookie{{Name: csrfCookie, Value: token, Path: "/"}})
+	if status := outro.postForm("/concursos/save", token); status != http.StatusForbidden {
+		t.Errorf("unsigned cookie: status = %d, want %d", status, http.StatusForbidden)
+	}
+
+	// A visitor without the cookie gets a token of their own, which is not the one of another visitor
+	terceiro := newCSRFClient(t)
+	if status := terceiro.postForm("/concursos/save", token); status != http.StatusForbidden {
+		t.Errorf("another visitor's token: status = %d, want %d", status, http.StatusForbidden)
+	}
+}
+
+func TestCSRFTokenRotatesAtLogin(t *testing.T) {
+	c := newCSRFClient(t)
+	anonimo := c.token()
+
+	if status := c.postForm("/login", anonimo); status != http.StatusOK {
+		t.Fatalf("login: status = %d, want %d", status, http.StatusOK)
+	}
+	if c.cookie("session-name") == nil {
+		t.Fatal("login stored no session")
+	}
+
+	// The session gets a new token, so a token learned before the login cannot be used after it
+	sessao := c.token()
+	if sessao == anonimo {
+		t.Fatal("the token did not change at login")
+	}
+	if again := c.token(); again != sessao {
+		t.Errorf("session token changed between requests: %q, want %q", again, sessao)
+	}
+	if status := c.postForm("/concursos/save", anonimo); status != http.StatusForbidden {
+		t.Errorf("token from before the login: status = %d, want %d", status, http.StatusForbidden)
+	}
+	if status := c.postForm("/concursos/save", sessao); status != http.StatusOK {
+		t.Errorf("session token: status = %d, want %d", status, http.StatusOK)
+	}
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Report types a schedule can send
+const (
+	RelatorioPrazos     = "prazos"
+	RelatorioResultados = "resultados"
+)
+
+// RelatorioTipos maps the report types to their descriptions
+var RelatorioTipos = map[string]string{
+	RelatorioPrazos:     "Próximos prazos",
+	RelatorioResultados: "Resumo de resultados",
+}
+
+// Agendamento represents a report emailed on a cron schedule
+type Agendamento struct {
+	ID            int
+	Nome          string
+	Cron          string
+	TipoRelatorio string
+	// EstadoID and TipoID are 0 when the report is not filtered by them
+	EstadoID int
+	TipoID   int
+	Entidade string
+	// Dias is how many days ahead the deadlines report covers
+	Dias          int
+	Destinatarios string
+	Ativo         bool
+	// CriadoPor is the user whose visibility the report has, 0 when that user was deleted
+	CriadoPor int
+	CriadoEm  string
+}
+
+// Filtro returns the concurso filter of the schedule; the report covers the concursos vis can see, the ones of
+// the user who created it
+func (a *Agendamento) Filtro(vis Visibilidade) ConcursoFiltro {
+	return ConcursoFiltro{
+		EstadoID:     a.EstadoID,
+		TipoID:       a.TipoID,
+		Entidade:     a.Entidade,
+		Visibilidade: vis,
+	}
+}
+
+// ListaDestinatarios splits the recipients, separated by commas, semicolons or new lines
+func (a *Agendamento) ListaDestinatarios() []string {
+	var lista []string
+	for _, d := range strings.FieldsFunc(a.Destinatarios, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n' || r == '\r'
+	}) {
+		if d = strings.TrimSpace(d); d != "" {
+			lista = append(lista, d)
+		}
+	}
+	return lista
+}
+
+// AgendamentoExecucao represents one run of a schedule
+type AgendamentoExecucao struct {
+	ID              int
+	AgendamentoID   int
+	AgendamentoNome string
+	Inicio          string
+	Fim             NullString
+	Manual          bool
+	// Sucesso is not valid while the run is in progress
+	Sucesso  sql.NullBool
+	Mensagem NullString
+}
+
+const agendamentoColumns = `id_agendamento, nome, cron, tipo_relatorio, COALESCE(estado_id, 0), COALESCE(tipo_id, 0),
+               entidade, dias, destinatarios, ativo, COALESCE(criado_por, 0), criado_em`
+
+// scanAgendamento scans a row selected with agendamentoColumns
+func scanAgendamento(row interface{ Scan(...interface{}) error }) (*Agendamento, error) {
+	var a Agendamento
+	err := row.Scan(&a.ID, &a.Nome, &a.Cron, &a.TipoRelatorio, &a.EstadoID, &a.TipoID,
+		&a.Entidade, &a.Dias, &a.Destinatarios, &a.Ativo, &a.CriadoPor, &a.CriadoEm)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetAgendamentos retrieves all report schedules ordered by name
+func GetAgendamentos(db *sql.DB) ([]Agendamento, error) {
+	rows, err := db.Query("SELECT " + agendamentoColumns + " FROM agendamento ORDER BY nome")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var agendamentos []Agendamento
+	for rows.Next() {
+		a, err := scanAgendamento(rows)
+		if err != nil {
+			return nil, err
+		}
+		agendamentos = append(agendamentos, *a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return agendamentos, nil
+}
+
+// GetAgendamentoByID retrieves a report schedule by ID
+func GetAgendamentoByID(db *sql.DB, id int) (*Agendamento, error) {
+	row := db.QueryRow("SELECT "+agendamentoColumns+" FROM agendamento WHERE id_agendamento = ?", id)
+	return scanAgendamento(row)
+}
+
+// CreateAgendamento creates a new report schedule
+func CreateAgendamento(db *sql.DB, a *Agendamento) error {
+	result, err := db.Exec(`
+        INSERT INTO agendamento (nome, cron, tipo_relatorio, estado_id, tipo_id, entidade, dias, destinatarios, ativo, criado_por)
+        VALUES (?, ?, ?, NULLIF(?, 0), NULLIF(?, 0), ?, ?, ?, ?, NULLIF(?, 0))
+    `, a.Nome, a.Cron, a.TipoRelatorio, a.EstadoID, a.TipoID, a.Entidade, a.Dias, a.Destinatarios, a.Ativo, a.CriadoPor)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	a.ID = int(id)
+
+	return nil
+}
+
+// SetAgendamentoAtivo enables or pauses a report schedule
+func SetAgendamentoAtivo(db *sql.DB, id int, ativo bool) error {
+	_, err := db.Exec("UPDATE agendamento SET ativo = ? WHERE id_agendamento = ?", ativo, id)
+	return err
+}
+
+// DeleteAgendamento deletes a report schedule and its run history
+func DeleteAgendamento(db *sql.DB, id int) error {
+	_, err := db.Exec("DELETE FROM agendamento WHERE id_agendamento = ?", id)
+	return err
+}
+
+// CreateAgendamentoExecucao records the start of a run of a schedule
+func CreateAgendamentoExecucao(db *sql.DB, agendamentoID int, manual bool) (int, error) {
+	result, err := db.Exec("INSERT INTO agendamento_execucao (agendamento_id, manual) VALUES (?, ?)", agendamentoID, manual)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// FinishAgendamentoExecucao records the outcome of a run
+func FinishAgendamentoExecucao(db *sql.DB, id int, sucesso bool, mensagem string) error {
+	_, err := db.Exec(`
+        UPDATE agendamento_execucao SET fim = CURRENT_TIMESTAMP, sucesso = ?, mensagem = ?
+        WHERE id_execucao = ?
+    `, sucesso, mensagem, id)
+	return err
+}
+
+// GetAgendamentoExecucoes retrieves the most recent runs of all schedules, newest first
+func GetAgendamentoExecucoes(db *sql.DB, limit int) ([]AgendamentoExecucao, error) {
+	rows, err := db.Query(`
+        SELECT x.id_execucao, x.agendamento_id, a.nome, x.inicio, x.fim, x.manual, x.sucesso, x.mensagem
+        FROM agendamento_execucao x
+        JOIN agendamento a ON x.agendamento_id = a.id_agendamento
+        ORDER BY x.inicio DESC, x.id_execucao DESC
+        LIMIT ?
+    `, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var execucoes []AgendamentoExecucao
+	for rows.Next() {
+		var x AgendamentoExecucao
+		err := rows.Scan(&x.ID, &x.AgendamentoID, &x.AgendamentoNome, &x.Inicio,
+			&x.Fim.NullString, &x.Manual, &x.Sucesso, &x.Mensagem.NullString)
+		if err != nil {
+			return nil, err
+		}
+		execucoes = append(execucoes, x)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return execucoes, nil
+}
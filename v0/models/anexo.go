@@ -0,0 +1,144 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// AnexoCategorias lists the categories an attachment can be filed under
+var AnexoCategorias = []string{
+	"Caderno de Encargos",
+	"Esclarecimentos",
+	"Proposta",
+	"Outros",
+}
+
+// Anexo represents a file attached to a concurso
+type Anexo struct {
+	ID           int
+	ConcursoID   int
+	Categoria    string
+	NomeFicheiro string
+	Versao       int
+	Chave        string
+	Tamanho      int64
+	ContentType  string
+	UserID       sql.NullInt64
+	UserNome     NullString
+	CriadoEm     string
+}
+
+// IsAnexoCategoria checks if a category is one of AnexoCategorias
+func IsAnexoCategoria(categoria string) bool {
+	for _, c := range AnexoCategorias {
+		if c == categoria {
+			return true
+		}
+	}
+	return false
+}
+
+// TamanhoFormatado returns the file size in a human readable unit
+func (a Anexo) TamanhoFormatado() string {
+	switch {
+	case a.Tamanho >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(a.Tamanho)/(1<<20))
+	case a.Tamanho >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(a.Tamanho)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", a.Tamanho)
+}
+
+// NextAnexoVersao returns the version number for a new upload of a file in a category
+func NextAnexoVersao(db *sql.DB, concursoID int, categoria, nomeFicheiro string) (int, error) {
+	var versao int
+	err := db.QueryRow(`
+        SELECT COALESCE(MAX(versao), 0) + 1
+        FROM anexo
+        WHERE concurso_id = ? AND categoria = ? AND nome_ficheiro = ?
+    `, concursoID, categoria, nomeFicheiro).Scan(&versao)
+	if err != nil {
+		return 0, err
+	}
+
+	return versao, nil
+}
+
+// CreateAnexo creates a new attachment record and sets its ID
+func CreateAnexo(db *sql.DB, a *Anexo) error {
+	result, err := db.Exec(`
+        INSERT INTO anexo (concurso_id, categoria, nome_ficheiro, versao, chave, tamanho, content_type, id_user)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+    `, a.ConcursoID, a.Categoria, a.NomeFicheiro, a.Versao, a.Chave, a.Tamanho, a.ContentType, a.UserID)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	a.ID = int(id)
+
+	return nil
+}
+
+// GetAnexos retrieves all attachment versions of a concurso, newest version first
+func GetAnexos(db *sql.DB, concursoID int) ([]Anexo, error) {
+	rows, err := db.Query(`
+        SELECT a.id_anexo, a.concurso_id, a.categoria, a.nome_ficheiro, a.versao, a.chave,
+               a.tamanho, a.content_type, a.id_user, u.nome, a.criado_em
+        FROM anexo a
+        LEFT JOIN user u ON a.id_user = u.id_user
+        WHERE a.concurso_id = ?
+        ORDER BY a.categoria, a.nome_ficheiro, a.versao DESC
+    `, concursoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var anexos []Anexo
+	for rows.Next() {
+		var a Anexo
+		if err := rows.Scan(
+			&a.ID, &a.ConcursoID, &a.Categoria, &a.NomeFicheiro, &a.Versao, &a.Chave,
+			&a.Tamanho, &a.ContentType, &a.UserID, &a.UserNome.NullString, &a.CriadoEm,
+		); err != nil {
+			return nil, err
+		}
+		anexos = append(anexos, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return anexos, nil
+}
+
+// GetAnexoByID retrieves an attachment by ID
+func GetAnexoByID(db *sql.DB, id int) (*Anexo, error) {
+	var a Anexo
+	err := db.QueryRow(`
+        SELECT a.id_anexo, a.concurso_id, a.categoria, a.nome_ficheiro, a.versao, a.chave,
+               a.tamanho, a.content_type, a.id_user, u.nome, a.criado_em
+        FROM anexo a
+        LEFT JOIN user u ON a.id_user = u.id_user
+        WHERE a.id_anexo = ?
+    `, id).Scan(
+		&a.ID, &a.ConcursoID, &a.Categoria, &a.NomeFicheiro, &a.Versao, &a.Chave,
+		&a.Tamanho, &a.ContentType, &a.UserID, &a.UserNome.NullString, &a.CriadoEm,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// DeleteAnexo deletes an attachment record by ID
+func DeleteAnexo(db *sql.DB, id int) error {
+	_, err := db.Exec("DELETE FROM anexo WHERE id_anexo = ?", id)
+	return err
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// apiTokenPrefixo starts every API token, so that leaked tokens are easy to recognise
+const apiTokenPrefixo = "cdc_"
+
+// APIToken is a read-only API token of a user. Only the hash of the token is stored, and Prefixo is the start
+// of the token shown to tell tokens apart
+type APIToken struct {
+	ID        int
+	Nome      string
+	Prefixo   string
+	CriadoEm  string
+	UltimoUso string
+	ExpiraEm  string
+}
+
+// APITokenUser is the user of a valid API token
+type APITokenUser struct {
+	UserID       int
+	CargoID      int
+	SessaoVersao int
+}
+
+// CreateAPIToken creates an API token for a user valid for the given days, or without expiry for 0 days,
+// returning the token to show once
+func CreateAPIToken(db *sql.DB, userID int, nome string, dias int) (string, error) {
+	segredo, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	token := apiTokenPrefixo + segredo
+
+	_, err = db.Exec(`
+        INSERT INTO api_token (user_id, nome, token_hash, prefixo, expira_em)
+        VALUES (?, ?, ?, ?, IF(? > 0, DATE_ADD(NOW(), INTERVAL ? DAY), NULL))
+    `, userID, nome, hashToken(token), segredo[:8], dias, dias)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// GetUserAPITokens retrieves the API tokens of a user, newest first
+func GetUserAPITokens(db *sql.DB, userID int) ([]APIToken, error) {
+	rows, err := db.Query(`
+        SELECT id_token, nome, prefixo, criado_em, COALESCE(ultimo_uso, ''), COALESCE(expira_em, '')
+        FROM api_token
+        WHERE user_id = ?
+        ORDER BY criado_em DESC, id_token DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		var t APIToken
+		if err := rows.Scan(&t.ID, &t.Nome, &t.Prefixo, &t.CriadoEm, &t.UltimoUso, &t.ExpiraEm); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// DeleteUserAPIToken revokes one of the user's API tokens, returning sql.ErrNoRows when the user has no such token
+func DeleteUserAPIToken(db *sql.DB, userID, tokenID int) error {
+	result, err := db.Exec("DELETE FROM api_token WHERE id_token = ? AND user_id = ?", tokenID, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// deleteUserAPITokens revokes all the API tokens of a user whose password or second factor is reset or changed,
+// so that the tokens created by whoever had the account stop working with it
+func deleteUserAPITokens(tx *sql.Tx, userID int) error {
+	_, err := tx.Exec("DELETE FROM api_token WHERE user_id = ?", userID)
+	return err
+}
+
+// GetAPITokenUser retrieves the active user of an API token that has not expired, recording its use. It returns
+// sql.ErrNoRows for unknown, expired and malformed tokens
+func GetAPITokenUser(db *sql.DB, token string) (*APITokenUser, error) {
+	if !strings.HasPrefix(token, apiTokenPrefixo) {
+		return nil, sql.ErrNoRows
+	}
+
+	var user APITokenUser
+	err := db.QueryRow(`
+        SELECT u.id_user, u.cargo_id, u.sessao_versao
+        FROM api_token t
+        JOIN user u ON t.user_id = u.id_user
+        WHERE t.token_hash = ? AND (t.expira_em IS NULL OR t.expira_em > NOW())
+          AND u.estado = ? AND u.cargo_id IS NOT NULL
+    `, hashToken(token), EstadoAtivo).Scan(&user.UserID, &user.CargoID, &user.SessaoVersao)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last use is recorded at most once a minute, as for sessions
+	_, err = db.Exec(`
+        UPDATE api_token SET ultimo_uso = NOW()
+        WHERE token_hash = ? AND (ultimo_uso IS NULL OR ultimo_uso < DATE_SUB(NOW(), INTERVAL 1 MINUTE))
+    `, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
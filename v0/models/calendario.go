@@ -0,0 +1,79 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"v0/utils"
+)
+
+// Evento represents a single deadline of a concurso shown in the calendar
+type Evento struct {
+	ConcursoID int    `json:"concurso_id"`
+	Referencia string `json:"referencia"`
+	Entidade   string `json:"entidade"`
+	Tipo       string `json:"tipo"`
+	TipoID     int    `json:"tipo_id"`
+	TipoDesc   string `json:"tipo_desc"`
+	Data       string `json:"data"`
+	Hora       string `json:"hora"`
+	// Inicio is Data and Hora in the deadlines timezone, in RFC 3339 with its UTC offset
+	Inicio string `json:"inicio,omitempty"`
+}
+
+// GetEventos retrieves the proposta, erro and audiencia deadlines between inicio and fim (inclusive),
+// with the same optional entidade filter and visibility as GetConcursos
+func GetEventos(db *sql.DB, inicio, fim, entidade string, vis Visibilidade) ([]Evento, error) {
+	// One row per deadline column of each concurso
+	query := `
+        SELECT e.id_concurso, e.referencia, e.entidade, e.evento, e.tipo_id, COALESCE(t.descricao, ''), e.dia, COALESCE(e.hora, '')
+        FROM (
+            SELECT id_concurso, referencia, entidade, tipo_id, 'Proposta' AS evento, dia_proposta AS dia, hora_proposta AS hora FROM concurso
+            UNION ALL
+            SELECT id_concurso, referencia, entidade, tipo_id, 'Erro', dia_erro, hora_erro FROM concurso
+            UNION ALL
+            SELECT id_concurso, referencia, entidade, tipo_id, 'Audiencia', dia_audiencia, hora_audiencia FROM concurso
+        ) e
+        JOIN concurso c ON e.id_concurso = c.id_concurso
+        LEFT JOIN tipo t ON e.tipo_id = t.id_tipo
+        WHERE e.dia BETWEEN ? AND ?
+    `
+	args := []interface{}{inicio, fim}
+
+	// Only the concursos the caller can see
+	condicao, visArgs := vis.condicao()
+	query += " AND " + condicao
+	args = append(args, visArgs...)
+
+	if entidade != "" {
+		query += ` AND (e.entidade LIKE ?
+            OR EXISTS (SELECT 1 FROM comentario cm WHERE cm.concurso_id = e.id_concurso AND cm.texto LIKE ?))`
+		args = append(args, "%"+entidade+"%", "%"+entidade+"%")
+	}
+
+	query += " ORDER BY e.dia, e.hora"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	eventos := []Evento{}
+	for rows.Next() {
+		var e Evento
+		if err := rows.Scan(&e.ConcursoID, &e.Referencia, &e.Entidade, &e.Tipo, &e.TipoID, &e.TipoDesc, &e.Data, &e.Hora); err != nil {
+			return nil, err
+		}
+		if inicio, err := utils.ParseDataHora(e.Data, e.Hora); err == nil {
+			e.Inicio = inicio.Format(time.RFC3339)
+		}
+		eventos = append(eventos, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return eventos, nil
+}
@@ -0,0 +1,206 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// ChecklistModelo represents a checklist template item for a tipo
+type ChecklistModelo struct {
+	ID        int
+	TipoID    int
+	TipoDesc  string
+	Descricao string
+	Ordem     int
+}
+
+// ChecklistItem represents a checklist item of a concurso
+type ChecklistItem struct {
+	ID               int
+	ConcursoID       int
+	Descricao        string
+	Ordem            int
+	Concluido        bool
+	ConcluidoPor     sql.NullInt64
+	ConcluidoPorNome NullString
+	ConcluidoEm      NullString
+}
+
+// ChecklistProgress summarizes how many checklist items of a concurso are done
+type ChecklistProgress struct {
+	Total      int
+	Concluidos int
+}
+
+// Percent returns the completed percentage of the checklist
+func (p ChecklistProgress) Percent() int {
+	if p.Total == 0 {
+		return 0
+	}
+	return p.Concluidos * 100 / p.Total
+}
+
+// Complete checks if every checklist item is done
+func (p ChecklistProgress) Complete() bool {
+	return p.Concluidos >= p.Total
+}
+
+// NeedsWarning checks if the proposta deadline is within avisoDias and the checklist is incomplete
+func (p ChecklistProgress) NeedsWarning(diasRestantes, avisoDias int) bool {
+	return p.Total > 0 && !p.Complete() && diasRestantes >= 0 && diasRestantes <= avisoDias
+}
+
+// GetChecklistModelos retrieves all checklist templates ordered by tipo
+func GetChecklistModelos(db *sql.DB) ([]ChecklistModelo, error) {
+	rows, err := db.Query(`
+        SELECT m.id_modelo, m.tipo_id, t.descricao, m.descricao, m.ordem
+        FROM checklist_modelo m
+        JOIN tipo t ON m.tipo_id = t.id_tipo
+        ORDER BY m.tipo_id, m.ordem, m.id_modelo
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var modelos []ChecklistModelo
+	for rows.Next() {
+		var m ChecklistModelo
+		if err := rows.Scan(&m.ID, &m.TipoID, &m.TipoDesc, &m.Descricao, &m.Ordem); err != nil {
+			return nil, err
+		}
+		modelos = append(modelos, m)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return modelos, nil
+}
+
+// CreateChecklistModelo creates a new checklist template item
+func CreateChecklistModelo(db *sql.DB, m *ChecklistModelo) error {
+	result, err := db.Exec("INSERT INTO checklist_modelo (tipo_id, descricao, ordem) VALUES (?, ?, ?)",
+		m.TipoID, m.Descricao, m.Ordem)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	m.ID = int(id)
+
+	return nil
+}
+
+// DeleteChecklistModelo deletes a checklist template item by ID
+func DeleteChecklistModelo(db *sql.DB, id int) error {
+	_, err := db.Exec("DELETE FROM checklist_modelo WHERE id_modelo = ?", id)
+	return err
+}
+
+// CreateChecklistFromModelo instantiates the checklist templates of a tipo for a concurso
+func CreateChecklistFromModelo(db *sql.DB, concursoID, tipoID int) error {
+	_, err := db.Exec(`
+        INSERT INTO checklist_item (concurso_id, descricao, ordem)
+        SELECT ?, descricao, ordem FROM checklist_modelo WHERE tipo_id = ?
+    `, concursoID, tipoID)
+	return err
+}
+
+// GetChecklistItems retrieves the checklist items of a concurso
+func GetChecklistItems(db *sql.DB, concursoID int) ([]ChecklistItem, error) {
+	rows, err := db.Query(`
+        SELECT i.id_item, i.concurso_id, i.descricao, i.ordem, i.concluido,
+               i.concluido_por, u.nome, i.concluido_em
+        FROM checklist_item i
+        LEFT JOIN user u ON i.concluido_por = u.id_user
+        WHERE i.concurso_id = ?
+        ORDER BY i.ordem, i.id_item
+    `, concursoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []ChecklistItem
+	for rows.Next() {
+		var item ChecklistItem
+		if err := rows.Scan(
+			&item.ID, &item.ConcursoID, &item.Descricao, &item.Ordem, &item.Concluido,
+			&item.ConcluidoPor, &item.ConcluidoPorNome.NullString, &item.ConcluidoEm.NullString,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// GetChecklistItem retrieves a checklist item by ID
+func GetChecklistItem(db *sql.DB, id int) (*ChecklistItem, error) {
+	var item ChecklistItem
+	err := db.QueryRow(`
+        SELECT id_item, concurso_id, descricao, ordem, concluido
+        FROM checklist_item
+        WHERE id_item = ?
+    `, id).Scan(&item.ID, &item.ConcursoID, &item.Descricao, &item.Ordem, &item.Concluido)
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// SetChecklistItemConcluido marks a checklist item as done or not done by a user
+func SetChecklistItemConcluido(db *sql.DB, id int, concluido bool, userID int) error {
+	if !concluido {
+		_, err := db.Exec(`
+            UPDATE checklist_item SET concluido = FALSE, concluido_por = NULL, concluido_em = NULL
+            WHERE id_item = ?
+        `, id)
+		return err
+	}
+
+	_, err := db.Exec(`
+        UPDATE checklist_item SET concluido = TRUE, concluido_por = ?, concluido_em = CURRENT_TIMESTAMP
+        WHERE id_item = ?
+    `, userID, id)
+	return err
+}
+
+// GetChecklistProgress retrieves the checklist progress of every concurso that has a checklist
+func GetChecklistProgress(db *sql.DB) (map[int]ChecklistProgress, error) {
+	rows, err := db.Query(`
+        SELECT concurso_id, COUNT(*), COALESCE(SUM(concluido), 0)
+        FROM checklist_item
+        GROUP BY concurso_id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := make(map[int]ChecklistProgress)
+	for rows.Next() {
+		var concursoID int
+		var p ChecklistProgress
+		if err := rows.Scan(&concursoID, &p.Total, &p.Concluidos); err != nil {
+			return nil, err
+		}
+		progress[concursoID] = p
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return progress, nil
+}
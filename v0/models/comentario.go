@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+)
+
+// mentionPattern matches @mentions of user names in comment text
+var mentionPattern = regexp.MustCompile(`@([\p{L}\p{N}._-]+)`)
+
+// Comentario represents a comment on a concurso
+type Comentario struct {
+	ID         int
+	ConcursoID int
+	UserID     sql.NullInt64
+	UserNome   NullString
+	Texto      string
+	CriadoEm   string
+	EditadoEm  NullString
+}
+
+// ParseMentions returns the distinct user names mentioned in a text
+func ParseMentions(texto string) []string {
+	seen := make(map[string]bool)
+	var nomes []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(texto, -1) {
+		nome := strings.TrimRight(match[1], ".-_")
+		if nome != "" && !seen[nome] {
+			seen[nome] = true
+			nomes = append(nomes, nome)
+		}
+	}
+	return nomes
+}
+
+// HighlightMentions wraps every @mention of an already escaped text in a highlight tag
+func HighlightMentions(texto string) string {
+	return mentionPattern.ReplaceAllString(texto, `<strong class="mention">$0</strong>`)
+}
+
+// CreateComentario creates a new comment and sets its ID
+func CreateComentario(db *sql.DB, c *Comentario) error {
+	result, err := db.Exec("INSERT INTO comentario (concurso_id, id_user, texto) VALUES (?, ?, ?)",
+		c.ConcursoID, c.UserID, c.Texto)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	c.ID = int(id)
+
+	return nil
+}
+
+// GetComentarios retrieves the comments of a concurso, oldest first
+func GetComentarios(db *sql.DB, concursoID int) ([]Comentario, error) {
+	rows, err := db.Query(`
+        SELECT c.id_comentario, c.concurso_id, c.id_user, u.nome, c.texto, c.criado_em, c.editado_em
+        FROM comentario c
+        LEFT JOIN user u ON c.id_user = u.id_user
+        WHERE c.concurso_id = ?
+        ORDER BY c.criado_em, c.id_comentario
+    `, concursoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comentarios []Comentario
+	for rows.Next() {
+		var c Comentario
+		if err := rows.Scan(
+			&c.ID, &c.ConcursoID, &c.UserID, &c.UserNome.NullString,
+			&c.Texto, &c.CriadoEm, &c.EditadoEm.NullString,
+		); err != nil {
+			return nil, err
+		}
+		comentarios = append(comentarios, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comentarios, nil
+}
+
+// GetComentarioByID retrieves a comment by ID
+func GetComentarioByID(db *sql.DB, id int) (*Comentario, error) {
+	var c Comentario
+	err := db.QueryRow(`
+        SELECT c.id_comentario, c.concurso_id, c.id_user, u.nome, c.texto, c.criado_em, c.editado_em
+        FROM comentario c
+        LEFT JOIN user u ON c.id_user = u.id_user
+        WHERE c.id_comentario = ?
+    `, id).Scan(
+		&c.ID, &c.ConcursoID, &c.UserID, &c.UserNome.NullString,
+		&c.Texto, &c.CriadoEm, &c.EditadoEm.NullString,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// UpdateComentario updates the text of a comment and marks it as edited
+func UpdateComentario(db *sql.DB, id int, texto string) error {
+	_, err := db.Exec("UPDATE comentario SET texto = ?, editado_em = CURRENT_TIMESTAMP WHERE id_comentario = ?",
+		texto, id)
+	return err
+}
+
+// DeleteComentario deletes a comment by ID
+func DeleteComentario(db *sql.DB, id int) error {
+	_, err := db.Exec("DELETE FROM comentario WHERE id_comentario = ?", id)
+	return err
+}
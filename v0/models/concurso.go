@@ -3,6 +3,8 @@ package models
 import (
 	"database/sql"
 	"time"
+
+	"v0/utils"
 )
 
 // Concurso represents a concurso record
@@ -31,34 +33,95 @@ type Concurso struct {
 	Adjudicatario  string        // New field
 	ResultadoID    int           // New field
 	ResultadoDesc  string        // New field for display
+	EstadoDesc     string
+	EquipaID       int           // 0 when no team owns the concurso
+	EquipaDesc     string
 }
 
 // ConcursoItem represents a concurso item for the ordered view
 type ConcursoItem struct {
+	ID            int
 	Referencia    string
 	Entidade      string
 	Objeto        int
 	Data          string
 	Hora          string
 	Tipo          string
+	EstadoDesc    string
 	DiasRestantes int
+	DiasUteis     int
+	Momento       time.Time     // Data and Hora in the deadlines timezone
 	Link          string        // New field
 	Adjudicatario string        // New field
 	ResultadoID   int           // New field
 }
 
-// GetConcursoByID retrieves a concurso by ID
-func GetConcursoByID(db *sql.DB, id string) (*Concurso, error) {
+// Prazo represents a deadline of a concurso
+type Prazo struct {
+	Tipo    string
+	Data    string
+	Hora    string
+	Momento time.Time // Data and Hora in the deadlines timezone
+}
+
+// Prazos returns the deadlines of the concurso that have both a date and a time,
+// in proposta, erro, audiencia order
+func (c *Concurso) Prazos() []Prazo {
+	var prazos []Prazo
+	for _, p := range []Prazo{
+		{Tipo: "Proposta", Data: c.DiaProposta.String, Hora: c.HoraProposta.String},
+		{Tipo: "Erro", Data: c.DiaErro.String, Hora: c.HoraErro.String},
+		{Tipo: "Audiencia", Data: c.DiaAudiencia.String, Hora: c.HoraAudiencia.String},
+	} {
+		momento, err := utils.ParseDataHora(p.Data, p.Hora)
+		if err != nil {
+			continue
+		}
+		p.Momento = momento
+		prazos = append(prazos, p)
+	}
+
+	return prazos
+}
+
+// ProximoPrazo returns the earliest future deadline of the concurso, or nil if there is none
+func (c *Concurso) ProximoPrazo() *Prazo {
+	now := time.Now()
+
+	var proximo *Prazo
+	for _, p := range c.Prazos() {
+		if !p.Momento.After(now) {
+			continue
+		}
+		if proximo == nil || p.Momento.Before(proximo.Momento) {
+			prazo := p
+			proximo = &prazo
+		}
+	}
+
+	return proximo
+}
+
+// GetConcursoByID retrieves a concurso by ID, returning sql.ErrNoRows when it is not visible
+func GetConcursoByID(db *sql.DB, id string, vis Visibilidade) (*Concurso, error) {
 	var concurso Concurso
 
+	condicao, args := vis.condicao()
 	err := db.QueryRow(`
         SELECT c.id_concurso, c.preco, c.referencia, c.entidade, c.dia_erro, c.hora_erro, 
                c.dia_proposta, c.hora_proposta, c.referencia_bc, c.preliminar, 
                c.dia_audiencia, c.hora_audiencia, c.final, c.recurso, c.impugnacao, 
-               c.tipo_id, c.plataforma_id, c.estado_id, c.link, c.adjudicatario, c.resultado_id
+               c.tipo_id, c.plataforma_id, c.estado_id, c.link, c.adjudicatario, c.resultado_id,
+               COALESCE(t.descricao, ''), COALESCE(p.descricao, ''),
+               COALESCE(e.descricao, ''), COALESCE(r.descricao, ''),
+               COALESCE(c.equipa_id, 0), COALESCE(q.nome, '')
         FROM concurso c
-        WHERE c.id_concurso = ?
-    `, id).Scan(
+        LEFT JOIN tipo t ON c.tipo_id = t.id_tipo
+        LEFT JOIN plataforma p ON c.plataforma_id = p.id_platforma
+        LEFT JOIN estado e ON c.estado_id = e.id_estado
+        LEFT JOIN resultado r ON c.resultado_id = r.id_resultado
+        LEFT JOIN equipa q ON c.equipa_id = q.id_equipa
+        WHERE c.id_concurso = ? AND `+condicao, append([]interface{}{id}, args...)...).Scan(
 		&concurso.ID, &concurso.Preco, &concurso.Referencia, &concurso.Entidade,
 		&concurso.DiaErro.NullString, &concurso.HoraErro.NullString,
 		&concurso.DiaProposta.NullString, &concurso.HoraProposta.NullString,
@@ -67,6 +130,9 @@ func GetConcursoByID(db *sql.DB, id string) (*Concurso, error) {
 		&concurso.Final, &concurso.Recurso, &concurso.Impugnacao,
 		&concurso.TipoID, &concurso.PlataformaID, &concurso.EstadoID,
 		&concurso.Link, &concurso.Adjudicatario, &concurso.ResultadoID,
+		&concurso.TipoDesc, &concurso.PlataformaDesc,
+		&concurso.EstadoDesc, &concurso.ResultadoDesc,
+		&concurso.EquipaID, &concurso.EquipaDesc,
 	)
 
 	if err != nil {
@@ -76,25 +142,31 @@ func GetConcursoByID(db *sql.DB, id string) (*Concurso, error) {
 	return &concurso, nil
 }
 
-// GetConcursos retrieves all concursos with optional filtering
-func GetConcursos(db *sql.DB, entidade string) ([]Concurso, error) {
+// GetConcursos retrieves the visible concursos with optional filtering
+func GetConcursos(db *sql.DB, entidade string, vis Visibilidade) ([]Concurso, error) {
 	// Construct the base query
 	query := `
         SELECT c.id_concurso, c.preco, c.referencia, c.entidade, c.dia_erro, c.hora_erro, c.dia_proposta, c.hora_proposta, 
                c.referencia_bc, c.preliminar, c.dia_audiencia, c.hora_audiencia, c.final, c.recurso, c.impugnacao, 
                t.descricao AS tipo, p.descricao AS plataforma, c.estado_id AS estado, c.tipo_id, c.plataforma_id,
-               c.link, c.adjudicatario, c.resultado_id, r.descricao AS resultado
+               c.link, c.adjudicatario, c.resultado_id, r.descricao AS resultado,
+               COALESCE(c.equipa_id, 0), COALESCE(q.nome, '')
         FROM concurso c
         JOIN tipo t ON c.tipo_id = t.id_tipo
         JOIN plataforma p ON c.plataforma_id = p.id_platforma
         LEFT JOIN resultado r ON c.resultado_id = r.id_resultado
+        LEFT JOIN equipa q ON c.equipa_id = q.id_equipa
     `
 
-	// Add filter if there's a search by entidade
-	var args []interface{}
+	// Only the concursos the caller can see
+	condicao, args := vis.condicao()
+	query += " WHERE " + condicao
+
+	// Add filter if there's a search by entidade, also matching comment text
 	if entidade != "" {
-		query += " WHERE c.entidade LIKE ?"
-		args = append(args, "%"+entidade+"%")
+		query += ` AND (c.entidade LIKE ?
+            OR EXISTS (SELECT 1 FROM comentario cm WHERE cm.concurso_id = c.id_concurso AND cm.texto LIKE ?))`
+		args = append(args, "%"+entidade+"%", "%"+entidade+"%")
 	}
 
 	// Add ordering
@@ -123,6 +195,7 @@ func GetConcursos(db *sql.DB, entidade string) ([]Concurso, error) {
 			&c.Final, &c.Recurso, &c.Impugnacao,
 			&c.TipoDesc, &c.PlataformaDesc, &c.EstadoID, &c.TipoID, &c.PlataformaID,
 			&c.Link, &c.Adjudicatario, &c.ResultadoID, &c.ResultadoDesc,
+			&c.EquipaID, &c.EquipaDesc,
 		)
 		if err != nil {
 			return nil, err
@@ -139,152 +212,106 @@ func GetConcursos(db *sql.DB, entidade string) ([]Concurso, error) {
 	return concursos, nil
 }
 
-// GetFutureConcursos retrieves concursos with future dates
-func GetFutureConcursos(db *sql.DB) ([]ConcursoItem, error) {
-	// Get current date and time
-	now := time.Now()
-	currentDate := now.Format("2006-01-02")
-	currentTime := now.Format("15:04:05")
+// EstadoEmAndamento is the estado whose concursos have deadlines to track
+const EstadoEmAndamento = 2
 
-	// Query concursos with estado_id = 1
-	rows, err := db.Query(`
-        SELECT c.id_concurso, c.entidade, c.estado_id,
-               c.dia_erro, c.hora_erro, 
+// ConcursoFiltro holds the optional filters of GetConcursosFiltrados; zero values match everything.
+// Visibilidade always applies, and its zero value sees nothing
+type ConcursoFiltro struct {
+	EstadoID     int
+	TipoID       int
+	Entidade     string
+	Visibilidade Visibilidade
+}
+
+// GetConcursosFiltrados retrieves the concursos matching a filter, with their deadlines and descriptions
+func GetConcursosFiltrados(db *sql.DB, filtro ConcursoFiltro) ([]Concurso, error) {
+	query := `
+        SELECT c.id_concurso, c.referencia, c.entidade,
+               c.dia_erro, c.hora_erro,
                c.dia_proposta, c.hora_proposta,
                c.dia_audiencia, c.hora_audiencia,
-               c.tipo_id, c.referencia, c.link, c.adjudicatario, c.resultado_id
+               c.tipo_id, c.estado_id, c.link, c.adjudicatario, c.resultado_id,
+               COALESCE(t.descricao, ''), COALESCE(e.descricao, ''), COALESCE(r.descricao, '')
         FROM concurso c
-        WHERE c.estado_id = 2
-        ORDER BY 
-            COALESCE(c.dia_proposta, c.dia_erro, c.dia_audiencia) ASC,
-            COALESCE(c.hora_proposta, c.hora_erro, c.hora_audiencia) ASC
-    `)
+        LEFT JOIN tipo t ON c.tipo_id = t.id_tipo
+        LEFT JOIN estado e ON c.estado_id = e.id_estado
+        LEFT JOIN resultado r ON c.resultado_id = r.id_resultado
+        WHERE `
 
-	if err != nil {
-		return nil, err
+	condicao, args := filtro.Visibilidade.condicao()
+	query += condicao
+	if filtro.EstadoID > 0 {
+		query += " AND c.estado_id = ?"
+		args = append(args, filtro.EstadoID)
 	}
-	defer rows.Close()
-
-	var items []ConcursoItem
-
-	// Function to calculate remaining days
-	calcularDiasRestantes := func(data string) int {
-		concursoDate, err := time.Parse("2006-01-02", data)
-		if err != nil {
-			return -1
-		}
-
-		diff := concursoDate.Sub(now)
-		return int(diff.Hours() / 24)
+	if filtro.TipoID > 0 {
+		query += " AND c.tipo_id = ?"
+		args = append(args, filtro.TipoID)
+	}
+	if filtro.Entidade != "" {
+		query += " AND c.entidade LIKE ?"
+		args = append(args, "%"+filtro.Entidade+"%")
 	}
 
-	// Function to check if date is in the future
-	isFutureDate := func(date, time string) bool {
-		if date > currentDate {
-			return true
-		}
-		if date == currentDate && time > currentTime {
-			return true
-		}
-		return false
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	// Scan rows
+	var concursos []Concurso
 	for rows.Next() {
-		var id, estado, objeto int
-		var entidade, referencia, link, adjudicatario string
-		var resultadoID int
-		var diaErro, horaErro sql.NullString
-		var diaProposta, horaProposta sql.NullString
-		var diaAudiencia, horaAudiencia sql.NullString
-
+		var c Concurso
 		err := rows.Scan(
-			&id, &entidade, &estado,
-			&diaErro, &horaErro,
-			&diaProposta, &horaProposta,
-			&diaAudiencia, &horaAudiencia,
-			&objeto, &referencia, &link, &adjudicatario, &resultadoID,
+			&c.ID, &c.Referencia, &c.Entidade,
+			&c.DiaErro.NullString, &c.HoraErro.NullString,
+			&c.DiaProposta.NullString, &c.HoraProposta.NullString,
+			&c.DiaAudiencia.NullString, &c.HoraAudiencia.NullString,
+			&c.TipoID, &c.EstadoID, &c.Link, &c.Adjudicatario, &c.ResultadoID,
+			&c.TipoDesc, &c.EstadoDesc, &c.ResultadoDesc,
 		)
 		if err != nil {
 			return nil, err
 		}
-
-		// Add each valid date as a separate item, only if it's in the future
-		if diaProposta.Valid && horaProposta.Valid && isFutureDate(diaProposta.String, horaProposta.String) {
-			dias := calcularDiasRestantes(diaProposta.String)
-			items = append(items, ConcursoItem{
-				Referencia:    referencia,
-				Entidade:      entidade,
-				Objeto:        objeto,
-				Data:          diaProposta.String,
-				Hora:          horaProposta.String,
-				Tipo:          "Proposta",
-				DiasRestantes: dias,
-				Link:          link,
-				Adjudicatario: adjudicatario,
-				ResultadoID:   resultadoID,
-			})
-		}
-
-		if diaErro.Valid && horaErro.Valid && isFutureDate(diaErro.String, horaErro.String) {
-			dias := calcularDiasRestantes(diaErro.String)
-			items = append(items, ConcursoItem{
-				Referencia:    referencia,
-				Entidade:      entidade,
-				Objeto:        objeto,
-				Data:          diaErro.String,
-				Hora:          horaErro.String,
-				Tipo:          "Erro",
-				DiasRestantes: dias,
-				Link:          link,
-				Adjudicatario: adjudicatario,
-				ResultadoID:   resultadoID,
-			})
-		}
-
-		if diaAudiencia.Valid && horaAudiencia.Valid && isFutureDate(diaAudiencia.String, horaAudiencia.String) {
-			dias := calcularDiasRestantes(diaAudiencia.String)
-			items = append(items, ConcursoItem{
-				Referencia:    referencia,
-				Entidade:      entidade,
-				Objeto:        objeto,
-				Data:          diaAudiencia.String,
-				Hora:          horaAudiencia.String,
-				Tipo:          "Audiencia",
-				DiasRestantes: dias,
-				Link:          link,
-				Adjudicatario: adjudicatario,
-				ResultadoID:   resultadoID,
-			})
-		}
+		concursos = append(concursos, c)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return items, nil
+	return concursos, nil
 }
 
-// CreateConcurso creates a new concurso
+// CreateConcurso creates a new concurso and sets its ID
 func CreateConcurso(db *sql.DB, c *Concurso) error {
-	_, err := db.Exec(`
+	result, err := db.Exec(`
         INSERT INTO concurso (
             referencia, entidade, dia_erro, hora_erro, 
             dia_proposta, hora_proposta, preco, tipo_id, 
             plataforma_id, referencia_bc, preliminar, dia_audiencia, 
             hora_audiencia, final, recurso, impugnacao, estado_id,
-            link, adjudicatario, resultado_id
+            link, adjudicatario, resultado_id, equipa_id
         )
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, 0))
     `,
 		c.Referencia, c.Entidade, c.DiaErro.NullString, c.HoraErro.NullString,
 		c.DiaProposta.NullString, c.HoraProposta.NullString, c.Preco, c.TipoID,
 		c.PlataformaID, c.ReferenciaBC, c.Preliminar, c.DiaAudiencia.NullString,
 		c.HoraAudiencia.NullString, c.Final, c.Recurso, c.Impugnacao, c.EstadoID,
-		c.Link, c.Adjudicatario, c.ResultadoID)
+		c.Link, c.Adjudicatario, c.ResultadoID, c.EquipaID)
+	if err != nil {
+		return err
+	}
 
-	return err
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	c.ID = int(id)
+
+	return nil
 }
 
 // UpdateConcurso updates an existing concurso
@@ -295,7 +322,7 @@ func UpdateConcurso(db *sql.DB, id string, c *Concurso) error {
             dia_erro = ?, hora_erro = ?, dia_proposta = ?, hora_proposta = ?,
             dia_audiencia = ?, hora_audiencia = ?, preliminar = ?, final = ?,
             recurso = ?, impugnacao = ?, tipo_id = ?, plataforma_id = ?, estado_id = ?,
-            link = ?, adjudicatario = ?, resultado_id = ?
+            link = ?, adjudicatario = ?, resultado_id = ?, equipa_id = NULLIF(?, 0)
         WHERE id_concurso = ?
     `,
 		c.Preco, c.Referencia, c.Entidade, c.ReferenciaBC,
@@ -304,7 +331,7 @@ func UpdateConcurso(db *sql.DB, id string, c *Concurso) error {
 		c.DiaAudiencia.NullString, c.HoraAudiencia.NullString,
 		c.Preliminar, c.Final, c.Recurso, c.Impugnacao,
 		c.TipoID, c.PlataformaID, c.EstadoID,
-		c.Link, c.Adjudicatario, c.ResultadoID, id)
+		c.Link, c.Adjudicatario, c.ResultadoID, c.EquipaID, id)
 
 	return err
 }
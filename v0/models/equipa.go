@@ -0,0 +1,198 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// Equipa represents a team; concursos owned by a team are visible to its members
+type Equipa struct {
+	ID      int
+	Nome    string
+	Membros []User
+}
+
+// Visibilidade decides which concursos a user can see: all of them, or the ones owned by
+// one of the user's teams plus the ones explicitly shared with the user
+type Visibilidade struct {
+	Todos  bool
+	UserID int
+}
+
+// VisibilidadeTotal sees every concurso, for work done on behalf of no particular user
+var VisibilidadeTotal = Visibilidade{Todos: true}
+
+// condicao returns the SQL condition restricting the concurso alias c to the visible ones
+func (v Visibilidade) condicao() (string, []interface{}) {
+	if v.Todos {
+		return "1 = 1", nil
+	}
+
+	return `(c.equipa_id IN (SELECT em.equipa_id FROM equipa_membro em WHERE em.user_id = ?)
+            OR EXISTS (SELECT 1 FROM concurso_partilha cp WHERE cp.concurso_id = c.id_concurso AND cp.user_id = ?))`,
+		[]interface{}{v.UserID, v.UserID}
+}
+
+// GetEquipas retrieves all teams with their members, ordered by name
+func GetEquipas(db *sql.DB) ([]Equipa, error) {
+	rows, err := db.Query("SELECT id_equipa, nome FROM equipa ORDER BY nome")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var equipas []Equipa
+	index := make(map[int]int)
+	for rows.Next() {
+		var e Equipa
+		if err := rows.Scan(&e.ID, &e.Nome); err != nil {
+			return nil, err
+		}
+		index[e.ID] = len(equipas)
+		equipas = append(equipas, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	membros, err := db.Query(`
+        SELECT em.equipa_id, u.id_user, u.nome, u.email, u.cargo_id
+        FROM equipa_membro em
+        JOIN user u ON em.user_id = u.id_user
+        ORDER BY u.nome
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer membros.Close()
+
+	for membros.Next() {
+		var equipaID int
+		var u User
+		if err := membros.Scan(&equipaID, &u.ID, &u.Nome, &u.Email, &u.CargoID); err != nil {
+			return nil, err
+		}
+		if i, ok := index[equipaID]; ok {
+			equipas[i].Membros = append(equipas[i].Membros, u)
+		}
+	}
+
+	if err = membros.Err(); err != nil {
+		return nil, err
+	}
+
+	return equipas, nil
+}
+
+// GetEquipasDisponiveis retrieves the teams concursos can be assigned to under a visibility:
+// every team when it sees all concursos, otherwise only the user's own teams
+func GetEquipasDisponiveis(db *sql.DB, vis Visibilidade) ([]Equipa, error) {
+	query := "SELECT id_equipa, nome FROM equipa ORDER BY nome"
+	var args []interface{}
+	if !vis.Todos {
+		query = `
+            SELECT e.id_equipa, e.nome
+            FROM equipa e
+            JOIN equipa_membro em ON em.equipa_id = e.id_equipa
+            WHERE em.user_id = ?
+            ORDER BY e.nome
+        `
+		args = append(args, vis.UserID)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var equipas []Equipa
+	for rows.Next() {
+		var e Equipa
+		if err := rows.Scan(&e.ID, &e.Nome); err != nil {
+			return nil, err
+		}
+		equipas = append(equipas, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return equipas, nil
+}
+
+// CreateEquipa creates a new team
+func CreateEquipa(db *sql.DB, nome string) (int, error) {
+	result, err := db.Exec("INSERT INTO equipa (nome) VALUES (?)", nome)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// DeleteEquipa deletes a team; its concursos are left without a team
+func DeleteEquipa(db *sql.DB, id int) error {
+	_, err := db.Exec("DELETE FROM equipa WHERE id_equipa = ?", id)
+	return err
+}
+
+// AddEquipaMembro adds a user to a team
+func AddEquipaMembro(db *sql.DB, equipaID, userID int) error {
+	_, err := db.Exec("INSERT IGNORE INTO equipa_membro (equipa_id, user_id) VALUES (?, ?)", equipaID, userID)
+	return err
+}
+
+// RemoveEquipaMembro removes a user from a team
+func RemoveEquipaMembro(db *sql.DB, equipaID, userID int) error {
+	_, err := db.Exec("DELETE FROM equipa_membro WHERE equipa_id = ? AND user_id = ?", equipaID, userID)
+	return err
+}
+
+// GetPartilhas retrieves the users a concurso is explicitly shared with
+func GetPartilhas(db *sql.DB, concursoID int) ([]User, error) {
+	rows, err := db.Query(`
+        SELECT u.id_user, u.nome, u.email, u.cargo_id
+        FROM concurso_partilha cp
+        JOIN user u ON cp.user_id = u.id_user
+        WHERE cp.concurso_id = ?
+        ORDER BY u.nome
+    `, concursoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Nome, &u.Email, &u.CargoID); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// AddPartilha shares a concurso with a user
+func AddPartilha(db *sql.DB, concursoID, userID int) error {
+	_, err := db.Exec("INSERT IGNORE INTO concurso_partilha (concurso_id, user_id) VALUES (?, ?)", concursoID, userID)
+	return err
+}
+
+// RemovePartilha stops sharing a concurso with a user
+func RemovePartilha(db *sql.DB, concursoID, userID int) error {
+	_, err := db.Exec("DELETE FROM concurso_partilha WHERE concurso_id = ? AND user_id = ?", concursoID, userID)
+	return err
+}
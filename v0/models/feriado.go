@@ -0,0 +1,75 @@
+package models
+
+import (
+	"database/sql"
+
+	"v0/utils"
+)
+
+// Feriado represents a municipal holiday defined by an admin
+type Feriado struct {
+	ID        int
+	Data      string
+	Descricao string
+}
+
+// GetFeriados retrieves all municipal holidays ordered by date
+func GetFeriados(db *sql.DB) ([]Feriado, error) {
+	rows, err := db.Query("SELECT id_feriado, data, descricao FROM feriado ORDER BY data")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feriados []Feriado
+	for rows.Next() {
+		var f Feriado
+		if err := rows.Scan(&f.ID, &f.Data, &f.Descricao); err != nil {
+			return nil, err
+		}
+		feriados = append(feriados, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return feriados, nil
+}
+
+// CreateFeriado creates a new municipal holiday
+func CreateFeriado(db *sql.DB, f *Feriado) error {
+	result, err := db.Exec("INSERT INTO feriado (data, descricao) VALUES (?, ?)", f.Data, f.Descricao)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	f.ID = int(id)
+
+	return nil
+}
+
+// DeleteFeriado deletes a municipal holiday by ID
+func DeleteFeriado(db *sql.DB, id int) error {
+	_, err := db.Exec("DELETE FROM feriado WHERE id_feriado = ?", id)
+	return err
+}
+
+// GetCalendario builds the working days calendar with the national and municipal holidays
+func GetCalendario(db *sql.DB) (*utils.Calendario, error) {
+	feriados, err := GetFeriados(db)
+	if err != nil {
+		return nil, err
+	}
+
+	municipais := make(map[string]string, len(feriados))
+	for _, f := range feriados {
+		municipais[f.Data] = f.Descricao
+	}
+
+	return utils.NewCalendario(municipais), nil
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+var (
+	// ErrIdentidadeLigada is returned when a single sign-on account is already linked to another user
+	ErrIdentidadeLigada = errors.New("a conta já está ligada a outro user")
+	// ErrUltimaIdentidade is returned when unlinking would leave a user without any way to sign in
+	ErrUltimaIdentidade = errors.New("não é possível desligar a única forma de entrar na conta")
+)
+
+// Identidade is a single sign-on account linked to a user
+type Identidade struct {
+	ID       int
+	UserID   int
+	Provedor string
+	Subject  string
+	Email    string
+	CriadoEm string
+}
+
+// GetIdentidadeUser retrieves the user linked to a single sign-on account, returning sql.ErrNoRows when there is none
+func GetIdentidadeUser(db *sql.DB, provedor, subject string) (int, error) {
+	var userID int
+	err := db.QueryRow("SELECT user_id FROM user_identidade WHERE provedor = ? AND subject = ?", provedor, subject).Scan(&userID)
+	return userID, err
+}
+
+// LinkIdentidade links a single sign-on account to a user. Linking it again to the same user does nothing
+func LinkIdentidade(db *sql.DB, userID int, provedor, subject, email string) error {
+	linkedID, err := GetIdentidadeUser(db, provedor, subject)
+	if err == nil {
+		if linkedID != userID {
+			return ErrIdentidadeLigada
+		}
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = db.Exec(`
+        INSERT INTO user_identidade (user_id, provedor, subject, email)
+        VALUES (?, ?, ?, ?)
+    `, userID, provedor, subject, email)
+	return err
+}
+
+// GetUserIdentidades retrieves the single sign-on accounts linked to a user
+func GetUserIdentidades(db *sql.DB, userID int) ([]Identidade, error) {
+	rows, err := db.Query(`
+        SELECT id_identidade, user_id, provedor, subject, COALESCE(email, ''), criado_em
+        FROM user_identidade
+        WHERE user_id = ?
+        ORDER BY criado_em
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identidades []Identidade
+	for rows.Next() {
+		var i Identidade
+		if err := rows.Scan(&i.ID, &i.UserID, &i.Provedor, &i.Subject, &i.Email, &i.CriadoEm); err != nil {
+			return nil, err
+		}
+		identidades = append(identidades, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return identidades, nil
+}
+
+// DeleteUserIdentidade unlinks one of the user's single sign-on accounts. The last one of a user without
+// login form password cannot be unlinked
+func DeleteUserIdentidade(db *sql.DB, userID, identidadeID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var loginLocal bool
+	var origem string
+	if err := tx.QueryRow("SELECT login_local, origem FROM user WHERE id_user = ? FOR UPDATE", userID).Scan(&loginLocal, &origem); err != nil {
+		return err
+	}
+
+	var total int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM user_identidade WHERE user_id = ?", userID).Scan(&total); err != nil {
+		return err
+	}
+	if total <= 1 && (!loginLocal || origem != OrigemLocal) {
+		return ErrUltimaIdentidade
+	}
+
+	result, err := tx.Exec("DELETE FROM user_identidade WHERE id_identidade = ? AND user_id = ?", identidadeID, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+// SetLoginLocal allows or forbids the login form password of a user
+func SetLoginLocal(db *sql.DB, userID int, permitido bool) error {
+	_, err := db.Exec("UPDATE user SET login_local = ? WHERE id_user = ?", permitido, userID)
+	return err
+}
+
+// CreateExternalUser creates the account of a user first seen through an external provider, returning its ID.
+// The account has no local password; a name or email of another account returns ErrNomeEmUso or ErrEmailEmUso
+func CreateExternalUser(db *sql.DB, origem, nome, email string, cargoID int) (int, error) {
+	result, err := db.Exec(`
+        INSERT INTO user (nome, email, password, cargo_id, origem, estado, login_local)
+        VALUES (?, ?, '', ?, ?, ?, FALSE)
+    `, nome, email, cargoID, origem, EstadoAtivo)
+	if err != nil {
+		return 0, erroDuplicado(err)
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// SetUserCargo changes the cargo of a user, ending the user's sessions when it is a new one, as in UpdateUser
+func SetUserCargo(db *sql.DB, userID, cargoID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE user SET cargo_id = ? WHERE id_user = ? AND NOT (cargo_id <=> ?)", cargoID, userID, cargoID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		if _, err := tx.Exec("DELETE FROM sessao WHERE user_id = ?", userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// LockoutPolicy is when failed logins lock an account and for how long
+type LockoutPolicy struct {
+	Limite  int
+	Minutos int
+}
+
+// LoginError is returned when a login fails or is refused
+type LoginError struct {
+	// UserID is 0 when no user has the login
+	UserID int
+	// Falhas is the number of failed attempts in a row of the user
+	Falhas    int
+	Bloqueado bool
+	// NovoBloqueio is set when this attempt locked the account
+	NovoBloqueio bool
+	// Pendente is the estado of a registration that is not active yet
+	Pendente string
+}
+
+func (e *LoginError) Error() string {
+	if e.Pendente != "" {
+		return "conta pendente"
+	}
+	if e.Bloqueado {
+		return "conta bloqueada temporariamente"
+	}
+	return "credenciais inválidas"
+}
+
+// IsBloqueado reports whether the account of a user is locked after too many failed attempts
+func IsBloqueado(db *sql.DB, userID int) (bool, error) {
+	var bloqueado bool
+	err := db.QueryRow(`
+        SELECT bloqueado_ate IS NOT NULL AND bloqueado_ate > NOW() FROM user WHERE id_user = ?
+    `, userID).Scan(&bloqueado)
+	return bloqueado, err
+}
+
+// RegisterLoginFailure counts a failed attempt of a user, locking the account when the policy limit is reached
+func RegisterLoginFailure(db *sql.DB, userID int, policy LockoutPolicy) (*LoginError, error) {
+	if _, err := db.Exec("UPDATE user SET failed_attempts = failed_attempts + 1 WHERE id_user = ?", userID); err != nil {
+		return nil, err
+	}
+
+	var falhas int
+	if err := db.QueryRow("SELECT failed_attempts FROM user WHERE id_user = ?", userID).Scan(&falhas); err != nil {
+		return nil, err
+	}
+
+	loginErr := &LoginError{UserID: userID, Falhas: falhas}
+	if policy.Limite <= 0 || falhas < policy.Limite {
+		return loginErr, nil
+	}
+
+	// Only one of concurrent failures locks the account, so the lock is notified once
+	result, err := db.Exec(`
+        UPDATE user SET failed_attempts = 0, bloqueado_ate = DATE_ADD(NOW(), INTERVAL ? MINUTE)
+        WHERE id_user = ? AND failed_attempts >= ?
+    `, policy.Minutos, userID, policy.Limite)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	loginErr.Bloqueado = true
+	loginErr.NovoBloqueio = n == 1
+	return loginErr, nil
+}
+
+// LoginTentativa represents a login attempt
+type LoginTentativa struct {
+	ID     int
+	UserID int
+	Login  string
+	IP     string
+	// Sucesso is false for refused attempts, with the reason in Motivo; for successful ones Motivo is the method
+	Sucesso  bool
+	Motivo   string
+	CriadoEm string
+}
+
+// Login attempt reasons
+const (
+	MotivoCredenciais = "credenciais"
+	MotivoBloqueado   = "bloqueado"
+	MotivoIP          = "ip_limitado"
+	Motivo2FA         = "2fa_invalido"
+	MotivoPendente    = "pendente"
+	MotivoSemAcesso   = "sem_acesso"
+)
+
+// CreateLoginTentativa records a login attempt
+func CreateLoginTentativa(db *sql.DB, t LoginTentativa) error {
+	_, err := db.Exec(`
+        INSERT INTO login_tentativa (user_id, login, ip, sucesso, motivo)
+        VALUES (NULLIF(?, 0), ?, ?, ?, ?)
+    `, t.UserID, t.Login, t.IP, t.Sucesso, t.Motivo)
+	return err
+}
+
+// GetLoginTentativas retrieves the latest login attempts of a user
+func GetLoginTentativas(db *sql.DB, userID, limite int) ([]LoginTentativa, error) {
+	rows, err := db.Query(`
+        SELECT id_tentativa, user_id, login, ip, sucesso, motivo, criado_em
+        FROM login_tentativa
+        WHERE user_id = ?
+        ORDER BY criado_em DESC, id_tentativa DESC
+        LIMIT ?
+    `, userID, limite)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tentativas []LoginTentativa
+	for rows.Next() {
+		var t LoginTentativa
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Login, &t.IP, &t.Sucesso, &t.Motivo, &t.CriadoEm); err != nil {
+			return nil, err
+		}
+		tentativas = append(tentativas, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tentativas, nil
+}
+
+// CountFalhasIP counts the failed login attempts from an IP in the last minutes, leaving out the ones it
+// was already throttled for so that the block ends with the window
+func CountFalhasIP(db *sql.DB, ip string, minutos int) (int, error) {
+	var count int
+	err := db.QueryRow(`
+        SELECT COUNT(*) FROM login_tentativa
+        WHERE ip = ? AND sucesso = FALSE AND motivo <> ?
+          AND criado_em > DATE_SUB(NOW(), INTERVAL ? MINUTE)
+    `, ip, MotivoIP, minutos).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,306 @@
+package models
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hash algorithms of the password policy
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+// PasswordMinLength is the default minimum length of a new password
+const PasswordMinLength = 8
+
+// ErrPasswordReutilizada is returned when a new password is one of the user's recent passwords
+var ErrPasswordReutilizada = errors.New("a password foi usada recentemente nesta conta")
+
+// PasswordPolicy holds the rules for new passwords and how passwords are hashed
+type PasswordPolicy struct {
+	MinLength int
+	// Historico is how many of the latest passwords of a user, the current one included, cannot be reused
+	Historico int
+	// Comprometidas are known breached or common passwords, in lower case
+	Comprometidas map[string]bool
+	// Hash is HashBcrypt or HashArgon2id; stored passwords with another algorithm or cost are rehashed on login
+	Hash            string
+	BcryptCost      int
+	Argon2Memoria   uint32
+	Argon2Iteracoes uint32
+	Argon2Threads   uint8
+}
+
+// passwordPolicy is the policy set at startup
+var passwordPolicy = PasswordPolicy{
+	MinLength:       PasswordMinLength,
+	Hash:            HashBcrypt,
+	BcryptCost:      bcrypt.DefaultCost,
+	Argon2Memoria:   64 * 1024,
+	Argon2Iteracoes: 3,
+	Argon2Threads:   2,
+}
+
+// SetPasswordPolicy sets the password policy used for new passwords and logins
+func SetPasswordPolicy(policy PasswordPolicy) {
+	passwordPolicy = policy
+}
+
+// MinPasswordLength returns the minimum length of a new password
+func MinPasswordLength() int {
+	return passwordPolicy.MinLength
+}
+
+// LoadPasswordList reads a list of breached or common passwords, one per line; empty lines and lines starting
+// with # are skipped
+func LoadPasswordList(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	passwords := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return passwords, nil
+}
+
+// ValidatePassword checks a new password against the password policy
+func ValidatePassword(password string) error {
+	if len([]rune(password)) < passwordPolicy.MinLength {
+		return fmt.Errorf("a password deve ter pelo menos %d caracteres", passwordPolicy.MinLength)
+	}
+
+	var letra, digito bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letra = true
+		case unicode.IsDigit(r):
+			digito = true
+		}
+	}
+	if !letra || !digito {
+		return errors.New("a password deve ter letras e números")
+	}
+
+	if passwordPolicy.Comprometidas[strings.ToLower(password)] {
+		return errors.New("a password é demasiado comum ou foi exposta numa fuga de dados")
+	}
+
+	return nil
+}
+
+// HashPassword hashes a password with the algorithm of the password policy
+func HashPassword(password string) (string, error) {
+	if passwordPolicy.Hash == HashArgon2id {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		p := passwordPolicy
+		key := argon2.IDKey([]byte(password), salt, p.Argon2Iteracoes, p.Argon2Memoria, p.Argon2Threads, 32)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Argon2Memoria, p.Argon2Iteracoes,
+			p.Argon2Threads, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordPolicy.BcryptCost)
+	return string(hash), err
+}
+
+// verifyPassword checks a password against a stored hash, also reporting whether the hash should be replaced
+// by one made with the current policy. Values that are not a known hash never match
+func verifyPassword(hash, password string) (bool, bool) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		var versao int
+		var memoria, iteracoes uint32
+		var threads uint8
+		partes := strings.Split(hash, "$")
+		if len(partes) != 6 {
+			return false, false
+		}
+		if _, err := fmt.Sscanf(partes[2], "v=%d", &versao); err != nil || versao != argon2.Version {
+			return false, false
+		}
+		if _, err := fmt.Sscanf(partes[3], "m=%d,t=%d,p=%d", &memoria, &iteracoes, &threads); err != nil || iteracoes == 0 || threads == 0 {
+			return false, false
+		}
+		salt, err := base64.RawStdEncoding.DecodeString(partes[4])
+		if err != nil {
+			return false, false
+		}
+		key, err := base64.RawStdEncoding.DecodeString(partes[5])
+		if err != nil || len(key) == 0 {
+			return false, false
+		}
+
+		calculada := argon2.IDKey([]byte(password), salt, iteracoes, memoria, threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(calculada, key) != 1 {
+			return false, false
+		}
+
+		p := passwordPolicy
+		return true, p.Hash != HashArgon2id || memoria != p.Argon2Memoria || iteracoes != p.Argon2Iteracoes || threads != p.Argon2Threads
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false, false
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, passwordPolicy.Hash != HashBcrypt || err != nil || cost != passwordPolicy.BcryptCost
+}
+
+// setPassword hashes and saves a new password of a user, keeping the previous one in the password history.
+// One of the user's recent passwords returns ErrPasswordReutilizada
+func setPassword(tx *sql.Tx, userID int, password string) error {
+	var atual string
+	if err := tx.QueryRow("SELECT password FROM user WHERE id_user = ? FOR UPDATE", userID).Scan(&atual); err != nil {
+		return err
+	}
+
+	if passwordPolicy.Historico > 0 {
+		recentes := []string{atual}
+
+		rows, err := tx.Query(`
+            SELECT password FROM password_historico
+            WHERE user_id = ?
+            ORDER BY id_historico DESC
+            LIMIT ?
+        `, userID, passwordPolicy.Historico-1)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var hash string
+			if err := rows.Scan(&hash); err != nil {
+				rows.Close()
+				return err
+			}
+			recentes = append(recentes, hash)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if passwordReutilizada(recentes, password) {
+			return ErrPasswordReutilizada
+		}
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE user SET password = ? WHERE id_user = ?", hash, userID); err != nil {
+		return err
+	}
+
+	// The current password is checked from the user, so the history keeps the ones before it
+	if passwordPolicy.Historico > 1 && isPasswordHash(atual) {
+		if _, err := tx.Exec("INSERT INTO password_historico (user_id, password) VALUES (?, ?)", userID, atual); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(`
+            DELETE FROM password_historico
+            WHERE user_id = ? AND id_historico NOT IN (
+                SELECT id_historico FROM (
+                    SELECT id_historico FROM password_historico
+                    WHERE user_id = ?
+                    ORDER BY id_historico DESC
+                    LIMIT ?
+                ) recentes
+            )
+        `, userID, userID, passwordPolicy.Historico-1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// passwordReutilizada reports whether a password matches one of the hashes of a user's recent passwords
+func passwordReutilizada(recentes []string, password string) bool {
+	for _, hash := range recentes {
+		if ok, _ := verifyPassword(hash, password); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isPasswordHash reports whether a stored password is a hash this package can check
+func isPasswordHash(password string) bool {
+	return strings.HasPrefix(password, "$2") || strings.HasPrefix(password, "$argon2id$")
+}
+
+// rehashPassword replaces the stored hash of a user who just logged in with one made with the current policy,
+// unless the password changed in the meantime
+func rehashPassword(db *sql.DB, userID int, antigo, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE user SET password = ? WHERE id_user = ? AND password = ?", hash, userID, antigo)
+	return err
+}
+
+// GetUnhashedPasswordUsers retrieves the local users whose stored password is not a known hash, such as a
+// plaintext password inserted by hand. Such passwords never match, so these users cannot log in until reset
+func GetUnhashedPasswordUsers(db *sql.DB) ([]User, error) {
+	rows, err := db.Query(`
+        SELECT id_user, nome, email
+        FROM user
+        WHERE origem = ? AND password <> ''
+          AND password NOT LIKE '$2_$%' AND password NOT LIKE '$argon2id$%'
+    `, OrigemLocal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Nome, &user.Email); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
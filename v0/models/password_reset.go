@@ -0,0 +1,149 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// ErrResetInvalido is returned for unknown, used or expired password reset tokens
+var ErrResetInvalido = errors.New("link de recuperação inválido ou expirado")
+
+// GetUserByEmail retrieves a user by email
+func GetUserByEmail(db *sql.DB, email string) (*User, error) {
+	var user User
+	err := db.QueryRow("SELECT id_user, nome, email, cargo_id, origem, estado, login_local FROM user WHERE email = ?", email).Scan(
+		&user.ID, &user.Nome, &user.Email, &user.CargoID, &user.Origem, &user.Estado, &user.LoginLocal,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetSessaoVersao retrieves the session version of a user; sessions saved with an older version are no longer valid
+func GetSessaoVersao(db *sql.DB, userID int) (int, error) {
+	var versao int
+	err := db.QueryRow("SELECT sessao_versao FROM user WHERE id_user = ?", userID).Scan(&versao)
+	return versao, err
+}
+
+// hashToken returns the hash a random token is stored as, for reset tokens and recovery codes
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newToken generates a random token to send by email
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CreatePasswordReset creates a reset token for a user valid for the given minutes, returning the token
+// to send by email; only its hash is stored
+func CreatePasswordReset(db *sql.DB, userID, minutos int) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Exec(`
+        INSERT INTO password_reset (user_id, token_hash, expira_em)
+        VALUES (?, ?, DATE_ADD(NOW(), INTERVAL ? MINUTE))
+    `, userID, hashToken(token), minutos)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RecordResetPedido records a password reset request for an email from an IP
+func RecordResetPedido(db *sql.DB, email, ip string) error {
+	_, err := db.Exec("INSERT INTO password_reset_pedido (email, ip) VALUES (?, ?)", strings.ToLower(email), ip)
+	return err
+}
+
+// CountResetPedidos counts the password reset requests from an IP and for an email in the last minutes
+func CountResetPedidos(db *sql.DB, email, ip string, minutos int) (int, int, error) {
+	var porIP, porEmail int
+	err := db.QueryRow(`
+        SELECT COALESCE(SUM(ip = ?), 0), COALESCE(SUM(email = ?), 0)
+        FROM password_reset_pedido
+        WHERE (ip = ? OR email = ?) AND criado_em > DATE_SUB(NOW(), INTERVAL ? MINUTE)
+    `, ip, strings.ToLower(email), ip, strings.ToLower(email), minutos).Scan(&porIP, &porEmail)
+	return porIP, porEmail, err
+}
+
+// GetPasswordResetUser retrieves the user of a valid reset token, returning ErrResetInvalido otherwise
+func GetPasswordResetUser(db *sql.DB, token string) (int, error) {
+	var userID int
+	err := db.QueryRow(`
+        SELECT user_id FROM password_reset
+        WHERE token_hash = ? AND usado_em IS NULL AND expira_em > NOW()
+    `, hashToken(token)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrResetInvalido
+	}
+
+	return userID, err
+}
+
+// ResetPassword sets a new password using a reset token. The token and any other pending tokens of the
+// user are used up, the account is unlocked and its existing sessions are ended. A recently used password
+// returns ErrPasswordReutilizada
+func ResetPassword(db *sql.DB, token, password string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Lock the token row so that it can only be used once
+	var userID int
+	err = tx.QueryRow(`
+        SELECT user_id FROM password_reset
+        WHERE token_hash = ? AND usado_em IS NULL AND expira_em > NOW()
+        FOR UPDATE
+    `, hashToken(token)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrResetInvalido
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE password_reset SET usado_em = NOW() WHERE user_id = ? AND usado_em IS NULL", userID); err != nil {
+		return 0, err
+	}
+
+	if err := setPassword(tx, userID, password); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+        UPDATE user SET failed_attempts = 0, bloqueado_ate = NULL, sessao_versao = sessao_versao + 1
+        WHERE id_user = ?
+    `, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM sessao WHERE user_id = ?", userID); err != nil {
+		return 0, err
+	}
+
+	if err := deleteUserAPITokens(tx, userID); err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPolicy sets a password policy with cheap hashes for a test, restoring the previous one afterwards
+func testPolicy(t *testing.T, policy PasswordPolicy) {
+	anterior := passwordPolicy
+	t.Cleanup(func() { passwordPolicy = anterior })
+
+	if policy.BcryptCost == 0 {
+		policy.BcryptCost = bcrypt.MinCost
+	}
+	if policy.Argon2Memoria == 0 {
+		policy.Argon2Memoria, policy.Argon2Iteracoes, policy.Argon2Threads = 1024, 1, 1
+	}
+	SetPasswordPolicy(policy)
+}
+
+func TestValidatePassword(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "comprometidas.txt")
+	lista := "# exposed passwords\n\nPassword123\n  qwerty2024  \n"
+	if err := os.WriteFile(path, []byte(lista), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	comprometidas, err := LoadPasswordList(path)
+	if err != nil {
+		t.Fatalf("LoadPasswordList: %v", err)
+	}
+	if len(comprometidas) != 2 {
+		t.Errorf("LoadPasswordList = %v, want 2 passwords without comments or empty lines", comprometidas)
+	}
+
+	testPolicy(t, PasswordPolicy{MinLength: 10, Comprometidas: comprometidas})
+
+	tests := []struct {
+		password string
+		valida   bool
+	}{
+		{"concurso2024", true},
+		{"curta1", false},
+		// Length counts characters, not bytes
+		{"ãéíõúçàâê1", true},
+		{"semnumeros", false},
+		{"1234567890", false},
+		{"password123", false},
+		{"PASSWORD123", false},
+		{"qwerty2024", false},
+		{"qwerty20245", true},
+	}
+
+	for _, tt := range tests {
+		err := ValidatePassword(tt.password)
+		if (err == nil) != tt.valida {
+			t.Errorf("ValidatePassword(%q) = %v, want valid %v", tt.password, err, tt.valida)
+		}
+	}
+}
+
+func TestHashPasswordRoundTrip(t *testing.T) {
+	for _, algoritmo := range []string{HashBcrypt, HashArgon2id} {
+		testPolicy(t, PasswordPolicy{Hash: algoritmo})
+
+		hash, err := HashPassword("concurso2024")
+		if err != nil {
+			t.Fatalf("%s: HashPassword: %v", algoritmo, err)
+		}
+		if algoritmo == HashArgon2id && !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+			t.Errorf("argon2id hash = %q, want the PHC format with the policy parameters", hash)
+		}
+		if !isPasswordHash(hash) {
+			t.Errorf("%s: isPasswordHash(%q) = false", algoritmo, hash)
+		}
+
+		outro, _ := HashPassword("concurso2024")
+		if outro == hash {
+			t.Errorf("%s: two hashes of a password are equal, so the salt is not random", algoritmo)
+		}
+
+		if ok, rehash := verifyPassword(hash, "concurso2024"); !ok || rehash {
+			t.Errorf("%s: verifyPassword(right password) = %v, %v; want true, false", algoritmo, ok, rehash)
+		}
+		if ok, _ := verifyPassword(hash, "concurso2025"); ok {
+			t.Errorf("%s: verifyPassword accepted a wrong password", algoritmo)
+		}
+	}
+}
+
+func TestVerifyPasswordRejectsMalformedHashes(t *testing.T) {
+	testPolicy(t, PasswordPolicy{Hash: HashArgon2id})
+
+	hash, err := HashPassword("concurso2024")
+	if err != nil {
+		t.Fatal(err)
+	}
+	partes := strings.Split(hash, "$")
+
+	tests := map[string]string{
+		"plaintext":       "concurso2024",
+		"empty":           "",
+		"missing key":     strings.Join(partes[:5], "$"),
+		"other version":   strings.Replace(hash, "$v=19$", "$v=16$", 1),
+		"zero iterations": strings.Replace(hash, ",t=1,", ",t=0,", 1),
+		"bad salt":        strings.Join([]string{"", partes[1], partes[2], partes[3], "%%%", partes[5]}, "$"),
+		"empty key":       strings.Join([]string{"", partes[1], partes[2], partes[3], partes[4], ""}, "$"),
+	}
+	for nome, hash := range tests {
+		if ok, _ := verifyPassword(hash, "concurso2024"); ok {
+			t.Errorf("%s: verifyPassword(%q) matched", nome, hash)
+		}
+	}
+}
+
+func TestVerifyPasswordAsksForRehash(t *testing.T) {
+	// Hashes stored under an older policy
+	testPolicy(t, PasswordPolicy{Hash: HashBcrypt})
+	bcryptMin, _ := HashPassword("concurso2024")
+	testPolicy(t, PasswordPolicy{Hash: HashArgon2id})
+	argonFraco, _ := HashPassword("concurso2024")
+
+	tests := []struct {
+		nome   string
+		policy PasswordPolicy
+		hash   string
+		rehash bool
+	}{
+		{"same bcrypt cost", PasswordPolicy{Hash: HashBcrypt}, bcryptMin, false},
+		{"higher bcrypt cost", PasswordPolicy{Hash: HashBcrypt, BcryptCost: bcrypt.MinCost + 1}, bcryptMin, true},
+		{"bcrypt to argon2id", PasswordPolicy{Hash: HashArgon2id}, bcryptMin, true},
+		{"argon2id to bcrypt", PasswordPolicy{Hash: HashBcrypt}, argonFraco, true},
+		{"same argon2id parameters", PasswordPolicy{Hash: HashArgon2id}, argonFraco, false},
+		{"more argon2id memory", PasswordPolicy{Hash: HashArgon2id, Argon2Memoria: 2048, Argon2Iteracoes: 1, Argon2Threads: 1}, argonFraco, true},
+		{"more argon2id iterations", PasswordPolicy{Hash: HashArgon2id, Argon2Memoria: 1024, Argon2Iteracoes: 2, Argon2Threads: 1}, argonFraco, true},
+		{"more argon2id threads", PasswordPolicy{Hash: HashArgon2id, Argon2Memoria: 1024, Argon2Iteracoes: 1, Argon2Threads: 2}, argonFraco, true},
+	}
+
+	for _, tt := range tests {
+		testPolicy(t, tt.policy)
+
+		ok, rehash := verifyPassword(tt.hash, "concurso2024")
+		if !ok || rehash != tt.rehash {
+			t.Errorf("%s: verifyPassword = %v, %v; want true, %v", tt.nome, ok, rehash, tt.rehash)
+		}
+		// A wrong password never asks for a rehash, which would store the hash of the wrong password
+		if ok, rehash := verifyPassword(tt.hash, "errada2024"); ok || rehash {
+			t.Errorf("%s: verifyPassword(wrong password) = %v, %v", tt.nome, ok, rehash)
+		}
+
+		// The hash made on login satisfies the policy, so the next login does not rehash again
+		if tt.rehash {
+			novo, err := HashPassword("concurso2024")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok, rehash := verifyPassword(novo, "concurso2024"); !ok || rehash {
+				t.Errorf("%s: rehashed password = %v, %v; want true, false", tt.nome, ok, rehash)
+			}
+		}
+	}
+}
+
+func TestPasswordReutilizada(t *testing.T) {
+	testPolicy(t, PasswordPolicy{Hash: HashArgon2id})
+	argon, _ := HashPassword("antiga2023")
+	testPolicy(t, PasswordPolicy{Hash: HashBcrypt})
+	bcryptHash, _ := HashPassword("atual2024")
+
+	// The history may hold hashes of any algorithm the policy used before, and unhashed legacy values
+	recentes := []string{bcryptHash, argon, "texto2022"}
+
+	tests := []struct {
+		password    string
+		reutilizada bool
+	}{
+		{"atual2024", true},
+		{"antiga2023", true},
+		{"texto2022", false},
+		{"nova2025", false},
+		{"ATUAL2024", false},
+	}
+
+	for _, tt := range tests {
+		if got := passwordReutilizada(recentes, tt.password); got != tt.reutilizada {
+			t.Errorf("passwordReutilizada(%q) = %v, want %v", tt.password, got, tt.reutilizada)
+		}
+	}
+	if passwordReutilizada(nil, "atual2024") {
+		t.Error("a password was reused with no history")
+	}
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// Permission codes checked by the routes and templates
+const (
+	PermConcursoView    = "concurso.view"
+	PermConcursoEdit    = "concurso.edit"
+	PermConcursoDelete  = "concurso.delete"
+	PermChecklistTick   = "checklist.tick"
+	PermReportExport    = "report.export"
+	PermUserManage      = "user.manage"
+	PermConfigManage    = "config.manage"
+	PermRoleManage      = "role.manage"
+	PermConcursoViewAll = "concurso.view_all"
+)
+
+// Permissao represents a permission that can be granted to cargos
+type Permissao struct {
+	ID        int
+	Codigo    string
+	Descricao string
+}
+
+// Permissoes is the set of permission codes of a cargo
+type Permissoes map[string]bool
+
+// Has checks if the set contains a permission
+func (p Permissoes) Has(codigo string) bool {
+	return p[codigo]
+}
+
+// GetPermissoes retrieves all permissions
+func GetPermissoes(db *sql.DB) ([]Permissao, error) {
+	rows, err := db.Query("SELECT id_permissao, codigo, descricao FROM permissao ORDER BY id_permissao")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissoes []Permissao
+	for rows.Next() {
+		var p Permissao
+		if err := rows.Scan(&p.ID, &p.Codigo, &p.Descricao); err != nil {
+			return nil, err
+		}
+		permissoes = append(permissoes, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return permissoes, nil
+}
+
+// GetCargoPermissoes retrieves the permission codes of a cargo
+func GetCargoPermissoes(db *sql.DB, cargoID int) (Permissoes, error) {
+	rows, err := db.Query(`
+        SELECT p.codigo
+        FROM cargo_permissao cp
+        JOIN permissao p ON cp.permissao_id = p.id_permissao
+        WHERE cp.cargo_id = ?
+    `, cargoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissoes := make(Permissoes)
+	for rows.Next() {
+		var codigo string
+		if err := rows.Scan(&codigo); err != nil {
+			return nil, err
+		}
+		permissoes[codigo] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return permissoes, nil
+}
+
+// SetCargoPermissoes replaces the permissions of a cargo with the given permission codes
+func SetCargoPermissoes(db *sql.DB, cargoID int, codigos []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM cargo_permissao WHERE cargo_id = ?", cargoID); err != nil {
+		return err
+	}
+
+	for _, codigo := range codigos {
+		_, err := tx.Exec(`
+            INSERT INTO cargo_permissao (cargo_id, permissao_id)
+            SELECT ?, id_permissao FROM permissao WHERE codigo = ?
+        `, cargoID, codigo)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CreateCargo creates a new cargo without permissions
+func CreateCargo(db *sql.DB, descricao string) (int, error) {
+	result, err := db.Exec("INSERT INTO cargo (descricao) VALUES (?)", descricao)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// CountCargoUsers counts the users with a cargo
+func CountCargoUsers(db *sql.DB, cargoID int) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM user WHERE cargo_id = ?", cargoID).Scan(&count)
+	return count, err
+}
+
+// DeleteCargo deletes a cargo; its permissions and estado transitions go with it
+func DeleteCargo(db *sql.DB, cargoID int) error {
+	_, err := db.Exec("DELETE FROM cargo WHERE id_cargo = ?", cargoID)
+	return err
+}
+
+// GetUsersWithPermissao retrieves the active users whose cargo has a permission
+func GetUsersWithPermissao(db *sql.DB, codigo string) ([]User, error) {
+	rows, err := db.Query(`
+        SELECT u.id_user, u.nome, u.email, u.cargo_id
+        FROM user u
+        JOIN cargo_permissao cp ON cp.cargo_id = u.cargo_id
+        JOIN permissao p ON cp.permissao_id = p.id_permissao
+        WHERE p.codigo = ? AND u.estado = ?
+    `, codigo, EstadoAtivo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Nome, &user.Email, &user.CargoID); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
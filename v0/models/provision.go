@@ -0,0 +1,121 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Account origins
+const (
+	OrigemLocal = "local"
+	OrigemLDAP  = "ldap"
+	OrigemOIDC  = "oidc"
+)
+
+var (
+	// ErrEmailEmUso is returned when an account's email belongs to another account
+	ErrEmailEmUso = errors.New("email já em uso por outra conta")
+	// ErrNomeEmUso is returned when an account's name belongs to another account
+	ErrNomeEmUso = errors.New("user já em uso por outra conta")
+)
+
+// erroDuplicado returns ErrNomeEmUso or ErrEmailEmUso for an insert or update of a user refused by the unique index
+// on the name or the email, which catches the accounts created after the NomeExists and EmailExists checks
+func erroDuplicado(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != 1062 {
+		return err
+	}
+
+	// The message names the key, as 'nome' or 'user.nome' depending on the server version
+	switch {
+	case strings.HasSuffix(mysqlErr.Message, "nome'"):
+		return ErrNomeEmUso
+	case strings.HasSuffix(mysqlErr.Message, "email'"):
+		return ErrEmailEmUso
+	}
+	return err
+}
+
+// LoginEstado is the local state of the account with a login, used by providers that check passwords elsewhere
+type LoginEstado struct {
+	UserID    int
+	Origem    string
+	Estado    string
+	Falhas    int
+	Bloqueado bool
+}
+
+// GetLoginEstado retrieves the local state of the account with a login, returning sql.ErrNoRows when there is none
+func GetLoginEstado(db *sql.DB, login string) (*LoginEstado, error) {
+	var e LoginEstado
+	err := db.QueryRow(`
+        SELECT id_user, origem, estado, failed_attempts,
+               bloqueado_ate IS NOT NULL AND bloqueado_ate > NOW()
+        FROM user WHERE nome = ?
+    `, login).Scan(&e.UserID, &e.Origem, &e.Estado, &e.Falhas, &e.Bloqueado)
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// ProvisionUser creates or updates the account of a user authenticated by an external provider, returning its ID.
+// The account has no local password; a new cargo ends the user's sessions, as in UpdateUser. A name or email of
+// another account returns ErrNomeEmUso or ErrEmailEmUso
+func ProvisionUser(db *sql.DB, origem, nome, email string, cargoID int) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var cargoAtual sql.NullInt64
+	err = tx.QueryRow("SELECT id_user, cargo_id FROM user WHERE nome = ? AND origem = ? FOR UPDATE", nome, origem).Scan(&id, &cargoAtual)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	// The email is unique, so it cannot be taken over from another account
+	var outros int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM user WHERE email = ? AND id_user <> ?", email, id).Scan(&outros); err != nil {
+		return 0, err
+	}
+	if outros > 0 {
+		return 0, ErrEmailEmUso
+	}
+
+	if id == 0 {
+		result, err := tx.Exec(`
+            INSERT INTO user (nome, email, password, cargo_id, origem, estado)
+            VALUES (?, ?, '', ?, ?, ?)
+        `, nome, email, cargoID, origem, EstadoAtivo)
+		if err != nil {
+			return 0, erroDuplicado(err)
+		}
+
+		newID, err := result.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+
+		return int(newID), tx.Commit()
+	}
+
+	if _, err := tx.Exec("UPDATE user SET email = ?, cargo_id = ? WHERE id_user = ?", email, cargoID, id); err != nil {
+		return 0, erroDuplicado(err)
+	}
+
+	if !cargoAtual.Valid || int(cargoAtual.Int64) != cargoID {
+		if _, err := tx.Exec("DELETE FROM sessao WHERE user_id = ?", id); err != nil {
+			return 0, err
+		}
+	}
+
+	return id, tx.Commit()
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestErroDuplicado(t *testing.T) {
+	outro := errors.New("ligação perdida")
+
+	tests := []struct {
+		nome string
+		err  error
+		want error
+	}{
+		{"name key", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'ana' for key 'user.nome'"}, ErrNomeEmUso},
+		{"name key of older servers", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'ana' for key 'nome'"}, ErrNomeEmUso},
+		{"email key", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@b.pt' for key 'user.email'"}, ErrEmailEmUso},
+		{"wrapped", fmt.Errorf("insert: %w", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'ana' for key 'nome'"}), ErrNomeEmUso},
+		{"other key", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"}, nil},
+		{"other error", &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row"}, nil},
+		{"not a server error", outro, outro},
+		{"no error", nil, nil},
+	}
+
+	for _, tt := range tests {
+		got := erroDuplicado(tt.err)
+		want := tt.want
+		if want == nil {
+			// Errors of other keys and codes are returned as they are
+			want = tt.err
+		}
+		if got != want {
+			t.Errorf("%s: erroDuplicado = %v, want %v", tt.nome, got, want)
+		}
+	}
+}
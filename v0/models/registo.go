@@ -0,0 +1,183 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Account states; self-registered accounts can only log in once active
+const (
+	EstadoAtivo             = "ativo"
+	EstadoPendenteEmail     = "pendente_email"
+	EstadoPendenteAprovacao = "pendente_aprovacao"
+)
+
+// ErrVerificacaoInvalida is returned for unknown, used or expired email verification tokens
+var ErrVerificacaoInvalida = errors.New("link de verificação inválido ou expirado")
+
+// RegistoPendente is a registration waiting for admin approval
+type RegistoPendente struct {
+	ID              int
+	Nome            string
+	Email           string
+	EmailVerificado string
+	RegistadoEm     string
+}
+
+// NomeExists checks if a user name already exists in the database
+func NomeExists(db *sql.DB, nome string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM user WHERE nome = ?", nome).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// CreatePendingUser creates a self-registered user waiting for email verification, returning its ID. The cargo
+// is only a placeholder until the account is approved. A name or email of another account returns ErrNomeEmUso or
+// ErrEmailEmUso
+func CreatePendingUser(db *sql.DB, nome, email, password string, cargoID int) (int, error) {
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := db.Exec(`
+        INSERT INTO user (nome, email, password, cargo_id, estado)
+        VALUES (?, ?, ?, ?, ?)
+    `, nome, email, hashedPassword, cargoID, EstadoPendenteEmail)
+	if err != nil {
+		return 0, erroDuplicado(err)
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// CreateEmailVerificacao creates an email verification token for a user valid for the given hours, returning
+// the token to send by email; only its hash is stored
+func CreateEmailVerificacao(db *sql.DB, userID, horas int) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Exec(`
+        INSERT INTO email_verificacao (user_id, token_hash, expira_em)
+        VALUES (?, ?, DATE_ADD(NOW(), INTERVAL ? HOUR))
+    `, userID, hashToken(token), horas)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// VerifyEmail uses up a verification token, moving its user to pendente_aprovacao, and returns the user ID.
+// Unknown, used or expired tokens return ErrVerificacaoInvalida
+func VerifyEmail(db *sql.DB, token string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Lock the token row so that it can only be used once
+	var userID int
+	err = tx.QueryRow(`
+        SELECT user_id FROM email_verificacao
+        WHERE token_hash = ? AND usado_em IS NULL AND expira_em > NOW()
+        FOR UPDATE
+    `, hashToken(token)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrVerificacaoInvalida
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE email_verificacao SET usado_em = NOW() WHERE user_id = ? AND usado_em IS NULL", userID); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+        UPDATE user SET estado = ?, email_verificado_em = NOW()
+        WHERE id_user = ? AND estado = ?
+    `, EstadoPendenteAprovacao, userID, EstadoPendenteEmail)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
+
+// ApproveUser activates an account waiting for approval with the given cargo. It returns sql.ErrNoRows
+// when the user is not waiting for approval
+func ApproveUser(db *sql.DB, userID, cargoID int) error {
+	result, err := db.Exec(`
+        UPDATE user SET estado = ?, cargo_id = ?
+        WHERE id_user = ? AND estado = ?
+    `, EstadoAtivo, cargoID, userID, EstadoPendenteAprovacao)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// RejectUser deletes a registration that is not active yet. It returns sql.ErrNoRows when there is none
+func RejectUser(db *sql.DB, userID int) error {
+	result, err := db.Exec("DELETE FROM user WHERE id_user = ? AND estado <> ?", userID, EstadoAtivo)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetPendingUsers retrieves the registrations that are not active yet, oldest first
+func GetPendingUsers(db *sql.DB) ([]RegistoPendente, error) {
+	rows, err := db.Query(`
+        SELECT id_user, nome, email, COALESCE(email_verificado_em, ''), registado_em
+        FROM user
+        WHERE estado <> ?
+        ORDER BY registado_em, id_user
+    `, EstadoAtivo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var registos []RegistoPendente
+	for rows.Next() {
+		var r RegistoPendente
+		if err := rows.Scan(&r.ID, &r.Nome, &r.Email, &r.EmailVerificado, &r.RegistadoEm); err != nil {
+			return nil, err
+		}
+		registos = append(registos, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return registos, nil
+}
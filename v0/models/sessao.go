@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// Sessao represents an active login session. Only the hash of the session token is stored, and ID is that hash
+type Sessao struct {
+	ID           string
+	UserID       int
+	IP           string
+	UserAgent    string
+	CriadoEm     string
+	UltimoAcesso string
+	// Atual marks the session of the request listing the sessions
+	Atual bool
+}
+
+// GetSessaoDados retrieves the encoded values of a session that has neither expired nor been idle for too long
+func GetSessaoDados(db *sql.DB, token string, idleMinutos int) ([]byte, error) {
+	var dados []byte
+	err := db.QueryRow(`
+        SELECT dados FROM sessao
+        WHERE id_sessao = ? AND expira_em > NOW() AND ultimo_acesso > DATE_SUB(NOW(), INTERVAL ? MINUTE)
+    `, hashToken(token), idleMinutos).Scan(&dados)
+	return dados, err
+}
+
+// TouchSessao records activity on a session, at most once a minute
+func TouchSessao(db *sql.DB, token string) error {
+	_, err := db.Exec(`
+        UPDATE sessao SET ultimo_acesso = NOW()
+        WHERE id_sessao = ? AND ultimo_acesso < DATE_SUB(NOW(), INTERVAL 1 MINUTE)
+    `, hashToken(token))
+	return err
+}
+
+// CreateSessao stores a new session valid for maxAge seconds
+func CreateSessao(db *sql.DB, token string, userID int, dados []byte, ip, userAgent string, maxAge int) error {
+	_, err := db.Exec(`
+        INSERT INTO sessao (id_sessao, user_id, dados, ip, user_agent, ultimo_acesso, expira_em)
+        VALUES (?, NULLIF(?, 0), ?, ?, ?, NOW(), DATE_ADD(NOW(), INTERVAL ? SECOND))
+    `, hashToken(token), userID, dados, ip, truncate(userAgent, 255), maxAge)
+	return err
+}
+
+// UpdateSessao saves the values of an existing session and extends it by maxAge seconds; revoked sessions are
+// not brought back
+func UpdateSessao(db *sql.DB, token string, userID int, dados []byte, maxAge int) error {
+	_, err := db.Exec(`
+        UPDATE sessao
+        SET user_id = NULLIF(?, 0), dados = ?, ultimo_acesso = NOW(), expira_em = DATE_ADD(NOW(), INTERVAL ? SECOND)
+        WHERE id_sessao = ?
+    `, userID, dados, maxAge, hashToken(token))
+	return err
+}
+
+// DeleteSessao deletes a session by its token
+func DeleteSessao(db *sql.DB, token string) error {
+	_, err := db.Exec("DELETE FROM sessao WHERE id_sessao = ?", hashToken(token))
+	return err
+}
+
+// DeleteExpiredSessoes deletes the sessions that expired or have been idle for too long
+func DeleteExpiredSessoes(db *sql.DB, idleMinutos int) error {
+	_, err := db.Exec(`
+        DELETE FROM sessao
+        WHERE expira_em <= NOW() OR ultimo_acesso <= DATE_SUB(NOW(), INTERVAL ? MINUTE)
+    `, idleMinutos)
+	return err
+}
+
+// GetUserSessoes retrieves the sessions of a user, most recently used first, marking the one with the token atual
+func GetUserSessoes(db *sql.DB, userID int, atual string) ([]Sessao, error) {
+	rows, err := db.Query(`
+        SELECT id_sessao, user_id, ip, user_agent, criado_em, ultimo_acesso
+        FROM sessao
+        WHERE user_id = ? AND expira_em > NOW()
+        ORDER BY ultimo_acesso DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	atualID := hashToken(atual)
+	var sessoes []Sessao
+	for rows.Next() {
+		var s Sessao
+		if err := rows.Scan(&s.ID, &s.UserID, &s.IP, &s.UserAgent, &s.CriadoEm, &s.UltimoAcesso); err != nil {
+			return nil, err
+		}
+		s.Atual = s.ID == atualID
+		sessoes = append(sessoes, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessoes, nil
+}
+
+// DeleteUserSessao revokes one session of a user by its ID
+func DeleteUserSessao(db *sql.DB, userID int, id string) error {
+	_, err := db.Exec("DELETE FROM sessao WHERE id_sessao = ? AND user_id = ?", id, userID)
+	return err
+}
+
+// DeleteUserSessoes revokes all the sessions of a user except the one with the token exceto
+func DeleteUserSessoes(db *sql.DB, userID int, exceto string) error {
+	_, err := db.Exec("DELETE FROM sessao WHERE user_id = ? AND id_sessao <> ?", userID, hashToken(exceto))
+	return err
+}
+
+// truncate shortens a string to at most n runes
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
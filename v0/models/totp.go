@@ -0,0 +1,194 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"strings"
+)
+
+// RecoveryCodeCount is how many recovery codes are generated when 2FA is enabled
+const RecoveryCodeCount = 10
+
+// recoveryAlphabet leaves out i, l, o and 0 so codes are easy to read; 32 characters keep the random bytes unbiased
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz123456789"
+
+// GetTOTP retrieves the TOTP secret of a user and whether 2FA is enabled
+func GetTOTP(db *sql.DB, userID int) (string, bool, error) {
+	var secret sql.NullString
+	var ativo bool
+	err := db.QueryRow("SELECT totp_secret, totp_ativo FROM user WHERE id_user = ?", userID).Scan(&secret, &ativo)
+	return secret.String, ativo, err
+}
+
+// UseTOTPStep records the time step of an accepted authenticator code, returning false when it is not after
+// the last one recorded for the user, so that each code is only accepted once
+func UseTOTPStep(db *sql.DB, userID int, passo int64) (bool, error) {
+	result, err := db.Exec(`
+        UPDATE user SET totp_ultimo_passo = ?
+        WHERE id_user = ? AND (totp_ultimo_passo IS NULL OR totp_ultimo_passo < ?)
+    `, passo, userID, passo)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// CargoExige2FA checks if the users of a cargo must use 2FA
+func CargoExige2FA(db *sql.DB, cargoID int) (bool, error) {
+	var exige bool
+	err := db.QueryRow("SELECT exige_2fa FROM cargo WHERE id_cargo = ?", cargoID).Scan(&exige)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return exige, err
+}
+
+// SetCargoExige2FA sets whether the users of a cargo must use 2FA
+func SetCargoExige2FA(db *sql.DB, cargoID int, exige bool) error {
+	_, err := db.Exec("UPDATE cargo SET exige_2fa = ? WHERE id_cargo = ?", exige, cargoID)
+	return err
+}
+
+// GenerateRecoveryCodes generates a new set of recovery codes, formatted as xxxxx-xxxxx
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		for j := range b {
+			b[j] = recoveryAlphabet[int(b[j])%len(recoveryAlphabet)]
+		}
+		codes[i] = string(b[:5]) + "-" + string(b[5:])
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and the dash when comparing recovery codes
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// EnableTOTP enables 2FA for a user with a confirmed secret, replacing any previous recovery codes
+func EnableTOTP(db *sql.DB, userID int, secret string, codes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE user SET totp_secret = ?, totp_ativo = TRUE WHERE id_user = ?", secret, userID); err != nil {
+		return err
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, codes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DisableTOTP disables 2FA for a user, removing the secret and the recovery codes
+func DisableTOTP(db *sql.DB, userID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := disableTOTP(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ResetTOTP disables 2FA for a user who lost their authenticator, also revoking the user's API tokens
+func ResetTOTP(db *sql.DB, userID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := disableTOTP(tx, userID); err != nil {
+		return err
+	}
+
+	if err := deleteUserAPITokens(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// disableTOTP removes the 2FA secret and the recovery codes of a user
+func disableTOTP(tx *sql.Tx, userID int) error {
+	if _, err := tx.Exec("UPDATE user SET totp_secret = NULL, totp_ativo = FALSE WHERE id_user = ?", userID); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec("DELETE FROM user_recovery_code WHERE user_id = ?", userID)
+	return err
+}
+
+// ReplaceRecoveryCodes replaces the recovery codes of a user
+func ReplaceRecoveryCodes(db *sql.DB, userID int, codes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// replaceRecoveryCodes stores the hashes of the new recovery codes in place of the old ones
+func replaceRecoveryCodes(tx *sql.Tx, userID int, codes []string) error {
+	if _, err := tx.Exec("DELETE FROM user_recovery_code WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		_, err := tx.Exec("INSERT INTO user_recovery_code (user_id, code_hash) VALUES (?, ?)",
+			userID, hashToken(normalizeRecoveryCode(code)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode uses up a recovery code of a user, returning false if it is unknown or already used
+func UseRecoveryCode(db *sql.DB, userID int, code string) (bool, error) {
+	result, err := db.Exec(`
+        UPDATE user_recovery_code SET usado_em = NOW()
+        WHERE user_id = ? AND code_hash = ? AND usado_em IS NULL
+        LIMIT 1
+    `, userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// CountRecoveryCodes counts the unused recovery codes of a user
+func CountRecoveryCodes(db *sql.DB, userID int) (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM user_recovery_code WHERE user_id = ? AND usado_em IS NULL", userID).Scan(&count)
+	return count, err
+}
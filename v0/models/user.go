@@ -2,8 +2,7 @@ package models
 
 import (
 	"database/sql"
-
-	"golang.org/x/crypto/bcrypt"
+	"strings"
 )
 
 // User represents a user record
@@ -13,44 +12,76 @@ type User struct {
 	Email    string
 	Password string
 	CargoID  int
+	// Origem is where the account comes from: OrigemLocal, or a provider that provisioned it
+	Origem string
+	Estado string
+	// LoginLocal is false when the user may only sign in through single sign-on
+	LoginLocal bool
+	// NotifConcursos and NotifMencoes are the user's choice of notification emails
+	NotifConcursos bool
+	NotifMencoes   bool
+	// TOTPAtivo, Bloqueado and SemHash are only filled in by GetAllUsers
+	TOTPAtivo bool
+	Bloqueado bool
+	// SemHash marks a local password stored without a hash, which never matches
+	SemHash bool
 }
 
 // Cargo represents a cargo (role) record
 type Cargo struct {
 	ID        int
 	Descricao string
+	Exige2FA  bool
 }
 
-func Authenticate(db *sql.DB, email, password string) (int, int, error) {
+// Authenticate checks the login and password of a local user, returning the user and cargo IDs. Failed attempts
+// count towards the lockout policy; a failed or refused login returns a *LoginError
+func Authenticate(db *sql.DB, login, password string, policy LockoutPolicy) (int, int, error) {
 	var id, cargo, failedAttempts int
-	var storedPassword string
-
-	// Primeiro verifica se o usuário existe e obtém os dados atuais
-	err := db.QueryRow("SELECT id_user, password, cargo_id, failed_attempts FROM user WHERE nome = ?", email).Scan(&id, &storedPassword, &cargo, &failedAttempts)
+	var storedPassword, estado string
+	var bloqueado bool
+
+	err := db.QueryRow(`
+        SELECT id_user, password, cargo_id, failed_attempts, estado,
+               bloqueado_ate IS NOT NULL AND bloqueado_ate > NOW()
+        FROM user WHERE nome = ? AND origem = ? AND login_local
+    `, login, OrigemLocal).Scan(&id, &storedPassword, &cargo, &failedAttempts, &estado, &bloqueado)
+	if err == sql.ErrNoRows {
+		return 0, 0, &LoginError{}
+	}
 	if err != nil {
 		return 0, 0, err
 	}
 
-	// Se tiver 3 ou mais tentativas falhadas, retorna erro como se a senha estivesse incorreta
-	if failedAttempts >= 3 {
-		return 0, 0, err
+	// Locked accounts are refused without checking the password
+	if bloqueado {
+		return 0, 0, &LoginError{UserID: id, Falhas: failedAttempts, Bloqueado: true}
 	}
 
-	// Compara a senha fornecida com o hash armazenado
-	err = bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(password))
-	if err != nil {
-		// Se a senha estiver errada, incrementa o contador de tentativas falhadas
-		_, updateErr := db.Exec("UPDATE user SET failed_attempts = failed_attempts + 1 WHERE nome = ?", email)
-		if updateErr != nil {
+	ok, rehash := verifyPassword(storedPassword, password)
+	if !ok {
+		loginErr, err := RegisterLoginFailure(db, id, policy)
+		if err != nil {
 			return 0, 0, err
 		}
-		return 0, 0, err
+		return 0, 0, loginErr
 	}
 
-	// Se a autenticação for bem-sucedida, reseta o contador de tentativas falhadas
+	// Registrations waiting for verification or approval cannot log in yet
+	if estado != EstadoAtivo {
+		return 0, 0, &LoginError{UserID: id, Pendente: estado}
+	}
+
+	// Hashes made with an older algorithm or cost are upgraded while the password is at hand
+	if rehash {
+		if err := rehashPassword(db, id, storedPassword, password); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	// A successful login clears the failed attempts and any expired lock
 	if failedAttempts > 0 {
-		_, updateErr := db.Exec("UPDATE user SET failed_attempts = 0 WHERE nome = ?", email)
-		if updateErr != nil {
+		if err := ResetFailedAttempts(db, id); err != nil {
 			return 0, 0, err
 		}
 	}
@@ -58,19 +89,19 @@ func Authenticate(db *sql.DB, email, password string) (int, int, error) {
 	return id, cargo, nil
 }
 
-// CreateUser creates a new user
+// CreateUser creates a new user, returning ErrNomeEmUso or ErrEmailEmUso when the name or email is taken
 func CreateUser(db *sql.DB, nome, email, password string, cargoID int) error {
 	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := HashPassword(password)
 	if err != nil {
 		return err
 	}
 
 	// Insert the new user
 	_, err = db.Exec("INSERT INTO user (nome, email, password, cargo_id) VALUES (?, ?, ?, ?)",
-		nome, email, string(hashedPassword), cargoID)
+		nome, email, hashedPassword, cargoID)
 
-	return err
+	return erroDuplicado(err)
 }
 
 // EmailExists checks if an email already exists in the database
@@ -87,8 +118,12 @@ func EmailExists(db *sql.DB, email string) (bool, error) {
 // GetUserByID retrieves a user by ID
 func GetUserByID(db *sql.DB, id int) (*User, error) {
 	var user User
-	err := db.QueryRow("SELECT id_user, nome, email, cargo_id FROM user WHERE id_user = ?", id).Scan(
-		&user.ID, &user.Nome, &user.Email, &user.CargoID,
+	err := db.QueryRow(`
+        SELECT id_user, nome, email, cargo_id, origem, estado, login_local, notif_concursos, notif_mencoes
+        FROM user WHERE id_user = ?
+    `, id).Scan(
+		&user.ID, &user.Nome, &user.Email, &user.CargoID, &user.Origem, &user.Estado, &user.LoginLocal,
+		&user.NotifConcursos, &user.NotifMencoes,
 	)
 	if err != nil {
 		return nil, err
@@ -97,29 +132,113 @@ func GetUserByID(db *sql.DB, id int) (*User, error) {
 	return &user, nil
 }
 
-// UpdateUser updates an existing user
+// UpdateUser updates an existing user, returning ErrNomeEmUso or ErrEmailEmUso when the name or email is taken
 func UpdateUser(db *sql.DB, user *User) error {
-	_, err := db.Exec("UPDATE user SET nome = ?, email = ?, cargo_id = ? WHERE id_user = ?",
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var cargoAtual sql.NullInt64
+	if err := tx.QueryRow("SELECT cargo_id FROM user WHERE id_user = ? FOR UPDATE", user.ID).Scan(&cargoAtual); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE user SET nome = ?, email = ?, cargo_id = ? WHERE id_user = ?",
 		user.Nome, user.Email, user.CargoID, user.ID)
+	if err != nil {
+		return erroDuplicado(err)
+	}
 
-	return err
+	// A new cargo ends the user's sessions, so that no session keeps the old one
+	if !cargoAtual.Valid || int(cargoAtual.Int64) != user.CargoID {
+		if _, err := tx.Exec("DELETE FROM sessao WHERE user_id = ?", user.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
-// UpdatePassword updates a user's password
+// UpdatePassword updates a user's password, ending all of the user's sessions and revoking their API tokens. A
+// recently used password returns ErrPasswordReutilizada
 func UpdatePassword(db *sql.DB, userID int, newPassword string) error {
-	// Hash the new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
 	// Update the password
-	_, err = db.Exec("UPDATE user SET password = ? WHERE id_user = ?", string(hashedPassword), userID)
+	if err := setPassword(tx, userID, newPassword); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM sessao WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+
+	if err := deleteUserAPITokens(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CheckPassword checks the current password of a local user
+func CheckPassword(db *sql.DB, userID int, password string) (bool, error) {
+	var storedPassword string
+	err := db.QueryRow("SELECT password FROM user WHERE id_user = ? AND origem = ?", userID, OrigemLocal).Scan(&storedPassword)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ok, _ := verifyPassword(storedPassword, password)
+	return ok, nil
+}
+
+// ChangePassword updates the password of a user who changes it, ending all of the user's sessions except the one
+// with the token atual and revoking their API tokens. A recently used password returns ErrPasswordReutilizada
+func ChangePassword(db *sql.DB, userID int, newPassword, atual string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := setPassword(tx, userID, newPassword); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM sessao WHERE user_id = ? AND id_sessao <> ?", userID, hashToken(atual)); err != nil {
+		return err
+	}
+
+	if err := deleteUserAPITokens(tx, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
 
+// UpdateProfile updates the name and email of a user editing their own profile, returning ErrNomeEmUso or
+// ErrEmailEmUso when the name or email is taken
+func UpdateProfile(db *sql.DB, userID int, nome, email string) error {
+	_, err := db.Exec("UPDATE user SET nome = ?, email = ? WHERE id_user = ?", nome, email, userID)
+	return erroDuplicado(err)
+}
+
+// UpdateNotificacoes saves the notification emails a user wants to receive
+func UpdateNotificacoes(db *sql.DB, userID int, concursos, mencoes bool) error {
+	_, err := db.Exec("UPDATE user SET notif_concursos = ?, notif_mencoes = ? WHERE id_user = ?", concursos, mencoes, userID)
 	return err
 }
 
-// DeleteUser deletes a user by ID
+// DeleteUser deletes a user by ID; the user's sessions are deleted with it
 func DeleteUser(db *sql.DB, id int) error {
 	_, err := db.Exec("DELETE FROM user WHERE id_user = ?", id)
 	return err
@@ -127,7 +246,43 @@ func DeleteUser(db *sql.DB, id int) error {
 
 // GetAllUsers retrieves all users
 func GetAllUsers(db *sql.DB) ([]User, error) {
-	rows, err := db.Query("SELECT id_user, nome, email, cargo_id FROM user")
+	rows, err := db.Query(`
+        SELECT id_user, nome, email, cargo_id, totp_ativo,
+               bloqueado_ate IS NOT NULL AND bloqueado_ate > NOW(), estado, origem,
+               origem = ? AND password <> '' AND password NOT LIKE '$2_$%' AND password NOT LIKE '$argon2id$%'
+        FROM user
+    `, OrigemLocal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Nome, &user.Email, &user.CargoID, &user.TOTPAtivo, &user.Bloqueado, &user.Estado, &user.Origem, &user.SemHash); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetNotifConcursosUsers retrieves the active users who can see concursos and receive the concurso update emails,
+// leaving out the users who turned them off
+func GetNotifConcursosUsers(db *sql.DB) ([]User, error) {
+	rows, err := db.Query(`
+        SELECT u.id_user, u.nome, u.email, u.cargo_id
+        FROM user u
+        JOIN cargo_permissao cp ON cp.cargo_id = u.cargo_id
+        JOIN permissao p ON cp.permissao_id = p.id_permissao
+        WHERE p.codigo = ? AND u.estado = ? AND u.notif_concursos
+    `, PermConcursoView, EstadoAtivo)
 	if err != nil {
 		return nil, err
 	}
@@ -149,9 +304,43 @@ func GetAllUsers(db *sql.DB) ([]User, error) {
 	return users, nil
 }
 
+// GetUsersByNomes retrieves the users whose name matches one of the given names
+func GetUsersByNomes(db *sql.DB, nomes []string) ([]User, error) {
+	if len(nomes) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(nomes)), ", ")
+	args := make([]interface{}, len(nomes))
+	for i, nome := range nomes {
+		args[i] = nome
+	}
+
+	rows, err := db.Query("SELECT id_user, nome, email, cargo_id, notif_mencoes FROM user WHERE nome IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.ID, &user.Nome, &user.Email, &user.CargoID, &user.NotifMencoes); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
 // GetAllCargos retrieves all cargos (roles)
 func GetAllCargos(db *sql.DB) ([]Cargo, error) {
-	rows, err := db.Query("SELECT id_cargo, descricao FROM cargo")
+	rows, err := db.Query("SELECT id_cargo, descricao, exige_2fa FROM cargo")
 	if err != nil {
 		return nil, err
 	}
@@ -160,7 +349,7 @@ func GetAllCargos(db *sql.DB) ([]Cargo, error) {
 	var cargos []Cargo
 	for rows.Next() {
 		var cargo Cargo
-		if err := rows.Scan(&cargo.ID, &cargo.Descricao); err != nil {
+		if err := rows.Scan(&cargo.ID, &cargo.Descricao, &cargo.Exige2FA); err != nil {
 			return nil, err
 		}
 		cargos = append(cargos, cargo)
@@ -173,8 +362,8 @@ func GetAllCargos(db *sql.DB) ([]Cargo, error) {
 	return cargos, nil
 }
 
-// ResetFailedAttempts reseta o contador de tentativas falhadas
+// ResetFailedAttempts reseta o contador de tentativas falhadas e desbloqueia a conta
 func ResetFailedAttempts(db *sql.DB, userID int) error {
-	_, err := db.Exec("UPDATE user SET failed_attempts = 0 WHERE id_user = ?", userID)
+	_, err := db.Exec("UPDATE user SET failed_attempts = 0, bloqueado_ate = NULL WHERE id_user = ?", userID)
 	return err
 }
@@ -0,0 +1,312 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// EstadoInicial is the estado a concurso is considered to be in before it is created
+const EstadoInicial = 1
+
+// Transicao represents an allowed transition between two estados
+type Transicao struct {
+	ID                 int
+	EstadoOrigemID     int
+	EstadoDestinoID    int
+	EstadoOrigemDesc   string
+	EstadoDestinoDesc  string
+	CamposObrigatorios []string
+	Notificar          bool
+	Acao               string
+	Cargos             []int
+}
+
+// CampoLabels maps the fields that can be required by a transition to their display names
+var CampoLabels = map[string]string{
+	"referencia":     "Referência",
+	"entidade":       "Entidade",
+	"preco":          "Preço",
+	"referencia_bc":  "Referência BC",
+	"link":           "Link",
+	"dia_erro":       "Data Esclarecimentos/Erros",
+	"hora_erro":      "Hora Esclarecimentos/Erros",
+	"dia_proposta":   "Data Proposta",
+	"hora_proposta":  "Hora Proposta",
+	"dia_audiencia":  "Data Audiência Prévia",
+	"hora_audiencia": "Hora Audiência Prévia",
+	"resultado_id":   "Resultado",
+	"adjudicatario":  "Adjudicatário",
+}
+
+// GetTransicao retrieves the transition between two estados, returning sql.ErrNoRows if it is not allowed
+func GetTransicao(db *sql.DB, origemID, destinoID int) (*Transicao, error) {
+	var t Transicao
+	var campos string
+
+	err := db.QueryRow(`
+        SELECT t.id_transicao, t.estado_origem_id, t.estado_destino_id,
+               eo.descricao, ed.descricao, t.campos_obrigatorios, t.notificar, t.acao
+        FROM estado_transicao t
+        JOIN estado eo ON t.estado_origem_id = eo.id_estado
+        JOIN estado ed ON t.estado_destino_id = ed.id_estado
+        WHERE t.estado_origem_id = ? AND t.estado_destino_id = ?
+    `, origemID, destinoID).Scan(
+		&t.ID, &t.EstadoOrigemID, &t.EstadoDestinoID,
+		&t.EstadoOrigemDesc, &t.EstadoDestinoDesc, &campos, &t.Notificar, &t.Acao,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	t.CamposObrigatorios = splitCampos(campos)
+
+	t.Cargos, err = getTransicaoCargos(db, t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// GetTransicoesFrom retrieves all transitions leaving an estado
+func GetTransicoesFrom(db *sql.DB, origemID int) ([]Transicao, error) {
+	return queryTransicoes(db, "WHERE t.estado_origem_id = ? ORDER BY t.estado_destino_id", origemID)
+}
+
+// GetTransicoes retrieves all transitions, ordered by estado
+func GetTransicoes(db *sql.DB) ([]Transicao, error) {
+	return queryTransicoes(db, "ORDER BY t.estado_origem_id, t.estado_destino_id")
+}
+
+// GetTransicaoByID retrieves a transition by ID, returning sql.ErrNoRows if it does not exist
+func GetTransicaoByID(db *sql.DB, id int) (*Transicao, error) {
+	transicoes, err := queryTransicoes(db, "WHERE t.id_transicao = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(transicoes) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &transicoes[0], nil
+}
+
+// queryTransicoes retrieves the transitions selected by a WHERE and ORDER BY clause, with their cargos
+func queryTransicoes(db *sql.DB, clause string, args ...interface{}) ([]Transicao, error) {
+	rows, err := db.Query(`
+        SELECT t.id_transicao, t.estado_origem_id, t.estado_destino_id,
+               eo.descricao, ed.descricao, t.campos_obrigatorios, t.notificar, t.acao
+        FROM estado_transicao t
+        JOIN estado eo ON t.estado_origem_id = eo.id_estado
+        JOIN estado ed ON t.estado_destino_id = ed.id_estado
+        `+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transicoes []Transicao
+	for rows.Next() {
+		var t Transicao
+		var campos string
+		if err := rows.Scan(
+			&t.ID, &t.EstadoOrigemID, &t.EstadoDestinoID,
+			&t.EstadoOrigemDesc, &t.EstadoDestinoDesc, &campos, &t.Notificar, &t.Acao,
+		); err != nil {
+			return nil, err
+		}
+		t.CamposObrigatorios = splitCampos(campos)
+		transicoes = append(transicoes, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Load cargos after closing the iteration to avoid holding two connections
+	for i := range transicoes {
+		transicoes[i].Cargos, err = getTransicaoCargos(db, transicoes[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return transicoes, nil
+}
+
+// CreateTransicao creates a transition with the cargos allowed to perform it, setting its ID
+func CreateTransicao(db *sql.DB, t *Transicao) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+        INSERT INTO estado_transicao (estado_origem_id, estado_destino_id, campos_obrigatorios, notificar, acao)
+        VALUES (?, ?, ?, ?, ?)
+    `, t.EstadoOrigemID, t.EstadoDestinoID, strings.Join(t.CamposObrigatorios, ","), t.Notificar, t.Acao)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	if err := setTransicaoCargos(tx, int(id), t.Cargos); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	t.ID = int(id)
+	return nil
+}
+
+// UpdateTransicao updates the required fields, notification, action and cargos of a transition; its estados
+// do not change
+func UpdateTransicao(db *sql.DB, t *Transicao) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+        UPDATE estado_transicao
+        SET campos_obrigatorios = ?, notificar = ?, acao = ?
+        WHERE id_transicao = ?
+    `, strings.Join(t.CamposObrigatorios, ","), t.Notificar, t.Acao, t.ID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM estado_transicao_cargo WHERE transicao_id = ?", t.ID); err != nil {
+		return err
+	}
+	if err := setTransicaoCargos(tx, t.ID, t.Cargos); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteTransicao deletes a transition; its cargos go with it
+func DeleteTransicao(db *sql.DB, id int) error {
+	_, err := db.Exec("DELETE FROM estado_transicao WHERE id_transicao = ?", id)
+	return err
+}
+
+// setTransicaoCargos inserts the cargos allowed to perform a transition
+func setTransicaoCargos(tx *sql.Tx, transicaoID int, cargos []int) error {
+	for _, cargo := range cargos {
+		if _, err := tx.Exec("INSERT INTO estado_transicao_cargo (transicao_id, cargo_id) VALUES (?, ?)", transicaoID, cargo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AllowsCargo checks if the given cargo may perform the transition
+func (t *Transicao) AllowsCargo(cargoID int) bool {
+	for _, c := range t.Cargos {
+		if c == cargoID {
+			return true
+		}
+	}
+	return false
+}
+
+// ExigeCampo checks if the transition requires a field
+func (t *Transicao) ExigeCampo(campo string) bool {
+	for _, c := range t.CamposObrigatorios {
+		if c == campo {
+			return true
+		}
+	}
+	return false
+}
+
+// CamposEmFalta returns the required fields that are empty in the concurso
+func CamposEmFalta(c *Concurso, campos []string) []string {
+	var missing []string
+	for _, campo := range campos {
+		if !campoPreenchido(c, campo) {
+			missing = append(missing, campo)
+		}
+	}
+	return missing
+}
+
+// campoPreenchido checks if a single field of the concurso has a value
+func campoPreenchido(c *Concurso, campo string) bool {
+	switch campo {
+	case "referencia":
+		return c.Referencia != ""
+	case "entidade":
+		return c.Entidade != ""
+	case "preco":
+		return c.Preco > 0
+	case "referencia_bc":
+		return c.ReferenciaBC != ""
+	case "link":
+		return c.Link != ""
+	case "dia_erro":
+		return c.DiaErro.Valid
+	case "hora_erro":
+		return c.HoraErro.Valid
+	case "dia_proposta":
+		return c.DiaProposta.Valid
+	case "hora_proposta":
+		return c.HoraProposta.Valid
+	case "dia_audiencia":
+		return c.DiaAudiencia.Valid
+	case "hora_audiencia":
+		return c.HoraAudiencia.Valid
+	case "resultado_id":
+		// resultado 1 is the empty description
+		return c.ResultadoID > 1
+	case "adjudicatario":
+		return c.Adjudicatario != ""
+	}
+	return true
+}
+
+// getTransicaoCargos retrieves the cargos allowed to perform a transition
+func getTransicaoCargos(db *sql.DB, transicaoID int) ([]int, error) {
+	rows, err := db.Query("SELECT cargo_id FROM estado_transicao_cargo WHERE transicao_id = ?", transicaoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cargos []int
+	for rows.Next() {
+		var cargo int
+		if err := rows.Scan(&cargo); err != nil {
+			return nil, err
+		}
+		cargos = append(cargos, cargo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return cargos, nil
+}
+
+// splitCampos splits the comma separated list of required fields
+func splitCampos(campos string) []string {
+	var result []string
+	for _, campo := range strings.Split(campos, ",") {
+		campo = strings.TrimSpace(campo)
+		if campo != "" {
+			result = append(result, campo)
+		}
+	}
+	return result
+}
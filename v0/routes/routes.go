@@ -7,65 +7,186 @@ import (
 	"v0/config"
 	"v0/handlers"
 	"v0/middleware"
+	"v0/models"
+	"v0/services"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
 )
 
 // SetupRoutes configures all routes for the application
-func SetupRoutes(db *sql.DB, cfg *config.Config) http.Handler {
+func SetupRoutes(db *sql.DB, cfg *config.Config, scheduler *services.Scheduler) http.Handler {
 	// Create session store
-	store := sessions.NewCookieStore([]byte(cfg.Session.Secret))
+	store := services.NewDBSessionStore(db, cfg.Session.IdleMinutes, []byte(cfg.Session.Secret))
 	store.Options = &sessions.Options{
 		Path:     "/",
 		MaxAge:   86400 * 7, // 7 days
 		HttpOnly: true,
+		Secure:   cfg.Server.HTTPS(),
+		SameSite: http.SameSiteLaxMode,
 	}
 
 	// Create router
 	router := mux.NewRouter()
+	router.Use(middleware.SecurityHeaders(cfg.Server.HTTPS()), middleware.APIToken(db, store), middleware.CSRF(store, cfg.Server.HTTPS(), []byte(cfg.Session.Secret)))
+
+	storage := services.NewLocalStorage(cfg.Storage.Path)
 
 	// Create handler instances
-	authHandler := handlers.NewAuthHandler(db, store)
-	concursoHandler := handlers.NewConcursoHandler(db, store, cfg)
-	pdfHandler := handlers.NewPDFHandler(db)
+	authHandler := handlers.NewAuthHandler(db, store, cfg)
+	concursoHandler := handlers.NewConcursoHandler(db, store, cfg, storage)
+	pdfHandler := handlers.NewPDFHandler(db, store, cfg)
 	userHandler := handlers.NewUserHandler(db, store)
+	checklistHandler := handlers.NewChecklistHandler(db, store)
+	anexoHandler := handlers.NewAnexoHandler(db, store, cfg, storage)
+	comentarioHandler := handlers.NewComentarioHandler(db, store, cfg)
+	calendarioHandler := handlers.NewCalendarioHandler(db, store, cfg)
+	feriadoHandler := handlers.NewFeriadoHandler(db, store)
+	transicaoHandler := handlers.NewTransicaoHandler(db, store)
+	relatorioHandler := handlers.NewRelatorioHandler(db, store, cfg)
+	agendamentoHandler := handlers.NewAgendamentoHandler(db, store, scheduler)
+	cargoHandler := handlers.NewCargoHandler(db, store)
+	equipaHandler := handlers.NewEquipaHandler(db, store)
+	passwordHandler := handlers.NewPasswordHandler(db, store, cfg)
+	twoFactorHandler := handlers.NewTwoFactorHandler(db, store)
+	sessaoHandler := handlers.NewSessaoHandler(db, store)
+	registoHandler := handlers.NewRegistoHandler(db, store, cfg)
+	identidadeHandler := handlers.NewIdentidadeHandler(db, store, cfg.OIDC.IssuerURL != "")
+	perfilHandler := handlers.NewPerfilHandler(db, store, cfg)
 
 	// Public routes
 	router.HandleFunc("/", handlers.IndexHandler).Methods("GET")
 	router.HandleFunc("/login", authHandler.Login).Methods("GET", "POST")
+	router.HandleFunc("/login/2fa", authHandler.Login2FA).Methods("GET", "POST")
+	router.HandleFunc("/login/2fa/ativar", authHandler.Enrol2FA).Methods("GET", "POST")
+	router.HandleFunc("/login/oidc", authHandler.LoginOIDC).Methods("GET")
+	router.HandleFunc("/login/oidc/callback", authHandler.CallbackOIDC).Methods("GET")
 	router.HandleFunc("/register", authHandler.Register).Methods("GET", "POST")
-	router.HandleFunc("/logout", authHandler.Logout).Methods("GET")
-
-	// Protected routes - View Only (All authenticated users)
-	viewOnly := router.PathPrefix("/").Subrouter()
-	viewOnly.Use(middleware.ViewOnlyMiddleware(store))
-
-	viewOnly.HandleFunc("/concursos", concursoHandler.List).Methods("GET")
-	viewOnly.HandleFunc("/concursos-ordenados", concursoHandler.ListOrdered).Methods("GET")
-	viewOnly.HandleFunc("/download-pdf", pdfHandler.Download).Methods("GET")
-
-	// Protected routes - Admin and SAV Only
-	adminSAV := router.PathPrefix("/").Subrouter()
-	adminSAV.Use(middleware.AdminSAVMiddleware(store))
-
-	adminSAV.HandleFunc("/edit-concurso/{id}", concursoHandler.Edit).Methods("GET")
-	adminSAV.HandleFunc("/update-concurso/{id}", concursoHandler.Update).Methods("POST")
-	adminSAV.HandleFunc("/create-concurso", concursoHandler.Create).Methods("GET")
-	adminSAV.HandleFunc("/save-concurso", concursoHandler.Save).Methods("POST")
-	adminSAV.HandleFunc("/delete-concurso/{id}", concursoHandler.Delete).Methods("GET", "POST")
-
-	// Admin Only routes
-	adminOnly := router.PathPrefix("/admin").Subrouter()
-	adminOnly.Use(middleware.AdminOnlyMiddleware(store))
-
-	adminOnly.HandleFunc("/users", userHandler.List).Methods("GET")
-	adminOnly.HandleFunc("/users/create", userHandler.Create).Methods("GET")
-	adminOnly.HandleFunc("/users/save", userHandler.Save).Methods("POST")
-	adminOnly.HandleFunc("/users/edit/{id}", userHandler.Edit).Methods("GET")
-	adminOnly.HandleFunc("/users/update/{id}", userHandler.Update).Methods("POST")
-	adminOnly.HandleFunc("/users/delete/{id}", userHandler.Delete).Methods("GET", "POST")
-	adminOnly.HandleFunc("/users/reset/{id}", userHandler.Reset).Methods("GET", "POST")
+	router.HandleFunc("/verificar-email", authHandler.VerifyEmail).Methods("GET")
+	router.HandleFunc("/logout", authHandler.Logout).Methods("POST")
+	router.HandleFunc("/recuperar-password", passwordHandler.Forgot).Methods("GET", "POST")
+	router.HandleFunc("/redefinir-password", passwordHandler.Reset).Methods("GET", "POST")
+
+	// Account routes - any logged in user
+	conta := router.PathPrefix("/conta").Subrouter()
+	conta.Use(middleware.AuthMiddleware(db, store))
+
+	conta.HandleFunc("/2fa", twoFactorHandler.Page).Methods("GET")
+	conta.HandleFunc("/2fa/ativar", twoFactorHandler.Enable).Methods("POST")
+	conta.HandleFunc("/2fa/desativar", twoFactorHandler.Disable).Methods("POST")
+	conta.HandleFunc("/2fa/codigos", twoFactorHandler.RecoveryCodes).Methods("POST")
+	conta.HandleFunc("/sso", identidadeHandler.Page).Methods("GET")
+	conta.HandleFunc("/sso/ligar", authHandler.LinkOIDC).Methods("POST")
+	conta.HandleFunc("/sso/login-local", identidadeHandler.SetLoginLocal).Methods("POST")
+	conta.HandleFunc("/sso/{id:[0-9]+}/desligar", identidadeHandler.Unlink).Methods("POST")
+	conta.HandleFunc("/sessoes", sessaoHandler.List).Methods("GET")
+	conta.HandleFunc("/sessoes/revogar-outras", sessaoHandler.RevokeOthers).Methods("POST")
+	conta.HandleFunc("/sessoes/{id:[0-9a-f]{64}}/revogar", sessaoHandler.Revoke).Methods("POST")
+
+	perfil := router.PathPrefix("/perfil").Subrouter()
+	perfil.Use(middleware.AuthMiddleware(db, store))
+
+	perfil.HandleFunc("", perfilHandler.Page).Methods("GET")
+	perfil.HandleFunc("", perfilHandler.Update).Methods("POST")
+	perfil.HandleFunc("/password", perfilHandler.ChangePassword).Methods("POST")
+	perfil.HandleFunc("/notificacoes", perfilHandler.UpdateNotificacoes).Methods("POST")
+	perfil.HandleFunc("/tokens", perfilHandler.CreateToken).Methods("POST")
+	perfil.HandleFunc("/tokens/{id:[0-9]+}/revogar", perfilHandler.RevokeToken).Methods("POST")
+
+	// Protected routes - each group requires one permission of the user's cargo
+	view := router.PathPrefix("/").Subrouter()
+	view.Use(middleware.RequirePermission(db, store, models.PermConcursoView))
+
+	view.HandleFunc("/concursos", concursoHandler.List).Methods("GET")
+	view.HandleFunc("/concursos-ordenados", concursoHandler.ListOrdered).Methods("GET")
+	view.HandleFunc("/concursos-quadro", concursoHandler.Board).Methods("GET")
+	view.HandleFunc("/calendario", calendarioHandler.Page).Methods("GET")
+	view.HandleFunc("/api/calendario", calendarioHandler.Eventos).Methods("GET")
+	view.HandleFunc("/concursos/{id:[0-9]+}", concursoHandler.View).Methods("GET")
+	view.HandleFunc("/anexos/{id:[0-9]+}", anexoHandler.Download).Methods("GET")
+	view.HandleFunc("/concursos/{id:[0-9]+}/comentarios", comentarioHandler.Create).Methods("POST")
+	view.HandleFunc("/comentarios/{id:[0-9]+}/edit", comentarioHandler.Update).Methods("POST")
+	view.HandleFunc("/comentarios/{id:[0-9]+}/delete", comentarioHandler.Delete).Methods("POST")
+
+	export := router.PathPrefix("/").Subrouter()
+	export.Use(middleware.RequirePermission(db, store, models.PermReportExport))
+
+	export.HandleFunc("/calendario.ics", calendarioHandler.ICS).Methods("GET")
+	export.HandleFunc("/download-pdf", pdfHandler.Download).Methods("GET")
+	export.HandleFunc("/relatorios", relatorioHandler.Page).Methods("GET")
+	export.HandleFunc("/relatorios/pdf", relatorioHandler.PDF).Methods("GET")
+	export.HandleFunc("/concursos/{id:[0-9]+}/pdf", pdfHandler.Dossier).Methods("GET")
+
+	tick := router.PathPrefix("/").Subrouter()
+	tick.Use(middleware.RequirePermission(db, store, models.PermChecklistTick))
+
+	tick.HandleFunc("/concursos/{id:[0-9]+}/checklist/{item:[0-9]+}", checklistHandler.Toggle).Methods("POST")
+
+	edit := router.PathPrefix("/").Subrouter()
+	edit.Use(middleware.RequirePermission(db, store, models.PermConcursoEdit))
+
+	edit.HandleFunc("/edit-concurso/{id}", concursoHandler.Edit).Methods("GET")
+	edit.HandleFunc("/update-concurso/{id}", concursoHandler.Update).Methods("POST")
+	edit.HandleFunc("/create-concurso", concursoHandler.Create).Methods("GET")
+	edit.HandleFunc("/save-concurso", concursoHandler.Save).Methods("POST")
+	edit.HandleFunc("/concursos/{id:[0-9]+}/estado", concursoHandler.MoveEstado).Methods("POST")
+	edit.HandleFunc("/concursos/{id:[0-9]+}/anexos", anexoHandler.Upload).Methods("POST")
+	edit.HandleFunc("/anexos/{id:[0-9]+}/delete", anexoHandler.Delete).Methods("POST")
+	edit.HandleFunc("/concursos/{id:[0-9]+}/partilhas", equipaHandler.AddPartilha).Methods("POST")
+	edit.HandleFunc("/concursos/{id:[0-9]+}/partilhas/{user:[0-9]+}/delete", equipaHandler.RemovePartilha).Methods("POST")
+
+	remove := router.PathPrefix("/").Subrouter()
+	remove.Use(middleware.RequirePermission(db, store, models.PermConcursoDelete))
+
+	remove.HandleFunc("/delete-concurso/{id}", concursoHandler.Delete).Methods("POST")
+
+	// Admin routes
+	users := router.PathPrefix("/admin").Subrouter()
+	users.Use(middleware.RequirePermission(db, store, models.PermUserManage))
+
+	users.HandleFunc("/users", userHandler.List).Methods("GET")
+	users.HandleFunc("/users/create", userHandler.Create).Methods("GET")
+	users.HandleFunc("/users/save", userHandler.Save).Methods("POST")
+	users.HandleFunc("/users/edit/{id}", userHandler.Edit).Methods("GET")
+	users.HandleFunc("/users/update/{id}", userHandler.Update).Methods("POST")
+	users.HandleFunc("/users/delete/{id}", userHandler.Delete).Methods("POST")
+	users.HandleFunc("/users/reset/{id}", userHandler.Reset).Methods("POST")
+	users.HandleFunc("/users/2fa/reset/{id:[0-9]+}", userHandler.Reset2FA).Methods("POST")
+	users.HandleFunc("/registos", registoHandler.List).Methods("GET")
+	users.HandleFunc("/registos/{id:[0-9]+}/aprovar", registoHandler.Approve).Methods("POST")
+	users.HandleFunc("/registos/{id:[0-9]+}/rejeitar", registoHandler.Reject).Methods("POST")
+	users.HandleFunc("/equipas", equipaHandler.List).Methods("GET")
+	users.HandleFunc("/equipas/save", equipaHandler.Save).Methods("POST")
+	users.HandleFunc("/equipas/delete/{id:[0-9]+}", equipaHandler.Delete).Methods("POST")
+	users.HandleFunc("/equipas/{id:[0-9]+}/membros", equipaHandler.AddMembro).Methods("POST")
+	users.HandleFunc("/equipas/{id:[0-9]+}/membros/{user:[0-9]+}/delete", equipaHandler.RemoveMembro).Methods("POST")
+
+	roles := router.PathPrefix("/admin").Subrouter()
+	roles.Use(middleware.RequirePermission(db, store, models.PermRoleManage))
+
+	roles.HandleFunc("/cargos", cargoHandler.List).Methods("GET")
+	roles.HandleFunc("/cargos/save", cargoHandler.Save).Methods("POST")
+	roles.HandleFunc("/cargos/{id:[0-9]+}/permissoes", cargoHandler.UpdatePermissoes).Methods("POST")
+	roles.HandleFunc("/cargos/delete/{id:[0-9]+}", cargoHandler.Delete).Methods("POST")
+
+	settings := router.PathPrefix("/admin").Subrouter()
+	settings.Use(middleware.RequirePermission(db, store, models.PermConfigManage))
+
+	settings.HandleFunc("/checklists", checklistHandler.Modelos).Methods("GET")
+	settings.HandleFunc("/checklists/save", checklistHandler.SaveModelo).Methods("POST")
+	settings.HandleFunc("/checklists/delete/{id}", checklistHandler.DeleteModelo).Methods("POST")
+	settings.HandleFunc("/feriados", feriadoHandler.List).Methods("GET")
+	settings.HandleFunc("/feriados/save", feriadoHandler.Save).Methods("POST")
+	settings.HandleFunc("/feriados/delete/{id}", feriadoHandler.Delete).Methods("POST")
+	settings.HandleFunc("/transicoes", transicaoHandler.List).Methods("GET")
+	settings.HandleFunc("/transicoes/save", transicaoHandler.Save).Methods("POST")
+	settings.HandleFunc("/transicoes/update/{id:[0-9]+}", transicaoHandler.Update).Methods("POST")
+	settings.HandleFunc("/transicoes/delete/{id:[0-9]+}", transicaoHandler.Delete).Methods("POST")
+	settings.HandleFunc("/agendamentos", agendamentoHandler.List).Methods("GET")
+	settings.HandleFunc("/agendamentos/save", agendamentoHandler.Save).Methods("POST")
+	settings.HandleFunc("/agendamentos/run/{id}", agendamentoHandler.Run).Methods("POST")
+	settings.HandleFunc("/agendamentos/toggle/{id}", agendamentoHandler.Toggle).Methods("POST")
+	settings.HandleFunc("/agendamentos/delete/{id}", agendamentoHandler.Delete).Methods("POST")
 
 	// Serve static files
 	fs := http.FileServer(http.Dir("./static"))
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+
+	"v0/config"
+	"v0/models"
+)
+
+// ErrSemAcesso is returned when a provider accepts the credentials but the account may not use the application
+var ErrSemAcesso = errors.New("conta sem acesso à aplicação")
+
+// AuthProvider checks the credentials of the login form against one source of accounts
+type AuthProvider interface {
+	// Metodo is the login method recorded for the provider
+	Metodo() string
+	// Authenticate returns the user and cargo IDs. Logins the provider does not know return a *models.LoginError
+	// without UserID, so that the next provider is tried
+	Authenticate(login, password string) (int, int, error)
+}
+
+// NewAuthProviders creates the providers listed in the configuration, in order
+func NewAuthProviders(db *sql.DB, cfg *config.Config) []AuthProvider {
+	policy := models.LockoutPolicy{
+		Limite:  cfg.Auth.LockoutThreshold,
+		Minutos: cfg.Auth.LockoutMinutes,
+	}
+
+	var providers []AuthProvider
+	for _, nome := range cfg.Auth.Providers {
+		switch nome {
+		case "db":
+			providers = append(providers, NewDBAuthProvider(db, policy))
+		case "ldap":
+			providers = append(providers, NewLDAPAuthProvider(db, cfg.LDAP, policy))
+		}
+	}
+
+	return providers
+}
+
+// Authenticate tries the providers in order until one knows the login, returning the user and cargo IDs and
+// the login method
+func Authenticate(providers []AuthProvider, login, password string) (int, int, string, error) {
+	var err error = &models.LoginError{}
+	for _, provider := range providers {
+		var userID, cargoID int
+		userID, cargoID, err = provider.Authenticate(login, password)
+
+		var loginErr *models.LoginError
+		if errors.As(err, &loginErr) && loginErr.UserID == 0 {
+			continue
+		}
+		return userID, cargoID, provider.Metodo(), err
+	}
+
+	return 0, 0, "", err
+}
+
+// DBAuthProvider checks passwords against the bcrypt hashes of local accounts
+type DBAuthProvider struct {
+	db     *sql.DB
+	policy models.LockoutPolicy
+}
+
+// NewDBAuthProvider creates a new DBAuthProvider
+func NewDBAuthProvider(db *sql.DB, policy models.LockoutPolicy) *DBAuthProvider {
+	return &DBAuthProvider{db: db, policy: policy}
+}
+
+// Metodo returns the login method of local passwords
+func (p *DBAuthProvider) Metodo() string {
+	return "password"
+}
+
+// Authenticate checks the password of a local account
+func (p *DBAuthProvider) Authenticate(login, password string) (int, int, error) {
+	return models.Authenticate(p.db, login, password, p.policy)
+}
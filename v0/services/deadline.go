@@ -0,0 +1,103 @@
+package services
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"v0/models"
+	"v0/utils"
+)
+
+// DeadlineSource provides the data the deadline list is built from
+type DeadlineSource interface {
+	// Concursos returns the concursos matching a filter
+	Concursos(filtro models.ConcursoFiltro) ([]models.Concurso, error)
+	// Calendario returns the working days calendar used for the countdowns
+	Calendario() (*utils.Calendario, error)
+}
+
+// dbDeadlineSource reads the deadline data from the database
+type dbDeadlineSource struct {
+	db *sql.DB
+}
+
+func (s dbDeadlineSource) Concursos(filtro models.ConcursoFiltro) ([]models.Concurso, error) {
+	return models.GetConcursosFiltrados(s.db, filtro)
+}
+
+func (s dbDeadlineSource) Calendario() (*utils.Calendario, error) {
+	return models.GetCalendario(s.db)
+}
+
+// DeadlineService builds the list of upcoming deadlines shared by the ordered view and the PDF
+type DeadlineService struct {
+	source DeadlineSource
+	now    func() time.Time
+}
+
+// NewDeadlineService creates a new DeadlineService reading from the database
+func NewDeadlineService(db *sql.DB) *DeadlineService {
+	return NewDeadlineServiceWithSource(dbDeadlineSource{db: db})
+}
+
+// NewDeadlineServiceWithSource creates a new DeadlineService reading from source
+func NewDeadlineServiceWithSource(source DeadlineSource) *DeadlineService {
+	return &DeadlineService{
+		source: source,
+		now:    time.Now,
+	}
+}
+
+// Upcoming returns one item per future deadline of the visible concursos in progress, earliest first
+func (s *DeadlineService) Upcoming(vis models.Visibilidade) ([]models.ConcursoItem, error) {
+	filtro := models.ConcursoFiltro{EstadoID: models.EstadoEmAndamento, Visibilidade: vis}
+	return s.Prazos(filtro, s.now(), time.Time{})
+}
+
+// Prazos returns one item per deadline of the concursos matching filtro from inicio until fim
+// (exclusive, or without limit when fim is zero), earliest first
+func (s *DeadlineService) Prazos(filtro models.ConcursoFiltro, inicio, fim time.Time) ([]models.ConcursoItem, error) {
+	concursos, err := s.source.Concursos(filtro)
+	if err != nil {
+		return nil, err
+	}
+
+	calendario, err := s.source.Calendario()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []models.ConcursoItem
+	for i := range concursos {
+		c := &concursos[i]
+		for _, p := range c.Prazos() {
+			if p.Momento.Before(inicio) || (!fim.IsZero() && !p.Momento.Before(fim)) {
+				continue
+			}
+			items = append(items, models.ConcursoItem{
+				ID:            c.ID,
+				Referencia:    c.Referencia,
+				Entidade:      c.Entidade,
+				Objeto:        c.TipoID,
+				Data:          p.Data,
+				Hora:          p.Hora,
+				Tipo:          p.Tipo,
+				EstadoDesc:    c.EstadoDesc,
+				DiasRestantes: utils.CalculateDaysRemaining(p.Data),
+				DiasUteis:     calendario.DiasUteisRestantes(p.Data),
+				Momento:       p.Momento,
+				Link:          c.Link,
+				Adjudicatario: c.Adjudicatario,
+				ResultadoID:   c.ResultadoID,
+			})
+		}
+	}
+
+	// Sort by deadline, keeping the proposta, erro, audiencia order on ties
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Momento.Before(items[j].Momento)
+	})
+
+	return items, nil
+}
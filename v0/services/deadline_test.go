@@ -0,0 +1,165 @@
+package services
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+
+	"v0/config"
+	"v0/models"
+	"v0/utils"
+)
+
+// fakeDeadlineSource serves fixed concursos instead of reading the database
+type fakeDeadlineSource struct {
+	concursos []models.Concurso
+}
+
+func (s fakeDeadlineSource) Concursos(filtro models.ConcursoFiltro) ([]models.Concurso, error) {
+	var concursos []models.Concurso
+	for _, c := range s.concursos {
+		if filtro.EstadoID == 0 || c.EstadoID == filtro.EstadoID {
+			concursos = append(concursos, c)
+		}
+	}
+	return concursos, nil
+}
+
+func (s fakeDeadlineSource) Calendario() (*utils.Calendario, error) {
+	return utils.NewCalendario(nil), nil
+}
+
+// testUser stands in for the layout user, with every permission
+type testUser struct{}
+
+func (testUser) Pode(codigo string) bool { return true }
+
+var testNow = time.Date(2030, time.March, 10, 12, 0, 0, 0, utils.Location())
+
+func newTestDeadlineService() *DeadlineService {
+	s := NewDeadlineServiceWithSource(fakeDeadlineSource{concursos: []models.Concurso{
+		{
+			ID: 1, Referencia: "CP-01", Entidade: "Camara A", TipoID: 2, EstadoID: models.EstadoEmAndamento,
+			DiaProposta: models.ParseNullString("2030-03-20"), HoraProposta: models.ParseNullString("17:00"),
+			DiaErro: models.ParseNullString("2030-03-12"), HoraErro: models.ParseNullString("12:00:00"),
+		},
+		{
+			// Every deadline already passed
+			ID: 2, Referencia: "CP-02", Entidade: "Camara B", TipoID: 3, EstadoID: models.EstadoEmAndamento,
+			DiaProposta: models.ParseNullString("2030-03-01"), HoraProposta: models.ParseNullString("10:00"),
+		},
+		{
+			// Proposta later today, audiencia without a time
+			ID: 3, Referencia: "CP-03", Entidade: "Camara C", TipoID: 6, EstadoID: models.EstadoEmAndamento,
+			DiaProposta: models.ParseNullString("2030-03-10"), HoraProposta: models.ParseNullString("18:30"),
+			DiaAudiencia: models.ParseNullString("2030-04-01"),
+		},
+		{
+			// Not in progress, so its deadlines are not tracked
+			ID: 4, Referencia: "CP-04", Entidade: "Camara D", TipoID: 2, EstadoID: 3,
+			DiaProposta: models.ParseNullString("2030-03-15"), HoraProposta: models.ParseNullString("10:00"),
+		},
+	}})
+	s.now = func() time.Time { return testNow }
+	return s
+}
+
+func TestUpcomingSkipsPastAndSortsByDeadline(t *testing.T) {
+	items, err := newTestDeadlineService().Upcoming(models.VisibilidadeTotal)
+	if err != nil {
+		t.Fatalf("Upcoming: %v", err)
+	}
+
+	want := []struct {
+		referencia, tipo, data string
+	}{
+		{"CP-03", "Proposta", "2030-03-10"},
+		{"CP-01", "Erro", "2030-03-12"},
+		{"CP-01", "Proposta", "2030-03-20"},
+	}
+
+	if len(items) != len(want) {
+		t.Fatalf("got %d deadlines, want %d: %+v", len(items), len(want), items)
+	}
+	for i, w := range want {
+		if items[i].Referencia != w.referencia || items[i].Tipo != w.tipo || items[i].Data != w.data {
+			t.Errorf("deadline %d = %s %s %s, want %s %s %s", i,
+				items[i].Referencia, items[i].Tipo, items[i].Data, w.referencia, w.tipo, w.data)
+		}
+	}
+}
+
+func TestOrderedViewAndPDFListSameDeadlines(t *testing.T) {
+	items, err := newTestDeadlineService().Upcoming(models.VisibilidadeTotal)
+	if err != nil {
+		t.Fatalf("Upcoming: %v", err)
+	}
+
+	// Render the ordered view rows the way ConcursoHandler.ListOrdered does
+	type orderedItem struct {
+		models.ConcursoItem
+		Checklist      models.ChecklistProgress
+		ChecklistAviso bool
+	}
+	var rows []orderedItem
+	for _, item := range items {
+		rows = append(rows, orderedItem{ConcursoItem: item})
+	}
+
+	tmpl := template.Must(template.ParseFiles("../templates/concursos/ordered.html"))
+	var html bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&html, "content", struct {
+		User        interface{}
+		Items       []orderedItem
+		CurrentTime string
+	}{User: testUser{}, Items: rows}); err != nil {
+		t.Fatalf("rendering ordered view: %v", err)
+	}
+
+	pdf, err := NewPDFService(config.ReportConfig{}).GenerateConcursosPDF(items)
+	if err != nil {
+		t.Fatalf("GenerateConcursosPDF: %v", err)
+	}
+	pdf.SetCompression(false)
+	var raw bytes.Buffer
+	if err := pdf.Output(&raw); err != nil {
+		t.Fatalf("writing PDF: %v", err)
+	}
+
+	view := html.String()
+	doc := raw.String()
+
+	if got := strings.Count(view, "<tr class="); got != len(items) {
+		t.Errorf("ordered view has %d rows, want %d", got, len(items))
+	}
+
+	for _, item := range items {
+		objeto := utils.GetObjetoString(item.Objeto)
+		for _, cell := range []string{item.Referencia, item.Data, item.Hora, item.Tipo, objeto} {
+			if !strings.Contains(view, cell) {
+				t.Errorf("ordered view is missing %q of %s", cell, item.Referencia)
+			}
+			if !strings.Contains(doc, "("+cell+")") {
+				t.Errorf("PDF is missing %q of %s", cell, item.Referencia)
+			}
+		}
+	}
+
+	// Each deadline type appears as often in both outputs
+	for _, tipo := range []string{"Proposta", "Erro", "Audiencia"} {
+		inView := strings.Count(view, "<td>"+tipo+"</td>")
+		inPDF := strings.Count(doc, "("+tipo+")")
+		if inView != inPDF {
+			t.Errorf("%s deadlines: %d in ordered view, %d in PDF", tipo, inView, inPDF)
+		}
+	}
+
+	// Past deadlines and concursos not in progress appear in neither
+	for _, referencia := range []string{"CP-02", "CP-04"} {
+		if strings.Contains(view, referencia) || strings.Contains(doc, referencia) {
+			t.Errorf("concurso %s should not be listed", referencia)
+		}
+	}
+}
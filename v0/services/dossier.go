@@ -0,0 +1,129 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"v0/models"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Dossier holds everything printed in the dossier of a concurso
+type Dossier struct {
+	Concurso  *models.Concurso
+	Checklist []models.ChecklistItem
+	Anexos    []models.Anexo
+	Historico []HistoricoEntry
+}
+
+const dossierLabelWidth = 55.0
+
+// GenerateDossier generates the summary PDF of a single concurso
+func (s *PDFService) GenerateDossier(d Dossier) (*gofpdf.Fpdf, error) {
+	c := d.Concurso
+	doc := s.newDocument(false, "Dossier do Concurso "+c.Referencia, c.Entidade)
+	pdf := doc.pdf
+	pdf.AddPage()
+
+	doc.section("Informações Básicas")
+	doc.field("Referência", c.Referencia)
+	doc.field("Entidade", c.Entidade)
+	doc.field("Preço Base", fmt.Sprintf("%.2f €", c.Preco))
+	doc.field("Ref. BC", c.ReferenciaBC)
+	doc.field("Tipo", c.TipoDesc)
+	doc.field("Plataforma", c.PlataformaDesc)
+	doc.field("Equipa", c.EquipaDesc)
+	doc.field("Link", c.Link)
+	doc.field("Estado", c.EstadoDesc)
+
+	doc.section("Prazos")
+	doc.field("Esclarecimentos/Erros", strings.TrimSpace(c.DiaErro.String+" "+c.HoraErro.String))
+	doc.field("Proposta", strings.TrimSpace(c.DiaProposta.String+" "+c.HoraProposta.String))
+	doc.field("Audiência Prévia", strings.TrimSpace(c.DiaAudiencia.String+" "+c.HoraAudiencia.String))
+
+	doc.section("Relatórios e Reclamações")
+	doc.field("Relatório Preliminar", simNao(c.Preliminar))
+	doc.field("Relatório Final", simNao(c.Final))
+	doc.field("Recurso", simNao(c.Recurso))
+	doc.field("Impugnação", simNao(c.Impugnacao))
+
+	doc.section("Resultado")
+	doc.field("Resultado", c.ResultadoDesc)
+	doc.field("Adjudicatário", c.Adjudicatario)
+
+	if len(d.Checklist) > 0 {
+		progress := models.ChecklistProgress{Total: len(d.Checklist)}
+		for _, item := range d.Checklist {
+			if item.Concluido {
+				progress.Concluidos++
+			}
+		}
+
+		doc.section(fmt.Sprintf("Checklist de Submissão (%d/%d)", progress.Concluidos, progress.Total))
+		for _, item := range d.Checklist {
+			estado := "Pendente"
+			if item.Concluido {
+				estado = strings.TrimSpace("Concluído " + item.ConcluidoPorNome.String + " " + item.ConcluidoEm.String)
+			}
+			doc.field(estado, item.Descricao)
+		}
+	}
+
+	if len(d.Anexos) > 0 {
+		doc.section("Anexos")
+		for _, a := range d.Anexos {
+			doc.field(a.Categoria, fmt.Sprintf("%s (v%d, %s, %s %s)",
+				a.NomeFicheiro, a.Versao, a.TamanhoFormatado(), a.UserNome.String, a.CriadoEm))
+		}
+	}
+
+	doc.section("Histórico")
+	if len(d.Historico) == 0 {
+		doc.field("", "Sem histórico")
+	}
+	for _, h := range d.Historico {
+		doc.field(h.Timestamp+"\n"+h.UserNome, h.Descricao)
+	}
+
+	return pdf, pdf.Error()
+}
+
+// section writes a section title, keeping it on the same page as its first line
+func (d *pdfDocument) section(titulo string) {
+	d.ensureSpace(20)
+	d.pdf.Ln(4)
+	d.pdf.SetFont(d.family, "B", 12)
+	d.pdf.SetFillColor(226, 232, 240)
+	d.pdf.CellFormat(d.width, 8, d.tr(titulo), "", 1, "", true, 0, "")
+	d.pdf.Ln(1)
+}
+
+// field writes a label and a value that wraps over as many lines as needed
+func (d *pdfDocument) field(label, value string) {
+	pdf := d.pdf
+	lineHeight := 5.0
+
+	// Long values continue on the next page through MultiCell's page break
+	d.ensureSpace(2 * lineHeight)
+
+	x, y := pdf.GetX(), pdf.GetY()
+	pdf.SetFont(d.family, "B", 10)
+	pdf.MultiCell(dossierLabelWidth, lineHeight, d.tr(label), "", "", false)
+	bottom := pdf.GetY()
+
+	pdf.SetXY(x+dossierLabelWidth, y)
+	pdf.SetFont(d.family, "", 10)
+	pdf.MultiCell(d.width-dossierLabelWidth, lineHeight, d.tr(value), "", "", false)
+	if pdf.GetY() < bottom {
+		pdf.SetY(bottom)
+	}
+}
+
+// simNao formats a flag as Sim or Não
+func simNao(b bool) string {
+	if b {
+		return "Sim"
+	}
+	return "Não"
+}
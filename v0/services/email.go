@@ -1,14 +1,18 @@
 package services
 
 import (
-	"database/sql"
+	"bytes"
+	"encoding/base64"
 	"fmt"
 	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net/smtp"
+	"net/textproto"
 	"strings"
 
 	"v0/config"
-	"v0/database"
 )
 
 // EmailService handles email operations
@@ -23,25 +27,17 @@ func NewEmailService(cfg config.EmailConfig) *EmailService {
 	}
 }
 
-// SendMail sends an email to all recipients
-func (s *EmailService) SendMail(messageBody string, db *sql.DB) error {
-	// Get receivers from DB
-	to, err := database.GetEmailsFromDB(db)
-	if err != nil {
-		log.Printf("Error getting emails from database: %v", err)
-		return err
-	}
-
+// SendMail sends an email to the given recipients; there is nothing to send without recipients
+func (s *EmailService) SendMail(messageBody string, to []string) error {
 	if len(to) == 0 {
-		log.Println("No recipients found in database")
-		return fmt.Errorf("no recipients found")
+		return nil
 	}
 
 	// Authentication
 	auth := smtp.PlainAuth("", s.config.From, s.config.Password, s.config.SMTPHost)
 
 	// Send email
-	err = smtp.SendMail(
+	err := smtp.SendMail(
 		s.config.SMTPHost+":"+s.config.SMTPPort,
 		auth,
 		s.config.From,
@@ -53,7 +49,7 @@ func (s *EmailService) SendMail(messageBody string, db *sql.DB) error {
 		return err
 	}
 
-	log.Println("Email sent successfully to all recipients!")
+	log.Printf("Email sent successfully to %d recipient(s)!", len(to))
 	return nil
 }
 
@@ -79,12 +75,12 @@ func (s *EmailService) SendMailToSpecificRecipient(messageBody string, recipient
 	return nil
 }
 
-// SendUpdateEmail sends an email with concurso update information
+// SendUpdateEmail sends an email with concurso update information to the given recipients
 func (s *EmailService) SendUpdateEmail(
 	referencia, entidade, tipo, estado, diaErro, horaErro, diaProposta, horaProposta, diaAudiencia, horaAudiencia string,
 	preliminar, final, recurso, impugnacao bool,
 	resultado, link string,
-	db *sql.DB,
+	to []string,
 ) error {
 	// Format email subject
 	subject := fmt.Sprintf("%s - %s - %s", referencia, entidade, tipo)
@@ -131,7 +127,7 @@ func (s *EmailService) SendUpdateEmail(
 	fullMessage := fmt.Sprintf("Subject: %s\n\n%s", subject, body.String())
 
 	// Use the existing SendMail function
-	return s.SendMail(fullMessage, db)
+	return s.SendMail(fullMessage, to)
 }
 
 // SendAdjudicatarioEmail sends an email to the adjudicatario
@@ -196,3 +192,237 @@ func (s *EmailService) SendAdjudicatarioEmail(
 	// Send email to adjudicatario
 	return s.SendMailToSpecificRecipient(fullMessage, adjudicatario)
 }
+
+// SendTransitionEmail sends an email announcing an estado transition of a concurso to the given recipients
+func (s *EmailService) SendTransitionEmail(referencia, entidade, estadoOrigem, estadoDestino, link string, to []string) error {
+	// Format email subject
+	subject := fmt.Sprintf("%s - %s - Estado: %s", referencia, entidade, estadoDestino)
+
+	// Build email body
+	var body strings.Builder
+
+	body.WriteString(fmt.Sprintf("O concurso %s (%s) mudou de estado.\n\n", referencia, entidade))
+	body.WriteString(fmt.Sprintf("Estado anterior: %s\n", estadoOrigem))
+	body.WriteString(fmt.Sprintf("Novo estado: %s\n", estadoDestino))
+
+	if link != "" {
+		body.WriteString(fmt.Sprintf("\nLink: %s\n", link))
+	}
+
+	// Format complete message with subject and body
+	fullMessage := fmt.Sprintf("Subject: %s\n\n%s", subject, body.String())
+
+	return s.SendMail(fullMessage, to)
+}
+
+// SendMentionEmail sends an email to a user mentioned in a comment
+func (s *EmailService) SendMentionEmail(recipient, autor, referencia, entidade, texto, link string) error {
+	// Format email subject
+	subject := fmt.Sprintf("%s mencionou-o num comentário: %s - %s", autor, referencia, entidade)
+
+	// Build email body
+	var body strings.Builder
+
+	body.WriteString(fmt.Sprintf("Olá,\n\n%s mencionou-o num comentário do concurso %s (%s):\n\n", autor, referencia, entidade))
+	body.WriteString(texto)
+	body.WriteString("\n")
+
+	if link != "" {
+		body.WriteString(fmt.Sprintf("\nLink para o concurso: %s\n", link))
+	}
+
+	body.WriteString("\n\nEste é um email automático. Por favor, não responda a este email.\n")
+
+	// Format complete message with subject and body
+	fullMessage := fmt.Sprintf("Subject: %s\n\n%s", subject, body.String())
+
+	return s.SendMailToSpecificRecipient(fullMessage, recipient)
+}
+
+// SendPasswordResetEmail sends the password reset link to a user
+func (s *EmailService) SendPasswordResetEmail(recipient, nome, link string, minutos int) error {
+	subject := "Recuperação de password - Controlo de Concursos"
+
+	var body strings.Builder
+
+	body.WriteString(fmt.Sprintf("Olá %s,\n\nFoi pedida a recuperação da password da sua conta.\n", nome))
+	body.WriteString(fmt.Sprintf("Para definir uma nova password abra o link abaixo, válido durante %d minutos:\n\n%s\n", minutos, link))
+	body.WriteString("\nSe não fez este pedido, ignore este email. A sua password não foi alterada.\n")
+	body.WriteString("\n\nEste é um email automático. Por favor, não responda a este email.\n")
+
+	// Format complete message with subject and body
+	fullMessage := fmt.Sprintf("Subject: %s\n\n%s", subject, body.String())
+
+	return s.SendMailToSpecificRecipient(fullMessage, recipient)
+}
+
+// SendAccountLockedEmail tells a user that their account was locked after too many failed logins
+func (s *EmailService) SendAccountLockedEmail(recipient, nome, ip string, minutos int) error {
+	subject := "Conta bloqueada temporariamente - Controlo de Concursos"
+
+	var body strings.Builder
+
+	body.WriteString(fmt.Sprintf("Olá %s,\n\nA sua conta foi bloqueada durante %d minutos após várias tentativas de login falhadas (último IP: %s).\n", nome, minutos, ip))
+	body.WriteString("\nSe não foi você, recomendamos que altere a sua password e avise um administrador.\n")
+	body.WriteString("\n\nEste é um email automático. Por favor, não responda a este email.\n")
+
+	// Format complete message with subject and body
+	fullMessage := fmt.Sprintf("Subject: %s\n\n%s", subject, body.String())
+
+	return s.SendMailToSpecificRecipient(fullMessage, recipient)
+}
+
+// SendAccountLockedAdminEmail tells an admin that a user's account was locked
+func (s *EmailService) SendAccountLockedAdminEmail(recipient, conta, email, ip, link string, minutos int) error {
+	subject := fmt.Sprintf("Conta bloqueada: %s - Controlo de Concursos", conta)
+
+	var body strings.Builder
+
+	body.WriteString(fmt.Sprintf("Olá,\n\nA conta %s (%s) foi bloqueada durante %d minutos após várias tentativas de login falhadas (último IP: %s).\n", conta, email, minutos, ip))
+	body.WriteString(fmt.Sprintf("\nPode consultar o histórico de tentativas e desbloquear a conta em: %s\n", link))
+	body.WriteString("\n\nEste é um email automático. Por favor, não responda a este email.\n")
+
+	// Format complete message with subject and body
+	fullMessage := fmt.Sprintf("Subject: %s\n\n%s", subject, body.String())
+
+	return s.SendMailToSpecificRecipient(fullMessage, recipient)
+}
+
+// SendVerificacaoEmail sends the email verification link of a new registration
+func (s *EmailService) SendVerificacaoEmail(recipient, nome, link string, horas int) error {
+	subject := "Confirmação de email - Controlo de Concursos"
+
+	var body strings.Builder
+
+	body.WriteString(fmt.Sprintf("Olá %s,\n\nFoi criado um pedido de conta com este email.\n", nome))
+	body.WriteString(fmt.Sprintf("Para confirmar o email abra o link abaixo, válido durante %d horas:\n\n%s\n", horas, link))
+	body.WriteString("\nSe não fez este pedido, ignore este email.\n")
+	body.WriteString("\n\nEste é um email automático. Por favor, não responda a este email.\n")
+
+	// Format complete message with subject and body
+	fullMessage := fmt.Sprintf("Subject: %s\n\n%s", subject, body.String())
+
+	return s.SendMailToSpecificRecipient(fullMessage, recipient)
+}
+
+// SendRegistoPendenteEmail tells an admin that a registration is waiting for approval
+func (s *EmailService) SendRegistoPendenteEmail(recipient, conta, email, link string) error {
+	subject := fmt.Sprintf("Pedido de conta: %s - Controlo de Concursos", conta)
+
+	var body strings.Builder
+
+	body.WriteString(fmt.Sprintf("Olá,\n\nO pedido de conta de %s (%s) confirmou o email e aguarda aprovação.\n", conta, email))
+	body.WriteString(fmt.Sprintf("\nPode aprovar ou rejeitar o pedido em: %s\n", link))
+	body.WriteString("\n\nEste é um email automático. Por favor, não responda a este email.\n")
+
+	// Format complete message with subject and body
+	fullMessage := fmt.Sprintf("Subject: %s\n\n%s", subject, body.String())
+
+	return s.SendMailToSpecificRecipient(fullMessage, recipient)
+}
+
+// SendContaAprovadaEmail tells a user that their registration was approved
+func (s *EmailService) SendContaAprovadaEmail(recipient, nome, cargo, link string) error {
+	subject := "Conta aprovada - Controlo de Concursos"
+
+	var body strings.Builder
+
+	body.WriteString(fmt.Sprintf("Olá %s,\n\nO seu pedido de conta foi aprovado com o cargo %s.\n", nome, cargo))
+	body.WriteString(fmt.Sprintf("\nJá pode entrar em: %s\n", link))
+	body.WriteString("\n\nEste é um email automático. Por favor, não responda a este email.\n")
+
+	// Format complete message with subject and body
+	fullMessage := fmt.Sprintf("Subject: %s\n\n%s", subject, body.String())
+
+	return s.SendMailToSpecificRecipient(fullMessage, recipient)
+}
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Nome        string
+	ContentType string
+	Dados       []byte
+}
+
+// SendMailWithAttachments sends a MIME email with attachments to the given recipients
+func (s *EmailService) SendMailWithAttachments(recipients []string, subject, body string, anexos []Attachment) error {
+	if len(recipients) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+
+	message, err := buildMIMEMessage(s.config.From, recipients, subject, body, anexos)
+	if err != nil {
+		return err
+	}
+
+	// Authentication
+	auth := smtp.PlainAuth("", s.config.From, s.config.Password, s.config.SMTPHost)
+
+	// Send email
+	err = smtp.SendMail(
+		s.config.SMTPHost+":"+s.config.SMTPPort,
+		auth,
+		s.config.From,
+		recipients,
+		message,
+	)
+	if err != nil {
+		log.Printf("Error sending email with attachments: %v", err)
+		return err
+	}
+
+	log.Printf("Email with %d attachment(s) sent to %d recipient(s)", len(anexos), len(recipients))
+	return nil
+}
+
+// buildMIMEMessage builds a multipart/mixed message with a UTF-8 text body and base64 encoded attachments
+func buildMIMEMessage(from string, to []string, subject, body string, anexos []Attachment) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", writer.Boundary())
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	for _, a := range anexos {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.ContentType, map[string]string{"name": a.Nome})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Nome})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Base64 lines are limited to 76 characters
+		encoded := base64.StdEncoding.EncodeToString(a.Dados)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
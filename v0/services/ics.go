@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"v0/models"
+	"v0/utils"
+)
+
+// icsEscaper escapes text values as required by RFC 5545
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// BuildICS renders deadlines as an iCalendar feed. Times are written in UTC so any client
+// places them correctly regardless of its own timezone; deadlines without a time are all-day events.
+func BuildICS(eventos []models.Evento, baseURL string) string {
+	var b strings.Builder
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//CDC//Concursos//PT")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "X-WR-CALNAME:Prazos de Concursos")
+	writeICSLine(&b, "X-WR-TIMEZONE:"+utils.Location().String())
+
+	for _, e := range eventos {
+		datas, ok := icsDatas(e)
+		if !ok {
+			continue
+		}
+		link := fmt.Sprintf("%s/concursos/%d", baseURL, e.ConcursoID)
+
+		writeICSLine(&b, "BEGIN:VEVENT")
+		writeICSLine(&b, fmt.Sprintf("UID:concurso-%d-%s@concursos", e.ConcursoID, strings.ToLower(e.Tipo)))
+		writeICSLine(&b, "DTSTAMP:"+stamp)
+		for _, data := range datas {
+			writeICSLine(&b, data)
+		}
+		writeICSLine(&b, "SUMMARY:"+icsEscaper.Replace(e.Tipo+": "+e.Referencia+" - "+e.Entidade))
+		writeICSLine(&b, "DESCRIPTION:"+icsEscaper.Replace(link))
+		writeICSLine(&b, "URL:"+link)
+		writeICSLine(&b, "END:VEVENT")
+	}
+
+	writeICSLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+// icsDatas returns the DTSTART, and DTEND for all-day events, of a deadline, or false when its date or time
+// cannot be read. A deadline without a time takes its whole day, which ends at the start of the next one
+func icsDatas(e models.Evento) ([]string, bool) {
+	if strings.TrimSpace(e.Hora) == "" {
+		dia, err := time.ParseInLocation("2006-01-02", e.Data, utils.Location())
+		if err != nil {
+			return nil, false
+		}
+		return []string{
+			"DTSTART;VALUE=DATE:" + dia.Format("20060102"),
+			"DTEND;VALUE=DATE:" + dia.AddDate(0, 0, 1).Format("20060102"),
+		}, true
+	}
+
+	inicio, err := utils.ParseDataHora(e.Data, e.Hora)
+	if err != nil {
+		return nil, false
+	}
+	return []string{"DTSTART:" + inicio.UTC().Format("20060102T150405Z")}, true
+}
+
+// writeICSLine writes a content line folded at 75 octets, without splitting UTF-8 characters
+func writeICSLine(b *strings.Builder, line string) {
+	for len(line) > 75 {
+		cut := 75
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// isRuneStart checks if a byte begins a UTF-8 character
+func isRuneStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
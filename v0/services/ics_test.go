@@ -0,0 +1,103 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"v0/models"
+	"v0/utils"
+)
+
+// icsEventos returns the unfolded lines of each event of a feed
+func icsEventos(t *testing.T, feed string) [][]string {
+	t.Helper()
+	if !strings.HasPrefix(feed, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(feed, "END:VCALENDAR\r\n") {
+		t.Fatalf("feed is not a calendar:\n%s", feed)
+	}
+
+	var eventos [][]string
+	var atual []string
+	for _, line := range strings.Split(strings.ReplaceAll(feed, "\r\n ", ""), "\r\n") {
+		switch line {
+		case "BEGIN:VEVENT":
+			atual = []string{}
+		case "END:VEVENT":
+			eventos = append(eventos, atual)
+			atual = nil
+		default:
+			if atual != nil {
+				atual = append(atual, line)
+			}
+		}
+	}
+	return eventos
+}
+
+// icsValor returns the value of a property of an event, with its parameters
+func icsValor(evento []string, nome string) string {
+	for _, line := range evento {
+		if strings.HasPrefix(line, nome+":") || strings.HasPrefix(line, nome+";") {
+			return line[len(nome):]
+		}
+	}
+	return ""
+}
+
+func TestBuildICS(t *testing.T) {
+	lisboa, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anterior := utils.Location()
+	utils.SetLocation(lisboa)
+	t.Cleanup(func() { utils.SetLocation(anterior) })
+
+	feed := BuildICS([]models.Evento{
+		// Summer time in Lisbon is UTC+1
+		{ConcursoID: 1, Referencia: "CP-01", Entidade: "Câmara, Lda; Norte", Tipo: "Proposta", Data: "2030-07-10", Hora: "17:00:00"},
+		{ConcursoID: 1, Referencia: "CP-01", Entidade: "Câmara, Lda; Norte", Tipo: "Erro", Data: "2030-01-10", Hora: "09:30"},
+		// Without a time the deadline takes the whole day
+		{ConcursoID: 2, Referencia: "CP-02", Entidade: "Junta", Tipo: "Audiencia", Data: "2030-12-31", Hora: ""},
+		{ConcursoID: 3, Referencia: "CP-03", Entidade: "Junta", Tipo: "Proposta", Data: "31/12/2030", Hora: ""},
+		{ConcursoID: 4, Referencia: "CP-04", Entidade: "Junta", Tipo: "Proposta", Data: "2030-12-31", Hora: "25:00"},
+	}, "https://concursos.empresa.pt")
+
+	for _, line := range strings.Split(feed, "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line longer than 75 octets: %q", line)
+		}
+	}
+
+	eventos := icsEventos(t, feed)
+	if len(eventos) != 3 {
+		t.Fatalf("%d events, want 3 without the unreadable dates:\n%s", len(eventos), feed)
+	}
+
+	tests := []struct {
+		uid, inicio, fim string
+	}{
+		{"concurso-1-proposta@concursos", ":20300710T160000Z", ""},
+		{"concurso-1-erro@concursos", ":20300110T093000Z", ""},
+		{"concurso-2-audiencia@concursos", ";VALUE=DATE:20301231", ";VALUE=DATE:20310101"},
+	}
+	for i, tt := range tests {
+		evento := eventos[i]
+		if uid := icsValor(evento, "UID"); uid != ":"+tt.uid {
+			t.Errorf("event %d UID = %q, want %q", i, uid, tt.uid)
+		}
+		if inicio := icsValor(evento, "DTSTART"); inicio != tt.inicio {
+			t.Errorf("%s DTSTART = %q, want %q", tt.uid, inicio, tt.inicio)
+		}
+		if fim := icsValor(evento, "DTEND"); fim != tt.fim {
+			t.Errorf("%s DTEND = %q, want %q", tt.uid, fim, tt.fim)
+		}
+	}
+
+	if summary := icsValor(eventos[0], "SUMMARY"); summary != `:Proposta: CP-01 - Câmara\, Lda\; Norte` {
+		t.Errorf("SUMMARY = %q, want the commas and semicolons escaped", summary)
+	}
+	if url := icsValor(eventos[2], "URL"); url != ":https://concursos.empresa.pt/concursos/2" {
+		t.Errorf("URL = %q", url)
+	}
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"v0/config"
+	"v0/models"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPConn is the part of a directory connection the provider uses
+type LDAPConn interface {
+	Bind(username, password string) error
+	UnauthenticatedBind(username string) error
+	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// LDAPAccounts keeps the local accounts of directory users
+type LDAPAccounts interface {
+	// LoginEstado returns the local account of a login, or sql.ErrNoRows when there is none
+	LoginEstado(login string) (*models.LoginEstado, error)
+	// LoginFailure counts a failed login of a user, locking the account past the policy limit
+	LoginFailure(userID int) (*models.LoginError, error)
+	// Provision creates or updates the account of a directory user, returning its ID
+	Provision(nome, email string, cargoID int) (int, error)
+	// ResetFailures clears the failed logins of a user
+	ResetFailures(userID int) error
+}
+
+// dbLDAPAccounts keeps the accounts of directory users in the database
+type dbLDAPAccounts struct {
+	db     *sql.DB
+	policy models.LockoutPolicy
+}
+
+func (a dbLDAPAccounts) LoginEstado(login string) (*models.LoginEstado, error) {
+	return models.GetLoginEstado(a.db, login)
+}
+
+func (a dbLDAPAccounts) LoginFailure(userID int) (*models.LoginError, error) {
+	return models.RegisterLoginFailure(a.db, userID, a.policy)
+}
+
+func (a dbLDAPAccounts) Provision(nome, email string, cargoID int) (int, error) {
+	return models.ProvisionUser(a.db, models.OrigemLDAP, nome, email, cargoID)
+}
+
+func (a dbLDAPAccounts) ResetFailures(userID int) error {
+	return models.ResetFailedAttempts(a.db, userID)
+}
+
+// LDAPAuthProvider checks passwords by binding to an LDAP or Active Directory server as the user. Users are
+// provisioned on their first login and their cargo follows their directory groups
+type LDAPAuthProvider struct {
+	accounts LDAPAccounts
+	cfg      config.LDAPConfig
+	dial     func() (LDAPConn, error)
+}
+
+// NewLDAPAuthProvider creates a new LDAPAuthProvider connecting to the configured server
+func NewLDAPAuthProvider(db *sql.DB, cfg config.LDAPConfig, policy models.LockoutPolicy) *LDAPAuthProvider {
+	p := NewLDAPAuthProviderWith(dbLDAPAccounts{db: db, policy: policy}, cfg, nil)
+	p.dial = p.dialServer
+	return p
+}
+
+// NewLDAPAuthProviderWith creates a new LDAPAuthProvider keeping accounts in accounts and connecting with dial
+func NewLDAPAuthProviderWith(accounts LDAPAccounts, cfg config.LDAPConfig, dial func() (LDAPConn, error)) *LDAPAuthProvider {
+	return &LDAPAuthProvider{accounts: accounts, cfg: cfg, dial: dial}
+}
+
+// Metodo returns the login method of directory passwords
+func (p *LDAPAuthProvider) Metodo() string {
+	return "ldap"
+}
+
+// Authenticate binds as the directory user of a login and provisions its local account
+func (p *LDAPAuthProvider) Authenticate(login, password string) (int, int, error) {
+	// An empty password would be an unauthenticated bind, which servers accept without checking anything
+	if login == "" || password == "" {
+		return 0, 0, &models.LoginError{}
+	}
+
+	// Local accounts are left to the database provider, and locked accounts are refused before the bind
+	estado, err := p.accounts.LoginEstado(login)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, 0, err
+	}
+	if estado != nil {
+		if estado.Origem != models.OrigemLDAP {
+			return 0, 0, &models.LoginError{}
+		}
+		if estado.Bloqueado {
+			return 0, 0, &models.LoginError{UserID: estado.UserID, Falhas: estado.Falhas, Bloqueado: true}
+		}
+	}
+
+	conn, err := p.dial()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	if err := p.bindSearch(conn); err != nil {
+		return 0, 0, err
+	}
+
+	entry, err := p.findUser(conn, login)
+	if err != nil {
+		return 0, 0, err
+	}
+	if entry == nil {
+		return 0, 0, &models.LoginError{}
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return 0, 0, err
+		}
+		if estado == nil {
+			return 0, 0, &models.LoginError{}
+		}
+		loginErr, err := p.accounts.LoginFailure(estado.UserID)
+		if err != nil {
+			return 0, 0, err
+		}
+		return 0, 0, loginErr
+	}
+
+	groups, err := p.userGroups(conn, entry)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cargoID := p.cargo(groups)
+	if cargoID == 0 {
+		return 0, 0, fmt.Errorf("%w: %s is in no mapped group", ErrSemAcesso, entry.DN)
+	}
+
+	email := entry.GetAttributeValue(p.cfg.EmailAttr)
+	if email == "" {
+		return 0, 0, fmt.Errorf("%w: %s has no %s", ErrSemAcesso, entry.DN, p.cfg.EmailAttr)
+	}
+
+	// The directory spelling of the login is kept, so that the account is found whatever case is typed
+	nome := entry.GetAttributeValue(p.cfg.LoginAttr)
+	if nome == "" {
+		nome = login
+	}
+
+	userID, err := p.accounts.Provision(nome, email, cargoID)
+	if err == models.ErrEmailEmUso || err == models.ErrNomeEmUso {
+		return 0, 0, fmt.Errorf("%w: %s", ErrSemAcesso, err)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if estado != nil && estado.Falhas > 0 {
+		if err := p.accounts.ResetFailures(userID); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return userID, cargoID, nil
+}
+
+// dialServer connects to the configured directory server
+func (p *LDAPAuthProvider) dialServer() (LDAPConn, error) {
+	u, err := url.Parse(p.cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP_URL: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: p.cfg.SkipTLSVerify,
+	}
+
+	conn, err := ldap.DialURL(p.cfg.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(p.cfg.Timeout)
+
+	if p.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// bindSearch binds with the search account, or anonymously without one
+func (p *LDAPAuthProvider) bindSearch(conn LDAPConn) error {
+	var err error
+	if p.cfg.BindDN != "" {
+		err = conn.Bind(p.cfg.BindDN, p.cfg.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		return fmt.Errorf("LDAP search bind: %w", err)
+	}
+
+	return nil
+}
+
+// findUser searches the directory entry of a login, returning nil when there is not exactly one
+func (p *LDAPAuthProvider) findUser(conn LDAPConn, login string) (*ldap.Entry, error) {
+	filter := fmt.Sprintf("(&%s(%s=%s))", p.cfg.UserFilter, p.cfg.LoginAttr, ldap.EscapeFilter(login))
+	request := ldap.NewSearchRequest(
+		p.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(p.cfg.Timeout.Seconds()), false,
+		filter, []string{p.cfg.LoginAttr, p.cfg.EmailAttr, p.cfg.GroupAttr}, nil,
+	)
+
+	result, err := conn.Search(request)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, err
+	}
+	if result == nil || len(result.Entries) != 1 {
+		if result != nil && len(result.Entries) > 1 {
+			log.Printf("LDAP login %q matches more than one entry", login)
+		}
+		return nil, nil
+	}
+
+	return result.Entries[0], nil
+}
+
+// userGroups returns the DNs of the groups of a user, from its group attribute or a group search
+func (p *LDAPAuthProvider) userGroups(conn LDAPConn, entry *ldap.Entry) ([]string, error) {
+	if p.cfg.GroupBaseDN == "" {
+		return entry.GetAttributeValues(p.cfg.GroupAttr), nil
+	}
+
+	// The connection is bound as the user after the password check, who may not be allowed to search groups
+	if err := p.bindSearch(conn); err != nil {
+		return nil, err
+	}
+
+	request := ldap.NewSearchRequest(
+		p.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(p.cfg.Timeout.Seconds()), false,
+		strings.ReplaceAll(p.cfg.GroupFilter, "%s", ldap.EscapeFilter(entry.DN)), []string{"dn"}, nil,
+	)
+
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]string, 0, len(result.Entries))
+	for _, group := range result.Entries {
+		groups = append(groups, group.DN)
+	}
+
+	return groups, nil
+}
+
+// cargo maps the groups of a user to a cargo, returning 0 when the user has no access
+func (p *LDAPAuthProvider) cargo(groups []string) int {
+	for _, mapping := range p.cfg.GroupCargos {
+		for _, group := range groups {
+			if equalDN(group, mapping.Valor) {
+				return mapping.CargoID
+			}
+		}
+	}
+
+	return p.cfg.DefaultCargo
+}
+
+// equalDN compares two DNs ignoring case and spacing differences
+func equalDN(a, b string) bool {
+	dnA, errA := ldap.ParseDN(a)
+	dnB, errB := ldap.ParseDN(b)
+	if errA != nil || errB != nil {
+		return strings.EqualFold(a, b)
+	}
+
+	return dnA.EqualFold(dnB)
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"v0/config"
+	"v0/models"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	testBindDN       = "cn=pesquisa,dc=empresa,dc=pt"
+	testBindPassword = "pesquisa"
+	testGroupBaseDN  = "ou=grupos,dc=empresa,dc=pt"
+)
+
+// fakeDirectory is an LDAP server holding a few users, their passwords and their groups
+type fakeDirectory struct {
+	users     []*ldap.Entry
+	passwords map[string]string
+	// groups are the group DNs of each user DN, for group searches
+	groups map[string][]string
+	bound  string
+}
+
+func (d *fakeDirectory) Bind(username, password string) error {
+	if (username == testBindDN && password == testBindPassword) || (password != "" && d.passwords[username] == password) {
+		d.bound = username
+		return nil
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (d *fakeDirectory) UnauthenticatedBind(username string) error {
+	d.bound = ""
+	return nil
+}
+
+func (d *fakeDirectory) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	result := &ldap.SearchResult{}
+
+	if request.BaseDN == testGroupBaseDN {
+		// Users may not search groups, only the search account
+		if d.bound != testBindDN {
+			return nil, ldap.NewError(ldap.LDAPResultInsufficientAccessRights, errors.New("insufficient access"))
+		}
+		for dn, groups := range d.groups {
+			if strings.Contains(request.Filter, ldap.EscapeFilter(dn)) {
+				for _, group := range groups {
+					result.Entries = append(result.Entries, ldap.NewEntry(group, nil))
+				}
+			}
+		}
+		return result, nil
+	}
+
+	for _, entry := range d.users {
+		uid := entry.GetAttributeValue("uid")
+		if strings.Contains(strings.ToLower(request.Filter), "(uid="+strings.ToLower(ldap.EscapeFilter(uid))+")") {
+			result.Entries = append(result.Entries, entry)
+		}
+	}
+	return result, nil
+}
+
+func (d *fakeDirectory) Close() error {
+	return nil
+}
+
+// fakeLDAPAccounts keeps local accounts in memory
+type fakeLDAPAccounts struct {
+	estados     map[string]*models.LoginEstado
+	provisioned []models.User
+	falhas      map[int]int
+	resets      []int
+}
+
+func (a *fakeLDAPAccounts) LoginEstado(login string) (*models.LoginEstado, error) {
+	if e, ok := a.estados[strings.ToLower(login)]; ok {
+		return e, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (a *fakeLDAPAccounts) LoginFailure(userID int) (*models.LoginError, error) {
+	a.falhas[userID]++
+	return &models.LoginError{UserID: userID, Falhas: a.falhas[userID]}, nil
+}
+
+func (a *fakeLDAPAccounts) Provision(nome, email string, cargoID int) (int, error) {
+	a.provisioned = append(a.provisioned, models.User{Nome: nome, Email: email, CargoID: cargoID})
+	if e, ok := a.estados[strings.ToLower(nome)]; ok {
+		return e.UserID, nil
+	}
+	return 100 + len(a.provisioned), nil
+}
+
+func (a *fakeLDAPAccounts) ResetFailures(userID int) error {
+	a.resets = append(a.resets, userID)
+	return nil
+}
+
+func newTestDirectory() *fakeDirectory {
+	ana := ldap.NewEntry("uid=ASilva,ou=pessoas,dc=empresa,dc=pt", map[string][]string{
+		"uid":      {"ASilva"},
+		"mail":     {"ana@empresa.pt"},
+		"memberOf": {"cn=Todos,ou=grupos,dc=empresa,dc=pt", "CN=Gestores, OU=Grupos, DC=Empresa, DC=pt"},
+	})
+	rui := ldap.NewEntry("uid=rui,ou=pessoas,dc=empresa,dc=pt", map[string][]string{
+		"uid":      {"rui"},
+		"mail":     {"rui@empresa.pt"},
+		"memberOf": {"cn=todos,ou=grupos,dc=empresa,dc=pt"},
+	})
+	semEmail := ldap.NewEntry("uid=tmp,ou=pessoas,dc=empresa,dc=pt", map[string][]string{
+		"uid":      {"tmp"},
+		"memberOf": {"cn=gestores,ou=grupos,dc=empresa,dc=pt"},
+	})
+
+	return &fakeDirectory{
+		users: []*ldap.Entry{ana, rui, semEmail},
+		passwords: map[string]string{
+			ana.DN:      "ana-pw",
+			rui.DN:      "rui-pw",
+			semEmail.DN: "tmp-pw",
+		},
+		groups: map[string][]string{
+			ana.DN: {"cn=tecnicos,ou=grupos,dc=empresa,dc=pt"},
+			rui.DN: {"cn=gestores,ou=grupos,dc=empresa,dc=pt"},
+		},
+	}
+}
+
+func newTestLDAPProvider(dir *fakeDirectory, accounts *fakeLDAPAccounts, configure func(*config.LDAPConfig)) *LDAPAuthProvider {
+	cfg := config.LDAPConfig{
+		BindDN:       testBindDN,
+		BindPassword: testBindPassword,
+		Timeout:      time.Second,
+		BaseDN:       "ou=pessoas,dc=empresa,dc=pt",
+		UserFilter:   "(objectClass=person)",
+		LoginAttr:    "uid",
+		EmailAttr:    "mail",
+		GroupAttr:    "memberOf",
+		GroupFilter:  "(member=%s)",
+		GroupCargos: []config.CargoMap{
+			{Valor: "cn=gestores,ou=grupos,dc=empresa,dc=pt", CargoID: 2},
+			{Valor: "cn=tecnicos,ou=grupos,dc=empresa,dc=pt", CargoID: 3},
+		},
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	return NewLDAPAuthProviderWith(accounts, cfg, func() (LDAPConn, error) {
+		if dir == nil {
+			return nil, errors.New("the directory should not be contacted")
+		}
+		return dir, nil
+	})
+}
+
+func newTestLDAPAccounts() *fakeLDAPAccounts {
+	return &fakeLDAPAccounts{estados: map[string]*models.LoginEstado{}, falhas: map[int]int{}}
+}
+
+func TestLDAPBindProvisionsUserWithGroupCargo(t *testing.T) {
+	accounts := newTestLDAPAccounts()
+	p := newTestLDAPProvider(newTestDirectory(), accounts, nil)
+
+	userID, cargoID, err := p.Authenticate("asilva", "ana-pw")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	// The group DN matches its mapping despite the different case and spacing
+	if cargoID != 2 {
+		t.Errorf("cargo = %d, want 2", cargoID)
+	}
+	if len(accounts.provisioned) != 1 {
+		t.Fatalf("provisioned %d accounts, want 1", len(accounts.provisioned))
+	}
+	got := accounts.provisioned[0]
+	if got.Nome != "ASilva" || got.Email != "ana@empresa.pt" || got.CargoID != 2 {
+		t.Errorf("provisioned %+v, want the directory name, email and cargo", got)
+	}
+	if userID != 101 {
+		t.Errorf("user = %d, want the provisioned account", userID)
+	}
+}
+
+func TestLDAPGroupSearchMapsCargo(t *testing.T) {
+	accounts := newTestLDAPAccounts()
+	p := newTestLDAPProvider(newTestDirectory(), accounts, func(cfg *config.LDAPConfig) {
+		cfg.GroupBaseDN = testGroupBaseDN
+	})
+
+	// Searched groups replace the group attribute, after binding again as the search account
+	_, cargoID, err := p.Authenticate("ASilva", "ana-pw")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if cargoID != 3 {
+		t.Errorf("cargo = %d, want 3", cargoID)
+	}
+
+	_, cargoID, err = p.Authenticate("rui", "rui-pw")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if cargoID != 2 {
+		t.Errorf("cargo = %d, want 2", cargoID)
+	}
+}
+
+func TestLDAPUnmappedGroups(t *testing.T) {
+	// Without a default cargo, users in no mapped group have no access and get no account
+	accounts := newTestLDAPAccounts()
+	_, _, err := newTestLDAPProvider(newTestDirectory(), accounts, nil).Authenticate("rui", "rui-pw")
+	if !errors.Is(err, ErrSemAcesso) {
+		t.Errorf("err = %v, want ErrSemAcesso", err)
+	}
+	if len(accounts.provisioned) != 0 {
+		t.Errorf("provisioned %+v, want none", accounts.provisioned)
+	}
+
+	_, cargoID, err := newTestLDAPProvider(newTestDirectory(), accounts, func(cfg *config.LDAPConfig) {
+		cfg.DefaultCargo = 4
+	}).Authenticate("rui", "rui-pw")
+	if err != nil || cargoID != 4 {
+		t.Errorf("Authenticate = cargo %d, %v; want the default cargo 4", cargoID, err)
+	}
+}
+
+func TestLDAPUserWithoutEmail(t *testing.T) {
+	_, _, err := newTestLDAPProvider(newTestDirectory(), newTestLDAPAccounts(), nil).Authenticate("tmp", "tmp-pw")
+	if !errors.Is(err, ErrSemAcesso) {
+		t.Errorf("err = %v, want ErrSemAcesso", err)
+	}
+}
+
+func TestLDAPWrongPassword(t *testing.T) {
+	accounts := newTestLDAPAccounts()
+	accounts.estados["rui"] = &models.LoginEstado{UserID: 7, Origem: models.OrigemLDAP}
+	p := newTestLDAPProvider(newTestDirectory(), accounts, nil)
+
+	// An account that logged in before counts the failure
+	_, _, err := p.Authenticate("rui", "errada")
+	var loginErr *models.LoginError
+	if !errors.As(err, &loginErr) || loginErr.UserID != 7 || accounts.falhas[7] != 1 {
+		t.Errorf("err = %v, falhas = %d; want a failure of user 7", err, accounts.falhas[7])
+	}
+
+	// A directory user without an account is left to the next provider
+	_, _, err = p.Authenticate("asilva", "errada")
+	if !errors.As(err, &loginErr) || loginErr.UserID != 0 {
+		t.Errorf("err = %v, want a LoginError without user", err)
+	}
+
+	// Unknown logins too
+	_, _, err = p.Authenticate("ninguem", "errada")
+	if !errors.As(err, &loginErr) || loginErr.UserID != 0 {
+		t.Errorf("err = %v, want a LoginError without user", err)
+	}
+
+	if len(accounts.provisioned) != 0 {
+		t.Errorf("provisioned %+v, want none", accounts.provisioned)
+	}
+}
+
+func TestLDAPSuccessResetsFailures(t *testing.T) {
+	accounts := newTestLDAPAccounts()
+	accounts.estados["asilva"] = &models.LoginEstado{UserID: 9, Origem: models.OrigemLDAP, Falhas: 2}
+
+	userID, _, err := newTestLDAPProvider(newTestDirectory(), accounts, nil).Authenticate("asilva", "ana-pw")
+	if err != nil || userID != 9 {
+		t.Fatalf("Authenticate = %d, %v; want user 9", userID, err)
+	}
+	if len(accounts.resets) != 1 || accounts.resets[0] != 9 {
+		t.Errorf("resets = %v, want [9]", accounts.resets)
+	}
+}
+
+func TestLDAPRefusedBeforeBind(t *testing.T) {
+	accounts := newTestLDAPAccounts()
+	accounts.estados["admin"] = &models.LoginEstado{UserID: 1, Origem: models.OrigemLocal}
+	accounts.estados["rui"] = &models.LoginEstado{UserID: 7, Origem: models.OrigemLDAP, Bloqueado: true}
+
+	// A nil directory fails the test if it is contacted
+	p := newTestLDAPProvider(nil, accounts, nil)
+
+	tests := []struct {
+		nome, login, password string
+		userID                int
+		bloqueado             bool
+	}{
+		// An empty password would be an unauthenticated bind that always succeeds
+		{"empty password", "asilva", "", 0, false},
+		{"local account", "admin", "ana-pw", 0, false},
+		{"locked account", "rui", "rui-pw", 7, true},
+	}
+
+	for _, tt := range tests {
+		_, _, err := p.Authenticate(tt.login, tt.password)
+		var loginErr *models.LoginError
+		if !errors.As(err, &loginErr) || loginErr.UserID != tt.userID || loginErr.Bloqueado != tt.bloqueado {
+			t.Errorf("%s: err = %v, want a LoginError of user %d, locked %v", tt.nome, err, tt.userID, tt.bloqueado)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 )
 
 // LogService handles logging operations
@@ -168,3 +170,167 @@ func (s *LogService) GetLogs(table string, action string, userID int, limit int,
 
 	return logs, nil
 }
+
+// HistoricoEntry represents one event in the history of a concurso
+type HistoricoEntry struct {
+	Timestamp string
+	UserNome  string
+	Tabela    string
+	Acao      string
+	Descricao string
+}
+
+// GetConcursoHistory retrieves every log entry related to a concurso, newest first.
+// Concurso entries are matched by their ID field, related tables by their concurso_id field.
+func (s *LogService) GetConcursoHistory(concursoID int) ([]HistoricoEntry, error) {
+	rows, err := s.db.Query(`
+        SELECT l.tabela, l.acao, l.old_data, l.new_data, l.timestamp, COALESCE(u.nome, '')
+        FROM logs l
+        LEFT JOIN user u ON l.id_user = u.id_user
+        WHERE (l.tabela = 'concurso'
+               AND COALESCE(JSON_EXTRACT(l.new_data, '$.ID'), JSON_EXTRACT(l.old_data, '$.ID')) = ?)
+           OR (l.tabela <> 'concurso'
+               AND COALESCE(JSON_EXTRACT(l.new_data, '$.concurso_id'), JSON_EXTRACT(l.old_data, '$.concurso_id')) = ?)
+        ORDER BY l.timestamp DESC, l.id_logs DESC
+    `, concursoID, concursoID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying concurso history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []HistoricoEntry
+	for rows.Next() {
+		var entry HistoricoEntry
+		var oldData, newData sql.NullString
+
+		if err := rows.Scan(&entry.Tabela, &entry.Acao, &oldData, &newData, &entry.Timestamp, &entry.UserNome); err != nil {
+			return nil, fmt.Errorf("error scanning history row: %w", err)
+		}
+
+		entry.Descricao = describeLogEntry(entry.Tabela, entry.Acao, decodeLogData(oldData), decodeLogData(newData))
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating history rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetUserActivity retrieves the latest log entries of a user's own actions, newest first
+func (s *LogService) GetUserActivity(userID int, limit int) ([]HistoricoEntry, error) {
+	rows, err := s.db.Query(`
+        SELECT l.tabela, l.acao, l.old_data, l.new_data, l.timestamp, COALESCE(u.nome, '')
+        FROM logs l
+        LEFT JOIN user u ON l.id_user = u.id_user
+        WHERE l.id_user = ?
+        ORDER BY l.timestamp DESC, l.id_logs DESC
+        LIMIT ?
+    `, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying user activity: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []HistoricoEntry
+	for rows.Next() {
+		var entry HistoricoEntry
+		var oldData, newData sql.NullString
+
+		if err := rows.Scan(&entry.Tabela, &entry.Acao, &oldData, &newData, &entry.Timestamp, &entry.UserNome); err != nil {
+			return nil, fmt.Errorf("error scanning activity row: %w", err)
+		}
+
+		entry.Descricao = describeLogEntry(entry.Tabela, entry.Acao, decodeLogData(oldData), decodeLogData(newData))
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating activity rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// decodeLogData decodes the JSON data of a log entry into a map
+func decodeLogData(data sql.NullString) map[string]interface{} {
+	if !data.Valid {
+		return nil
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(data.String), &m); err != nil {
+		return nil
+	}
+	return m
+}
+
+// describeLogEntry builds a human readable summary of a log entry
+func describeLogEntry(tabela, acao string, oldData, newData map[string]interface{}) string {
+	switch tabela {
+	case "concurso":
+		switch {
+		case acao == "create":
+			return "Concurso criado"
+		case acao == "delete":
+			return "Concurso excluído"
+		case strings.HasPrefix(acao, "transicao_"):
+			return fmt.Sprintf("Estado alterado: %v → %v", oldData["estado"], newData["estado"])
+		case acao == "update":
+			changed := changedFields(oldData, newData)
+			if len(changed) == 0 {
+				return "Concurso guardado sem alterações"
+			}
+			return "Alterado: " + strings.Join(changed, ", ")
+		}
+	case "comentario":
+		switch acao {
+		case "create":
+			return fmt.Sprintf("Comentário: %v", newData["texto"])
+		case "update":
+			return fmt.Sprintf("Comentário editado: %v", newData["texto"])
+		case "delete":
+			return "Comentário excluído"
+		}
+	case "anexo":
+		data := newData
+		if data == nil {
+			data = oldData
+		}
+		name := fmt.Sprintf("%v (%v, v%v)", data["nome_ficheiro"], data["categoria"], data["versao"])
+		switch acao {
+		case "upload":
+			return "Anexo carregado: " + name
+		case "download":
+			return "Anexo descarregado: " + name
+		case "delete":
+			return "Anexo excluído: " + name
+		}
+	case "checklist_item":
+		switch acao {
+		case "concluir":
+			return fmt.Sprintf("Checklist concluído: %v", newData["descricao"])
+		case "reabrir":
+			return fmt.Sprintf("Checklist reaberto: %v", newData["descricao"])
+		}
+	}
+
+	return fmt.Sprintf("%s: %s", tabela, acao)
+}
+
+// changedFields lists the keys whose values differ between two log data maps
+func changedFields(oldData, newData map[string]interface{}) []string {
+	var changed []string
+	for key, newValue := range newData {
+		// Display-only descriptions are not stored in the concurso table
+		if strings.HasSuffix(key, "Desc") {
+			continue
+		}
+		if fmt.Sprint(oldData[key]) != fmt.Sprint(newValue) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
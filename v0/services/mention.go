@@ -0,0 +1,109 @@
+package services
+
+import (
+	"database/sql"
+	"strconv"
+
+	"v0/models"
+)
+
+// VisibilidadeSource decides whether a user can see a concurso, for the emails sent about it
+type VisibilidadeSource interface {
+	// Permissoes returns the permissions of a cargo
+	Permissoes(cargoID int) (models.Permissoes, error)
+	// ConcursoVisivel reports whether a concurso is visible under a visibility
+	ConcursoVisivel(concursoID int, vis models.Visibilidade) (bool, error)
+}
+
+// MentionSource provides the data the recipients of mention emails are chosen from
+type MentionSource interface {
+	VisibilidadeSource
+	// UsersByNomes returns the users with the given names
+	UsersByNomes(nomes []string) ([]models.User, error)
+}
+
+// dbVisibilidadeSource reads the permissions and concursos from the database
+type dbVisibilidadeSource struct {
+	db *sql.DB
+}
+
+func (s dbVisibilidadeSource) Permissoes(cargoID int) (models.Permissoes, error) {
+	return models.GetCargoPermissoes(s.db, cargoID)
+}
+
+func (s dbVisibilidadeSource) ConcursoVisivel(concursoID int, vis models.Visibilidade) (bool, error) {
+	_, err := models.GetConcursoByID(s.db, strconv.Itoa(concursoID), vis)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// dbMentionSource reads the mention data from the database
+type dbMentionSource struct {
+	dbVisibilidadeSource
+}
+
+func (s dbMentionSource) UsersByNomes(nomes []string) ([]models.User, error) {
+	return models.GetUsersByNomes(s.db, nomes)
+}
+
+// MentionService decides who is emailed about a mention in a comment
+type MentionService struct {
+	source MentionSource
+}
+
+// NewMentionService creates a new MentionService reading from the database
+func NewMentionService(db *sql.DB) *MentionService {
+	return NewMentionServiceWithSource(dbMentionSource{dbVisibilidadeSource{db: db}})
+}
+
+// NewMentionServiceWithSource creates a new MentionService reading from source
+func NewMentionServiceWithSource(source MentionSource) *MentionService {
+	return &MentionService{source: source}
+}
+
+// Recipients returns the mentioned users to email about a comment on a concurso: not the author, not the users
+// who turned mentions off, and only the users who can see the concurso themselves, so that the email does not
+// disclose a concurso to someone outside its team
+func (s *MentionService) Recipients(autorID, concursoID int, nomes []string) ([]models.User, error) {
+	if len(nomes) == 0 {
+		return nil, nil
+	}
+
+	users, err := s.source.UsersByNomes(nomes)
+	if err != nil {
+		return nil, err
+	}
+
+	var recipients []models.User
+	for _, user := range users {
+		if user.ID == autorID || !user.NotifMencoes {
+			continue
+		}
+
+		visivel, err := veConcurso(s.source, user, concursoID)
+		if err != nil {
+			return nil, err
+		}
+		if visivel {
+			recipients = append(recipients, user)
+		}
+	}
+
+	return recipients, nil
+}
+
+// veConcurso reports whether a user can see a concurso with the permissions of their own cargo
+func veConcurso(source VisibilidadeSource, user models.User, concursoID int) (bool, error) {
+	permissoes, err := source.Permissoes(user.CargoID)
+	if err != nil {
+		return false, err
+	}
+	if !permissoes.Has(models.PermConcursoView) {
+		return false, nil
+	}
+
+	vis := models.Visibilidade{Todos: permissoes.Has(models.PermConcursoViewAll), UserID: user.ID}
+	return source.ConcursoVisivel(concursoID, vis)
+}
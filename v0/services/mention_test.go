@@ -0,0 +1,91 @@
+package services
+
+import (
+	"testing"
+
+	"v0/models"
+)
+
+// fakeMentionSource serves fixed users, cargos and team concursos instead of reading the database
+type fakeMentionSource struct {
+	users    []models.User
+	cargos   map[int]models.Permissoes
+	visiveis map[int]map[int]bool
+}
+
+func (s fakeMentionSource) UsersByNomes(nomes []string) ([]models.User, error) {
+	var users []models.User
+	for _, user := range s.users {
+		for _, nome := range nomes {
+			if user.Nome == nome {
+				users = append(users, user)
+			}
+		}
+	}
+	return users, nil
+}
+
+func (s fakeMentionSource) Permissoes(cargoID int) (models.Permissoes, error) {
+	return s.cargos[cargoID], nil
+}
+
+func (s fakeMentionSource) ConcursoVisivel(concursoID int, vis models.Visibilidade) (bool, error) {
+	return vis.Todos || s.visiveis[vis.UserID][concursoID], nil
+}
+
+func TestMentionRecipientsOnlyUsersWhoSeeTheConcurso(t *testing.T) {
+	const (
+		cargoAdmin = 1
+		cargoUser  = 2
+		cargoNada  = 3
+	)
+
+	source := fakeMentionSource{
+		users: []models.User{
+			{ID: 1, Nome: "autor", CargoID: cargoUser, NotifMencoes: true},
+			{ID: 2, Nome: "equipa", CargoID: cargoUser, NotifMencoes: true},
+			{ID: 3, Nome: "outra", CargoID: cargoUser, NotifMencoes: true},
+			{ID: 4, Nome: "admin", CargoID: cargoAdmin, NotifMencoes: true},
+			{ID: 5, Nome: "partilha", CargoID: cargoUser, NotifMencoes: true},
+			{ID: 6, Nome: "semacesso", CargoID: cargoNada, NotifMencoes: true},
+			{ID: 7, Nome: "calado", CargoID: cargoUser, NotifMencoes: false},
+		},
+		cargos: map[int]models.Permissoes{
+			cargoAdmin: {models.PermConcursoView: true, models.PermConcursoViewAll: true},
+			cargoUser:  {models.PermConcursoView: true},
+			// Sees the concurso through its team, but its cargo cannot view concursos at all
+			cargoNada: {},
+		},
+		visiveis: map[int]map[int]bool{
+			1: {10: true},
+			2: {10: true},
+			3: {20: true},
+			5: {10: true},
+			6: {10: true},
+			7: {10: true},
+		},
+	}
+
+	users, err := NewMentionServiceWithSource(source).Recipients(1, 10,
+		[]string{"autor", "equipa", "outra", "admin", "partilha", "semacesso", "calado", "ninguem"})
+	if err != nil {
+		t.Fatalf("Recipients: %v", err)
+	}
+
+	want := []string{"equipa", "admin", "partilha"}
+	if len(users) != len(want) {
+		t.Fatalf("got %d recipients, want %v: %+v", len(users), want, users)
+	}
+	for i, nome := range want {
+		if users[i].Nome != nome {
+			t.Errorf("recipient %d = %s, want %s", i, users[i].Nome, nome)
+		}
+	}
+}
+
+func TestMentionRecipientsWithoutMentions(t *testing.T) {
+	users, err := NewMentionServiceWithSource(fakeMentionSource{}).Recipients(1, 10, nil)
+	if err != nil || len(users) != 0 {
+		t.Errorf("Recipients without mentions = %v, %v; want none", users, err)
+	}
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"database/sql"
+
+	"v0/models"
+)
+
+// NotificacaoSource provides the data the recipients of concurso update emails are chosen from
+type NotificacaoSource interface {
+	VisibilidadeSource
+	// Subscritores returns the users who receive the concurso update emails
+	Subscritores() ([]models.User, error)
+}
+
+// dbNotificacaoSource reads the notification data from the database
+type dbNotificacaoSource struct {
+	dbVisibilidadeSource
+}
+
+func (s dbNotificacaoSource) Subscritores() ([]models.User, error) {
+	return models.GetNotifConcursosUsers(s.db)
+}
+
+// NotificacaoService decides who is emailed about the changes and transitions of a concurso
+type NotificacaoService struct {
+	source NotificacaoSource
+}
+
+// NewNotificacaoService creates a new NotificacaoService reading from the database
+func NewNotificacaoService(db *sql.DB) *NotificacaoService {
+	return NewNotificacaoServiceWithSource(dbNotificacaoSource{dbVisibilidadeSource{db: db}})
+}
+
+// NewNotificacaoServiceWithSource creates a new NotificacaoService reading from source
+func NewNotificacaoServiceWithSource(source NotificacaoSource) *NotificacaoService {
+	return &NotificacaoService{source: source}
+}
+
+// Recipients returns the emails of the subscribed users who can see a concurso themselves, so that an update
+// does not disclose a concurso to someone outside its team
+func (s *NotificacaoService) Recipients(concursoID int) ([]string, error) {
+	users, err := s.source.Subscritores()
+	if err != nil {
+		return nil, err
+	}
+
+	var emails []string
+	for _, user := range users {
+		visivel, err := veConcurso(s.source, user, concursoID)
+		if err != nil {
+			return nil, err
+		}
+		if visivel {
+			emails = append(emails, user.Email)
+		}
+	}
+
+	return emails, nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"testing"
+
+	"v0/models"
+)
+
+// fakeNotificacaoSource serves fixed subscribers, cargos and team concursos instead of reading the database
+type fakeNotificacaoSource struct {
+	fakeMentionSource
+}
+
+func (s fakeNotificacaoSource) Subscritores() ([]models.User, error) {
+	return s.users, nil
+}
+
+func TestNotificacaoRecipientsOnlyUsersWhoSeeTheConcurso(t *testing.T) {
+	const (
+		cargoAdmin = 1
+		cargoUser  = 2
+		cargoNada  = 3
+	)
+
+	source := fakeNotificacaoSource{fakeMentionSource{
+		users: []models.User{
+			{ID: 1, Email: "equipa@empresa.pt", CargoID: cargoUser},
+			{ID: 2, Email: "outra@empresa.pt", CargoID: cargoUser},
+			{ID: 3, Email: "admin@empresa.pt", CargoID: cargoAdmin},
+			{ID: 4, Email: "partilha@empresa.pt", CargoID: cargoUser},
+			{ID: 5, Email: "semacesso@empresa.pt", CargoID: cargoNada},
+			{ID: 6, Email: "semcargo@empresa.pt"},
+		},
+		cargos: map[int]models.Permissoes{
+			cargoAdmin: {models.PermConcursoView: true, models.PermConcursoViewAll: true},
+			cargoUser:  {models.PermConcursoView: true},
+			cargoNada:  {},
+		},
+		visiveis: map[int]map[int]bool{
+			1: {10: true},
+			2: {20: true},
+			4: {10: true, 20: true},
+			5: {10: true},
+			6: {10: true},
+		},
+	}}
+	service := NewNotificacaoServiceWithSource(source)
+
+	tests := []struct {
+		concursoID int
+		want       []string
+	}{
+		{10, []string{"equipa@empresa.pt", "admin@empresa.pt", "partilha@empresa.pt"}},
+		{20, []string{"outra@empresa.pt", "admin@empresa.pt", "partilha@empresa.pt"}},
+		// A concurso of no team is only seen by the users who see every concurso
+		{30, []string{"admin@empresa.pt"}},
+	}
+
+	for _, tt := range tests {
+		got, err := service.Recipients(tt.concursoID)
+		if err != nil {
+			t.Fatalf("concurso %d: Recipients: %v", tt.concursoID, err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("concurso %d: Recipients = %v, want %v", tt.concursoID, got, tt.want)
+			continue
+		}
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Errorf("concurso %d: Recipients = %v, want %v", tt.concursoID, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"v0/config"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCIdentity is the identity of a user signed in through the identity provider
+type OIDCIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Nome          string
+	Roles         []string
+}
+
+// OIDCProvider signs users in through an OpenID Connect identity provider with the authorization code flow and PKCE
+type OIDCProvider struct {
+	cfg    config.OIDCConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// NewOIDCProvider creates a new OIDCProvider, or returns nil when single sign-on is not configured
+func NewOIDCProvider(cfg config.OIDCConfig) *OIDCProvider {
+	if cfg.IssuerURL == "" {
+		return nil
+	}
+
+	return &OIDCProvider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Label returns the text of the login button
+func (p *OIDCProvider) Label() string {
+	return p.cfg.Label
+}
+
+// discover fetches the provider metadata on first use, so that the application starts while the provider is down
+func (p *OIDCProvider) discover() (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider != nil {
+		return p.provider, nil
+	}
+
+	// The context is kept by the provider to fetch its signing keys later, so it must not be cancelled
+	provider, err := oidc.NewProvider(oidc.ClientContext(context.Background(), p.client), p.cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("OIDC discovery: %w", err)
+	}
+
+	p.provider = provider
+	return provider, nil
+}
+
+// oauth2Config returns the client configuration for a provider
+func (p *OIDCProvider) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.cfg.Scopes,
+	}
+}
+
+// AuthCodeURL returns the address of the provider login page for a state, nonce and PKCE verifier
+func (p *OIDCProvider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	provider, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	return p.oauth2Config(provider).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange trades the code returned by the provider for its ID token, checking the token signature, audience and nonce
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*OIDCIdentity, error) {
+	provider, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = oidc.ClientContext(ctx, p.client)
+	token, err := p.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("OIDC code exchange: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("OIDC token response has no id_token")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("OIDC ID token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("OIDC ID token nonce does not match")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	identity := &OIDCIdentity{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Roles:   claimStrings(claimPath(claims, p.cfg.RoleClaim)),
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.Nome, _ = claimPath(claims, p.cfg.NomeClaim).(string)
+
+	// Some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	return identity, nil
+}
+
+// Cargo maps the roles of an identity to a cargo, returning 0 when none is mapped
+func (p *OIDCProvider) Cargo(identity *OIDCIdentity) int {
+	for _, mapping := range p.cfg.RoleCargos {
+		for _, role := range identity.Roles {
+			if role == mapping.Valor {
+				return mapping.CargoID
+			}
+		}
+	}
+
+	return 0
+}
+
+// DefaultCargo returns the cargo of new users without a mapped role, 0 when they have no access
+func (p *OIDCProvider) DefaultCargo() int {
+	return p.cfg.DefaultCargo
+}
+
+// claimPath returns the claim at a dotted path such as realm_access.roles
+func claimPath(claims map[string]interface{}, path string) interface{} {
+	var value interface{} = claims
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = obj[key]
+	}
+
+	return value
+}
+
+// claimStrings returns a claim holding one string or a list of them as a list
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
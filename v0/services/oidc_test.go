@@ -0,0 +1,416 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"v0/config"
+	"v0/models"
+
+	"golang.org/x/oauth2"
+)
+
+const testClientID = "concursos"
+
+// mockOIDCServer is an identity provider that issues RS256 ID tokens and checks PKCE on the code exchange
+type mockOIDCServer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockOIDCCode
+}
+
+// mockOIDCCode is an authorization code waiting to be exchanged
+type mockOIDCCode struct {
+	challenge string
+	claims    map[string]interface{}
+	// key signs the ID token, the provider key when nil
+	key *rsa.PrivateKey
+}
+
+func newMockOIDCServer(t *testing.T) *mockOIDCServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	m := &mockOIDCServer{key: key, codes: map[string]mockOIDCCode{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                m.URL,
+			"authorization_endpoint":                m.URL + "/authorize",
+			"token_endpoint":                        m.URL + "/token",
+			"jwks_uri":                              m.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "teste",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", m.token)
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+
+	return m
+}
+
+// authorize plays the provider login page for an authorization URL, returning the code sent back with the
+// given claims; the nonce and audience are taken from the request unless the claims set them
+func (m *mockOIDCServer) authorize(t *testing.T, authURL string, claims map[string]interface{}, key *rsa.PrivateKey) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("authorization URL: %v", err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization URL without an S256 PKCE challenge: %s", authURL)
+	}
+
+	all := map[string]interface{}{
+		"iss":   m.URL,
+		"aud":   query.Get("client_id"),
+		"nonce": query.Get("nonce"),
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		all[k] = v
+	}
+
+	code := base64.RawURLEncoding.EncodeToString([]byte(time.Now().String() + query.Get("state")))
+	m.mu.Lock()
+	m.codes[code] = mockOIDCCode{challenge: query.Get("code_challenge"), claims: all, key: key}
+	m.mu.Unlock()
+
+	return code
+}
+
+func (m *mockOIDCServer) token(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	pending, ok := m.codes[r.PostFormValue("code")]
+	delete(m.codes, r.PostFormValue("code"))
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != pending.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	key := pending.key
+	if key == nil {
+		key = m.key
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "acesso",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signJWT(key, pending.claims),
+	})
+}
+
+// signJWT signs claims as an RS256 JWT
+func signJWT(key *rsa.PrivateKey, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": "teste"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	sum := sha256.Sum256([]byte(signed))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newTestOIDCProvider(issuer string) *OIDCProvider {
+	return NewOIDCProvider(config.OIDCConfig{
+		IssuerURL:   issuer,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost/login/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+		NomeClaim:   "preferred_username",
+		RoleClaim:   "realm_access.roles",
+		RoleCargos: []config.CargoMap{
+			{Valor: "gestor", CargoID: 2},
+			{Valor: "tecnico", CargoID: 3},
+		},
+	})
+}
+
+func TestOIDCExchange(t *testing.T) {
+	server := newMockOIDCServer(t)
+	p := newTestOIDCProvider(server.URL)
+
+	claims := map[string]interface{}{
+		"sub":                "abc-123",
+		"email":              "ana@empresa.pt",
+		"email_verified":     "true",
+		"preferred_username": "ana",
+		"realm_access":       map[string]interface{}{"roles": []interface{}{"offline", "tecnico", "gestor"}},
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	tests := []struct {
+		nome string
+		// claims overrides the claims of the ID token
+		claims map[string]interface{}
+		key    *rsa.PrivateKey
+		// verifier and nonce replace the ones of the login when set
+		verifier, nonce string
+		valido          bool
+	}{
+		{nome: "valid", valido: true},
+		{nome: "wrong PKCE verifier", verifier: oauth2.GenerateVerifier()},
+		{nome: "nonce of another login", nonce: "outro"},
+		{nome: "token for another client", claims: map[string]interface{}{"aud": "outra-app"}},
+		{nome: "token of another issuer", claims: map[string]interface{}{"iss": "https://outro.example"}},
+		{nome: "expired token", claims: map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}},
+		{nome: "token signed by another key", key: otherKey},
+	}
+
+	for _, tt := range tests {
+		verifier := oauth2.GenerateVerifier()
+		authURL, err := p.AuthCodeURL("estado", "nonce-do-login", verifier)
+		if err != nil {
+			t.Fatalf("%s: AuthCodeURL: %v", tt.nome, err)
+		}
+
+		all := map[string]interface{}{}
+		for k, v := range claims {
+			all[k] = v
+		}
+		for k, v := range tt.claims {
+			all[k] = v
+		}
+		code := server.authorize(t, authURL, all, tt.key)
+
+		if tt.verifier != "" {
+			verifier = tt.verifier
+		}
+		nonce := "nonce-do-login"
+		if tt.nonce != "" {
+			nonce = tt.nonce
+		}
+
+		identity, err := p.Exchange(context.Background(), code, verifier, nonce)
+		if !tt.valido {
+			if err == nil {
+				t.Errorf("%s: Exchange accepted the token", tt.nome)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Exchange: %v", tt.nome, err)
+		}
+
+		if identity.Issuer != server.URL || identity.Subject != "abc-123" || identity.Email != "ana@empresa.pt" ||
+			!identity.EmailVerified || identity.Nome != "ana" {
+			t.Errorf("%s: identity = %+v", tt.nome, identity)
+		}
+		// The first mapping the user has a role of wins, whatever the order of the roles
+		if cargo := p.Cargo(identity); cargo != 2 {
+			t.Errorf("%s: cargo = %d, want 2", tt.nome, cargo)
+		}
+	}
+}
+
+// fakeOIDCAccounts keeps accounts and linked identities in memory
+type fakeOIDCAccounts struct {
+	users       map[int]*models.User
+	identidades map[string]int
+	cargos      map[int]int
+	// corrida are names another login takes between the NomeExists check and Create
+	corrida map[string]bool
+}
+
+func newFakeOIDCAccounts(users ...*models.User) *fakeOIDCAccounts {
+	a := &fakeOIDCAccounts{users: map[int]*models.User{}, identidades: map[string]int{}, cargos: map[int]int{}}
+	for _, user := range users {
+		a.users[user.ID] = user
+	}
+	return a
+}
+
+func (a *fakeOIDCAccounts) IdentidadeUser(issuer, subject string) (int, error) {
+	if id, ok := a.identidades[issuer+" "+subject]; ok {
+		return id, nil
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (a *fakeOIDCAccounts) User(userID int) (*models.User, error) {
+	if user, ok := a.users[userID]; ok {
+		copia := *user
+		return &copia, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (a *fakeOIDCAccounts) UserByEmail(email string) (*models.User, error) {
+	for _, user := range a.users {
+		if strings.EqualFold(user.Email, email) {
+			return a.User(user.ID)
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (a *fakeOIDCAccounts) NomeExists(nome string) (bool, error) {
+	for _, user := range a.users {
+		if user.Nome == nome {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (a *fakeOIDCAccounts) Create(nome, email string, cargoID int) (int, error) {
+	// Like the unique indexes on the user table
+	taken, _ := a.NomeExists(nome)
+	if taken || a.corrida[nome] {
+		return 0, models.ErrNomeEmUso
+	}
+	if _, err := a.UserByEmail(email); err == nil {
+		return 0, models.ErrEmailEmUso
+	}
+
+	id := 100 + len(a.users)
+	a.users[id] = &models.User{ID: id, Nome: nome, Email: email, CargoID: cargoID, Estado: models.EstadoAtivo, Origem: models.OrigemOIDC}
+	return id, nil
+}
+
+func (a *fakeOIDCAccounts) Link(userID int, identity *OIDCIdentity) error {
+	a.identidades[identity.Issuer+" "+identity.Subject] = userID
+	return nil
+}
+
+func (a *fakeOIDCAccounts) SetCargo(user *models.User, cargoID int) error {
+	a.users[user.ID].CargoID = cargoID
+	a.cargos[user.ID] = cargoID
+	return nil
+}
+
+func TestOIDCUserLinksOnlyVerifiedEmails(t *testing.T) {
+	p := newTestOIDCProvider("https://idp.example")
+	accounts := newFakeOIDCAccounts(&models.User{ID: 1, Nome: "ana", Email: "ana@empresa.pt", CargoID: 4, Estado: models.EstadoAtivo})
+
+	// Anyone can claim an email at a provider that does not verify it, so it never takes over an account
+	identity := &OIDCIdentity{Issuer: "https://idp.example", Subject: "x", Email: "ANA@empresa.pt", EmailVerified: false}
+	if _, _, err := p.User(accounts, identity); !errors.Is(err, ErrSemAcesso) {
+		t.Errorf("unverified email: err = %v, want ErrSemAcesso", err)
+	}
+	if len(accounts.identidades) != 0 {
+		t.Errorf("unverified email linked: %v", accounts.identidades)
+	}
+
+	identity.EmailVerified = true
+	userID, cargoID, err := p.User(accounts, identity)
+	if err != nil || userID != 1 || cargoID != 4 {
+		t.Fatalf("verified email: User = %d, %d, %v; want user 1 keeping cargo 4", userID, cargoID, err)
+	}
+	if accounts.identidades["https://idp.example x"] != 1 {
+		t.Errorf("verified email not linked: %v", accounts.identidades)
+	}
+
+	// Once linked, the identity is found by its subject even after the email changes at the provider
+	identity.Email, identity.EmailVerified = "outro@empresa.pt", false
+	if userID, _, err := p.User(accounts, identity); err != nil || userID != 1 {
+		t.Errorf("linked identity: User = %d, %v; want user 1", userID, err)
+	}
+}
+
+func TestOIDCUserRoleMapping(t *testing.T) {
+	p := newTestOIDCProvider("https://idp.example")
+	accounts := newFakeOIDCAccounts(
+		&models.User{ID: 1, Nome: "rui", Email: "rui@empresa.pt", CargoID: 3, Estado: models.EstadoAtivo},
+		&models.User{ID: 2, Nome: "pendente", Email: "p@empresa.pt", CargoID: 3, Estado: models.EstadoPendenteAprovacao},
+	)
+	accounts.identidades["https://idp.example rui"] = 1
+	accounts.identidades["https://idp.example pendente"] = 2
+
+	// A mapped role sets the cargo of an existing user on every login
+	_, cargoID, err := p.User(accounts, &OIDCIdentity{Issuer: "https://idp.example", Subject: "rui", Roles: []string{"gestor"}})
+	if err != nil || cargoID != 2 || accounts.cargos[1] != 2 {
+		t.Errorf("mapped role: cargo = %d, %v, stored %d; want 2", cargoID, err, accounts.cargos[1])
+	}
+
+	// Accounts that are not active are refused
+	_, _, err = p.User(accounts, &OIDCIdentity{Issuer: "https://idp.example", Subject: "pendente", Roles: []string{"gestor"}})
+	var loginErr *models.LoginError
+	if !errors.As(err, &loginErr) || loginErr.Pendente != models.EstadoPendenteAprovacao {
+		t.Errorf("pending account: err = %v, want a pending LoginError", err)
+	}
+
+	// New users need a mapped role or a default cargo
+	nova := &OIDCIdentity{Issuer: "https://idp.example", Subject: "novo", Email: "novo@empresa.pt", Nome: "rui"}
+	if _, _, err := p.User(accounts, nova); !errors.Is(err, ErrSemAcesso) {
+		t.Errorf("new user without role: err = %v, want ErrSemAcesso", err)
+	}
+
+	nova.Roles = []string{"tecnico"}
+	userID, cargoID, err := p.User(accounts, nova)
+	if err != nil || cargoID != 3 {
+		t.Fatalf("new user: User = %d, %d, %v; want cargo 3", userID, cargoID, err)
+	}
+	// The name is taken, so the account is named after the email
+	if user := accounts.users[userID]; user.Nome != "novo@empresa.pt" || user.Origem != models.OrigemOIDC {
+		t.Errorf("new user = %+v, want an OIDC account named after the email", user)
+	}
+}
+
+func TestOIDCUserNameTakenAfterTheCheck(t *testing.T) {
+	p := newTestOIDCProvider("https://idp.example")
+	accounts := newFakeOIDCAccounts()
+	accounts.corrida = map[string]bool{"marta": true}
+
+	// The unique index refuses the name, so the account is named after the email as when the check finds it
+	nova := &OIDCIdentity{Issuer: "https://idp.example", Subject: "marta", Email: "marta@empresa.pt", Nome: "marta", Roles: []string{"tecnico"}}
+	userID, _, err := p.User(accounts, nova)
+	if err != nil {
+		t.Fatalf("name taken: User = %v", err)
+	}
+	if user := accounts.users[userID]; user.Nome != "marta@empresa.pt" {
+		t.Errorf("name taken: new user = %+v, want it named after the email", user)
+	}
+
+	// With the email taken as a name too there is no name left, which refuses the login instead of failing
+	accounts.corrida["joao@empresa.pt"] = true
+	outra := &OIDCIdentity{Issuer: "https://idp.example", Subject: "joao", Email: "joao@empresa.pt", Roles: []string{"tecnico"}}
+	if _, _, err := p.User(accounts, outra); !errors.Is(err, ErrSemAcesso) {
+		t.Errorf("no name left: err = %v, want ErrSemAcesso", err)
+	}
+	if _, ok := accounts.identidades["https://idp.example joao"]; ok {
+		t.Error("no name left: the identity was linked")
+	}
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"v0/models"
+)
+
+// OIDCAccounts keeps the local accounts of identity provider users
+type OIDCAccounts interface {
+	// IdentidadeUser returns the user an identity is linked to, or sql.ErrNoRows when it is not linked
+	IdentidadeUser(issuer, subject string) (int, error)
+	// User returns a user by ID
+	User(userID int) (*models.User, error)
+	// UserByEmail returns the user with an email, or sql.ErrNoRows when there is none
+	UserByEmail(email string) (*models.User, error)
+	// NomeExists reports whether a user name is taken
+	NomeExists(nome string) (bool, error)
+	// Create creates the account of a new identity provider user, returning its ID, or ErrNomeEmUso or
+	// ErrEmailEmUso when the name or email is taken
+	Create(nome, email string, cargoID int) (int, error)
+	// Link links an identity to a user found by its email or just created
+	Link(userID int, identity *OIDCIdentity) error
+	// SetCargo changes the cargo of a user from the one it has to the one of its roles
+	SetCargo(user *models.User, cargoID int) error
+}
+
+// dbOIDCAccounts keeps the accounts of identity provider users in the database, logging every change
+type dbOIDCAccounts struct {
+	db         *sql.DB
+	logService *LogService
+}
+
+// NewOIDCAccounts creates the OIDCAccounts of the database
+func NewOIDCAccounts(db *sql.DB) OIDCAccounts {
+	return dbOIDCAccounts{db: db, logService: NewLogService(db)}
+}
+
+func (a dbOIDCAccounts) IdentidadeUser(issuer, subject string) (int, error) {
+	return models.GetIdentidadeUser(a.db, issuer, subject)
+}
+
+func (a dbOIDCAccounts) User(userID int) (*models.User, error) {
+	return models.GetUserByID(a.db, userID)
+}
+
+func (a dbOIDCAccounts) UserByEmail(email string) (*models.User, error) {
+	return models.GetUserByEmail(a.db, email)
+}
+
+func (a dbOIDCAccounts) NomeExists(nome string) (bool, error) {
+	return models.NomeExists(a.db, nome)
+}
+
+func (a dbOIDCAccounts) Create(nome, email string, cargoID int) (int, error) {
+	userID, err := models.CreateExternalUser(a.db, models.OrigemOIDC, nome, email, cargoID)
+	if err != nil {
+		return 0, err
+	}
+
+	a.logService.LogCreate(userID, "user", map[string]interface{}{
+		"user_id":  userID,
+		"nome":     nome,
+		"email":    email,
+		"cargo_id": cargoID,
+		"origem":   models.OrigemOIDC,
+	})
+
+	return userID, nil
+}
+
+func (a dbOIDCAccounts) Link(userID int, identity *OIDCIdentity) error {
+	if err := models.LinkIdentidade(a.db, userID, identity.Issuer, identity.Subject, identity.Email); err != nil {
+		return err
+	}
+
+	a.logService.LogAction(userID, "user", "oidc_ligado", nil, map[string]interface{}{
+		"user_id":  userID,
+		"provedor": identity.Issuer,
+	})
+
+	return nil
+}
+
+func (a dbOIDCAccounts) SetCargo(user *models.User, cargoID int) error {
+	if err := models.SetUserCargo(a.db, user.ID, cargoID); err != nil {
+		return err
+	}
+
+	a.logService.LogUpdate(user.ID, "user", map[string]interface{}{
+		"user_id":  user.ID,
+		"cargo_id": user.CargoID,
+	}, map[string]interface{}{
+		"user_id":  user.ID,
+		"cargo_id": cargoID,
+		"metodo":   "oidc",
+	})
+
+	return nil
+}
+
+// User finds the user of an identity, linking it by email or creating the account the first time, and
+// applies the cargo of its roles. Accounts that are not active yet return a *models.LoginError with Pendente
+func (p *OIDCProvider) User(accounts OIDCAccounts, identity *OIDCIdentity) (int, int, error) {
+	userID, err := accounts.IdentidadeUser(identity.Issuer, identity.Subject)
+	if err == sql.ErrNoRows {
+		userID, err = p.linkOrCreate(accounts, identity)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	user, err := accounts.User(userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if user.Estado != models.EstadoAtivo {
+		return 0, 0, &models.LoginError{UserID: user.ID, Pendente: user.Estado}
+	}
+
+	// A mapped role sets the cargo on every login; users without one keep theirs
+	cargoID := p.Cargo(identity)
+	if cargoID == 0 {
+		return user.ID, user.CargoID, nil
+	}
+	if cargoID != user.CargoID {
+		if err := accounts.SetCargo(user, cargoID); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return user.ID, cargoID, nil
+}
+
+// linkOrCreate links an identity seen for the first time to the user with its email, which the identity
+// provider must have verified, or creates a new account for it
+func (p *OIDCProvider) linkOrCreate(accounts OIDCAccounts, identity *OIDCIdentity) (int, error) {
+	if identity.Email == "" {
+		return 0, fmt.Errorf("%w: identity has no email", ErrSemAcesso)
+	}
+
+	user, err := accounts.UserByEmail(identity.Email)
+	if err == nil {
+		if !identity.EmailVerified {
+			return 0, fmt.Errorf("%w: email %s is not verified by the provider", ErrSemAcesso, identity.Email)
+		}
+		if err := accounts.Link(user.ID, identity); err != nil {
+			return 0, err
+		}
+		return user.ID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	cargoID := p.Cargo(identity)
+	if cargoID == 0 {
+		cargoID = p.DefaultCargo()
+	}
+	if cargoID == 0 {
+		return 0, fmt.Errorf("%w: no mapped role", ErrSemAcesso)
+	}
+
+	// Users log in by name, so a taken name falls back to the email
+	nome := identity.Nome
+	if nome != "" {
+		exists, err := accounts.NomeExists(nome)
+		if err != nil {
+			return 0, err
+		}
+		if exists {
+			nome = ""
+		}
+	}
+	if nome == "" {
+		nome = identity.Email
+		exists, err := accounts.NomeExists(nome)
+		if err != nil {
+			return 0, err
+		}
+		if exists {
+			return 0, fmt.Errorf("%w: name %s is taken", ErrSemAcesso, nome)
+		}
+	}
+
+	userID, err := accounts.Create(nome, identity.Email, cargoID)
+	// The name may be taken after the check above, which the unique index refuses
+	if err == models.ErrNomeEmUso && nome != identity.Email {
+		userID, err = accounts.Create(identity.Email, identity.Email, cargoID)
+	}
+	if err == models.ErrNomeEmUso || err == models.ErrEmailEmUso {
+		return 0, fmt.Errorf("%w: %s", ErrSemAcesso, err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := accounts.Link(userID, identity); err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
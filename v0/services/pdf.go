@@ -2,71 +2,348 @@ package services
 
 import (
 	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
 	"time"
 
+	"v0/config"
+	"v0/models"
+	"v0/utils"
+
 	"github.com/jung-kurt/gofpdf"
 )
 
+// Report groupings
+const (
+	AgruparSemana   = "semana"
+	AgruparEntidade = "entidade"
+)
+
+// ReportColumn describes a column available in PDF reports
+type ReportColumn struct {
+	Key    string
+	Titulo string
+	// Largura is relative to the other selected columns, which share the page width
+	Largura float64
+	Align   string
+	valor   func(item models.ConcursoItem) string
+}
+
+// ReportColumns lists the columns reports can include, in display order
+var ReportColumns = []ReportColumn{
+	{Key: "referencia", Titulo: "Referência", Largura: 30, valor: func(i models.ConcursoItem) string { return i.Referencia }},
+	{Key: "entidade", Titulo: "Entidade", Largura: 45, valor: func(i models.ConcursoItem) string { return i.Entidade }},
+	{Key: "objeto", Titulo: "Objeto", Largura: 16, valor: func(i models.ConcursoItem) string { return utils.GetObjetoString(i.Objeto) }},
+	{Key: "data", Titulo: "Data", Largura: 22, valor: func(i models.ConcursoItem) string { return i.Data }},
+	{Key: "hora", Titulo: "Hora", Largura: 15, valor: func(i models.ConcursoItem) string { return i.Hora }},
+	{Key: "tipo", Titulo: "Tipo", Largura: 22, valor: func(i models.ConcursoItem) string { return i.Tipo }},
+	{Key: "estado", Titulo: "Estado", Largura: 26, valor: func(i models.ConcursoItem) string { return i.EstadoDesc }},
+	{Key: "dias", Titulo: "Dias", Largura: 12, Align: "R", valor: func(i models.ConcursoItem) string { return strconv.Itoa(i.DiasRestantes) }},
+	{Key: "dias_uteis", Titulo: "Dias Úteis", Largura: 17, Align: "R", valor: func(i models.ConcursoItem) string { return strconv.Itoa(i.DiasUteis) }},
+	{Key: "adjudicatario", Titulo: "Adjudicatário", Largura: 35, valor: func(i models.ConcursoItem) string { return i.Adjudicatario }},
+}
+
+// DefaultReportColumns are the columns of the Concursos Futuros PDF
+var DefaultReportColumns = []string{"referencia", "entidade", "objeto", "data", "hora", "tipo", "dias", "dias_uteis"}
+
+// ReportOptions configures a PDF report
+type ReportOptions struct {
+	Titulo string
+	// Subtitulo is printed under the title on every page, e.g. the applied filters
+	Subtitulo string
+	// Colunas are ReportColumns keys; empty means DefaultReportColumns
+	Colunas     []string
+	Paisagem    bool
+	Agrupamento string
+}
+
+// reportGroup is a titled run of report rows
+type reportGroup struct {
+	Titulo string
+	Items  []models.ConcursoItem
+}
+
+const (
+	reportMargin    = 10.0
+	reportRowHeight = 8.0
+)
+
 // PDFService handles PDF generation
-type PDFService struct{}
+type PDFService struct {
+	cfg config.ReportConfig
+}
 
 // NewPDFService creates a new PDFService
-func NewPDFService() *PDFService {
-	return &PDFService{}
-}
-
-// GenerateConcursosPDF generates a PDF with concursos information
-func (s *PDFService) GenerateConcursosPDF(items []struct {
-	Referencia string
-	Entidade   string
-	Objeto     int
-	Data       string
-	Hora       string
-	Tipo       string
-}) (*gofpdf.Fpdf, error) {
-	// Create PDF
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
+func NewPDFService(cfg config.ReportConfig) *PDFService {
+	return &PDFService{cfg: cfg}
+}
+
+// GenerateConcursosPDF generates a PDF with the upcoming deadlines
+func (s *PDFService) GenerateConcursosPDF(items []models.ConcursoItem) (*gofpdf.Fpdf, error) {
+	return s.GenerateReport(items, ReportOptions{Titulo: "Concursos Futuros"})
+}
 
-	// PDF settings
-	pdf.SetFont("Arial", "B", 16)
-	pdf.Cell(0, 10, "Concursos Futuros")
-	pdf.Ln(12)
+// GenerateReport generates a deadlines PDF report with the given columns, orientation and grouping.
+// The page header, including the table header, is repeated on every page.
+func (s *PDFService) GenerateReport(items []models.ConcursoItem, opts ReportOptions) (*gofpdf.Fpdf, error) {
+	doc := s.newDocument(opts.Paisagem, opts.Titulo, opts.Subtitulo)
+	pdf, family, tr := doc.pdf, doc.family, doc.tr
+
+	// Selected columns share the usable page width
+	columns := selectReportColumns(opts.Colunas)
+	var total float64
+	for _, c := range columns {
+		total += c.Largura
+	}
+	widths := make([]float64, len(columns))
+	for i, c := range columns {
+		widths[i] = c.Largura / total * doc.width
+	}
 
 	// Table header
-	pdf.SetFont("Arial", "B", 10)
-	widths := []float64{40, 40, 20, 30, 20, 30}
-	headers := []string{"REF.", "ENTIDADE", "OBJETO", "DATA", "HORA", "TIPO"}
+	doc.afterHeader = func() {
+		pdf.SetFont(family, "B", 10)
+		pdf.SetFillColor(226, 232, 240)
+		for i, c := range columns {
+			pdf.CellFormat(widths[i], reportRowHeight, tr(c.Titulo), "1", 0, c.Align, true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont(family, "", 10)
+	}
 
-	for i, header := range headers {
-		pdf.CellFormat(widths[i], 10, header, "1", 0, "", false, 0, "")
+	pdf.AddPage()
+
+	if len(items) == 0 {
+		pdf.CellFormat(doc.width, reportRowHeight, tr("Sem prazos para os filtros escolhidos"), "1", 1, "C", false, 0, "")
 	}
-	pdf.Ln(-1)
 
-	// Table data
-	pdf.SetFont("Arial", "", 10)
+	for _, group := range groupReportItems(items, opts.Agrupamento) {
+		if group.Titulo != "" {
+			// Keep the group title on the same page as its first row
+			doc.ensureSpace(2 * reportRowHeight)
+			pdf.SetFont(family, "B", 10)
+			pdf.SetFillColor(190, 227, 248)
+			pdf.CellFormat(doc.width, reportRowHeight, tr(group.Titulo), "1", 1, "", true, 0, "")
+			pdf.SetFont(family, "", 10)
+		}
+
+		for _, item := range group.Items {
+			for i, c := range columns {
+				text := fitText(pdf, tr, c.valor(item), widths[i])
+				pdf.CellFormat(widths[i], reportRowHeight, text, "1", 0, c.Align, false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+	}
+
+	return pdf, pdf.Error()
+}
+
+// pdfDocument is an A4 document with the logo, company and title header and the page number footer on every page
+type pdfDocument struct {
+	pdf    *gofpdf.Fpdf
+	family string
+	tr     func(string) string
+	// width is the usable page width
+	width float64
+	// afterHeader, when set, is drawn below the title on every page
+	afterHeader func()
+}
+
+// newDocument creates a pdfDocument; call AddPage on its pdf to start writing
+func (s *PDFService) newDocument(paisagem bool, titulo, subtitulo string) *pdfDocument {
+	orientation := "P"
+	if paisagem {
+		orientation = "L"
+	}
+
+	pdf := gofpdf.New(orientation, "mm", "A4", "")
+	pdf.SetMargins(reportMargin, reportMargin, reportMargin)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AliasNbPages("")
+
+	family, tr := s.setupFonts(pdf)
+	logo := s.registerLogo(pdf)
+	pageWidth, _ := pdf.GetPageSize()
+
+	doc := &pdfDocument{
+		pdf:    pdf,
+		family: family,
+		tr:     tr,
+		width:  pageWidth - 2*reportMargin,
+	}
+
+	updated := utils.Now().Format("2006-01-02 15:04:05")
+
+	pdf.SetHeaderFunc(func() {
+		y := reportMargin
+		if logo != "" {
+			pdf.ImageOptions(logo, reportMargin, y, 0, 12, false, gofpdf.ImageOptions{ReadDpi: true}, 0, "")
+		}
+		if s.cfg.Empresa != "" {
+			pdf.SetFont(family, "B", 9)
+			pdf.SetXY(reportMargin, y)
+			pdf.CellFormat(doc.width, 5, tr(s.cfg.Empresa), "", 0, "R", false, 0, "")
+		}
+		if logo != "" {
+			y += 14
+		}
+
+		pdf.SetXY(reportMargin, y)
+		pdf.SetFont(family, "B", 16)
+		pdf.CellFormat(doc.width, 10, tr(titulo), "", 1, "", false, 0, "")
+		if subtitulo != "" {
+			pdf.SetFont(family, "", 9)
+			pdf.CellFormat(doc.width, 6, tr(subtitulo), "", 1, "", false, 0, "")
+		}
+		pdf.Ln(2)
+
+		if doc.afterHeader != nil {
+			doc.afterHeader()
+		}
+	})
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(family, "I", 8)
+		pdf.CellFormat(doc.width/2, 8, tr("Atualizado em: "+updated), "", 0, "", false, 0, "")
+		pdf.CellFormat(doc.width/2, 8, tr(fmt.Sprintf("Página %d de {nb}", pdf.PageNo())), "", 0, "R", false, 0, "")
+	})
+
+	return doc
+}
+
+// ensureSpace starts a new page unless height fits above the bottom margin
+func (d *pdfDocument) ensureSpace(height float64) {
+	_, pageHeight := d.pdf.GetPageSize()
+	_, _, _, bottom := d.pdf.GetMargins()
+	if d.pdf.GetY()+height > pageHeight-bottom {
+		d.pdf.AddPage()
+	}
+}
+
+// setupFonts registers the configured UTF-8 fonts, falling back to Arial with a cp1252 translator
+func (s *PDFService) setupFonts(pdf *gofpdf.Fpdf) (string, func(string) string) {
+	fallback := pdf.UnicodeTranslatorFromDescriptor("")
+
+	regular, err := os.ReadFile(s.cfg.FontPath)
+	if err != nil {
+		if s.cfg.FontPath != "" && !os.IsNotExist(err) {
+			log.Printf("Error loading report font, using Arial: %v", err)
+		}
+		return "Arial", fallback
+	}
+
+	bold, err := os.ReadFile(s.cfg.FontBoldPath)
+	if err != nil {
+		bold = regular
+	}
+
+	pdf.AddUTF8FontFromBytes("report", "", regular)
+	pdf.AddUTF8FontFromBytes("report", "B", bold)
+	pdf.AddUTF8FontFromBytes("report", "I", regular)
+	if err := pdf.Error(); err != nil {
+		log.Printf("Error loading report fonts, using Arial: %v", err)
+		pdf.ClearError()
+		return "Arial", fallback
+	}
+
+	return "report", func(text string) string { return text }
+}
+
+// registerLogo registers the configured logo and returns its path, or "" when there is none
+func (s *PDFService) registerLogo(pdf *gofpdf.Fpdf) string {
+	if !fileExists(s.cfg.LogoPath) {
+		return ""
+	}
+
+	pdf.RegisterImageOptions(s.cfg.LogoPath, gofpdf.ImageOptions{ReadDpi: true})
+	if err := pdf.Error(); err != nil {
+		log.Printf("Error loading report logo: %v", err)
+		pdf.ClearError()
+		return ""
+	}
+
+	return s.cfg.LogoPath
+}
+
+// selectReportColumns returns the ReportColumns with the given keys, in display order
+func selectReportColumns(keys []string) []ReportColumn {
+	if len(keys) == 0 {
+		keys = DefaultReportColumns
+	}
+
+	selected := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		selected[k] = true
+	}
+
+	var columns []ReportColumn
+	for _, c := range ReportColumns {
+		if selected[c.Key] {
+			columns = append(columns, c)
+		}
+	}
+	if len(columns) == 0 {
+		return selectReportColumns(DefaultReportColumns)
+	}
+
+	return columns
+}
+
+// groupReportItems splits deadlines, sorted by date, into the groups of a grouping
+func groupReportItems(items []models.ConcursoItem, agrupamento string) []reportGroup {
+	var key func(item models.ConcursoItem) string
+	switch agrupamento {
+	case AgruparSemana:
+		key = func(item models.ConcursoItem) string {
+			d := item.Momento
+			monday := time.Date(d.Year(), d.Month(), d.Day()-(int(d.Weekday())+6)%7, 0, 0, 0, 0, d.Location())
+			return "Semana de " + monday.Format("2006-01-02")
+		}
+	case AgruparEntidade:
+		items = append([]models.ConcursoItem(nil), items...)
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Entidade < items[j].Entidade
+		})
+		key = func(item models.ConcursoItem) string { return item.Entidade }
+	default:
+		return []reportGroup{{Items: items}}
+	}
+
+	var groups []reportGroup
 	for _, item := range items {
-		objetoStr := map[int]string{
-			1: "CTE",
-			2: "CON",
-			3: "INF",
-			4: "CI",
-			5: "ROB",
-		}[item.Objeto]
-
-		pdf.CellFormat(widths[0], 10, item.Referencia, "1", 0, "", false, 0, "")
-		pdf.CellFormat(widths[1], 10, item.Entidade, "1", 0, "", false, 0, "")
-		pdf.CellFormat(widths[2], 10, objetoStr, "1", 0, "", false, 0, "")
-		pdf.CellFormat(widths[3], 10, item.Data, "1", 0, "", false, 0, "")
-		pdf.CellFormat(widths[4], 10, item.Hora, "1", 0, "", false, 0, "")
-		pdf.CellFormat(widths[5], 10, item.Tipo, "1", 0, "", false, 0, "")
-		pdf.Ln(-1)
+		k := key(item)
+		if len(groups) == 0 || groups[len(groups)-1].Titulo != k {
+			groups = append(groups, reportGroup{Titulo: k})
+		}
+		groups[len(groups)-1].Items = append(groups[len(groups)-1].Items, item)
+	}
+
+	return groups
+}
+
+// fitText translates text for the font, shortened with an ellipsis to fit in a cell of the given width
+func fitText(pdf *gofpdf.Fpdf, tr func(string) string, text string, width float64) string {
+	max := width - 2*pdf.GetCellMargin()
+	if pdf.GetStringWidth(tr(text)) <= max {
+		return tr(text)
 	}
 
-	// Footer
-	pdf.Ln(10)
-	pdf.SetFont("Arial", "I", 8)
-	pdf.Cell(0, 10, fmt.Sprintf("Atualizado em: %s", time.Now().Format("2006-01-02 15:04:05")))
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(tr(string(runes)+"...")) > max {
+		runes = runes[:len(runes)-1]
+	}
+	return tr(string(runes) + "...")
+}
 
-	return pdf, nil
+// fileExists checks if path names an existing regular file
+func fileExists(path string) bool {
+	if path == "" {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && !info.Mode().IsDir()
 }
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"v0/models"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// GenerateResultadosPDF generates a summary of the concursos with a resultado:
+// how many ended with each resultado, followed by the list of concursos
+func (s *PDFService) GenerateResultadosPDF(concursos []models.Concurso, subtitulo string) (*gofpdf.Fpdf, error) {
+	doc := s.newDocument(false, "Resumo de Resultados", subtitulo)
+	pdf, family, tr := doc.pdf, doc.family, doc.tr
+	pdf.AddPage()
+
+	// Resultado 1 is the empty one, i.e. no resultado yet
+	var comResultado []models.Concurso
+	contagem := make(map[string]int)
+	for _, c := range concursos {
+		if c.ResultadoID <= 1 {
+			continue
+		}
+		comResultado = append(comResultado, c)
+		contagem[c.ResultadoDesc]++
+	}
+
+	var resultados []string
+	for r := range contagem {
+		resultados = append(resultados, r)
+	}
+	sort.Strings(resultados)
+
+	sort.SliceStable(comResultado, func(i, j int) bool {
+		if comResultado[i].ResultadoDesc != comResultado[j].ResultadoDesc {
+			return comResultado[i].ResultadoDesc < comResultado[j].ResultadoDesc
+		}
+		return comResultado[i].Entidade < comResultado[j].Entidade
+	})
+
+	doc.section("Resumo")
+	pdf.SetFont(family, "B", 10)
+	pdf.SetFillColor(226, 232, 240)
+	pdf.CellFormat(doc.width*0.7, reportRowHeight, tr("Resultado"), "1", 0, "", true, 0, "")
+	pdf.CellFormat(doc.width*0.3, reportRowHeight, tr("Concursos"), "1", 1, "R", true, 0, "")
+	pdf.SetFont(family, "", 10)
+	for _, r := range resultados {
+		pdf.CellFormat(doc.width*0.7, reportRowHeight, tr(r), "1", 0, "", false, 0, "")
+		pdf.CellFormat(doc.width*0.3, reportRowHeight, fmt.Sprint(contagem[r]), "1", 1, "R", false, 0, "")
+	}
+	pdf.SetFont(family, "B", 10)
+	pdf.CellFormat(doc.width*0.7, reportRowHeight, tr("Total"), "1", 0, "", false, 0, "")
+	pdf.CellFormat(doc.width*0.3, reportRowHeight, fmt.Sprint(len(comResultado)), "1", 1, "R", false, 0, "")
+
+	doc.section("Concursos")
+	columns := []struct {
+		titulo  string
+		largura float64
+		valor   func(c models.Concurso) string
+	}{
+		{"Referência", 0.18, func(c models.Concurso) string { return c.Referencia }},
+		{"Entidade", 0.30, func(c models.Concurso) string { return c.Entidade }},
+		{"Objeto", 0.10, func(c models.Concurso) string { return c.TipoDesc }},
+		{"Resultado", 0.16, func(c models.Concurso) string { return c.ResultadoDesc }},
+		{"Adjudicatário", 0.26, func(c models.Concurso) string { return c.Adjudicatario }},
+	}
+
+	header := func() {
+		pdf.SetFont(family, "B", 10)
+		pdf.SetFillColor(226, 232, 240)
+		for _, c := range columns {
+			pdf.CellFormat(doc.width*c.largura, reportRowHeight, tr(c.titulo), "1", 0, "", true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont(family, "", 10)
+	}
+
+	// Repeat the table header on the following pages
+	header()
+	doc.afterHeader = header
+
+	if len(comResultado) == 0 {
+		pdf.CellFormat(doc.width, reportRowHeight, tr("Sem concursos com resultado para os filtros escolhidos"), "1", 1, "C", false, 0, "")
+	}
+	for _, concurso := range comResultado {
+		for _, c := range columns {
+			text := fitText(pdf, tr, c.valor(concurso), doc.width*c.largura)
+			pdf.CellFormat(doc.width*c.largura, reportRowHeight, text, "1", 0, "", false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	return pdf, pdf.Error()
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"v0/config"
+	"v0/models"
+	"v0/utils"
+)
+
+// Scheduler runs the report schedules and emails the generated PDFs
+type Scheduler struct {
+	db              *sql.DB
+	deadlineService *DeadlineService
+	pdfService      *PDFService
+	emailService    *EmailService
+}
+
+// NewScheduler creates a new Scheduler
+func NewScheduler(db *sql.DB, cfg *config.Config) *Scheduler {
+	return &Scheduler{
+		db:              db,
+		deadlineService: NewDeadlineService(db),
+		pdfService:      NewPDFService(cfg.Report),
+		emailService:    NewEmailService(cfg.Email),
+	}
+}
+
+// Start checks the active schedules at the start of every minute until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	log.Println("Report scheduler started")
+	for {
+		now := utils.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+
+		select {
+		case <-ctx.Done():
+			log.Println("Report scheduler stopped")
+			return
+		case <-time.After(next.Sub(now)):
+			s.runDue(next)
+		}
+	}
+}
+
+// runDue runs the active schedules whose expression matches t
+func (s *Scheduler) runDue(t time.Time) {
+	agendamentos, err := models.GetAgendamentos(s.db)
+	if err != nil {
+		log.Printf("Error fetching report schedules: %v", err)
+		return
+	}
+
+	for i := range agendamentos {
+		a := &agendamentos[i]
+		if !a.Ativo {
+			continue
+		}
+
+		cron, err := utils.ParseCron(a.Cron)
+		if err != nil {
+			log.Printf("Invalid cron expression of schedule %d: %v", a.ID, err)
+			continue
+		}
+
+		if cron.Match(t) {
+			s.Run(a, false)
+		}
+	}
+}
+
+// Run generates and emails the report of a schedule, recording the run in its history
+func (s *Scheduler) Run(a *models.Agendamento, manual bool) error {
+	execucaoID, err := models.CreateAgendamentoExecucao(s.db, a.ID, manual)
+	if err != nil {
+		log.Printf("Error recording schedule run: %v", err)
+		return err
+	}
+
+	destinatarios := a.ListaDestinatarios()
+	err = s.send(a, destinatarios)
+
+	sucesso, mensagem := true, fmt.Sprintf("Enviado para %d destinatário(s)", len(destinatarios))
+	if err != nil {
+		log.Printf("Error running report schedule %d: %v", a.ID, err)
+		sucesso, mensagem = false, err.Error()
+	}
+
+	if err := models.FinishAgendamentoExecucao(s.db, execucaoID, sucesso, mensagem); err != nil {
+		log.Printf("Error recording schedule run result: %v", err)
+	}
+
+	return err
+}
+
+// send generates the report of a schedule and emails it to the recipients
+func (s *Scheduler) send(a *models.Agendamento, destinatarios []string) error {
+	if len(destinatarios) == 0 {
+		return fmt.Errorf("sem destinatários")
+	}
+
+	// The report goes to fixed recipients, so it only covers what the user who set it up can see
+	vis, err := s.visibilidade(a)
+	if err != nil {
+		return err
+	}
+	filtro := a.Filtro(vis)
+
+	hoje := utils.Now()
+	filtros := describeFiltro(filtro)
+
+	var (
+		buf    bytes.Buffer
+		titulo string
+		nome   string
+		resumo string
+	)
+
+	switch a.TipoRelatorio {
+	case models.RelatorioPrazos:
+		inicio := hoje
+		fim := time.Date(hoje.Year(), hoje.Month(), hoje.Day()+a.Dias+1, 0, 0, 0, 0, hoje.Location())
+		items, err := s.deadlineService.Prazos(filtro, inicio, fim)
+		if err != nil {
+			return fmt.Errorf("erro ao buscar prazos: %v", err)
+		}
+
+		filtros = append([]string{fmt.Sprintf("Próximos %d dias", a.Dias)}, filtros...)
+		pdf, err := s.pdfService.GenerateReport(items, ReportOptions{
+			Titulo:    "Próximos Prazos",
+			Subtitulo: strings.Join(filtros, " | "),
+		})
+		if err == nil {
+			err = pdf.Output(&buf)
+		}
+		if err != nil {
+			return fmt.Errorf("erro ao gerar PDF: %v", err)
+		}
+
+		titulo, nome = "Próximos prazos", "prazos"
+		resumo = fmt.Sprintf("%d prazo(s) nos próximos %d dias.", len(items), a.Dias)
+
+	case models.RelatorioResultados:
+		concursos, err := models.GetConcursosFiltrados(s.db, filtro)
+		if err != nil {
+			return fmt.Errorf("erro ao buscar concursos: %v", err)
+		}
+
+		pdf, err := s.pdfService.GenerateResultadosPDF(concursos, strings.Join(filtros, " | "))
+		if err == nil {
+			err = pdf.Output(&buf)
+		}
+		if err != nil {
+			return fmt.Errorf("erro ao gerar PDF: %v", err)
+		}
+
+		titulo, nome = "Resumo de resultados", "resultados"
+		resumo = "Segue o resumo dos resultados dos concursos."
+
+	default:
+		return fmt.Errorf("tipo de relatório desconhecido: %s", a.TipoRelatorio)
+	}
+
+	data := hoje.Format("2006-01-02")
+	subject := fmt.Sprintf("%s - %s - %s", a.Nome, titulo, data)
+
+	var body strings.Builder
+	body.WriteString("Olá,\n\n")
+	body.WriteString(fmt.Sprintf("Segue em anexo o relatório \"%s\" de %s.\n", a.Nome, data))
+	body.WriteString(resumo + "\n")
+	body.WriteString("\n\nEste é um email automático. Por favor, não responda a este email.\n")
+
+	return s.emailService.SendMailWithAttachments(destinatarios, subject, body.String(), []Attachment{{
+		Nome:        fmt.Sprintf("%s-%s.pdf", nome, data),
+		ContentType: "application/pdf",
+		Dados:       buf.Bytes(),
+	}})
+}
+
+// visibilidade returns the concursos the creator of a schedule can see with the permissions of their cargo
+func (s *Scheduler) visibilidade(a *models.Agendamento) (models.Visibilidade, error) {
+	if a.CriadoPor == 0 {
+		return models.Visibilidade{}, fmt.Errorf("o utilizador que criou o agendamento já não existe")
+	}
+
+	user, err := models.GetUserByID(s.db, a.CriadoPor)
+	if err != nil {
+		return models.Visibilidade{}, fmt.Errorf("erro ao buscar o criador do agendamento: %v", err)
+	}
+
+	permissoes, err := models.GetCargoPermissoes(s.db, user.CargoID)
+	if err != nil {
+		return models.Visibilidade{}, fmt.Errorf("erro ao buscar permissões: %v", err)
+	}
+	if user.Estado != models.EstadoAtivo || !permissoes.Has(models.PermConcursoView) {
+		return models.Visibilidade{}, fmt.Errorf("o criador do agendamento já não pode ver concursos")
+	}
+
+	return models.Visibilidade{Todos: permissoes.Has(models.PermConcursoViewAll), UserID: user.ID}, nil
+}
+
+// describeFiltro lists the applied filters for the report subtitle
+func describeFiltro(filtro models.ConcursoFiltro) []string {
+	var filtros []string
+	if filtro.EstadoID > 0 {
+		filtros = append(filtros, "Estado: "+utils.GetEstadoString(filtro.EstadoID))
+	}
+	if filtro.TipoID > 0 {
+		filtros = append(filtros, "Objeto: "+utils.GetObjetoString(filtro.TipoID))
+	}
+	if filtro.Entidade != "" {
+		filtros = append(filtros, "Entidade: "+filtro.Entidade)
+	}
+	return filtros
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/gob"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+
+	"v0/models"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// DBSessionStore keeps session values in the database; the cookie only holds a signed random token, so
+// sessions can be listed and revoked
+type DBSessionStore struct {
+	db      *sql.DB
+	codecs  []securecookie.Codec
+	Options *sessions.Options
+	// IdleMinutes is how long a session lasts without requests
+	IdleMinutes int
+}
+
+// NewDBSessionStore creates a new DBSessionStore signing the cookie with the given key
+func NewDBSessionStore(db *sql.DB, idleMinutes int, keyPairs ...[]byte) *DBSessionStore {
+	return &DBSessionStore{
+		db:     db,
+		codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:     "/",
+			MaxAge:   86400 * 7,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		IdleMinutes: idleMinutes,
+	}
+}
+
+// Get returns the session of the request, cached for the rest of the request
+func (s *DBSessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session of the request from the database, or returns a new empty session when there is none
+func (s *DBSessionStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	var token string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &token, s.codecs...); err != nil {
+		return session, nil
+	}
+
+	// Revoked, expired and idle sessions start over with a new token
+	dados, err := models.GetSessaoDados(s.db, token, s.IdleMinutes)
+	if err == sql.ErrNoRows {
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(dados)).Decode(&session.Values); err != nil {
+		return session, err
+	}
+
+	if err := models.TouchSessao(s.db, token); err != nil {
+		log.Printf("Error updating session activity: %v", err)
+	}
+
+	session.ID = token
+	session.IsNew = false
+	return session, nil
+}
+
+// Save stores the session values and sets the cookie; a negative MaxAge deletes the session. Sessions of API
+// token requests are never stored
+func (s *DBSessionStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if apiToken, _ := session.Values["api_token"].(bool); apiToken {
+		return nil
+	}
+
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := models.DeleteSessao(s.db, session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	var dados bytes.Buffer
+	if err := gob.NewEncoder(&dados).Encode(session.Values); err != nil {
+		return err
+	}
+
+	// Only authenticated sessions are listed for the user
+	var userID int
+	if auth, _ := session.Values["authenticated"].(bool); auth {
+		userID, _ = session.Values["user_id"].(int)
+	}
+
+	if session.ID == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		session.ID = hex.EncodeToString(b)
+
+		// Sessions are only created on login, rare enough to clean up the old ones at the same time
+		if err := models.DeleteExpiredSessoes(s.db, s.IdleMinutes); err != nil {
+			log.Printf("Error deleting expired sessions: %v", err)
+		}
+
+		err := models.CreateSessao(s.db, session.ID, userID, dados.Bytes(), ClientIP(r), r.UserAgent(), session.Options.MaxAge)
+		if err != nil {
+			return err
+		}
+	} else {
+		if err := models.UpdateSessao(s.db, session.ID, userID, dados.Bytes(), session.Options.MaxAge); err != nil {
+			return err
+		}
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// ClientIP returns the IP address of the client without the port
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Storage stores the contents of uploaded files under a key
+type Storage interface {
+	// Save writes the contents of r under key and returns the number of bytes written
+	Save(key string, r io.Reader) (int64, error)
+	// Open returns a reader for the contents stored under key
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the contents stored under key
+	Delete(key string) error
+}
+
+// LocalStorage stores files in a directory of the local filesystem
+type LocalStorage struct {
+	baseDir string
+}
+
+// NewLocalStorage creates a new LocalStorage rooted at baseDir
+func NewLocalStorage(baseDir string) *LocalStorage {
+	return &LocalStorage{
+		baseDir: baseDir,
+	}
+}
+
+// Save writes the contents of r to a file under the base directory
+func (s *LocalStorage) Save(key string, r io.Reader) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// Open opens the file stored under key
+func (s *LocalStorage) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Delete removes the file stored under key
+func (s *LocalStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// path resolves a key to a file path, refusing keys that escape the base directory
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+	return filepath.Join(s.baseDir, clean), nil
+}
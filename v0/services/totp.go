@@ -0,0 +1,93 @@
+package services
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/base64"
+	"image/png"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// totpIssuer is the name authenticator apps show next to the account
+const totpIssuer = "Controlo de Concursos"
+
+// NewTOTPSecret generates a new TOTP secret for an account
+func NewTOTPSecret(conta string) (string, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: conta,
+	})
+	if err != nil {
+		return "", err
+	}
+	return key.Secret(), nil
+}
+
+// TOTPQRCode returns the QR code to scan with an authenticator app as a PNG data URI
+func TOTPQRCode(conta, secret string) (string, error) {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", totpIssuer)
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + totpIssuer + ":" + conta,
+		RawQuery: v.Encode(),
+	}
+
+	key, err := otp.NewKeyFromURL(u.String())
+	if err != nil {
+		return "", err
+	}
+
+	img, err := key.Image(200, 200)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// totpPeriod is the number of seconds each code is valid for
+const totpPeriod = 30
+
+// ValidateTOTP checks a code from the authenticator app, allowing one period of clock skew, and returns the
+// time step it belongs to; a step at or below the last one accepted for the user must be refused as a replay
+func ValidateTOTP(code, secret string) (int64, bool) {
+	return validateTOTPAt(code, secret, time.Now())
+}
+
+// validateTOTPAt checks a code at a given time
+func validateTOTPAt(code, secret string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if secret == "" || len(code) != int(otp.DigitsSix) {
+		return 0, false
+	}
+
+	passo := t.Unix() / totpPeriod
+	for _, desvio := range []int64{0, -1, 1} {
+		esperado, err := totp.GenerateCodeCustom(secret, time.Unix((passo+desvio)*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(esperado), []byte(code)) == 1 {
+			return passo + desvio, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func TestValidateTOTPReturnsTheStepOfTheCode(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	agora := time.Unix(1_900_000_000, 0)
+	passo := agora.Unix() / totpPeriod
+
+	tests := []struct {
+		nome   string
+		em     time.Time
+		passo  int64
+		valido bool
+	}{
+		{"current step", agora, passo, true},
+		{"previous step", agora.Add(-totpPeriod * time.Second), passo - 1, true},
+		{"next step", agora.Add(totpPeriod * time.Second), passo + 1, true},
+		{"two steps old", agora.Add(-2 * totpPeriod * time.Second), 0, false},
+	}
+
+	for _, tt := range tests {
+		code, err := totp.GenerateCode(secret, tt.em)
+		if err != nil {
+			t.Fatalf("%s: GenerateCode: %v", tt.nome, err)
+		}
+		got, ok := validateTOTPAt(code, secret, agora)
+		if ok != tt.valido || got != tt.passo {
+			t.Errorf("%s: validateTOTPAt = %d, %v; want %d, %v", tt.nome, got, ok, tt.passo, tt.valido)
+		}
+	}
+
+	if _, ok := validateTOTPAt("123456", "", agora); ok {
+		t.Error("a code without a secret was accepted")
+	}
+}
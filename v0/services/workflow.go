@@ -0,0 +1,167 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+
+	"v0/models"
+)
+
+var (
+	// ErrTransicaoNaoPermitida is returned when no transition exists between two estados
+	ErrTransicaoNaoPermitida = errors.New("transição de estado não permitida")
+	// ErrTransicaoSemPermissao is returned when the cargo may not perform the transition
+	ErrTransicaoSemPermissao = errors.New("sem permissão para esta transição de estado")
+)
+
+// CamposEmFaltaError is returned when a transition requires fields that are empty
+type CamposEmFaltaError struct {
+	Campos []string
+}
+
+func (e *CamposEmFaltaError) Error() string {
+	return "campos obrigatórios em falta: " + strings.Join(e.Labels(), ", ")
+}
+
+// Labels returns the display names of the missing fields
+func (e *CamposEmFaltaError) Labels() []string {
+	labels := make([]string, 0, len(e.Campos))
+	for _, campo := range e.Campos {
+		if label, ok := models.CampoLabels[campo]; ok {
+			labels = append(labels, label)
+		} else {
+			labels = append(labels, campo)
+		}
+	}
+	return labels
+}
+
+// WorkflowSource provides the estado transitions the workflow is checked against
+type WorkflowSource interface {
+	// Transicao returns the transition between two estados, or sql.ErrNoRows if it is not allowed
+	Transicao(origemID, destinoID int) (*models.Transicao, error)
+	// TransicoesFrom returns the transitions leaving an estado
+	TransicoesFrom(estadoID int) ([]models.Transicao, error)
+}
+
+// dbWorkflowSource reads the estado transitions from the database
+type dbWorkflowSource struct {
+	db *sql.DB
+}
+
+func (s dbWorkflowSource) Transicao(origemID, destinoID int) (*models.Transicao, error) {
+	return models.GetTransicao(s.db, origemID, destinoID)
+}
+
+func (s dbWorkflowSource) TransicoesFrom(estadoID int) ([]models.Transicao, error) {
+	return models.GetTransicoesFrom(s.db, estadoID)
+}
+
+// WorkflowService enforces the estado state machine of concursos
+type WorkflowService struct {
+	source       WorkflowSource
+	logService   *LogService
+	emailService *EmailService
+	notificacoes *NotificacaoService
+}
+
+// NewWorkflowService creates a new WorkflowService reading from the database
+func NewWorkflowService(db *sql.DB, logService *LogService, emailService *EmailService) *WorkflowService {
+	return NewWorkflowServiceWithSource(dbWorkflowSource{db: db}, logService, emailService, NewNotificacaoService(db))
+}
+
+// NewWorkflowServiceWithSource creates a new WorkflowService reading the transitions from source
+func NewWorkflowServiceWithSource(source WorkflowSource, logService *LogService, emailService *EmailService, notificacoes *NotificacaoService) *WorkflowService {
+	return &WorkflowService{
+		source:       source,
+		logService:   logService,
+		emailService: emailService,
+		notificacoes: notificacoes,
+	}
+}
+
+// Validate checks if the estado change from oldConcurso to newConcurso is allowed for the cargo.
+// It returns a nil transition when the estado does not change.
+func (s *WorkflowService) Validate(oldConcurso, newConcurso *models.Concurso, cargoID int) (*models.Transicao, error) {
+	if oldConcurso.EstadoID == newConcurso.EstadoID {
+		return nil, nil
+	}
+
+	transicao, err := s.source.Transicao(oldConcurso.EstadoID, newConcurso.EstadoID)
+	if err == sql.ErrNoRows {
+		return nil, ErrTransicaoNaoPermitida
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !transicao.AllowsCargo(cargoID) {
+		return nil, ErrTransicaoSemPermissao
+	}
+
+	if missing := models.CamposEmFalta(newConcurso, transicao.CamposObrigatorios); len(missing) > 0 {
+		return nil, &CamposEmFaltaError{Campos: missing}
+	}
+
+	return transicao, nil
+}
+
+// AllowedEstados returns the estados a cargo can select for a concurso currently in estadoID,
+// including the current estado itself
+func (s *WorkflowService) AllowedEstados(estadoID int, cargoID int) (map[int]bool, error) {
+	transicoes, err := s.source.TransicoesFrom(estadoID)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := map[int]bool{estadoID: true}
+	for _, t := range transicoes {
+		if t.AllowsCargo(cargoID) {
+			allowed[t.EstadoDestinoID] = true
+		}
+	}
+
+	return allowed, nil
+}
+
+// Record writes the log entry for a transition and sends its notification when configured
+func (s *WorkflowService) Record(userID int, transicao *models.Transicao, oldConcurso, newConcurso *models.Concurso) {
+	err := s.logService.LogAction(userID, "concurso", "transicao_"+transicao.Acao,
+		map[string]interface{}{
+			"ID":        oldConcurso.ID,
+			"estado_id": transicao.EstadoOrigemID,
+			"estado":    transicao.EstadoOrigemDesc,
+		},
+		map[string]interface{}{
+			"ID":        newConcurso.ID,
+			"estado_id": transicao.EstadoDestinoID,
+			"estado":    transicao.EstadoDestinoDesc,
+		},
+	)
+	if err != nil {
+		log.Printf("Error logging transition: %v", err)
+	}
+
+	if !transicao.Notificar {
+		return
+	}
+
+	recipients, err := s.notificacoes.Recipients(newConcurso.ID)
+	if err != nil {
+		log.Printf("Error fetching transition email recipients: %v", err)
+		return
+	}
+
+	if err := s.emailService.SendTransitionEmail(
+		newConcurso.Referencia,
+		newConcurso.Entidade,
+		transicao.EstadoOrigemDesc,
+		transicao.EstadoDestinoDesc,
+		newConcurso.Link,
+		recipients,
+	); err != nil {
+		log.Printf("Error sending transition email: %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"v0/models"
+)
+
+// fakeWorkflowSource serves fixed transitions instead of reading the database
+type fakeWorkflowSource struct {
+	transicoes []models.Transicao
+}
+
+func (s fakeWorkflowSource) Transicao(origemID, destinoID int) (*models.Transicao, error) {
+	for _, t := range s.transicoes {
+		if t.EstadoOrigemID == origemID && t.EstadoDestinoID == destinoID {
+			return &t, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s fakeWorkflowSource) TransicoesFrom(estadoID int) ([]models.Transicao, error) {
+	var transicoes []models.Transicao
+	for _, t := range s.transicoes {
+		if t.EstadoOrigemID == estadoID {
+			transicoes = append(transicoes, t)
+		}
+	}
+	return transicoes, nil
+}
+
+// Estados of the test workflow: 1 em andamento, 2 submetido, 3 adjudicado, 4 anulado
+func newTestWorkflowService() *WorkflowService {
+	return NewWorkflowServiceWithSource(fakeWorkflowSource{transicoes: []models.Transicao{
+		{ID: 1, EstadoOrigemID: 1, EstadoDestinoID: 2, Acao: "submeter", Cargos: []int{1, 2, 3},
+			CamposObrigatorios: []string{"dia_proposta", "hora_proposta", "preco"}},
+		{ID: 2, EstadoOrigemID: 2, EstadoDestinoID: 3, Acao: "adjudicar", Cargos: []int{1, 2},
+			CamposObrigatorios: []string{"resultado_id", "adjudicatario"}},
+		{ID: 3, EstadoOrigemID: 1, EstadoDestinoID: 4, Acao: "anular", Cargos: []int{1}},
+	}}, nil, nil, nil)
+}
+
+func TestWorkflowValidate(t *testing.T) {
+	s := newTestWorkflowService()
+
+	preenchido := models.Concurso{
+		Preco:        1500,
+		DiaProposta:  models.ParseNullString("2030-03-20"),
+		HoraProposta: models.ParseNullString("17:00"),
+	}
+
+	tests := []struct {
+		nome          string
+		origem        models.Concurso
+		destino       models.Concurso
+		cargoID       int
+		transicaoID   int
+		err           error
+		camposEmFalta []string
+	}{
+		{
+			// Editing without changing the estado needs no transition, whatever the cargo and fields
+			nome: "unchanged estado", origem: models.Concurso{EstadoID: 4}, destino: models.Concurso{EstadoID: 4}, cargoID: 99,
+		},
+		{
+			nome: "allowed transition", origem: models.Concurso{EstadoID: 1}, destino: withEstado(preenchido, 2), cargoID: 3, transicaoID: 1,
+		},
+		{
+			nome: "no transition between the estados", origem: models.Concurso{EstadoID: 1}, destino: withEstado(preenchido, 3), cargoID: 1,
+			err: ErrTransicaoNaoPermitida,
+		},
+		{
+			// Transitions only go one way
+			nome: "reverse of a transition", origem: models.Concurso{EstadoID: 2}, destino: withEstado(preenchido, 1), cargoID: 1,
+			err: ErrTransicaoNaoPermitida,
+		},
+		{
+			nome: "cargo not allowed", origem: models.Concurso{EstadoID: 1}, destino: withEstado(preenchido, 4), cargoID: 2,
+			err: ErrTransicaoSemPermissao,
+		},
+		{
+			nome: "cargo allowed", origem: models.Concurso{EstadoID: 1}, destino: withEstado(preenchido, 4), cargoID: 1, transicaoID: 3,
+		},
+		{
+			// The cargo is checked before the fields, so a cargo that cannot move the concurso is not told what is missing
+			nome: "cargo not allowed with missing fields", origem: models.Concurso{EstadoID: 2}, destino: models.Concurso{EstadoID: 3}, cargoID: 3,
+			err: ErrTransicaoSemPermissao,
+		},
+		{
+			nome: "all required fields missing", origem: models.Concurso{EstadoID: 1}, destino: models.Concurso{EstadoID: 2}, cargoID: 1,
+			camposEmFalta: []string{"dia_proposta", "hora_proposta", "preco"},
+		},
+		{
+			nome: "one required field missing", origem: models.Concurso{EstadoID: 1},
+			destino: models.Concurso{EstadoID: 2, Preco: 10, DiaProposta: models.ParseNullString("2030-03-20")}, cargoID: 1,
+			camposEmFalta: []string{"hora_proposta"},
+		},
+		{
+			// resultado 1 is the empty description, so it does not fill the field
+			nome: "empty resultado", origem: models.Concurso{EstadoID: 2},
+			destino: models.Concurso{EstadoID: 3, ResultadoID: 1, Adjudicatario: "Empresa X"}, cargoID: 2,
+			camposEmFalta: []string{"resultado_id"},
+		},
+		{
+			nome: "resultado and adjudicatario filled", origem: models.Concurso{EstadoID: 2},
+			destino: models.Concurso{EstadoID: 3, ResultadoID: 2, Adjudicatario: "Empresa X"}, cargoID: 2, transicaoID: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		transicao, err := s.Validate(&tt.origem, &tt.destino, tt.cargoID)
+
+		var campos *CamposEmFaltaError
+		switch {
+		case tt.camposEmFalta != nil:
+			if !errors.As(err, &campos) {
+				t.Errorf("%s: err = %v, want missing fields %v", tt.nome, err, tt.camposEmFalta)
+				continue
+			}
+			if !reflect.DeepEqual(campos.Campos, tt.camposEmFalta) {
+				t.Errorf("%s: missing fields = %v, want %v", tt.nome, campos.Campos, tt.camposEmFalta)
+			}
+		case err != tt.err:
+			t.Errorf("%s: err = %v, want %v", tt.nome, err, tt.err)
+			continue
+		}
+
+		if err != nil {
+			if transicao != nil {
+				t.Errorf("%s: refused transition returned %+v", tt.nome, transicao)
+			}
+			continue
+		}
+		if tt.transicaoID == 0 && transicao != nil {
+			t.Errorf("%s: transition = %+v, want none", tt.nome, transicao)
+		}
+		if tt.transicaoID != 0 && (transicao == nil || transicao.ID != tt.transicaoID) {
+			t.Errorf("%s: transition = %+v, want transition %d", tt.nome, transicao, tt.transicaoID)
+		}
+	}
+}
+
+func TestCamposEmFaltaErrorLabels(t *testing.T) {
+	err := &CamposEmFaltaError{Campos: []string{"preco", "desconhecido"}}
+	if got := err.Error(); got != "campos obrigatórios em falta: Preço, desconhecido" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestWorkflowAllowedEstados(t *testing.T) {
+	s := newTestWorkflowService()
+
+	tests := []struct {
+		estadoID int
+		cargoID  int
+		want     []int
+	}{
+		{1, 1, []int{1, 2, 4}},
+		{1, 3, []int{1, 2}},
+		{2, 3, []int{2}},
+		// An estado without transitions can only be kept
+		{4, 1, []int{4}},
+	}
+
+	for _, tt := range tests {
+		allowed, err := s.AllowedEstados(tt.estadoID, tt.cargoID)
+		if err != nil {
+			t.Fatalf("AllowedEstados(%d, %d): %v", tt.estadoID, tt.cargoID, err)
+		}
+		var got []int
+		for estado := range allowed {
+			got = append(got, estado)
+		}
+		sort.Ints(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("AllowedEstados(%d, %d) = %v, want %v", tt.estadoID, tt.cargoID, got, tt.want)
+		}
+	}
+}
+
+// withEstado returns a copy of a concurso in another estado
+func withEstado(c models.Concurso, estadoID int) models.Concurso {
+	c.EstadoID = estadoID
+	return c
+}
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed five-field cron expression: minute, hour, day of month, month and day of week
+type Cron struct {
+	minutos, horas, dias, meses, diasSemana uint64
+	// Like cron, when both days are restricted a time matches if either does
+	diaRestrito, diaSemanaRestrito bool
+}
+
+// cronField describes the valid range of a cron field
+type cronField struct {
+	nome     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minuto", 0, 59},
+	{"hora", 0, 23},
+	{"dia do mês", 1, 31},
+	{"mês", 1, 12},
+	{"dia da semana", 0, 7},
+}
+
+// ParseCron parses a cron expression such as "0 8 * * 1" (Mondays at 08:00).
+// Fields accept *, numbers, ranges (1-5), lists (1,15) and steps (*/15, 8-18/2); Sunday is 0 or 7.
+func ParseCron(expr string) (*Cron, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("a expressão deve ter %d campos (minuto hora dia mês dia-da-semana)", len(cronFields))
+	}
+
+	bits := make([]uint64, len(parts))
+	for i, part := range parts {
+		b, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		bits[i] = b
+	}
+
+	c := &Cron{
+		minutos:           bits[0],
+		horas:             bits[1],
+		dias:              bits[2],
+		meses:             bits[3],
+		diasSemana:        bits[4],
+		diaRestrito:       parts[2] != "*",
+		diaSemanaRestrito: parts[4] != "*",
+	}
+
+	// Sunday may be written as 7
+	if c.diasSemana&(1<<7) != 0 {
+		c.diasSemana |= 1
+	}
+
+	return c, nil
+}
+
+// parseCronField parses one comma separated field into a bit set of the allowed values
+func parseCronField(field string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		intervalo, passo := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("passo inválido no %s: %q", f.nome, item)
+			}
+			intervalo, passo = item[:i], n
+		}
+
+		inicio, fim := f.min, f.max
+		if intervalo != "*" {
+			var err error
+			if i := strings.Index(intervalo, "-"); i >= 0 {
+				inicio, err = strconv.Atoi(intervalo[:i])
+				if err == nil {
+					fim, err = strconv.Atoi(intervalo[i+1:])
+				}
+			} else {
+				inicio, err = strconv.Atoi(intervalo)
+				fim = inicio
+				if passo > 1 {
+					fim = f.max
+				}
+			}
+			if err != nil || inicio < f.min || fim > f.max || inicio > fim {
+				return 0, fmt.Errorf("valor inválido no %s: %q (de %d a %d)", f.nome, item, f.min, f.max)
+			}
+		}
+
+		for v := inicio; v <= fim; v += passo {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// Match reports whether t, to the minute, is one of the times of the expression
+func (c *Cron) Match(t time.Time) bool {
+	return c.minutos&(1<<uint(t.Minute())) != 0 &&
+		c.horas&(1<<uint(t.Hour())) != 0 &&
+		c.matchDia(t)
+}
+
+// matchDia checks the month, day of month and day of week of t
+func (c *Cron) matchDia(t time.Time) bool {
+	if c.meses&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dia := c.dias&(1<<uint(t.Day())) != 0
+	diaSemana := c.diasSemana&(1<<uint(t.Weekday())) != 0
+	if c.diaRestrito && c.diaSemanaRestrito {
+		return dia || diaSemana
+	}
+	return dia && diaSemana
+}
+
+// Next returns the first time after t matching the expression, or the zero time if there is none within five years
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limite := t.AddDate(5, 0, 0)
+
+	for t.Before(limite) {
+		if !c.matchDia(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.horas&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minutos&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"0 8 * *",
+		"0 8 * * 1 2026",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"5-1 * * * *",
+		"1- * * * *",
+		"a * * * *",
+		"*/0 * * * *",
+		"*/-5 * * * *",
+		"*/x * * * *",
+		"1,,2 * * * *",
+		"0-60/5 * * * *",
+	}
+
+	for _, expr := range tests {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) accepted an invalid expression", expr)
+		}
+	}
+}
+
+// cronTime is a time in UTC to the minute
+func cronTime(ano int, mes time.Month, dia, hora, minuto int) time.Time {
+	return time.Date(ano, mes, dia, hora, minuto, 0, 0, time.UTC)
+}
+
+func TestCronMatch(t *testing.T) {
+	tests := []struct {
+		expr     string
+		t        time.Time
+		coincide bool
+	}{
+		// Steps start at the bottom of the range or at the given value
+		{"*/15 * * * *", cronTime(2026, 1, 5, 10, 45), true},
+		{"*/15 * * * *", cronTime(2026, 1, 5, 10, 50), false},
+		{"5/20 * * * *", cronTime(2026, 1, 5, 10, 45), true},
+		{"5/20 * * * *", cronTime(2026, 1, 5, 10, 40), false},
+		{"0 8-18/2 * * *", cronTime(2026, 1, 5, 18, 0), true},
+		{"0 8-18/2 * * *", cronTime(2026, 1, 5, 19, 0), false},
+		{"0 8-18/2 * * *", cronTime(2026, 1, 5, 6, 0), false},
+		// Range edges are included
+		{"0-59 0-23 1-31 1-12 0-7", cronTime(2026, 12, 31, 23, 59), true},
+		{"0 9 1,15 * *", cronTime(2026, 3, 15, 9, 0), true},
+		{"0 9 1,15 * *", cronTime(2026, 3, 16, 9, 0), false},
+		// Sunday is 0 or 7; 2026-01-04 is a Sunday
+		{"0 9 * * 7", cronTime(2026, 1, 4, 9, 0), true},
+		{"0 9 * * 0", cronTime(2026, 1, 4, 9, 0), true},
+		{"0 9 * * 5-7", cronTime(2026, 1, 4, 9, 0), true},
+		{"0 9 * * 1-5", cronTime(2026, 1, 4, 9, 0), false},
+		// With both days restricted either matches; 2026-02-13 is a Friday
+		{"0 9 13 * 5", cronTime(2026, 2, 13, 9, 0), true},
+		{"0 9 13 * 5", cronTime(2026, 2, 20, 9, 0), true},
+		{"0 9 13 * 5", cronTime(2026, 3, 13, 9, 0), true},
+		{"0 9 13 * 5", cronTime(2026, 2, 16, 9, 0), false},
+		// With one of them left as * only the other counts
+		{"0 9 13 * *", cronTime(2026, 2, 20, 9, 0), false},
+		{"0 9 * * 5", cronTime(2026, 3, 13, 9, 0), true},
+		{"0 9 * * 5", cronTime(2026, 3, 12, 9, 0), false},
+		// The month restricts both days
+		{"0 9 13 1 5", cronTime(2026, 2, 13, 9, 0), false},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+		if got := c.Match(tt.t); got != tt.coincide {
+			t.Errorf("%q Match(%s) = %v, want %v", tt.expr, tt.t.Format("2006-01-02 15:04 Mon"), got, tt.coincide)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		t    time.Time
+		want time.Time
+	}{
+		// Next is always after t, even when t matches
+		{"0 8 * * *", cronTime(2026, 5, 4, 8, 0), cronTime(2026, 5, 5, 8, 0)},
+		{"0 8 * * *", cronTime(2026, 5, 4, 7, 59), cronTime(2026, 5, 4, 8, 0)},
+		// Seconds are dropped before looking for the next minute
+		{"* * * * *", time.Date(2026, 5, 4, 8, 0, 59, 0, time.UTC), cronTime(2026, 5, 4, 8, 1)},
+		{"*/15 * * * *", cronTime(2026, 5, 4, 8, 46), cronTime(2026, 5, 4, 9, 0)},
+		{"0 8-18/2 * * *", cronTime(2026, 5, 4, 18, 30), cronTime(2026, 5, 5, 8, 0)},
+		// Month and year rollover
+		{"0 0 31 * *", cronTime(2026, 4, 30, 12, 0), cronTime(2026, 5, 31, 0, 0)},
+		{"0 0 1 * *", cronTime(2026, 1, 31, 23, 59), cronTime(2026, 2, 1, 0, 0)},
+		{"* * * * *", cronTime(2026, 12, 31, 23, 59), cronTime(2027, 1, 1, 0, 0)},
+		{"30 6 * 1 *", cronTime(2026, 2, 1, 0, 0), cronTime(2027, 1, 1, 6, 30)},
+		// The 29th of February only exists in leap years
+		{"0 12 29 2 *", cronTime(2026, 3, 1, 0, 0), cronTime(2028, 2, 29, 12, 0)},
+		// Mondays at 08:00; 2026-05-04 is a Monday
+		{"0 8 * * 1", cronTime(2026, 5, 4, 8, 0), cronTime(2026, 5, 11, 8, 0)},
+		// The 13th or a Friday, whichever comes first; 2026-05-08 is a Friday
+		{"0 9 13 * 5", cronTime(2026, 5, 4, 0, 0), cronTime(2026, 5, 8, 9, 0)},
+		{"0 9 13 * 5", cronTime(2026, 5, 8, 9, 0), cronTime(2026, 5, 13, 9, 0)},
+		// A day that never comes gives the zero time
+		{"0 0 30 2 *", cronTime(2026, 1, 1, 0, 0), time.Time{}},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+		}
+		if got := c.Next(tt.t); !got.Equal(tt.want) {
+			t.Errorf("%q Next(%s) = %s, want %s", tt.expr, tt.t.Format("2006-01-02 15:04:05"), got, tt.want)
+		}
+	}
+}
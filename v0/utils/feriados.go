@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"time"
+)
+
+// Pascoa returns the date of Easter Sunday for a year (anonymous Gregorian algorithm)
+func Pascoa(ano int) time.Time {
+	a := ano % 19
+	b := ano / 100
+	c := ano % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	mes := (h + l - 7*m + 114) / 31
+	dia := (h+l-7*m+114)%31 + 1
+
+	return time.Date(ano, time.Month(mes), dia, 0, 0, 0, 0, location)
+}
+
+// FeriadosNacionais returns the Portuguese national holidays of a year, keyed by date (2006-01-02)
+func FeriadosNacionais(ano int) map[string]string {
+	pascoa := Pascoa(ano)
+	data := func(mes time.Month, dia int) string {
+		return time.Date(ano, mes, dia, 0, 0, 0, 0, location).Format("2006-01-02")
+	}
+
+	return map[string]string{
+		data(time.January, 1):                         "Ano Novo",
+		pascoa.AddDate(0, 0, -2).Format("2006-01-02"): "Sexta-feira Santa",
+		pascoa.Format("2006-01-02"):                   "Páscoa",
+		data(time.April, 25):                          "Dia da Liberdade",
+		data(time.May, 1):                             "Dia do Trabalhador",
+		pascoa.AddDate(0, 0, 60).Format("2006-01-02"): "Corpo de Deus",
+		data(time.June, 10):                           "Dia de Portugal",
+		data(time.August, 15):                         "Assunção de Nossa Senhora",
+		data(time.October, 5):                         "Implantação da República",
+		data(time.November, 1):                        "Dia de Todos os Santos",
+		data(time.December, 1):                        "Restauração da Independência",
+		data(time.December, 8):                        "Imaculada Conceição",
+		data(time.December, 25):                       "Natal",
+	}
+}
+
+// Calendario tells working days apart from weekends and holidays
+type Calendario struct {
+	municipais map[string]string
+	nacionais  map[int]map[string]string
+	now        func() time.Time
+}
+
+// NewCalendario creates a Calendario with the given municipal holidays, keyed by date (2006-01-02)
+func NewCalendario(municipais map[string]string) *Calendario {
+	if municipais == nil {
+		municipais = make(map[string]string)
+	}
+	return &Calendario{
+		municipais: municipais,
+		nacionais:  make(map[int]map[string]string),
+		now:        Now,
+	}
+}
+
+// Feriado returns the name of the holiday on a date, if any
+func (c *Calendario) Feriado(d time.Time) (string, bool) {
+	chave := d.Format("2006-01-02")
+	if nome, ok := c.municipais[chave]; ok {
+		return nome, true
+	}
+
+	nacionais, ok := c.nacionais[d.Year()]
+	if !ok {
+		nacionais = FeriadosNacionais(d.Year())
+		c.nacionais[d.Year()] = nacionais
+	}
+	nome, ok := nacionais[chave]
+	return nome, ok
+}
+
+// IsDiaUtil checks if a date is a working day
+func (c *Calendario) IsDiaUtil(d time.Time) bool {
+	if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+		return false
+	}
+	_, feriado := c.Feriado(d)
+	return !feriado
+}
+
+// DiasUteisRestantes returns the number of working days after today up to and including the target date.
+// Past dates give a negative count and unparseable dates give -1, like CalculateDaysRemaining.
+func (c *Calendario) DiasUteisRestantes(date string) int {
+	targetDate, err := time.ParseInLocation("2006-01-02", date, location)
+	if err != nil {
+		return -1
+	}
+
+	today := startOfDay(c.now())
+	dias := 0
+	if !targetDate.Before(today) {
+		for d := today.AddDate(0, 0, 1); !d.After(targetDate); d = d.AddDate(0, 0, 1) {
+			if c.IsDiaUtil(d) {
+				dias++
+			}
+		}
+		return dias
+	}
+
+	for d := targetDate; d.Before(today); d = d.AddDate(0, 0, 1) {
+		if c.IsDiaUtil(d) {
+			dias--
+		}
+	}
+	return dias
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPascoa(t *testing.T) {
+	tests := []struct {
+		ano  int
+		want string
+	}{
+		{2024, "2024-03-31"},
+		{2025, "2025-04-20"},
+		{2026, "2026-04-05"},
+		{2000, "2000-04-23"},
+		// The earliest and latest dates Easter can fall on
+		{1818, "1818-03-22"},
+		{2038, "2038-04-25"},
+	}
+
+	for _, tt := range tests {
+		if got := Pascoa(tt.ano).Format("2006-01-02"); got != tt.want {
+			t.Errorf("Pascoa(%d) = %s, want %s", tt.ano, got, tt.want)
+		}
+	}
+}
+
+func TestFeriadosNacionaisMoveWithEaster(t *testing.T) {
+	tests := []struct {
+		data string
+		nome string
+	}{
+		{"2024-03-29", "Sexta-feira Santa"},
+		{"2024-05-30", "Corpo de Deus"},
+		{"2025-04-18", "Sexta-feira Santa"},
+		{"2025-06-19", "Corpo de Deus"},
+	}
+
+	for _, tt := range tests {
+		d, _ := time.Parse("2006-01-02", tt.data)
+		if nome := FeriadosNacionais(d.Year())[tt.data]; nome != tt.nome {
+			t.Errorf("FeriadosNacionais(%d)[%s] = %q, want %q", d.Year(), tt.data, nome, tt.nome)
+		}
+	}
+}
+
+// testCalendario returns a Calendario whose today is the given date
+func testCalendario(hoje string, municipais map[string]string) *Calendario {
+	d, _ := time.ParseInLocation("2006-01-02", hoje, location)
+	c := NewCalendario(municipais)
+	// Late in the day, which still counts as today
+	c.now = func() time.Time { return d.Add(23*time.Hour + 59*time.Minute) }
+	return c
+}
+
+func TestDiasUteisRestantes(t *testing.T) {
+	// Thursday 2025-04-17 is the day before Good Friday and Friday 2025-04-25 is Dia da Liberdade
+	c := testCalendario("2025-04-17", nil)
+
+	tests := []struct {
+		data string
+		want int
+	}{
+		{"2025-04-17", 0},
+		// Good Friday, the weekend and Easter Sunday are not working days
+		{"2025-04-18", 0},
+		{"2025-04-19", 0},
+		{"2025-04-20", 0},
+		{"2025-04-21", 1},
+		{"2025-04-24", 4},
+		{"2025-04-25", 4},
+		{"2025-04-28", 5},
+		// Past dates count the working days from the date up to yesterday
+		{"2025-04-16", -1},
+		{"2025-04-14", -3},
+		{"2025-04-12", -3},
+		{"17/04/2025", -1},
+	}
+
+	for _, tt := range tests {
+		if got := c.DiasUteisRestantes(tt.data); got != tt.want {
+			t.Errorf("DiasUteisRestantes(%s) = %d, want %d", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestDiasUteisRestantesMunicipalHolidaysAndNewYear(t *testing.T) {
+	c := testCalendario("2025-04-17", map[string]string{"2025-04-22": "Feriado municipal"})
+	if got := c.DiasUteisRestantes("2025-04-28"); got != 4 {
+		t.Errorf("with a municipal holiday: DiasUteisRestantes = %d, want 4", got)
+	}
+	if nome, ok := c.Feriado(time.Date(2025, time.April, 22, 0, 0, 0, 0, location)); !ok || nome != "Feriado municipal" {
+		t.Errorf("Feriado(2025-04-22) = %q, %v; want the municipal holiday", nome, ok)
+	}
+
+	// Tuesday 2025-12-30: the 31st is a working day and New Year's Day is not
+	c = testCalendario("2025-12-30", nil)
+	if got := c.DiasUteisRestantes("2026-01-02"); got != 2 {
+		t.Errorf("across the new year: DiasUteisRestantes = %d, want 2", got)
+	}
+	if got := c.DiasUteisRestantes("2026-01-01"); got != 1 {
+		t.Errorf("to New Year's Day: DiasUteisRestantes = %d, want 1", got)
+	}
+}
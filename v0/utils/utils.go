@@ -2,6 +2,7 @@ package utils
 
 import (
 	"database/sql"
+	"math"
 	"time"
 )
 
@@ -13,14 +14,36 @@ func ParseNullString(s string) sql.NullString {
 	return sql.NullString{String: s, Valid: true}
 }
 
-// IsFutureDate checks if a date and time are in the future
-func IsFutureDate(dateStr, timeStr string) bool {
-	// Combine date and time strings
-	fullStr := dateStr + " " + timeStr
+// location is the timezone deadline dates and times are entered in
+var location = time.Local
+
+// SetLocation sets the timezone deadline dates and times are interpreted in
+func SetLocation(loc *time.Location) {
+	location = loc
+}
+
+// Location returns the timezone deadline dates and times are interpreted in
+func Location() *time.Location {
+	return location
+}
+
+// Now returns the current time in the deadlines timezone
+func Now() time.Time {
+	return time.Now().In(location)
+}
 
-	// Parse layout must match the format of fullStr
+// ParseDataHora parses a deadline date and time (with or without seconds) in the deadlines timezone
+func ParseDataHora(dateStr, timeStr string) (time.Time, error) {
 	layout := "2006-01-02 15:04:05"
-	inputTime, err := time.Parse(layout, fullStr)
+	if len(timeStr) == len("15:04") {
+		layout = "2006-01-02 15:04"
+	}
+	return time.ParseInLocation(layout, dateStr+" "+timeStr, location)
+}
+
+// IsFutureDate checks if a date and time are in the future
+func IsFutureDate(dateStr, timeStr string) bool {
+	inputTime, err := ParseDataHora(dateStr, timeStr)
 	if err != nil {
 		// If parsing fails, assume it's not in the future
 		return false
@@ -30,19 +53,24 @@ func IsFutureDate(dateStr, timeStr string) bool {
 	return inputTime.After(time.Now())
 }
 
-// CalculateDaysRemaining returns the number of full days from today until the target date
+// CalculateDaysRemaining returns the number of calendar days from today until the target date
 func CalculateDaysRemaining(date string) int {
-	targetDate, err := time.Parse("2006-01-02", date)
+	targetDate, err := time.ParseInLocation("2006-01-02", date, location)
 	if err != nil {
 		return -1
 	}
 
-	// Zerar hora de hoje e do target para comparar apenas datas
-	now := time.Now()
-	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// Zerar hora de hoje para comparar apenas datas
+	today := startOfDay(Now())
 
+	// Round to absorb daylight saving changes between the two dates
 	diff := targetDate.Sub(today)
-	return int(diff.Hours() / 24)
+	return int(math.Round(diff.Hours() / 24))
+}
+
+// startOfDay returns midnight of the day of t in its location
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
 }
 
 // GetObjetoString returns the string representation of an objeto ID
@@ -70,6 +98,7 @@ func GetEstadoString(estadoID int) string {
 		3: "Enviado",
 		4: "Não Enviado",
 		5: "Declaração",
+		6: "Concluído",
 	}
 
 	if str, ok := estadoMap[estadoID]; ok {